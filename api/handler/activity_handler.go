@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/activity"
+	"tasker/core/token"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type ActivityHandler struct {
+	svc activity.Service
+}
+
+func NewActivityHandler(svc activity.Service) *ActivityHandler {
+	return &ActivityHandler{svc: svc}
+}
+
+// 注册路由：动态属于任务相关数据，令牌需要tasks:read
+func (h *ActivityHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+
+	g := r.Group("/activity")
+	g.Use(auth)
+	{
+		g.GET("", read, h.ListActivity)
+	}
+}
+
+func (h *ActivityHandler) ListActivity(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter := activity.ListFilter{
+		Type:   c.Query("type"),
+		Cursor: c.Query("cursor"),
+		Limit:  limit,
+	}
+	if filter.WorkspaceID, ok = parseIDQuery(c, "workspace_id"); !ok {
+		return
+	}
+	if filter.GroupID, ok = parseIDQuery(c, "group_id"); !ok {
+		return
+	}
+	if filter.ActorID, ok = parseIDQuery(c, "actor_id"); !ok {
+		return
+	}
+
+	page, err := h.svc.List(context.Background(), userID, filter)
+	if err != nil {
+		writeActivityError(c, err)
+		return
+	}
+	response.Success(c, page)
+}
+
+// 动态相关错误码到HTTP状态码的映射
+func writeActivityError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "WORKSPACE_NOT_FOUND", "GROUP_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "WORKSPACE_FORBIDDEN", "GROUP_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
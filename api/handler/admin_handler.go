@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/admin"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+	"tasker/pkg/jwtutil"
+	"tasker/pkg/response"
+)
+
+type AdminHandler struct {
+	userSvc  user.Service
+	adminSvc admin.Service
+}
+
+func NewAdminHandler(userSvc user.Service, adminSvc admin.Service) *AdminHandler {
+	return &AdminHandler{userSvc: userSvc, adminSvc: adminSvc}
+}
+
+// 注册路由：管理接口只接受登录态，且必须是管理员
+func (h *AdminHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	g := r.Group("/admin")
+	g.Use(auth, middleware.RequireSession(), middleware.AdminOnly(h.userSvc))
+	{
+		g.POST("/unlock", h.Unlock)
+
+		g.GET("/users", h.ListUsers)
+		g.GET("/users/:id", h.GetUser)
+		g.POST("/users/:id/role", h.SetRole)
+		g.POST("/users/:id/disable", h.DisableUser)
+		g.POST("/users/:id/enable", h.EnableUser)
+		g.POST("/users/:id/force-password-reset", h.ForcePasswordReset)
+		g.POST("/users/:id/impersonate", h.Impersonate)
+
+		g.GET("/stats", h.Stats)
+		g.GET("/audit", h.ListAudit)
+	}
+}
+
+func (h *AdminHandler) Unlock(c *gin.Context) {
+	var in user.UnlockInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	if err := h.userSvc.Unlock(context.Background(), in); err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "INVALID_UNLOCK" {
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		return
+	}
+	response.Success(c, gin.H{"message": "unlocked"})
+}
+
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	filter := admin.UserFilter{
+		Query:    c.Query("q"),
+		Role:     user.Role(c.Query("role")),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if v := c.Query("disabled"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "INVALID_DISABLED", "disabled must be true or false")
+			return
+		}
+		filter.Disabled = &disabled
+	}
+
+	list, err := h.adminSvc.ListUsers(context.Background(), filter)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response.Success(c, list)
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.adminSvc.GetUser(context.Background(), id)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response.Success(c, u)
+}
+
+type setRoleInput struct {
+	Role user.Role `json:"role"`
+}
+
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	actorID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in setRoleInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	u, err := h.adminSvc.SetRole(context.Background(), actorID, id, in.Role)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response.Success(c, u)
+}
+
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	actorID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.adminSvc.Disable(context.Background(), actorID, id)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response.Success(c, u)
+}
+
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	actorID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.adminSvc.Enable(context.Background(), actorID, id)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response.Success(c, u)
+}
+
+func (h *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	actorID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	res, err := h.adminSvc.ForcePasswordReset(context.Background(), actorID, id)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	// token只在这里返回一次，由管理员转交给用户
+	response.Success(c, res)
+}
+
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	actorID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in admin.ImpersonateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	imp, err := h.adminSvc.Impersonate(context.Background(), actorID, id, in, user.SessionMeta{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	})
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	token, err := jwtutil.GenerateToken(imp.User.ID, imp.Session.ID, user.SessionTTL)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
+		return
+	}
+	response.Success(c, gin.H{
+		"token":      token,
+		"user":       imp.User,
+		"expires_at": imp.Session.ExpiresAt,
+	})
+}
+
+func (h *AdminHandler) Stats(c *gin.Context) {
+	st, err := h.adminSvc.Stats(context.Background())
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response.Success(c, st)
+}
+
+func (h *AdminHandler) ListAudit(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter := admin.AuditFilter{Limit: limit}
+	var ok bool
+	if filter.TargetUserID, ok = parseIDQuery(c, "target_user_id"); !ok {
+		return
+	}
+
+	items, err := h.adminSvc.ListAudit(context.Background(), filter)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+	response.Success(c, items)
+}
+
+// 管理接口错误码到HTTP状态码的映射
+func writeAdminError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "USER_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "SELF_ACTION", "ADMIN_TARGET", "ACCOUNT_DISABLED":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "LAST_ADMIN":
+			response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
@@ -2,10 +2,14 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
 	"tasker/core/user"
 	"tasker/pkg/apperror"
 	"tasker/pkg/jwtutil"
@@ -20,13 +24,38 @@ func NewAuthHandler(userSvc user.Service) *AuthHandler {
 	return &AuthHandler{userSvc: userSvc}
 }
 
+// 两步验证挑战token的有效期，登录态token和会话一致（user.SessionTTL）
+const mfaChallengeTTL = 5 * time.Minute
+
 // 注册路由
-func (h *AuthHandler) RegisterRoutes(r *gin.Engine) {
+func (h *AuthHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
 	g := r.Group("/auth")
 	{
 		g.POST("/register", h.Register)
 		g.POST("/login", h.Login)
+		g.POST("/login/mfa", h.LoginMFA)
+		g.POST("/password/forgot", h.ForgotPassword)
+		g.POST("/password/reset", h.ResetPassword)
 	}
+
+	// 修改密码需要登录态，修改后其他会话下线
+	pwd := r.Group("/auth/password")
+	pwd.Use(auth, middleware.RequireSession())
+	{
+		pwd.POST("", h.ChangePassword)
+	}
+
+	// 两步验证管理，只能在登录态下操作
+	mfa := r.Group("/auth/mfa/totp")
+	mfa.Use(auth, middleware.RequireSession())
+	{
+		mfa.POST("/enroll", h.EnrollTOTP)
+		mfa.POST("/confirm", h.ConfirmTOTP)
+		mfa.POST("/disable", h.DisableTOTP)
+	}
+
+	// 公开验签公钥，其他服务无需持有签名密钥即可校验token
+	r.GET("/.well-known/jwks.json", h.JWKS)
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -40,7 +69,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	if err != nil {
 		if appErr, ok := apperror.IsAppError(err); ok {
 			switch appErr.Code {
-			case "INVALID_USERNAME", "INVALID_PASSWORD":
+			case "INVALID_USERNAME", "INVALID_PASSWORD", "INVALID_EMAIL":
 				response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
 			case "USERNAME_EXISTS":
 				response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
@@ -63,15 +92,23 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
 		return
 	}
+	in.IP = c.ClientIP()
 
 	u, err := h.userSvc.Login(context.Background(), in)
 	if err != nil {
+		if writeLockout(c, err) {
+			return
+		}
 		if appErr, ok := apperror.IsAppError(err); ok {
 			// 登录失败统一认为是401
 			if appErr.Code == "INVALID_CREDENTIALS" {
 				response.Error(c, http.StatusUnauthorized, appErr.Code, appErr.Message)
 				return
 			}
+			if appErr.Code == "ACCOUNT_DISABLED" || appErr.Code == "PASSWORD_RESET_REQUIRED" {
+				response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+				return
+			}
 			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
 			return
 		}
@@ -79,8 +116,72 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// 开启了两步验证：先发挑战token，POST /auth/login/mfa 后再发登录态token
+	if u.TOTPEnabled {
+		challenge, err := jwtutil.GenerateMFAToken(u.ID, mfaChallengeTTL)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
+			return
+		}
+		response.Success(c, gin.H{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+		})
+		return
+	}
+
 	// 登录成功
-	token, err := jwtutil.GenerateToken(u.ID, 2*time.Hour)
+	h.startSession(c, u)
+}
+
+// 两步验证时用的入参
+type mfaCodeInput struct {
+	Code string `json:"code"`
+}
+
+type loginMFAInput struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var in loginMFAInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	claims, err := jwtutil.ParseMFAToken(in.MFAToken)
+	if err != nil {
+		response.Error(c, http.StatusUnauthorized, "INVALID_MFA_TOKEN", "invalid or expired mfa token")
+		return
+	}
+
+	u, err := h.userSvc.VerifyMFA(context.Background(), claims.UserID, in.Code)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	h.startSession(c, u)
+}
+
+// 开启会话并签发登录态token，会话ID写进jti
+func (h *AuthHandler) startSession(c *gin.Context, u *user.User) {
+	sess, err := h.userSvc.StartSession(context.Background(), u.ID, user.SessionMeta{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	})
+	if err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "ACCOUNT_DISABLED" {
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		return
+	}
+
+	token, err := jwtutil.GenerateToken(u.ID, sess.ID, user.SessionTTL)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
 		return
@@ -88,6 +189,168 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	response.Success(c, gin.H{
 		"token": token,
-		"user": u,
+		"user":  u,
 	})
-}
\ No newline at end of file
+}
+
+func (h *AuthHandler) EnrollTOTP(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.userSvc.EnrollTOTP(context.Background(), userID)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	response.Success(c, enrollment)
+}
+
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in mfaCodeInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	codes, err := h.userSvc.ConfirmTOTP(context.Background(), userID, in.Code)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	// 恢复码明文只返回这一次
+	response.Success(c, gin.H{"recovery_codes": codes})
+}
+
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in mfaCodeInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	if err := h.userSvc.DisableTOTP(context.Background(), userID, in.Code); err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "two-factor authentication disabled"})
+}
+
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in user.ChangePasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	if err := h.userSvc.ChangePassword(context.Background(), userID, c.GetString("sessionID"), in); err != nil {
+		writePasswordError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "password changed"})
+}
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var in user.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	if err := h.userSvc.RequestPasswordReset(context.Background(), in); err != nil {
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		return
+	}
+	// 不管用户是否存在都返回同样的结果
+	response.Success(c, gin.H{"message": "if the account exists and has an email, a reset link has been sent"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var in user.ResetPasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	if err := h.userSvc.ResetPassword(context.Background(), in); err != nil {
+		writePasswordError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "password reset"})
+}
+
+// 密码相关错误码到HTTP状态码的映射
+func writePasswordError(c *gin.Context, err error) {
+	if writeLockout(c, err) {
+		return
+	}
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "INVALID_CREDENTIALS":
+			response.Error(c, http.StatusUnauthorized, appErr.Code, appErr.Message)
+		case "INVALID_PASSWORD", "INVALID_RESET_TOKEN":
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		default:
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
+
+// 两步验证相关错误码到HTTP状态码的映射
+func writeMFAError(c *gin.Context, err error) {
+	if writeLockout(c, err) {
+		return
+	}
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "INVALID_MFA_CODE":
+			response.Error(c, http.StatusUnauthorized, appErr.Code, appErr.Message)
+		case "MFA_ALREADY_ENABLED", "MFA_NOT_ENABLED", "MFA_NOT_ENROLLED":
+			response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
+		case "USER_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
+
+// 失败次数过多被锁定：429 + Retry-After（秒，向上取整）
+func writeLockout(c *gin.Context, err error) bool {
+	var lockErr *user.LockoutError
+	if !errors.As(err, &lockErr) {
+		return false
+	}
+	seconds := int(math.Ceil(lockErr.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	response.Error(c, http.StatusTooManyRequests, lockErr.Code, lockErr.Message)
+	return true
+}
+
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	// JWKS是标准格式，直接输出，不包data
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwtutil.JWKS())
+}
@@ -0,0 +1,539 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/caldav"
+	"tasker/core/token"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+// maxDAVRequestBytes PROPFIND和REPORT请求体的上限
+const maxDAVRequestBytes = 1 << 20
+
+// davRoot 所有CalDAV资源都挂在这个前缀下
+const davRoot = "/dav/"
+
+const (
+	davPrincipalPath = davRoot + "principal/"
+	davHomePath      = davRoot + "calendars/"
+)
+
+type CalDAVHandler struct {
+	svc   caldav.Service
+	users user.Service
+}
+
+func NewCalDAVHandler(svc caldav.Service, users user.Service) *CalDAVHandler {
+	return &CalDAVHandler{svc: svc, users: users}
+}
+
+// 注册路由：auth应当是BasicAuthMiddleware，日历客户端只会用Basic认证。
+// 读操作要求tasks:read，PUT和DELETE要求tasks:write；OPTIONS不需要登录
+func (h *CalDAVHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeTasksWrite)
+
+	// RFC 6764：客户端从这里发现服务地址
+	r.GET("/.well-known/caldav", h.WellKnown)
+	r.Handle("PROPFIND", "/.well-known/caldav", h.WellKnown)
+
+	r.Handle(http.MethodOptions, "/dav/*path", h.Options)
+	r.Handle("PROPFIND", "/dav/*path", auth, read, h.PropFind)
+	r.Handle("REPORT", "/dav/*path", auth, read, h.Report)
+	r.GET("/dav/*path", auth, read, h.Get)
+	r.HEAD("/dav/*path", auth, read, h.Get)
+	r.PUT("/dav/*path", auth, write, h.Put)
+	r.DELETE("/dav/*path", auth, write, h.Delete)
+}
+
+// davResource 请求路径指向的资源
+type davResource int
+
+const (
+	davResourceRoot davResource = iota
+	davResourcePrincipal
+	davResourceHome
+	davResourceCalendar
+	davResourceObject
+)
+
+type davTarget struct {
+	kind    davResource
+	groupID int64
+	// 对象的资源名，不含.ics后缀
+	name string
+}
+
+// parseDAVPath 解析/dav/之后的部分：principal/、calendars/、calendars/<分组ID>/、calendars/<分组ID>/<UID>.ics
+func parseDAVPath(p string) (davTarget, bool) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return davTarget{kind: davResourceRoot}, true
+	}
+	parts := strings.Split(p, "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "principal":
+		return davTarget{kind: davResourcePrincipal}, true
+	case parts[0] != "calendars" || len(parts) > 3:
+		return davTarget{}, false
+	case len(parts) == 1:
+		return davTarget{kind: davResourceHome}, true
+	}
+
+	groupID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || groupID <= 0 {
+		return davTarget{}, false
+	}
+	if len(parts) == 2 {
+		return davTarget{kind: davResourceCalendar, groupID: groupID}, true
+	}
+	name, ok := strings.CutSuffix(parts[2], ".ics")
+	if !ok || name == "" {
+		return davTarget{}, false
+	}
+	return davTarget{kind: davResourceObject, groupID: groupID, name: name}, true
+}
+
+// targetFromHref multiget里的href可能是绝对URL，也可能带有百分号编码
+func targetFromHref(href string) (davTarget, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return davTarget{}, false
+	}
+	p, ok := strings.CutPrefix(u.Path, davRoot)
+	if !ok {
+		return davTarget{}, false
+	}
+	return parseDAVPath(p)
+}
+
+func calendarHref(groupID int64) string {
+	return davHomePath + strconv.FormatInt(groupID, 10) + "/"
+}
+
+func objectHref(groupID int64, name string) string {
+	return calendarHref(groupID) + url.PathEscape(name) + ".ics"
+}
+
+func (h *CalDAVHandler) target(c *gin.Context) (davTarget, bool) {
+	t, ok := parseDAVPath(c.Param("path"))
+	if !ok {
+		response.Error(c, http.StatusNotFound, "NOT_FOUND", "no such DAV resource")
+	}
+	return t, ok
+}
+
+func (h *CalDAVHandler) WellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, davRoot)
+}
+
+func (h *CalDAVHandler) Options(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	c.Status(http.StatusOK)
+}
+
+// readDAVBody 请求体为空时返回nil；格式错误时已经写好了400
+func readDAVBody(c *gin.Context) (*davNode, bool) {
+	body, err := parseDAVBody(http.MaxBytesReader(c.Writer, c.Request.Body, maxDAVRequestBytes))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_XML", "request body is not valid XML")
+		return nil, false
+	}
+	return body, true
+}
+
+func (h *CalDAVHandler) PropFind(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	t, ok := h.target(c)
+	if !ok {
+		return
+	}
+	body, ok := readDAVBody(c)
+	if !ok {
+		return
+	}
+	req := propRequestOf(body)
+	// 不支持无限深度，按1处理
+	children := c.GetHeader("Depth") != "0"
+	ctx := context.Background()
+	ms := newMultistatus()
+
+	switch t.kind {
+	case davResourceRoot, davResourcePrincipal:
+		u, err := h.users.GetByID(ctx, userID)
+		if err != nil {
+			writeCalDAVError(c, err)
+			return
+		}
+		href := davRoot
+		if t.kind == davResourcePrincipal {
+			href = davPrincipalPath
+		}
+		ms.add(href, principalProps(u, t.kind == davResourcePrincipal), req)
+		if children && t.kind == davResourceRoot {
+			ms.add(davPrincipalPath, principalProps(u, true), req)
+			ms.add(davHomePath, homeProps(), req)
+		}
+
+	case davResourceHome:
+		ms.add(davHomePath, homeProps(), req)
+		if children {
+			cals, err := h.svc.Calendars(ctx, userID)
+			if err != nil {
+				writeCalDAVError(c, err)
+				return
+			}
+			for _, cal := range cals {
+				ms.add(calendarHref(cal.Group.ID), calendarProps(cal), req)
+			}
+		}
+
+	case davResourceCalendar:
+		if !children {
+			cal, err := h.svc.Calendar(ctx, userID, t.groupID)
+			if err != nil {
+				writeCalDAVError(c, err)
+				return
+			}
+			ms.add(calendarHref(t.groupID), calendarProps(cal), req)
+			break
+		}
+		cal, objs, err := h.svc.Objects(ctx, userID, t.groupID)
+		if err != nil {
+			writeCalDAVError(c, err)
+			return
+		}
+		ms.add(calendarHref(t.groupID), calendarProps(cal), req)
+		for _, obj := range objs {
+			ms.add(objectHref(t.groupID, obj.Name), objectProps(obj, req), req)
+		}
+
+	case davResourceObject:
+		obj, err := h.svc.Object(ctx, userID, t.groupID, t.name)
+		if err != nil {
+			writeCalDAVError(c, err)
+			return
+		}
+		ms.add(objectHref(t.groupID, obj.Name), objectProps(obj, req), req)
+	}
+
+	writeMultistatus(c, ms)
+}
+
+// Report 支持calendar-query、calendar-multiget和sync-collection
+func (h *CalDAVHandler) Report(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	t, ok := h.target(c)
+	if !ok {
+		return
+	}
+	body, ok := readDAVBody(c)
+	if !ok {
+		return
+	}
+	if body == nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_XML", "REPORT requires a request body")
+		return
+	}
+	req := propRequestOf(body)
+	ctx := context.Background()
+	ms := newMultistatus()
+
+	switch {
+	case body.Name.Space == nsCalDAV && body.Name.Local == "calendar-query":
+		if t.kind != davResourceCalendar {
+			writeDAVError(c.Writer, http.StatusForbidden, nsDAV, "supported-report")
+			return
+		}
+		_, objs, err := h.svc.Objects(ctx, userID, t.groupID)
+		if err != nil {
+			writeCalDAVError(c, err)
+			return
+		}
+		// 只看组件过滤，时间范围和属性过滤都忽略，结果可能比要求的多
+		if queriesTodos(body) {
+			for _, obj := range objs {
+				ms.add(objectHref(t.groupID, obj.Name), objectProps(obj, req), req)
+			}
+		}
+
+	case body.Name.Space == nsCalDAV && body.Name.Local == "calendar-multiget":
+		for _, n := range body.Children {
+			if n.Name.Space != nsDAV || n.Name.Local != "href" {
+				continue
+			}
+			href := strings.TrimSpace(n.Text)
+			ht, ok := targetFromHref(href)
+			if !ok || ht.kind != davResourceObject {
+				ms.addStatus(href, http.StatusNotFound)
+				continue
+			}
+			obj, err := h.svc.Object(ctx, userID, ht.groupID, ht.name)
+			if err != nil {
+				status, ok := multigetStatus(err)
+				if !ok {
+					writeCalDAVError(c, err)
+					return
+				}
+				ms.addStatus(href, status)
+				continue
+			}
+			ms.add(href, objectProps(obj, req), req)
+		}
+
+	case body.Name.Space == nsDAV && body.Name.Local == "sync-collection":
+		if t.kind != davResourceCalendar {
+			writeDAVError(c.Writer, http.StatusForbidden, nsDAV, "supported-report")
+			return
+		}
+		cal, objs, removed, err := h.svc.Changes(ctx, userID, t.groupID, strings.TrimSpace(body.child(nsDAV, "sync-token").textOrEmpty()))
+		if err != nil {
+			if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "INVALID_SYNC_TOKEN" {
+				writeDAVError(c.Writer, http.StatusForbidden, nsDAV, "valid-sync-token")
+				return
+			}
+			writeCalDAVError(c, err)
+			return
+		}
+		for _, obj := range objs {
+			ms.add(objectHref(t.groupID, obj.Name), objectProps(obj, req), req)
+		}
+		// RFC 6578：删除的成员只有href和404状态
+		for _, name := range removed {
+			ms.addStatus(objectHref(t.groupID, name), http.StatusNotFound)
+		}
+		ms.syncToken(cal.SyncToken)
+
+	default:
+		writeDAVError(c.Writer, http.StatusForbidden, nsDAV, "supported-report")
+		return
+	}
+
+	writeMultistatus(c, ms)
+}
+
+// queriesTodos calendar-query的过滤条件是否可能匹配VTODO
+func queriesTodos(body *davNode) bool {
+	cal := body.child(nsCalDAV, "filter").child(nsCalDAV, "comp-filter")
+	if cal == nil {
+		return true
+	}
+	if !strings.EqualFold(cal.attr("name"), "VCALENDAR") {
+		return false
+	}
+	comp := cal.child(nsCalDAV, "comp-filter")
+	return comp == nil || strings.EqualFold(comp.attr("name"), "VTODO")
+}
+
+// multigetStatus multiget里单个href的错误只影响那一项
+func multigetStatus(err error) (int, bool) {
+	appErr, ok := apperror.IsAppError(err)
+	if !ok {
+		return 0, false
+	}
+	switch appErr.Code {
+	case "OBJECT_NOT_FOUND", "GROUP_NOT_FOUND":
+		return http.StatusNotFound, true
+	case "GROUP_FORBIDDEN", "WORKSPACE_FORBIDDEN":
+		return http.StatusForbidden, true
+	}
+	return 0, false
+}
+
+func (h *CalDAVHandler) Get(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	t, ok := h.target(c)
+	if !ok {
+		return
+	}
+	if t.kind != davResourceObject {
+		response.Error(c, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "GET is only supported on calendar objects")
+		return
+	}
+
+	obj, err := h.svc.Object(context.Background(), userID, t.groupID, t.name)
+	if err != nil {
+		writeCalDAVError(c, err)
+		return
+	}
+	c.Header("ETag", obj.ETag)
+	c.Header("Last-Modified", obj.UpdatedAt.UTC().Format(http.TimeFormat))
+	c.Data(http.StatusOK, caldav.ContentType, obj.Data)
+}
+
+// Put 服务端会规范化日历数据，所以响应里不带ETag，客户端需要重新GET
+func (h *CalDAVHandler) Put(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	t, ok := h.target(c)
+	if !ok {
+		return
+	}
+	if t.kind != davResourceObject {
+		response.Error(c, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "PUT is only supported on calendar objects")
+		return
+	}
+	if ct := c.GetHeader("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || mediaType != "text/calendar" {
+			writeDAVError(c.Writer, http.StatusUnsupportedMediaType, nsCalDAV, "supported-calendar-data")
+			return
+		}
+	}
+
+	pre := caldav.Precondition{IfMatch: c.GetHeader("If-Match"), IfNoneMatch: c.GetHeader("If-None-Match")}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, caldav.MaxObjectBytes)
+	_, created, err := h.svc.Put(context.Background(), userID, t.groupID, t.name, body, pre)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeDAVError(c.Writer, http.StatusRequestEntityTooLarge, nsCalDAV, "max-resource-size")
+			return
+		}
+		writeCalDAVError(c, err)
+		return
+	}
+	if created {
+		c.Status(http.StatusCreated)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CalDAVHandler) Delete(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	t, ok := h.target(c)
+	if !ok {
+		return
+	}
+	if t.kind != davResourceObject {
+		response.Error(c, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "calendars cannot be deleted over CalDAV")
+		return
+	}
+
+	pre := caldav.Precondition{IfMatch: c.GetHeader("If-Match")}
+	if err := h.svc.Delete(context.Background(), userID, t.groupID, t.name, pre); err != nil {
+		writeCalDAVError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeMultistatus(c *gin.Context, ms *multistatus) {
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(ms.String()))
+}
+
+// principalProps 根路径和principal共用，客户端从这里找到日历主目录
+func principalProps(u *user.User, principal bool) []davProp {
+	resourceType := "<D:collection/>"
+	if principal {
+		resourceType += "<D:principal/>"
+	}
+	return []davProp{
+		prop(nsDAV, "resourcetype", resourceType),
+		prop(nsDAV, "displayname", escapeXML(u.Username)),
+		prop(nsDAV, "current-user-principal", hrefXML(davPrincipalPath)),
+		prop(nsDAV, "principal-URL", hrefXML(davPrincipalPath)),
+		prop(nsCalDAV, "calendar-home-set", hrefXML(davHomePath)),
+	}
+}
+
+func homeProps() []davProp {
+	return []davProp{
+		prop(nsDAV, "resourcetype", "<D:collection/>"),
+		prop(nsDAV, "displayname", "Tasker"),
+		prop(nsDAV, "owner", hrefXML(davPrincipalPath)),
+		prop(nsDAV, "current-user-principal", hrefXML(davPrincipalPath)),
+	}
+}
+
+func calendarProps(cal *caldav.Calendar) []davProp {
+	privileges := "<D:privilege><D:read/></D:privilege>"
+	if cal.Writable {
+		privileges += "<D:privilege><D:write/></D:privilege><D:privilege><D:write-content/></D:privilege>" +
+			"<D:privilege><D:bind/></D:privilege><D:privilege><D:unbind/></D:privilege>"
+	}
+	reports := ""
+	for _, r := range []string{"<C:calendar-query/>", "<C:calendar-multiget/>", "<D:sync-collection/>"} {
+		reports += "<D:supported-report><D:report>" + r + "</D:report></D:supported-report>"
+	}
+	return []davProp{
+		prop(nsDAV, "resourcetype", "<D:collection/><C:calendar/>"),
+		prop(nsDAV, "displayname", escapeXML(cal.Group.Name)),
+		prop(nsDAV, "owner", hrefXML(davPrincipalPath)),
+		prop(nsDAV, "current-user-principal", hrefXML(davPrincipalPath)),
+		prop(nsDAV, "current-user-privilege-set", privileges),
+		prop(nsDAV, "supported-report-set", reports),
+		prop(nsDAV, "sync-token", escapeXML(cal.SyncToken)),
+		prop(nsCalServer, "getctag", escapeXML(cal.SyncToken)),
+		prop(nsCalDAV, "supported-calendar-component-set", `<C:comp name="VTODO"/>`),
+		prop(nsCalDAV, "supported-calendar-data", `<C:calendar-data content-type="text/calendar" version="2.0"/>`),
+	}
+}
+
+// objectProps calendar-data比较大，按RFC 4791只在明确请求时返回，allprop不包含
+func objectProps(obj *caldav.Object, req propRequest) []davProp {
+	props := []davProp{
+		prop(nsDAV, "resourcetype", ""),
+		prop(nsDAV, "getetag", escapeXML(obj.ETag)),
+		prop(nsDAV, "getcontenttype", escapeXML(caldav.ContentType)),
+		prop(nsDAV, "getcontentlength", strconv.Itoa(len(obj.Data))),
+		prop(nsDAV, "getlastmodified", obj.UpdatedAt.UTC().Format(http.TimeFormat)),
+	}
+	if !req.All && !req.NamesOnly && req.wants(nsCalDAV, "calendar-data") {
+		props = append(props, prop(nsCalDAV, "calendar-data", escapeXML(string(obj.Data))))
+	}
+	return props
+}
+
+// CalDAV相关错误码到HTTP状态码的映射，RFC 4791定义了前置条件的用XML错误体返回
+func writeCalDAVError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "OBJECT_NOT_FOUND", "GROUP_NOT_FOUND", "TASK_NOT_FOUND", "USER_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "GROUP_FORBIDDEN", "WORKSPACE_FORBIDDEN", "FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "PRECONDITION_FAILED":
+			response.Error(c, http.StatusPreconditionFailed, appErr.Code, appErr.Message)
+		case "INVALID_CALENDAR_DATA":
+			writeDAVError(c.Writer, http.StatusBadRequest, nsCalDAV, "valid-calendar-data")
+		case "UNSUPPORTED_COMPONENT":
+			writeDAVError(c.Writer, http.StatusForbidden, nsCalDAV, "supported-calendar-component")
+		case "EXTERNAL_ID_EXISTS":
+			writeDAVError(c.Writer, http.StatusConflict, nsCalDAV, "no-uid-conflict")
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			// 其余都是日历对象本身不被接受，例如标题为空、UID不合法或状态不在工作流里
+			writeDAVError(c.Writer, http.StatusForbidden, nsCalDAV, "valid-calendar-object-resource")
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
@@ -0,0 +1,471 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/gin-gonic/gin"
+
+	davcore "tasker/core/caldav"
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+)
+
+const (
+	davTestUser      = int64(1)
+	davTestGroup     = int64(7)
+	davTestWorkspace = int64(3)
+)
+
+// davStore 内存里的一个分组，写入时像tasks上的触发器一样维护变更序号和离开记录
+type davStore struct {
+	mu       sync.Mutex
+	seq      int64
+	nextID   int64
+	now      time.Time
+	tasks    map[int64]*task.Task
+	seqs     map[int64]int64
+	removals []davRemoval
+}
+
+type davRemoval struct {
+	seq int64
+	r   davcore.Removal
+}
+
+func newDAVStore() *davStore {
+	return &davStore{
+		now:   time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC),
+		tasks: map[int64]*task.Task{},
+		seqs:  map[int64]int64{},
+	}
+}
+
+func (s *davStore) tick() time.Time {
+	s.now = s.now.Add(time.Second)
+	return s.now
+}
+
+// add 直接写入一个任务，相当于通过REST接口创建
+func (s *davStore) add(title, externalID string) *task.Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	gid := davTestGroup
+	now := s.tick()
+	t := &task.Task{
+		ID: s.nextID, UserID: davTestUser, WorkspaceID: davTestWorkspace, GroupID: &gid,
+		Title: title, Status: "pending", StatusCategory: group.CategoryTodo, Priority: "low",
+		ExternalID: externalID, CreatedAt: now, UpdatedAt: now,
+	}
+	s.changed(t)
+	return t
+}
+
+func (s *davStore) changed(t *task.Task) {
+	s.seq++
+	t.ChangeSeq = s.seq
+	s.tasks[t.ID] = t
+	s.seqs[t.ID] = s.seq
+}
+
+func (s *davStore) left(t *task.Task) {
+	s.seq++
+	s.removals = append(s.removals, davRemoval{seq: s.seq, r: davcore.Removal{TaskID: t.ID, ExternalID: t.ExternalID}})
+	delete(s.tasks, t.ID)
+	delete(s.seqs, t.ID)
+}
+
+func (s *davStore) update(id int64, f func(t *task.Task)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := *s.tasks[id]
+	f(&t)
+	s.changed(&t)
+}
+
+func (s *davStore) remove(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.left(s.tasks[id])
+}
+
+func (s *davStore) get(id int64) *task.Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tasks[id]
+}
+
+type davRepo struct{ s *davStore }
+
+func (r davRepo) SyncSeq(ctx context.Context, groupID int64) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.seq, nil
+}
+
+func (r davRepo) ChangesSince(ctx context.Context, groupID, since int64) ([]*task.Task, []davcore.Removal, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var changed []*task.Task
+	for id, t := range r.s.tasks {
+		if r.s.seqs[id] > since {
+			changed = append(changed, t)
+		}
+	}
+	var removed []davcore.Removal
+	for _, rm := range r.s.removals {
+		if rm.seq > since {
+			removed = append(removed, rm.r)
+		}
+	}
+	return changed, removed, nil
+}
+
+type davTasks struct {
+	task.Service
+	s *davStore
+}
+
+func (f davTasks) CreateTask(ctx context.Context, userID int64, in task.CreateTaskInput) (*task.Task, error) {
+	t := f.s.add(in.Title, in.ExternalID)
+	f.s.update(t.ID, func(t *task.Task) {
+		t.Description, t.DueDate, t.DueOn, t.Priority = in.Description, in.DueDate, in.DueOn, in.Priority
+		if in.Category == group.CategoryDone {
+			t.Status, t.StatusCategory = "completed", group.CategoryDone
+		}
+	})
+	return f.s.get(t.ID), nil
+}
+
+func (f davTasks) UpdateTask(ctx context.Context, userID, id int64, in task.UpdateTaskInput) (*task.Task, error) {
+	if in.IfChangeSeq != nil && *in.IfChangeSeq != f.s.get(id).ChangeSeq {
+		return nil, apperror.New("PRECONDITION_FAILED", "the resource has been changed or does not match the condition")
+	}
+	f.s.update(id, func(t *task.Task) {
+		t.Title, t.Description, t.Status = in.Title, in.Description, in.Status
+		t.StatusCategory = group.CategoryTodo
+		if in.Status == "completed" {
+			t.StatusCategory = group.CategoryDone
+		}
+		if in.ReplaceDue {
+			t.DueDate, t.DueOn = in.DueDate, in.DueOn
+		}
+		if in.Priority != "" {
+			t.Priority = in.Priority
+		}
+		t.UpdatedAt = f.s.tick()
+	})
+	return f.s.get(id), nil
+}
+
+func (f davTasks) DeleteTask(ctx context.Context, userID, id int64) error {
+	f.s.remove(id)
+	return nil
+}
+
+type davTaskRepo struct {
+	task.Repository
+	s *davStore
+}
+
+func (f davTaskRepo) ListByGroup(ctx context.Context, groupID int64) ([]*task.Task, error) {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	items := make([]*task.Task, 0, len(f.s.tasks))
+	for _, t := range f.s.tasks {
+		items = append(items, t)
+	}
+	return items, nil
+}
+
+func (f davTaskRepo) GetByExternalID(ctx context.Context, workspaceID int64, externalID string) (*task.Task, error) {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	for _, t := range f.s.tasks {
+		if t.ExternalID == externalID {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f davTaskRepo) GetByIDUnscoped(ctx context.Context, id int64) (*task.Task, error) {
+	if t := f.s.get(id); t != nil {
+		return t, nil
+	}
+	return nil, apperror.New("TASK_NOT_FOUND", "task not found")
+}
+
+var davTestGroupValue = group.Group{ID: davTestGroup, UserID: davTestUser, WorkspaceID: davTestWorkspace, Name: "工作"}
+
+type davGroups struct{ group.Service }
+
+func (davGroups) Authorize(ctx context.Context, userID, groupID int64, need group.Access) (*group.Group, error) {
+	if groupID != davTestGroup {
+		return nil, apperror.New("GROUP_NOT_FOUND", "group not found")
+	}
+	g := davTestGroupValue
+	return &g, nil
+}
+
+type davGroupRepo struct{ group.Repository }
+
+func (davGroupRepo) GetListByUserID(ctx context.Context, userID int64) (*[]group.Group, error) {
+	return &[]group.Group{davTestGroupValue}, nil
+}
+
+type davUsers struct{ user.Service }
+
+func (davUsers) GetByID(ctx context.Context, id int64) (*user.User, error) {
+	return &user.User{ID: id, Username: "alice"}, nil
+}
+
+// newDAVClient 用真实的CalDAV客户端访问handler，认证中间件直接放行为davTestUser
+func newDAVClient(t *testing.T, s *davStore) (*caldav.Client, *httptest.Server) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	svc := davcore.NewService(davRepo{s}, davTasks{s: s}, davTaskRepo{s: s}, davGroups{}, davGroupRepo{})
+	r := gin.New()
+	auth := func(c *gin.Context) {
+		c.Set("userID", davTestUser)
+		c.Next()
+	}
+	NewCalDAVHandler(svc, davUsers{}).RegisterRoutes(r, auth)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	client, err := caldav.NewClient(srv.Client(), srv.URL+davRoot)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client, srv
+}
+
+func todoCalendar(uid, summary string) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//test//EN")
+	todo := ical.NewComponent(ical.CompToDo)
+	todo.Props.SetText(ical.PropUID, uid)
+	todo.Props.SetText(ical.PropSummary, summary)
+	todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC))
+	cal.Children = append(cal.Children, todo)
+	return cal
+}
+
+func summaryOf(t *testing.T, cal *ical.Calendar) string {
+	t.Helper()
+	for _, c := range cal.Children {
+		if c.Name == ical.CompToDo {
+			s, _ := c.Props.Text(ical.PropSummary)
+			return s
+		}
+	}
+	t.Fatalf("no VTODO in calendar data")
+	return ""
+}
+
+func objectPaths(objs []caldav.CalendarObject) []string {
+	paths := make([]string, 0, len(objs))
+	for _, o := range objs {
+		paths = append(paths, o.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func assertPaths(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("%s = %v, want %v", what, got, want)
+	}
+}
+
+const (
+	davReportPath = "/dav/calendars/7/task-1@tasker.ics"
+	davMilkPath   = "/dav/calendars/7/milk@phone.ics"
+)
+
+func TestCalDAVClientDiscoveryAndQuery(t *testing.T) {
+	s := newDAVStore()
+	s.add("写周报", "")
+	s.add("买牛奶", "milk@phone")
+	client, _ := newDAVClient(t, s)
+	ctx := context.Background()
+
+	principal, err := client.FindCurrentUserPrincipal(ctx)
+	if err != nil || principal != davPrincipalPath {
+		t.Fatalf("principal = %q, %v", principal, err)
+	}
+	home, err := client.FindCalendarHomeSet(ctx, principal)
+	if err != nil || home != davHomePath {
+		t.Fatalf("home set = %q, %v", home, err)
+	}
+	cals, err := client.FindCalendars(ctx, home)
+	if err != nil {
+		t.Fatalf("FindCalendars: %v", err)
+	}
+	if len(cals) != 1 || cals[0].Path != "/dav/calendars/7/" || cals[0].Name != "工作" ||
+		len(cals[0].SupportedComponentSet) != 1 || cals[0].SupportedComponentSet[0] != ical.CompToDo {
+		t.Fatalf("calendars = %+v", cals)
+	}
+
+	query := &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{Name: ical.CompCalendar, Comps: []caldav.CalendarCompRequest{{Name: ical.CompToDo, AllProps: true}}},
+		CompFilter:  caldav.CompFilter{Name: ical.CompCalendar, Comps: []caldav.CompFilter{{Name: ical.CompToDo}}},
+	}
+	objs, err := client.QueryCalendar(ctx, cals[0].Path, query)
+	if err != nil {
+		t.Fatalf("QueryCalendar: %v", err)
+	}
+	assertPaths(t, "query", objectPaths(objs), davReportPath, davMilkPath)
+	for _, o := range objs {
+		want := "写周报"
+		if o.Path == davMilkPath {
+			want = "买牛奶"
+		}
+		if got := summaryOf(t, o.Data); got != want || o.ETag == "" {
+			t.Fatalf("%s: summary %q, etag %q", o.Path, got, o.ETag)
+		}
+	}
+
+	// 只有VTODO，查VEVENT没有结果
+	query.CompFilter.Comps[0].Name = ical.CompEvent
+	if objs, err := client.QueryCalendar(ctx, cals[0].Path, query); err != nil || len(objs) != 0 {
+		t.Fatalf("VEVENT query = %v, %v", objectPaths(objs), err)
+	}
+}
+
+func TestCalDAVClientSyncCollection(t *testing.T) {
+	s := newDAVStore()
+	report := s.add("写周报", "")
+	milk := s.add("买牛奶", "milk@phone")
+	client, _ := newDAVClient(t, s)
+	ctx := context.Background()
+	const calPath = "/dav/calendars/7/"
+	syncFrom := func(token string) *caldav.SyncResponse {
+		t.Helper()
+		res, err := client.SyncCollection(ctx, calPath, &caldav.SyncQuery{SyncToken: token})
+		if err != nil {
+			t.Fatalf("SyncCollection(%q): %v", token, err)
+		}
+		return res
+	}
+
+	first := syncFrom("")
+	assertPaths(t, "initial sync", objectPaths(first.Updated), davReportPath, davMilkPath)
+	if first.SyncToken == "" {
+		t.Fatalf("no sync token")
+	}
+
+	// 修改时间比令牌还早（例如时钟回拨、事务提交晚）的修改也要报告
+	s.update(report.ID, func(t *task.Task) {
+		t.Title = "写月报"
+		t.UpdatedAt = t.UpdatedAt.Add(-time.Hour)
+	})
+	s.remove(milk.ID)
+	if _, err := client.PutCalendarObject(ctx, calPath+"new@client.ics", todoCalendar("new@client", "新任务")); err != nil {
+		t.Fatalf("PutCalendarObject: %v", err)
+	}
+	second := syncFrom(first.SyncToken)
+	assertPaths(t, "updated", objectPaths(second.Updated), davReportPath, calPath+"new@client.ics")
+	assertPaths(t, "deleted", second.Deleted, davMilkPath)
+
+	third := syncFrom(second.SyncToken)
+	if len(third.Updated) != 0 || len(third.Deleted) != 0 || third.SyncToken != second.SyncToken {
+		t.Fatalf("sync without changes = %+v", third)
+	}
+
+	// 移出又移回来的任务只报告修改
+	s.remove(report.ID)
+	s.mu.Lock()
+	s.changed(report)
+	s.mu.Unlock()
+	fourth := syncFrom(third.SyncToken)
+	assertPaths(t, "moved back", objectPaths(fourth.Updated), davReportPath)
+	assertPaths(t, "moved back deleted", fourth.Deleted)
+
+	// 别的分组的令牌、比当前序号还新的令牌都让客户端全量同步
+	for _, token := range []string{"urn:tasker:sync:8:1", "urn:tasker:sync:7:999", "urn:tasker:sync:7:1:abc"} {
+		if _, err := client.SyncCollection(ctx, calPath, &caldav.SyncQuery{SyncToken: token}); err == nil ||
+			!strings.Contains(err.Error(), "403") {
+			t.Fatalf("SyncCollection(%q) error = %v, want 403", token, err)
+		}
+	}
+}
+
+func TestCalDAVClientConditionalPut(t *testing.T) {
+	s := newDAVStore()
+	client, srv := newDAVClient(t, s)
+	ctx := context.Background()
+	const objPath = "/dav/calendars/7/new@client.ics"
+
+	if _, err := client.PutCalendarObject(ctx, objPath, todoCalendar("new@client", "初稿")); err != nil {
+		t.Fatalf("PutCalendarObject: %v", err)
+	}
+	obj, err := client.GetCalendarObject(ctx, objPath)
+	if err != nil {
+		t.Fatalf("GetCalendarObject: %v", err)
+	}
+	if summaryOf(t, obj.Data) != "初稿" || obj.ETag == "" {
+		t.Fatalf("object = %+v", obj)
+	}
+
+	// 客户端库的PUT不带条件请求头，直接发请求
+	put := func(path, header, value, summary string) int {
+		t.Helper()
+		var body bytes.Buffer
+		if err := ical.NewEncoder(&body).Encode(todoCalendar(strings.TrimSuffix(strings.TrimPrefix(path, "/dav/calendars/7/"), ".ics"), summary)); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+path, &body)
+		req.Header.Set("Content-Type", ical.MIMEType)
+		req.Header.Set(header, value)
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("PUT: %v", err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	etag := `"` + obj.ETag + `"`
+	tests := []struct {
+		name          string
+		path          string
+		header, value string
+		status        int
+	}{
+		{"If-None-Match on an existing object", objPath, "If-None-Match", "*", http.StatusPreconditionFailed},
+		{"If-Match with a stale ETag", objPath, "If-Match", `"0000"`, http.StatusPreconditionFailed},
+		{"If-Match on a missing object", "/dav/calendars/7/missing@client.ics", "If-Match", etag, http.StatusPreconditionFailed},
+		{"If-None-Match on a missing object", "/dav/calendars/7/other@client.ics", "If-None-Match", "*", http.StatusCreated},
+		{"If-Match with the current ETag", objPath, "If-Match", etag, http.StatusNoContent},
+		{"If-Match with the old ETag after the update", objPath, "If-Match", etag, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		if status := put(tt.path, tt.header, tt.value, tt.name); status != tt.status {
+			t.Fatalf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+
+	obj, err = client.GetCalendarObject(ctx, objPath)
+	if err != nil {
+		t.Fatalf("GetCalendarObject: %v", err)
+	}
+	if got := summaryOf(t, obj.Data); got != "If-Match with the current ETag" {
+		t.Fatalf("summary after conditional PUTs = %q", got)
+	}
+}
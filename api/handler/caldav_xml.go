@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WebDAV/CalDAV请求和响应里用到的XML命名空间
+const (
+	nsDAV       = "DAV:"
+	nsCalDAV    = "urn:ietf:params:xml:ns:caldav"
+	nsCalServer = "http://calendarserver.org/ns/"
+)
+
+var davPrefixes = map[string]string{nsDAV: "D", nsCalDAV: "C", nsCalServer: "CS"}
+
+// davNode 请求体解析成的简单XML树，只保留元素名、子元素和文本
+type davNode struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Children []*davNode
+	Text     string
+}
+
+// child 第一个同名子元素，没有时返回nil
+func (n *davNode) child(ns, local string) *davNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Name.Space == ns && c.Name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *davNode) textOrEmpty() string {
+	if n == nil {
+		return ""
+	}
+	return n.Text
+}
+
+func (n *davNode) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// parseDAVBody 空请求体返回nil
+func parseDAVBody(r io.Reader) (*davNode, error) {
+	dec := xml.NewDecoder(r)
+	var root *davNode
+	var stack []*davNode
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &davNode{Name: t.Name, Attrs: t.Attr}
+			if len(stack) == 0 {
+				root = n
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(t)
+			}
+		}
+	}
+	return root, nil
+}
+
+// davProp 一个属性和它已经转义好的内容
+type davProp struct {
+	Name  xml.Name
+	Inner string
+}
+
+func prop(ns, local, inner string) davProp {
+	return davProp{Name: xml.Name{Space: ns, Local: local}, Inner: inner}
+}
+
+func hrefXML(href string) string {
+	return "<D:href>" + escapeXML(href) + "</D:href>"
+}
+
+// propRequest PROPFIND或REPORT里要求返回的属性
+type propRequest struct {
+	// allprop或者没有请求体
+	All bool
+	// propname：只返回属性名
+	NamesOnly bool
+	Names     []xml.Name
+}
+
+func propRequestOf(n *davNode) propRequest {
+	if n == nil || n.child(nsDAV, "allprop") != nil {
+		return propRequest{All: true}
+	}
+	if n.child(nsDAV, "propname") != nil {
+		return propRequest{NamesOnly: true}
+	}
+	p := n.child(nsDAV, "prop")
+	if p == nil {
+		return propRequest{All: true}
+	}
+	req := propRequest{}
+	for _, c := range p.Children {
+		req.Names = append(req.Names, c.Name)
+	}
+	return req
+}
+
+func (r propRequest) wants(ns, local string) bool {
+	if r.All {
+		return true
+	}
+	for _, n := range r.Names {
+		if n.Space == ns && n.Local == local {
+			return true
+		}
+	}
+	return false
+}
+
+// multistatus 207响应
+type multistatus struct {
+	b strings.Builder
+}
+
+func newMultistatus() *multistatus {
+	m := &multistatus{}
+	m.b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	m.b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="` + nsCalDAV + `" xmlns:CS="` + nsCalServer + `">`)
+	return m
+}
+
+// add 按请求挑出属性：有的放在200里，请求了但没有的放在404里
+func (m *multistatus) add(href string, props []davProp, req propRequest) {
+	var found, missing strings.Builder
+	switch {
+	case req.NamesOnly:
+		for _, p := range props {
+			found.WriteString(elementXML(p.Name, ""))
+		}
+	case req.All:
+		for _, p := range props {
+			found.WriteString(elementXML(p.Name, p.Inner))
+		}
+	default:
+		for _, name := range req.Names {
+			if p, ok := findProp(props, name); ok {
+				found.WriteString(elementXML(name, p.Inner))
+			} else {
+				missing.WriteString(elementXML(name, ""))
+			}
+		}
+	}
+
+	m.b.WriteString("<D:response>" + hrefXML(href))
+	if found.Len() > 0 || missing.Len() == 0 {
+		m.b.WriteString("<D:propstat><D:prop>" + found.String() + "</D:prop>" + statusXML(http.StatusOK) + "</D:propstat>")
+	}
+	if missing.Len() > 0 {
+		m.b.WriteString("<D:propstat><D:prop>" + missing.String() + "</D:prop>" + statusXML(http.StatusNotFound) + "</D:propstat>")
+	}
+	m.b.WriteString("</D:response>")
+}
+
+// addStatus 整个资源的状态，例如multiget里不存在的href
+func (m *multistatus) addStatus(href string, status int) {
+	m.b.WriteString("<D:response>" + hrefXML(href) + statusXML(status) + "</D:response>")
+}
+
+// syncToken sync-collection响应最后的新令牌
+func (m *multistatus) syncToken(token string) {
+	m.b.WriteString("<D:sync-token>" + escapeXML(token) + "</D:sync-token>")
+}
+
+func (m *multistatus) String() string {
+	return m.b.String() + "</D:multistatus>"
+}
+
+func findProp(props []davProp, name xml.Name) (davProp, bool) {
+	for _, p := range props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return davProp{}, false
+}
+
+// elementXML 已知命名空间用固定前缀，其他命名空间就地声明
+func elementXML(name xml.Name, inner string) string {
+	tag := name.Local
+	decl := ""
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "X:" + name.Local
+		decl = ` xmlns:X="` + escapeXML(name.Space) + `"`
+	}
+	if inner == "" {
+		return "<" + tag + decl + "/>"
+	}
+	return "<" + tag + decl + ">" + inner + "</" + tag + ">"
+}
+
+func statusXML(status int) string {
+	return fmt.Sprintf("<D:status>HTTP/1.1 %d %s</D:status>", status, http.StatusText(status))
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeDAVError 带前置条件元素的错误响应，见RFC 4918 16节
+func writeDAVError(w http.ResponseWriter, status int, ns, condition string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+
+		`<D:error xmlns:D="DAV:" xmlns:C="`+nsCalDAV+`">`+elementXML(xml.Name{Space: ns, Local: condition}, "")+`</D:error>`)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/calendar"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type CalendarHandler struct {
+	svc calendar.Service
+}
+
+func NewCalendarHandler(svc calendar.Service) *CalendarHandler {
+	return &CalendarHandler{svc: svc}
+}
+
+// 注册路由：订阅地址本身就是凭证，只能在登录态下管理；/calendar/<token>.ics 不需要登录
+func (h *CalendarHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	session := middleware.RequireSession()
+
+	g := r.Group("/calendar")
+	{
+		g.GET("/feed", auth, session, h.GetFeed)
+		g.POST("/feed", auth, session, h.RotateFeed)
+		g.DELETE("/feed", auth, session, h.DisableFeed)
+
+		g.GET("/:file", h.ServeFeed)
+	}
+}
+
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	f, err := h.svc.Get(context.Background(), userID)
+	if err != nil {
+		writeCalendarError(c, err)
+		return
+	}
+	response.Success(c, f)
+}
+
+func (h *CalendarHandler) RotateFeed(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	f, raw, err := h.svc.Rotate(context.Background(), userID)
+	if err != nil {
+		writeCalendarError(c, err)
+		return
+	}
+
+	// 明文只在这里返回一次
+	response.SuccessWithStatus(c, http.StatusCreated, gin.H{
+		"token": raw,
+		"url":   "/calendar/" + raw + ".ics",
+		"feed":  f,
+	})
+}
+
+func (h *CalendarHandler) DisableFeed(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Disable(context.Background(), userID); err != nil {
+		writeCalendarError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "calendar feed disabled"})
+}
+
+// ServeFeed 日历应用定期拉取的订阅地址，component=vevent 时输出事件而不是待办
+func (h *CalendarHandler) ServeFeed(c *gin.Context) {
+	raw, ok := strings.CutSuffix(c.Param("file"), ".ics")
+	if !ok {
+		response.Error(c, http.StatusNotFound, "FEED_NOT_FOUND", "calendar feed not found")
+		return
+	}
+
+	var opts calendar.FeedOptions
+	switch c.DefaultQuery("component", "vtodo") {
+	case "vtodo":
+	case "vevent":
+		opts.Events = true
+	default:
+		response.Error(c, http.StatusBadRequest, "INVALID_COMPONENT", "component must be vtodo or vevent")
+		return
+	}
+	if opts.GroupID, ok = parseIDQuery(c, "group_id"); !ok {
+		return
+	}
+
+	// 撤销后立即失效，不允许中间代理缓存
+	c.Header("Cache-Control", "no-store")
+	w := &attachmentWriter{c: c, contentType: "text/calendar; charset=utf-8", filename: "tasker.ics"}
+	if err := h.svc.Render(context.Background(), raw, opts, w); err != nil {
+		// 已经开始输出日历时没法再改状态码，只能中断
+		if w.written {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		writeCalendarError(c, err)
+	}
+}
+
+// 日历订阅相关错误码到HTTP状态码的映射
+func writeCalendarError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "FEED_NOT_FOUND", "GROUP_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "GROUP_FORBIDDEN", "WORKSPACE_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
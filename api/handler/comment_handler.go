@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/comment"
+	"tasker/core/token"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type CommentHandler struct {
+	svc comment.Service
+}
+
+func NewCommentHandler(svc comment.Service) *CommentHandler {
+	return &CommentHandler{svc: svc}
+}
+
+// 注册路由：评论属于任务数据，令牌按tasks:read/tasks:write区分
+func (h *CommentHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeTasksWrite)
+
+	t := r.Group("/tasks")
+	t.Use(auth)
+	{
+		t.GET("/:id/comments", read, h.ListComments)
+		t.POST("/:id/comments", write, h.CreateComment)
+	}
+
+	g := r.Group("/comments")
+	g.Use(auth)
+	{
+		g.PATCH("/:id", write, h.UpdateComment)
+		g.DELETE("/:id", write, h.DeleteComment)
+	}
+}
+
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	taskID, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in comment.CommentInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	cm, err := h.svc.Create(context.Background(), userID, taskID, in)
+	if err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	response.SuccessWithStatus(c, http.StatusCreated, cm)
+}
+
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	taskID, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.List(context.Background(), userID, taskID)
+	if err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	response.Success(c, items)
+}
+
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in comment.CommentInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	cm, err := h.svc.Update(context.Background(), userID, id, in)
+	if err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	response.Success(c, cm)
+}
+
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(context.Background(), userID, id); err != nil {
+		writeCommentError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "comment deleted"})
+}
+
+// 评论相关错误码到HTTP状态码的映射
+func writeCommentError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "COMMENT_NOT_FOUND", "TASK_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "COMMENT_FORBIDDEN", "WORKSPACE_FORBIDDEN", "GROUP_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
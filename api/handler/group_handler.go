@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/group"
+	"tasker/core/token"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type GroupHandler struct {
+	svc group.Service
+}
+
+func NewGroupHandler(svc group.Service) *GroupHandler {
+	return &GroupHandler{svc: svc}
+}
+
+// 注册路由：分组共享和工作流，令牌需要groups:write；读取工作流只需要tasks:read
+func (h *GroupHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeGroupsWrite)
+
+	g := r.Group("/groups")
+	g.Use(auth)
+	{
+		g.POST("/:id/shares", write, h.ShareGroup)
+		g.GET("/:id/shares", write, h.ListShares)
+		g.DELETE("/:id/shares/:user_id", write, h.RevokeShare)
+		g.GET("/:id/workflow", read, h.GetWorkflow)
+		g.PUT("/:id/workflow", write, h.SetWorkflow)
+	}
+}
+
+func (h *GroupHandler) ShareGroup(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in group.ShareGroupInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	share, err := h.svc.ShareGroup(context.Background(), userID, id, in)
+	if err != nil {
+		writeGroupError(c, err)
+		return
+	}
+	response.Success(c, share)
+}
+
+func (h *GroupHandler) ListShares(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.svc.ListShares(context.Background(), userID, id)
+	if err != nil {
+		writeGroupError(c, err)
+		return
+	}
+	response.Success(c, shares)
+}
+
+func (h *GroupHandler) RevokeShare(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	targetID, ok := parseNamedIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.RevokeShare(context.Background(), userID, id, targetID); err != nil {
+		writeGroupError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "share revoked"})
+}
+
+func (h *GroupHandler) GetWorkflow(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	wf, err := h.svc.GetWorkflow(context.Background(), userID, id)
+	if err != nil {
+		writeGroupError(c, err)
+		return
+	}
+	response.Success(c, wf)
+}
+
+// SetWorkflow states为空时恢复默认工作流
+func (h *GroupHandler) SetWorkflow(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in group.Workflow
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	wf, err := h.svc.SetWorkflow(context.Background(), userID, id, &in)
+	if err != nil {
+		writeGroupError(c, err)
+		return
+	}
+	response.Success(c, wf)
+}
+
+// 分组相关错误码到HTTP状态码的映射
+func writeGroupError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "GROUP_NOT_FOUND", "SHARE_NOT_FOUND", "USER_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "WORKSPACE_FORBIDDEN", "GROUP_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "ALREADY_MEMBER":
+			response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/notification"
+	"tasker/core/token"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type NotificationHandler struct {
+	svc notification.Service
+}
+
+func NewNotificationHandler(svc notification.Service) *NotificationHandler {
+	return &NotificationHandler{svc: svc}
+}
+
+// 注册路由：通知属于任务相关数据，令牌按tasks:read/tasks:write区分
+func (h *NotificationHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeTasksWrite)
+
+	g := r.Group("/notifications")
+	g.Use(auth)
+	{
+		g.GET("", read, h.ListNotifications)
+		g.POST("/read-all", write, h.MarkAllRead)
+		g.POST("/:id/read", write, h.MarkRead)
+	}
+}
+
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter := notification.ListFilter{
+		UnreadOnly: c.Query("unread") == "true",
+		Limit:      limit,
+	}
+
+	res, err := h.svc.List(context.Background(), userID, filter)
+	if err != nil {
+		writeNotificationError(c, err)
+		return
+	}
+	response.Success(c, res)
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.MarkRead(context.Background(), userID, id); err != nil {
+		writeNotificationError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "notification marked as read"})
+}
+
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.MarkAllRead(context.Background(), userID); err != nil {
+		writeNotificationError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "all notifications marked as read"})
+}
+
+func writeNotificationError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "NOTIFICATION_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/sharelink"
+	"tasker/core/token"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type ShareLinkHandler struct {
+	svc sharelink.Service
+}
+
+func NewShareLinkHandler(svc sharelink.Service) *ShareLinkHandler {
+	return &ShareLinkHandler{svc: svc}
+}
+
+// 注册路由：/links 管理自己创建的链接和自己管理的任务、分组上的链接，/public 不需要登录
+func (h *ShareLinkHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeTasksWrite)
+
+	g := r.Group("/links")
+	g.Use(auth)
+	{
+		g.POST("", write, h.CreateLink)
+		g.GET("", read, h.ListLinks)
+		g.DELETE("/:id", write, h.RevokeLink)
+	}
+
+	p := r.Group("/public")
+	{
+		p.GET("/:token", h.ViewLink)
+		// HTML页面的密码表单提交到这里
+		p.POST("/:token", h.ViewLink)
+	}
+}
+
+func (h *ShareLinkHandler) CreateLink(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in sharelink.CreateLinkInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	l, raw, err := h.svc.Create(context.Background(), userID, in)
+	if err != nil {
+		writeShareLinkError(c, err)
+		return
+	}
+
+	// 明文只在这里返回一次
+	response.SuccessWithStatus(c, http.StatusCreated, gin.H{
+		"token": raw,
+		"url":   "/public/" + raw,
+		"link":  l,
+	})
+}
+
+// ListLinks 默认列出自己创建的链接；带 target_type 和 target_id 时列出目标上所有人创建的链接
+func (h *ShareLinkHandler) ListLinks(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	targetID, ok := parseIDQuery(c, "target_id")
+	if !ok {
+		return
+	}
+	targetType := c.Query("target_type")
+	if (targetType == "") != (targetID == nil) {
+		response.Error(c, http.StatusBadRequest, "INVALID_TARGET", "target_type and target_id must be set together")
+		return
+	}
+
+	var links []*sharelink.Link
+	var err error
+	if targetID != nil {
+		links, err = h.svc.ListForTarget(context.Background(), userID, targetType, *targetID)
+	} else {
+		links, err = h.svc.List(context.Background(), userID)
+	}
+	if err != nil {
+		writeShareLinkError(c, err)
+		return
+	}
+	response.Success(c, links)
+}
+
+func (h *ShareLinkHandler) RevokeLink(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Revoke(context.Background(), userID, id); err != nil {
+		writeShareLinkError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "link revoked"})
+}
+
+// ViewLink 公开访问。默认返回JSON，format=html或浏览器访问时渲染页面。
+// 密码从 X-Link-Password 请求头或表单字段 password 读取
+func (h *ShareLinkHandler) ViewLink(c *gin.Context) {
+	password := c.GetHeader("X-Link-Password")
+	if password == "" {
+		password = c.PostForm("password")
+	}
+	asHTML := wantsHTML(c)
+
+	v, err := h.svc.View(context.Background(), c.Param("token"), password, c.ClientIP())
+	if err != nil {
+		if asHTML {
+			writeShareLinkPage(c, err)
+			return
+		}
+		if writeLockout(c, err) {
+			return
+		}
+		writeShareLinkError(c, err)
+		return
+	}
+
+	// 公开内容不允许被中间代理缓存，撤销后立即失效
+	c.Header("Cache-Control", "no-store")
+	if asHTML {
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		_ = publicViewTemplate.Execute(c.Writer, v)
+		return
+	}
+	response.Success(c, v)
+}
+
+func wantsHTML(c *gin.Context) bool {
+	if f := c.Query("format"); f != "" {
+		return f == "html"
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+// writeShareLinkPage HTML访问出错时的页面，需要密码时显示密码表单
+func writeShareLinkPage(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	data := gin.H{"Message": "Something went wrong.", "AskPassword": false}
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "LINK_NOT_FOUND":
+			status = http.StatusNotFound
+			data["Message"] = "This link does not exist, has expired or was revoked."
+		case "PASSWORD_REQUIRED":
+			status = http.StatusUnauthorized
+			data["Message"] = "This link is password protected."
+			data["AskPassword"] = true
+		case "INVALID_LINK_PASSWORD":
+			status = http.StatusUnauthorized
+			data["Message"] = "Incorrect password."
+			data["AskPassword"] = true
+		case "TOO_MANY_ATTEMPTS":
+			status = http.StatusTooManyRequests
+			data["Message"] = "Too many incorrect passwords. Try again later."
+			var lockErr *user.LockoutError
+			if errors.As(err, &lockErr) {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockErr.RetryAfter.Seconds()))))
+			}
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	_ = publicErrorTemplate.Execute(c.Writer, data)
+}
+
+// 公开链接相关错误码到HTTP状态码的映射
+func writeShareLinkError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "LINK_NOT_FOUND", "TASK_NOT_FOUND", "GROUP_NOT_FOUND", "WORKSPACE_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "PASSWORD_REQUIRED", "INVALID_LINK_PASSWORD":
+			response.Error(c, http.StatusUnauthorized, appErr.Code, appErr.Message)
+		case "WORKSPACE_FORBIDDEN", "GROUP_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
+
+const publicPageHead = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="robots" content="noindex">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Tasker</title>
+<style>body{font-family:sans-serif;max-width:720px;margin:2em auto;padding:0 1em;color:#222}
+.task{border-bottom:1px solid #ddd;padding:.6em 0}.meta{color:#666;font-size:.9em}
+.done .title{text-decoration:line-through;color:#888}</style></head><body>`
+
+var publicViewTemplate = template.Must(template.New("view").Parse(publicPageHead + `
+{{define "task"}}<div class="task {{.StatusCategory}}"><div class="title"><strong>{{.Title}}</strong></div>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<div class="meta">{{.Status}}{{if .Priority}} · {{.Priority}}{{end}}{{if .DueOn}} · due {{.DueOn}}{{else if .DueDate}} · due {{.DueDate.Format "2006-01-02 15:04 MST"}}{{end}}</div></div>{{end}}
+{{if .Task}}{{template "task" .Task}}{{end}}
+{{with .Group}}<h1>{{.Name}}</h1>{{range .Tasks}}{{template "task" .}}{{else}}<p class="meta">No tasks.</p>{{end}}{{end}}
+<p class="meta">Read-only view{{if .ExpiresAt}}, available until {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}{{end}}.</p>
+</body></html>`))
+
+var publicErrorTemplate = template.Must(template.New("error").Parse(publicPageHead + `
+<p>{{.Message}}</p>
+{{if .AskPassword}}<form method="post"><input type="password" name="password" autofocus>
+<button type="submit">View</button></form>{{end}}
+</body></html>`))
@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"strconv"
 	"tasker/api/middleware"
+	"tasker/core/group"
 	"tasker/core/task"
+	"tasker/core/token"
 	"tasker/pkg/apperror"
 	"tasker/pkg/response"
+	"time"
 )
 
 type TaskHandler struct {
@@ -19,16 +22,26 @@ func NewTaskHandler(svc task.Service) *TaskHandler {
 	return &TaskHandler{svc: svc}
 }
 
-// 路由注册
-func (h *TaskHandler) RegisterRoutes(r *gin.Engine) {
+// 路由注册，auth由main统一构造后传入
+func (h *TaskHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeTasksWrite)
+
 	g := r.Group("/tasks")
-	g.Use(middleware.AuthMiddleware())
+	g.Use(auth)
 	{
-		g.POST("", h.CreateTask)
-		g.GET("", h.ListTasks)
-		g.GET("/:id", h.GetTask)
-		g.PUT("/:id", h.UpdateTask)
-		g.DELETE("/:id", h.DeleteTask)
+		g.POST("", write, h.CreateTask)
+		g.GET("", read, h.ListTasks)
+		g.GET("/:id", read, h.GetTask)
+		g.PUT("/:id", write, h.UpdateTask)
+		g.DELETE("/:id", write, h.DeleteTask)
+		g.POST("/:id/move", write, h.MoveTask)
+	}
+
+	b := r.Group("/boards")
+	b.Use(auth)
+	{
+		b.GET("/:group_id", read, h.GetBoard)
 	}
 }
 
@@ -47,13 +60,7 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 
 	t, err := h.svc.CreateTask(context.Background(), userID, in)
 	if err != nil {
-		if appErr, ok := apperror.IsAppError(err); ok {
-			// 业务错误，一般是400
-			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
-			return
-		}
-		// 未知错误
-		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		writeTaskError(c, err)
 		return
 	}
 
@@ -65,35 +72,51 @@ func (h *TaskHandler) ListTasks(c *gin.Context) {
 	if !ok {
 		return
 	}
+	// 状态由分组的工作流决定，合法性交给service校验
 	status := c.Query("status")
-	if status != "" && status != string(task.StatusPending) && status != string(task.StatusCompleted) && status != "all" {
-		response.Error(c, http.StatusBadRequest, "INVALID_STATUS", "status must be pending/completed/all")
-		return
-	}
 
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-	sort := c.DefaultQuery("sort", "created_desc")
+	// 不传时由service决定：带q按相关度，否则按创建时间倒序
+	sort := c.Query("sort")
 	query := c.Query("q")
+	due := c.Query("due")
+	assignee := c.Query("assignee")
 
 	filter := task.ListTaskerFilter{
 		Status:   task.Status(status),
+		Category: group.Category(c.Query("category")),
 		Page:     page,
 		PageSize: pageSize,
 		Query:    query,
 		Sort:     sort,
+		Due:      due,
+		Assignee: assignee,
+		Filter:   c.Query("filter"),
+		// 默认按页码；pagination=cursor或带了cursor时按游标
+		Pagination:   c.Query("pagination"),
+		Cursor:       c.Query("cursor"),
+		IncludeTotal: c.Query("include_total") == "true",
 	}
 	if status == "all" {
 		filter.Status = ""
 	}
+	if filter.WorkspaceID, ok = parseIDQuery(c, "workspace_id"); !ok {
+		return
+	}
+	if filter.GroupID, ok = parseIDQuery(c, "group_id"); !ok {
+		return
+	}
+	if filter.CompletedAfter, ok = parseTimeQuery(c, "completed_after"); !ok {
+		return
+	}
+	if filter.CompletedBefore, ok = parseTimeQuery(c, "completed_before"); !ok {
+		return
+	}
 
 	tasks, err := h.svc.ListTasks(context.Background(), userID, filter)
 	if err != nil {
-		if appErr, ok := apperror.IsAppError(err); ok && (appErr.Code == "INVALID_STATUS" || appErr.Code == "INVALID_SORT") {
-			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
-			return
-		}
-		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		writeTaskError(c, err)
 		return
 	}
 	response.Success(c, tasks)
@@ -112,11 +135,7 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 
 	t, err := h.svc.GetTask(context.Background(), userID, id)
 	if err != nil {
-		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "TASK_NOT_FOUND" {
-			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
-			return
-		}
-		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		writeTaskError(c, err)
 		return
 	}
 	response.Success(c, t)
@@ -141,18 +160,7 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 
 	t, err := h.svc.UpdateTask(context.Background(), userID, id, in)
 	if err != nil {
-		if appErr, ok := apperror.IsAppError(err); ok {
-			switch appErr.Code {
-			case "TASK_NOT_FOUND":
-				response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
-			case "INVALID_TITLE", "INVALID_STATUS":
-				response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
-			default:
-				response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
-			}
-			return
-		}
-		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		writeTaskError(c, err)
 		return
 	}
 	response.Success(c, t)
@@ -170,11 +178,7 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	}
 
 	if err := h.svc.DeleteTask(context.Background(), userID, id); err != nil {
-		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "TASK_NOT_FOUND" {
-			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
-			return
-		}
-		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		writeTaskError(c, err)
 		return
 	}
 
@@ -182,15 +186,88 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	response.Success(c, gin.H{"message": "task deleted"})
 }
 
+// MoveTask 看板上拖动任务：换分组、换列或在列内调整位置
+func (h *TaskHandler) MoveTask(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in task.MoveTaskInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	t, err := h.svc.MoveTask(context.Background(), userID, id, in)
+	if err != nil {
+		writeTaskError(c, err)
+		return
+	}
+	response.Success(c, t)
+}
+
+func (h *TaskHandler) GetBoard(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	groupID, ok := parseNamedIDParam(c, "group_id")
+	if !ok {
+		return
+	}
+
+	board, err := h.svc.Board(context.Background(), userID, groupID)
+	if err != nil {
+		writeTaskError(c, err)
+		return
+	}
+	response.Success(c, board)
+}
+
+// 任务相关错误码到HTTP状态码的映射：不是工作区成员按404处理，角色不够403
+func writeTaskError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "TASK_NOT_FOUND", "GROUP_NOT_FOUND", "WORKSPACE_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "WORKSPACE_FORBIDDEN", "GROUP_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR", "USER_NOT_FOUND":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			// 业务错误，一般是400
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	// 未知错误
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
+
+// parseTimeQuery 解析RFC3339格式的查询参数，不存在时返回nil
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_TIME", name+" must be an RFC3339 timestamp")
+		return nil, false
+	}
+	return &t, true
+}
+
 // 工具函数：解析路径参数id
 func parseIDParam(c *gin.Context) (int64, bool) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil || id <= 0 {
-		response.Error(c, http.StatusBadRequest, "INVALID_ID", "id must be a positive integer")
-		return 0, false
-	}
-	return id, true
+	return parseNamedIDParam(c, "id")
 }
 
 func getUserIDFromContext(c *gin.Context) (int64, bool) {
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/token"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type TokenHandler struct {
+	svc token.Service
+}
+
+func NewTokenHandler(svc token.Service) *TokenHandler {
+	return &TokenHandler{svc: svc}
+}
+
+// 注册路由：令牌只能在登录态下管理，不能用令牌再签发令牌
+func (h *TokenHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	g := r.Group("/auth/tokens")
+	g.Use(auth, middleware.RequireSession())
+	{
+		g.POST("", h.CreateToken)
+		g.GET("", h.ListTokens)
+		g.DELETE("/:id", h.RevokeToken)
+	}
+}
+
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in token.CreateTokenInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	t, raw, err := h.svc.Create(context.Background(), userID, in)
+	if err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code != "DB_ERROR" {
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		return
+	}
+
+	// 明文只在这里返回一次
+	response.SuccessWithStatus(c, http.StatusCreated, gin.H{
+		"token":        raw,
+		"access_token": t,
+	})
+}
+
+func (h *TokenHandler) ListTokens(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.svc.List(context.Background(), userID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		return
+	}
+	response.Success(c, tokens)
+}
+
+func (h *TokenHandler) RevokeToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Revoke(context.Background(), userID, id); err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "TOKEN_NOT_FOUND" {
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		return
+	}
+	response.Success(c, gin.H{"message": "token revoked"})
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/token"
+	"tasker/core/transfer"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type TransferHandler struct {
+	svc transfer.Service
+}
+
+func NewTransferHandler(svc transfer.Service) *TransferHandler {
+	return &TransferHandler{svc: svc}
+}
+
+// 注册路由：导出要求tasks:read，导入要求tasks:write
+func (h *TransferHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeTasksWrite)
+
+	r.GET("/export", auth, read, h.Export)
+	r.POST("/import", auth, write, h.Import)
+}
+
+// attachmentWriter 第一次写入时才设置下载相关的响应头，
+// 这样权限校验失败时仍然可以返回普通的JSON错误
+type attachmentWriter struct {
+	c           *gin.Context
+	contentType string
+	filename    string
+	written     bool
+}
+
+func (w *attachmentWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", `attachment; filename="`+w.filename+`"`)
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer.Write(p)
+}
+
+func (h *TransferHandler) Export(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	name := c.DefaultQuery("format", "json")
+	format, ok := transfer.Lookup(name)
+	if !ok {
+		response.Error(c, http.StatusBadRequest, "INVALID_FORMAT", "format must be one of "+strings.Join(transfer.Names(), ", "))
+		return
+	}
+	workspaceID, ok := parseIDQuery(c, "workspace_id")
+	if !ok {
+		return
+	}
+
+	w := &attachmentWriter{c: c, contentType: format.ContentType, filename: "tasker-export." + format.Extension}
+	if err := h.svc.Export(context.Background(), userID, workspaceID, format.NewEncoder(w)); err != nil {
+		// 已经开始输出文件时没法再改状态码，只能中断
+		if w.written {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		writeTransferError(c, err)
+	}
+}
+
+func (h *TransferHandler) Import(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	workspaceID, ok := parseIDQuery(c, "workspace_id")
+	if !ok {
+		return
+	}
+	in := transfer.ImportInput{
+		WorkspaceID: workspaceID,
+		Format:      c.Query("format"),
+		DryRun:      c.Query("dry_run") == "true",
+		Body:        http.MaxBytesReader(c.Writer, c.Request.Body, transfer.MaxImportBytes),
+	}
+	if in.Format == "" {
+		in.Format = formatFromContentType(c.ContentType())
+	}
+
+	report, err := h.svc.Import(context.Background(), userID, in)
+	if err != nil {
+		writeTransferError(c, err)
+		return
+	}
+	response.Success(c, report)
+}
+
+// formatFromContentType 没有format参数时按请求的Content-Type推断
+func formatFromContentType(contentType string) string {
+	for _, name := range transfer.Names() {
+		f, _ := transfer.Lookup(name)
+		mediaType, _, err := mime.ParseMediaType(f.ContentType)
+		if err == nil && mediaType == contentType {
+			return name
+		}
+	}
+	return "json"
+}
+
+func writeTransferError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "WORKSPACE_NOT_FOUND", "USER_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "WORKSPACE_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "IMPORT_CONFLICT":
+			response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
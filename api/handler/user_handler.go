@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+// UserHandler 当前登录用户的资料与账号
+type UserHandler struct {
+	userSvc user.Service
+}
+
+func NewUserHandler(userSvc user.Service) *UserHandler {
+	return &UserHandler{userSvc: userSvc}
+}
+
+// 注册路由：读资料任何认证方式都可以，修改和注销必须是登录态
+func (h *UserHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	session := middleware.RequireSession()
+
+	g := r.Group("/me")
+	g.Use(auth)
+	{
+		g.GET("", h.GetMe)
+		g.PATCH("", session, h.UpdateMe)
+		g.PUT("/username", session, h.ChangeUsername)
+		g.DELETE("", session, h.DeleteMe)
+	}
+}
+
+func (h *UserHandler) GetMe(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.userSvc.GetByID(context.Background(), userID)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	response.Success(c, u)
+}
+
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in user.UpdateProfileInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	u, err := h.userSvc.UpdateProfile(context.Background(), userID, in)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	response.Success(c, u)
+}
+
+func (h *UserHandler) ChangeUsername(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in user.ChangeUsernameInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	u, err := h.userSvc.ChangeUsername(context.Background(), userID, in)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	response.Success(c, u)
+}
+
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in user.DeleteAccountInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	if err := h.userSvc.DeleteAccount(context.Background(), userID, in); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "account deleted"})
+}
+
+// 用户资料相关错误码到HTTP状态码的映射
+func writeUserError(c *gin.Context, err error) {
+	if writeLockout(c, err) {
+		return
+	}
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "USER_NOT_FOUND", "GROUP_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "USERNAME_EXISTS", "OWNS_SHARED_WORKSPACE":
+			response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
+		case "INVALID_CREDENTIALS":
+			response.Error(c, http.StatusUnauthorized, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/token"
+	"tasker/core/view"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type ViewHandler struct {
+	svc view.Service
+}
+
+func NewViewHandler(svc view.Service) *ViewHandler {
+	return &ViewHandler{svc: svc}
+}
+
+// 注册路由：视图是任务列表的条件，令牌按tasks:read/tasks:write区分。
+// 读取和运行时:id也可以是系统视图的key，修改只针对保存的视图
+func (h *ViewHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	write := middleware.RequireScope(token.ScopeTasksWrite)
+
+	g := r.Group("/views")
+	g.Use(auth)
+	{
+		g.GET("", read, h.ListViews)
+		g.POST("", write, h.CreateView)
+		g.GET("/:id", read, h.GetView)
+		g.PUT("/:id", write, h.UpdateView)
+		g.DELETE("/:id", write, h.DeleteView)
+		g.PUT("/:id/pin", write, h.PinView)
+		g.DELETE("/:id/pin", write, h.UnpinView)
+		g.GET("/:id/tasks", read, h.ListViewTasks)
+	}
+}
+
+func (h *ViewHandler) ListViews(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.List(context.Background(), userID)
+	if err != nil {
+		writeViewError(c, err)
+		return
+	}
+	response.Success(c, items)
+}
+
+func (h *ViewHandler) CreateView(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in view.ViewInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	v, err := h.svc.Create(context.Background(), userID, in)
+	if err != nil {
+		writeViewError(c, err)
+		return
+	}
+	response.SuccessWithStatus(c, http.StatusCreated, v)
+}
+
+func (h *ViewHandler) GetView(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	v, err := h.svc.Get(context.Background(), userID, c.Param("id"))
+	if err != nil {
+		writeViewError(c, err)
+		return
+	}
+	response.Success(c, v)
+}
+
+func (h *ViewHandler) UpdateView(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in view.ViewInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	v, err := h.svc.Update(context.Background(), userID, id, in)
+	if err != nil {
+		writeViewError(c, err)
+		return
+	}
+	response.Success(c, v)
+}
+
+func (h *ViewHandler) DeleteView(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(context.Background(), userID, id); err != nil {
+		writeViewError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "view deleted"})
+}
+
+func (h *ViewHandler) PinView(c *gin.Context) {
+	h.setPinned(c, true)
+}
+
+func (h *ViewHandler) UnpinView(c *gin.Context) {
+	h.setPinned(c, false)
+}
+
+func (h *ViewHandler) setPinned(c *gin.Context, pinned bool) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	v, err := h.svc.Pin(context.Background(), userID, id, pinned)
+	if err != nil {
+		writeViewError(c, err)
+		return
+	}
+	response.Success(c, v)
+}
+
+func (h *ViewHandler) ListViewTasks(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	paging := view.Paging{
+		Page:         page,
+		PageSize:     pageSize,
+		Pagination:   c.Query("pagination"),
+		Cursor:       c.Query("cursor"),
+		IncludeTotal: c.Query("include_total") == "true",
+	}
+
+	tasks, err := h.svc.Tasks(context.Background(), userID, c.Param("id"), paging)
+	if err != nil {
+		writeViewError(c, err)
+		return
+	}
+	response.Success(c, tasks)
+}
+
+// 视图相关错误码到HTTP状态码的映射，运行视图时的任务错误按列表接口的方式处理
+func writeViewError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "VIEW_NOT_FOUND", "GROUP_NOT_FOUND", "WORKSPACE_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "VIEW_FORBIDDEN", "WORKSPACE_FORBIDDEN", "GROUP_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"tasker/api/middleware"
+	"tasker/core/token"
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+	"tasker/pkg/response"
+)
+
+type WorkspaceHandler struct {
+	svc workspace.Service
+}
+
+func NewWorkspaceHandler(svc workspace.Service) *WorkspaceHandler {
+	return &WorkspaceHandler{svc: svc}
+}
+
+// 注册路由：读取允许带tasks:read的令牌，成员和邀请管理必须是登录态
+func (h *WorkspaceHandler) RegisterRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	read := middleware.RequireScope(token.ScopeTasksRead)
+	session := middleware.RequireSession()
+
+	g := r.Group("/workspaces")
+	g.Use(auth)
+	{
+		g.POST("", session, h.CreateWorkspace)
+		g.GET("", read, h.ListWorkspaces)
+		g.GET("/:id", read, h.GetWorkspace)
+		g.PATCH("/:id", session, h.UpdateWorkspace)
+		g.DELETE("/:id", session, h.DeleteWorkspace)
+
+		g.GET("/:id/members", read, h.ListMembers)
+		g.PATCH("/:id/members/:user_id", session, h.UpdateMember)
+		g.DELETE("/:id/members/:user_id", session, h.RemoveMember)
+
+		g.POST("/:id/invites", session, h.CreateInvite)
+		g.GET("/:id/invites", session, h.ListInvites)
+		g.DELETE("/:id/invites/:invite_id", session, h.RevokeInvite)
+	}
+
+	inv := r.Group("/invites")
+	inv.Use(auth, session)
+	{
+		inv.POST("/accept", h.AcceptInvite)
+	}
+}
+
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in workspace.CreateWorkspaceInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	w, err := h.svc.Create(context.Background(), userID, in)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.SuccessWithStatus(c, http.StatusCreated, w)
+}
+
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.List(context.Background(), userID)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, items)
+}
+
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	w, err := h.svc.Get(context.Background(), userID, id)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, w)
+}
+
+func (h *WorkspaceHandler) UpdateWorkspace(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in workspace.UpdateWorkspaceInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	w, err := h.svc.Update(context.Background(), userID, id, in)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, w)
+}
+
+func (h *WorkspaceHandler) DeleteWorkspace(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(context.Background(), userID, id); err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "workspace deleted"})
+}
+
+func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.svc.ListMembers(context.Background(), userID, id)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, members)
+}
+
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseNamedIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var in workspace.UpdateMemberInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	m, err := h.svc.UpdateMember(context.Background(), userID, id, memberID, in)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, m)
+}
+
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseNamedIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.RemoveMember(context.Background(), userID, id, memberID); err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "member removed"})
+}
+
+func (h *WorkspaceHandler) CreateInvite(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var in workspace.CreateInviteInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	inv, raw, err := h.svc.CreateInvite(context.Background(), userID, id, in)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+
+	// 明文只在这里返回一次，由邀请人转交给被邀请人
+	response.SuccessWithStatus(c, http.StatusCreated, gin.H{
+		"token":  raw,
+		"invite": inv,
+	})
+}
+
+func (h *WorkspaceHandler) ListInvites(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	invites, err := h.svc.ListInvites(context.Background(), userID, id)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, invites)
+}
+
+func (h *WorkspaceHandler) RevokeInvite(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	inviteID, ok := parseNamedIDParam(c, "invite_id")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.RevokeInvite(context.Background(), userID, id, inviteID); err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "invite revoked"})
+}
+
+func (h *WorkspaceHandler) AcceptInvite(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var in workspace.AcceptInviteInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		response.Error(c, http.StatusBadRequest, "INVALID_JSON", "invalid JSON body")
+		return
+	}
+
+	w, err := h.svc.AcceptInvite(context.Background(), userID, in)
+	if err != nil {
+		writeWorkspaceError(c, err)
+		return
+	}
+	response.Success(c, w)
+}
+
+// 工作区相关错误码到HTTP状态码的映射
+func writeWorkspaceError(c *gin.Context, err error) {
+	if appErr, ok := apperror.IsAppError(err); ok {
+		switch appErr.Code {
+		case "WORKSPACE_NOT_FOUND", "MEMBER_NOT_FOUND", "INVITE_NOT_FOUND", "TASK_NOT_FOUND", "GROUP_NOT_FOUND":
+			response.Error(c, http.StatusNotFound, appErr.Code, appErr.Message)
+		case "WORKSPACE_FORBIDDEN":
+			response.Error(c, http.StatusForbidden, appErr.Code, appErr.Message)
+		case "ALREADY_MEMBER", "PERSONAL_WORKSPACE", "GROUP_EXISTS":
+			response.Error(c, http.StatusConflict, appErr.Code, appErr.Message)
+		case "DB_ERROR", "INTERNAL_ERROR":
+			response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		default:
+			response.Error(c, http.StatusBadRequest, appErr.Code, appErr.Message)
+		}
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+}
+
+// 工具函数：解析任意名字的路径参数id
+func parseNamedIDParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		response.Error(c, http.StatusBadRequest, "INVALID_ID", name+" must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// 工具函数：解析可选的id查询参数，没传时返回nil
+func parseIDQuery(c *gin.Context, name string) (*int64, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		response.Error(c, http.StatusBadRequest, "INVALID_ID", name+" must be a positive integer")
+		return nil, false
+	}
+	return &id, true
+}
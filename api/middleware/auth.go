@@ -1,15 +1,31 @@
 package middleware
 
 import (
+	"context"
+	"github.com/gin-gonic/gin"
 	"net/http"
 	"strings"
-	"github.com/gin-gonic/gin"
+	"tasker/core/token"
+	"tasker/core/user"
 	"tasker/pkg/jwtutil"
 	"tasker/pkg/response"
 )
 
-// AuthMiddleware 验证JWT， 成功的话把userID写进gin.Context
-func AuthMiddleware() gin.HandlerFunc {
+// 认证方式，写在gin.Context的authMethod里
+const (
+	AuthMethodJWT = "jwt"
+	AuthMethodPAT = "pat"
+)
+
+// SessionChecker 校验JWT对应的会话是否还有效（改密码后会撤销其他会话）
+type SessionChecker interface {
+	CheckSession(ctx context.Context, userID int64, sessionID string) error
+	// CheckActive 账号被停用时返回错误，访问令牌认证时使用
+	CheckActive(ctx context.Context, userID int64) error
+}
+
+// AuthMiddleware 验证JWT或个人访问令牌， 成功的话把userID写进gin.Context
+func AuthMiddleware(tokenSvc token.Service, sessions SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -27,16 +43,139 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenStr := strings.TrimSpace(strings.TrimPrefix(authHeader, prefix))
 
+		// tsk_开头的是个人访问令牌，其余按JWT处理
+		if strings.HasPrefix(tokenStr, token.Prefix) {
+			t, err := tokenSvc.Authenticate(c.Request.Context(), tokenStr)
+			if err != nil {
+				response.Error(c, http.StatusUnauthorized, "UNAUTHORIZED", "invalid, expired or revoked token")
+				c.Abort()
+				return
+			}
+			if err := sessions.CheckActive(c.Request.Context(), t.UserID); err != nil {
+				response.Error(c, http.StatusUnauthorized, "UNAUTHORIZED", "account is disabled")
+				c.Abort()
+				return
+			}
+			c.Set("userID", t.UserID)
+			c.Set("authMethod", AuthMethodPAT)
+			c.Set("token", t)
+			c.Next()
+			return
+		}
+
 		claims, err := jwtutil.ParseToken(tokenStr)
 		if err != nil {
 			response.Error(c, http.StatusUnauthorized, "UNAUTHORIZED", "invalid or expired token")
 			c.Abort()
 			return
 		}
+		if err := sessions.CheckSession(c.Request.Context(), claims.UserID, claims.ID); err != nil {
+			response.Error(c, http.StatusUnauthorized, "UNAUTHORIZED", "session has been revoked")
+			c.Abort()
+			return
+		}
 
 		// 把userID放进context，后面的handler可以取出来用
 		c.Set("userID", claims.UserID)
+		c.Set("authMethod", AuthMethodJWT)
+		c.Set("sessionID", claims.ID)
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// BasicAuthMiddleware 给CalDAV这类只支持HTTP Basic认证的客户端用：用户名任意，密码是个人访问令牌。
+// 也接受Bearer，按AuthMiddleware处理；失败时带上WWW-Authenticate，客户端才会提示输入密码
+func BasicAuthMiddleware(tokenSvc token.Service, sessions SessionChecker) gin.HandlerFunc {
+	bearer := AuthMiddleware(tokenSvc, sessions)
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+			bearer(c)
+			return
+		}
+
+		unauthorized := func(msg string) {
+			c.Header("WWW-Authenticate", `Basic realm="Tasker", charset="UTF-8"`)
+			response.Error(c, http.StatusUnauthorized, "UNAUTHORIZED", msg)
+			c.Abort()
+		}
+		_, password, ok := c.Request.BasicAuth()
+		if !ok {
+			unauthorized("missing Authorization header")
+			return
+		}
+		if !strings.HasPrefix(password, token.Prefix) {
+			unauthorized("use a personal access token as the password")
+			return
+		}
+		t, err := tokenSvc.Authenticate(c.Request.Context(), password)
+		if err != nil {
+			unauthorized("invalid, expired or revoked token")
+			return
+		}
+		if err := sessions.CheckActive(c.Request.Context(), t.UserID); err != nil {
+			unauthorized("account is disabled")
+			return
+		}
+		c.Set("userID", t.UserID)
+		c.Set("authMethod", AuthMethodPAT)
+		c.Set("token", t)
+		c.Next()
+	}
+}
+
+// RequireScope 要求个人访问令牌带有指定scope；JWT登录态不受限制
+func RequireScope(scope token.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != AuthMethodPAT {
+			c.Next()
+			return
+		}
+
+		v, _ := c.Get("token")
+		t, ok := v.(*token.Token)
+		if !ok || !t.HasScope(scope) {
+			response.Error(c, http.StatusForbidden, "INSUFFICIENT_SCOPE", "token lacks required scope: "+string(scope))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession 只允许通过登录拿到的JWT访问，例如管理令牌本身的接口
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != AuthMethodJWT {
+			response.Error(c, http.StatusForbidden, "SESSION_REQUIRED", "this endpoint requires a login session")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminLookup 读取用户的系统角色，由user.Service实现
+type AdminLookup interface {
+	GetByID(ctx context.Context, id int64) (*user.User, error)
+}
+
+// AdminOnly 只允许系统角色为admin的用户访问
+func AdminOnly(users AdminLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		id, ok := userID.(int64)
+		if !ok {
+			response.Error(c, http.StatusForbidden, "FORBIDDEN", "admin only")
+			c.Abort()
+			return
+		}
+		u, err := users.GetByID(c.Request.Context(), id)
+		if err != nil || !u.IsAdmin() {
+			response.Error(c, http.StatusForbidden, "FORBIDDEN", "admin only")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,12 +1,35 @@
 package main
 
 import (
+	"context"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"tasker/api/handler"
+	"tasker/api/middleware"
+	"tasker/core/activity"
+	"tasker/core/admin"
+	"tasker/core/caldav"
+	"tasker/core/calendar"
+	"tasker/core/comment"
 	"tasker/core/group"
+	"tasker/core/mention"
+	"tasker/core/notification"
+	"tasker/core/sharelink"
 	"tasker/core/task"
+	"tasker/core/token"
+	"tasker/core/transfer"
 	"tasker/core/user"
+	"tasker/core/view"
+	"tasker/core/workspace"
 	"tasker/infra/db"
+	"tasker/infra/memory"
+	"tasker/pkg/clock"
+	"tasker/pkg/events"
+	"tasker/pkg/jwtutil"
+	"tasker/pkg/mailer"
 	"tasker/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +38,10 @@ import (
 
 func main() {
 	r := gin.Default()
+	// ClientIP只在请求来自可信代理时才采用X-Forwarded-For，否则登录限流的IP可以随意伪造
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("invalid TASKER_TRUSTED_PROXIES: %v", err)
+	}
 
 	r.Use(func(c *gin.Context) {
 	origin := c.GetHeader("Origin")
@@ -35,9 +62,10 @@ func main() {
 	}
 
 	c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 
-	if c.Request.Method == "OPTIONS" {
+	// CalDAV客户端用OPTIONS探测DAV能力，交给CalDAV路由处理
+	if c.Request.Method == "OPTIONS" && !strings.HasPrefix(c.Request.URL.Path, "/dav/") {
 		c.AbortWithStatus(204)
 		return
 	}
@@ -63,24 +91,106 @@ func main() {
 		response.Error(c, http.StatusBadRequest, "DEMO_ERROR", "this is a demo error")
 	})
 
+	// 加载JWT签名/验签密钥
+	if err := jwtutil.LoadFromEnv(); err != nil {
+		log.Fatalf("failed to load jwt keys: %v", err)
+	}
+
 	// 初始化数据库
 	var gormDB *gorm.DB = db.NewPostgresDB()
 
+	// 工作区：分组和任务的权限都按工作区成员校验
+	workspaceRepo := db.NewWorkspaceRepository(gormDB)
+	workspaceSvc := workspace.NewService(workspaceRepo)
+
 	// 初始化group service
 	groupRepo := db.NewGroupRepository(gormDB)
-	groupSvc := group.NewService(groupRepo)
+	groupSvc := group.NewService(groupRepo, workspaceSvc)
 
 	// User相关
 	userRepo := db.NewUserRepository(gormDB)
-	userSvc := user.NewService(userRepo)
+	limiterStore := newLimiterStore(gormDB)
+	userSvc := user.NewService(userRepo,
+		user.WithLimiter(limiterStore, user.DefaultLimiterPolicy()),
+		user.WithPasswordPolicy(newPasswordPolicy()),
+		user.WithMailer(mailer.NewLogMailer(), resetURL()),
+		user.WithGroups(groupSvc),
+	)
+
+	// 个人访问令牌，认证中间件同时接受JWT和令牌
+	tokenRepo := db.NewTokenRepository(gormDB)
+	tokenSvc := token.NewService(tokenRepo)
+	auth := middleware.AuthMiddleware(tokenSvc, userSvc)
+	tokenHandler := handler.NewTokenHandler(tokenSvc)
+	tokenHandler.RegisterRoutes(r, auth)
+
 	userHandler := handler.NewAuthHandler(userSvc)
-	userHandler.RegisterRoutes(r)
+	userHandler.RegisterRoutes(r, auth)
+	meHandler := handler.NewUserHandler(userSvc)
+	meHandler.RegisterRoutes(r, auth)
+	adminRepo := db.NewAdminRepository(gormDB)
+	adminSvc := admin.NewService(adminRepo, userSvc)
+	promoteLegacyAdmins(adminSvc)
+	adminHandler := handler.NewAdminHandler(userSvc, adminSvc)
+	adminHandler.RegisterRoutes(r, auth)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceSvc)
+	workspaceHandler.RegisterRoutes(r, auth)
+	groupHandler := handler.NewGroupHandler(groupSvc)
+	groupHandler.RegisterRoutes(r, auth)
+
+	// 进程内事件总线，任务指派等事件生成通知
+	bus := events.NewBus()
+	notificationRepo := db.NewNotificationRepository(gormDB)
+	notificationSvc := notification.NewService(notificationRepo)
+	notificationSvc.Subscribe(bus)
+	notificationHandler := handler.NewNotificationHandler(notificationSvc)
+	notificationHandler.RegisterRoutes(r, auth)
 
 	// 初始化 Repository Service Handler
 	taskRepo := db.NewTaskRepository(gormDB)
-	taskSvc := task.NewService(taskRepo, groupSvc)
+	mentionRepo := db.NewMentionRepository(gormDB)
+	mentionSvc := mention.NewService(mentionRepo, groupSvc, workspaceSvc, mention.WithEvents(bus))
+	taskSvc := task.NewService(taskRepo, groupSvc, userSvc, workspaceSvc, task.WithEvents(bus), task.WithMentions(mentionSvc))
 	taskHandler := handler.NewTaskHandler(taskSvc)
-	taskHandler.RegisterRoutes(r)
+	taskHandler.RegisterRoutes(r, auth)
+
+	commentRepo := db.NewCommentRepository(gormDB)
+	commentSvc := comment.NewService(commentRepo, taskSvc, groupSvc, mentionSvc, comment.WithEvents(bus))
+	commentHandler := handler.NewCommentHandler(commentSvc)
+	commentHandler.RegisterRoutes(r, auth)
+
+	activityRepo := db.NewActivityRepository(gormDB)
+	activitySvc := activity.NewService(activityRepo, groupSvc, groupRepo, workspaceSvc, userSvc)
+	activitySvc.Subscribe(bus)
+	activityHandler := handler.NewActivityHandler(activitySvc)
+	activityHandler.RegisterRoutes(r, auth)
+
+	shareLinkRepo := db.NewShareLinkRepository(gormDB)
+	shareLinkSvc := sharelink.NewService(shareLinkRepo, taskSvc, groupSvc, taskRepo, groupRepo,
+		sharelink.WithLimiter(user.NewLimiter(limiterStore, user.DefaultLimiterPolicy(), clock.Real)),
+	)
+	shareLinkHandler := handler.NewShareLinkHandler(shareLinkSvc)
+	shareLinkHandler.RegisterRoutes(r, auth)
+
+	transferRepo := db.NewTransferRepository(gormDB)
+	transferSvc := transfer.NewService(transferRepo, workspaceSvc, userSvc, groupSvc, taskSvc)
+	transferHandler := handler.NewTransferHandler(transferSvc)
+	transferHandler.RegisterRoutes(r, auth)
+
+	calendarRepo := db.NewCalendarRepository(gormDB)
+	calendarSvc := calendar.NewService(calendarRepo, groupSvc, userSvc)
+	calendarHandler := handler.NewCalendarHandler(calendarSvc)
+	calendarHandler.RegisterRoutes(r, auth)
+
+	caldavRepo := db.NewCalDAVRepository(gormDB)
+	caldavSvc := caldav.NewService(caldavRepo, taskSvc, taskRepo, groupSvc, groupRepo)
+	caldavHandler := handler.NewCalDAVHandler(caldavSvc, userSvc)
+	caldavHandler.RegisterRoutes(r, middleware.BasicAuthMiddleware(tokenSvc, userSvc))
+
+	viewRepo := db.NewViewRepository(gormDB)
+	viewSvc := view.NewService(viewRepo, taskSvc, groupSvc)
+	viewHandler := handler.NewViewHandler(viewSvc)
+	viewHandler.RegisterRoutes(r, auth)
 
 	// // task模块挂载（内存版）
 	// taskSvc := task.NewMemoryService()
@@ -90,3 +200,66 @@ func main() {
 	r.Run(":8080")
 }
 
+// 可信的反向代理，TASKER_TRUSTED_PROXIES 为逗号分隔的IP或CIDR；默认不信任任何代理，
+// 直接用连接的对端地址作为客户端IP
+func trustedProxies() []string {
+	var proxies []string
+	for _, s := range strings.Split(os.Getenv("TASKER_TRUSTED_PROXIES"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			proxies = append(proxies, s)
+		}
+	}
+	return proxies
+}
+
+// 登录失败计数默认存Postgres，多副本共享；TASKER_LIMITER_STORE=memory 时只在本进程生效
+func newLimiterStore(gormDB *gorm.DB) user.LimiterStore {
+	if os.Getenv("TASKER_LIMITER_STORE") == "memory" {
+		return memory.NewLimiterStore()
+	}
+	return db.NewLimiterStore(gormDB)
+}
+
+// 默认密码规则，TASKER_BREACHED_PASSWORDS_FILE 可追加泄漏密码列表（每行一个）
+func newPasswordPolicy() user.PasswordPolicy {
+	policy := user.DefaultPasswordPolicy()
+	if path := os.Getenv("TASKER_BREACHED_PASSWORDS_FILE"); path != "" {
+		if err := policy.LoadBreachedList(path); err != nil {
+			log.Fatalf("failed to load breached password list: %v", err)
+		}
+	}
+	return policy
+}
+
+// 找回密码邮件里的前端重置页面地址
+func resetURL() string {
+	if u := os.Getenv("TASKER_RESET_URL"); u != "" {
+		return u
+	}
+	return "http://localhost:5173/reset-password"
+}
+
+// 管理员以前由 TASKER_ADMIN_USER_IDS 指定，现在改为用户的系统角色；
+// 启动时把列表里的用户升级为admin，之后可以删掉这个环境变量。
+// 每个用户只迁移一次：审计里已有角色变更的跳过，被降级的管理员重启后不会恢复
+func promoteLegacyAdmins(adminSvc admin.Service) {
+	ctx := context.Background()
+	for _, s := range strings.Split(os.Getenv("TASKER_ADMIN_USER_IDS"), ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			continue
+		}
+		history, err := adminSvc.ListAudit(ctx, admin.AuditFilter{TargetUserID: &id, Action: admin.ActionSetRole, Limit: 1})
+		if err != nil {
+			log.Printf("failed to check role history of user %d: %v", id, err)
+			continue
+		}
+		if len(history) > 0 {
+			continue
+		}
+		// 记为命令行操作，审计里能看到这次升级
+		if _, err := adminSvc.SetRole(ctx, admin.CLIActor, id, user.RoleAdmin); err != nil {
+			log.Printf("failed to promote user %d to admin: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"tasker/core/admin"
+	"tasker/core/user"
+	"tasker/infra/db"
+	"tasker/pkg/mailer"
+)
+
+const adminUsage = `usage: tasker admin <action> [flags] [username]
+
+actions:
+  users [-q text] [-role user|admin] [-disabled true|false] [-page n]
+                        list and search users
+  show <username>       show one user
+  promote <username>    give the admin role
+  demote <username>     take the admin role away
+  disable <username>    disable the account and sign it out everywhere
+  enable <username>     re-enable a disabled account
+  reset-password <username>
+                        require a password reset and print a one-time reset token
+  stats                 user, workspace and task counts and database size
+  audit [-user username] [-limit n]
+                        show the admin audit log
+
+Actions are recorded in the audit log with an empty actor.
+`
+
+// adminCLI 命令行用到的依赖，和服务端一样通过repository访问数据库
+type adminCLI struct {
+	users    user.Service
+	userRepo *db.UserRepository
+	admin    admin.Service
+}
+
+func runAdmin(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, adminUsage)
+		return 2
+	}
+
+	gormDB := db.NewPostgresDB()
+	userRepo := db.NewUserRepository(gormDB)
+	resetURL := os.Getenv("TASKER_RESET_URL")
+	if resetURL == "" {
+		resetURL = "http://localhost:5173/reset-password"
+	}
+	userSvc := user.NewService(userRepo, user.WithMailer(mailer.NewLogMailer(), resetURL))
+	cli := &adminCLI{
+		users:    userSvc,
+		userRepo: userRepo,
+		admin:    admin.NewService(db.NewAdminRepository(gormDB), userSvc),
+	}
+
+	if err := cli.run(context.Background(), args[0], args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+func (a *adminCLI) run(ctx context.Context, action string, args []string) error {
+	switch action {
+	case "users":
+		return a.listUsers(ctx, args)
+	case "stats":
+		st, err := a.admin.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(st)
+	case "audit":
+		return a.listAudit(ctx, args)
+	}
+
+	// 其余动作都针对一个用户
+	if len(args) != 1 {
+		return fmt.Errorf("%s needs exactly one username", action)
+	}
+	u, err := a.userRepo.GetByUsername(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "show":
+		u, err = a.admin.GetUser(ctx, u.ID)
+	case "promote":
+		u, err = a.admin.SetRole(ctx, admin.CLIActor, u.ID, user.RoleAdmin)
+	case "demote":
+		u, err = a.admin.SetRole(ctx, admin.CLIActor, u.ID, user.RoleUser)
+	case "disable":
+		u, err = a.admin.Disable(ctx, admin.CLIActor, u.ID)
+	case "enable":
+		u, err = a.admin.Enable(ctx, admin.CLIActor, u.ID)
+	case "reset-password":
+		res, err := a.admin.ForcePasswordReset(ctx, admin.CLIActor, u.ID)
+		if err != nil {
+			return err
+		}
+		return printJSON(res)
+	default:
+		fmt.Fprint(os.Stderr, adminUsage)
+		return fmt.Errorf("unknown action %q", action)
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(u)
+}
+
+func (a *adminCLI) listUsers(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("users", flag.ContinueOnError)
+	query := fs.String("q", "", "match username or email")
+	role := fs.String("role", "", "user or admin")
+	disabled := fs.String("disabled", "", "true or false")
+	page := fs.Int("page", 1, "page number")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := admin.UserFilter{Query: *query, Role: user.Role(*role), Page: *page, PageSize: 50}
+	if *disabled != "" {
+		v, err := strconv.ParseBool(*disabled)
+		if err != nil {
+			return fmt.Errorf("-disabled must be true or false")
+		}
+		filter.Disabled = &v
+	}
+	list, err := a.admin.ListUsers(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLE\tSTATUS\tCREATED")
+	for _, u := range list.Items {
+		status := "active"
+		if u.DisabledAt != nil {
+			status = "disabled"
+		} else if u.PasswordResetRequired {
+			status = "reset required"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Username, u.Email, u.Role, status, u.CreatedAt.Format("2006-01-02"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("page %d, %d of %d users\n", list.Page, len(list.Items), list.Total)
+	return nil
+}
+
+func (a *adminCLI) listAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	username := fs.String("user", "", "only entries about this user")
+	limit := fs.Int("limit", 50, "number of entries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := admin.AuditFilter{Limit: *limit}
+	if *username != "" {
+		u, err := a.userRepo.GetByUsername(ctx, *username)
+		if err != nil {
+			return err
+		}
+		filter.TargetUserID = &u.ID
+	}
+	items, err := a.admin.ListAudit(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTOR\tACTION\tTARGET\tDETAIL")
+	for _, e := range items {
+		actor := "cli"
+		if e.ActorID != nil {
+			actor = strconv.FormatInt(*e.ActorID, 10)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", e.CreatedAt.Format("2006-01-02 15:04:05"), actor, e.Action, e.TargetUserID, e.Detail)
+	}
+	return w.Flush()
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
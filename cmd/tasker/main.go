@@ -0,0 +1,31 @@
+package main
+
+/*
+tasker 命令行：直接连数据库操作，HTTP服务挂掉时也能用。
+数据库连接和服务端相同（infra/db.NewPostgresDB）
+*/
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: tasker <command> [arguments]
+
+commands:
+  admin    manage users without the HTTP server (run "tasker admin" for details)
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "admin":
+		os.Exit(runAdmin(os.Args[2:]))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
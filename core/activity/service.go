@@ -0,0 +1,244 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasker/core/comment"
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/core/user"
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+	"tasker/pkg/events"
+)
+
+// 动态类型
+const (
+	TypeCreated   = "created"
+	TypeCompleted = "completed"
+	TypeReopened  = "reopened"
+	TypeMoved     = "moved"
+	TypeCommented = "commented"
+	TypeAssigned  = "assigned"
+)
+
+// 默认和最大每页条数
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Activity 一条动态，由业务事件生成，写入后不再修改
+type Activity struct {
+	ID            int64  `json:"id"`
+	WorkspaceID   int64  `json:"workspace_id"`
+	GroupID       *int64 `json:"group_id"`
+	TaskID        *int64 `json:"task_id"`
+	ActorID       int64  `json:"actor_id"`
+	ActorUsername string `json:"actor_username"`
+	Type          string `json:"type"`
+	// 不含操作人的描述，例如 completed "写周报"；返回时在前面拼上操作人
+	Summary   string    `json:"summary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListFilter struct {
+	WorkspaceID *int64 `json:"workspace_id"`
+	GroupID     *int64 `json:"group_id"`
+	ActorID     *int64 `json:"actor_id"`
+	Type        string `json:"type"`
+	// 上一页返回的next_cursor，空表示从最新的开始
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+
+	// 由service解析Cursor得到，只返回id小于它的动态
+	BeforeID int64 `json:"-"`
+}
+
+type Page struct {
+	Items []*Activity `json:"items"`
+	// 没有更多时为null
+	NextCursor *string `json:"next_cursor"`
+}
+
+// Repository 抽象动态的持久化
+type Repository interface {
+	Create(ctx context.Context, a *Activity) error
+	// List 返回用户能看到的动态，按id倒序，最多filter.Limit条
+	List(ctx context.Context, userID int64, filter ListFilter) ([]*Activity, error)
+}
+
+// Service 动态流相关业务
+type Service interface {
+	List(ctx context.Context, userID int64, filter ListFilter) (*Page, error)
+
+	// Subscribe 订阅任务和评论事件，生成动态
+	Subscribe(bus *events.Bus)
+}
+
+// UserLookup 生成指派动态时读取负责人的用户名
+type UserLookup interface {
+	GetByID(ctx context.Context, id int64) (*user.User, error)
+}
+
+type service struct {
+	repo       Repository
+	groups     group.Service
+	groupRepo  group.Repository
+	workspaces workspace.Service
+	users      UserLookup
+}
+
+func NewService(repo Repository, groups group.Service, groupRepo group.Repository, workspaces workspace.Service, users UserLookup) Service {
+	return &service{repo: repo, groups: groups, groupRepo: groupRepo, workspaces: workspaces, users: users}
+}
+
+func validType(t string) bool {
+	switch t {
+	case TypeCreated, TypeCompleted, TypeReopened, TypeMoved, TypeCommented, TypeAssigned:
+		return true
+	}
+	return false
+}
+
+func (s *service) List(ctx context.Context, userID int64, filter ListFilter) (*Page, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	if filter.Limit > MaxLimit {
+		return nil, apperror.New("INVALID_LIMIT", "limit must be at most 200")
+	}
+	if filter.Type != "" && !validType(filter.Type) {
+		return nil, apperror.New("INVALID_TYPE", "type must be created, completed, reopened, moved, commented or assigned")
+	}
+	if filter.Cursor != "" {
+		id, err := strconv.ParseInt(filter.Cursor, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, apperror.New("INVALID_CURSOR", "cursor is invalid")
+		}
+		filter.BeforeID = id
+	}
+
+	// 指定了工作区或分组时先确认能访问，repo只会返回用户能看到的动态
+	if filter.WorkspaceID != nil {
+		if _, err := s.workspaces.Authorize(ctx, userID, *filter.WorkspaceID, workspace.RoleViewer); err != nil {
+			return nil, err
+		}
+	}
+	if filter.GroupID != nil {
+		if _, err := s.groups.Authorize(ctx, userID, *filter.GroupID, group.AccessRead); err != nil {
+			return nil, err
+		}
+	}
+
+	// 多取一条判断是否还有下一页
+	limit := filter.Limit
+	filter.Limit = limit + 1
+	items, err := s.repo.List(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		next := strconv.FormatInt(page.Items[limit-1].ID, 10)
+		page.NextCursor = &next
+	}
+	for _, a := range page.Items {
+		actor := a.ActorUsername
+		if actor == "" {
+			actor = "A deleted user"
+		}
+		a.Summary = actor + " " + a.Summary
+	}
+	return page, nil
+}
+
+func (s *service) Subscribe(bus *events.Bus) {
+	bus.Subscribe(task.EventCreated, s.onTaskChanged(TypeCreated))
+	bus.Subscribe(task.EventCompleted, s.onTaskChanged(TypeCompleted))
+	bus.Subscribe(task.EventReopened, s.onTaskChanged(TypeReopened))
+	bus.Subscribe(task.EventMoved, s.onTaskChanged(TypeMoved))
+	bus.Subscribe(task.EventAssigned, s.onTaskAssigned)
+	bus.Subscribe(comment.EventCreated, s.onCommentCreated)
+}
+
+func (s *service) onTaskChanged(typ string) events.Handler {
+	return func(ctx context.Context, e events.Event) {
+		payload, ok := e.Payload.(task.ChangedEvent)
+		if !ok {
+			return
+		}
+		var summary string
+		switch typ {
+		case TypeMoved:
+			summary = fmt.Sprintf("moved %q from %s to %s", payload.Title, s.groupName(ctx, payload.FromGroupID), s.groupName(ctx, payload.GroupID))
+		default:
+			summary = fmt.Sprintf("%s %q", typ, payload.Title)
+		}
+		s.record(ctx, e, typ, payload.TaskID, payload.GroupID, summary)
+	}
+}
+
+func (s *service) onTaskAssigned(ctx context.Context, e events.Event) {
+	payload, ok := e.Payload.(task.AssignedEvent)
+	if !ok {
+		return
+	}
+	names := make([]string, 0, len(payload.AssigneeIDs))
+	for _, id := range payload.AssigneeIDs {
+		if id == e.ActorID {
+			names = append(names, "themselves")
+			continue
+		}
+		u, err := s.users.GetByID(ctx, id)
+		if err != nil {
+			names = append(names, "user "+strconv.FormatInt(id, 10))
+			continue
+		}
+		names = append(names, u.Username)
+	}
+	summary := fmt.Sprintf("assigned %q to %s", payload.Title, strings.Join(names, ", "))
+	s.record(ctx, e, TypeAssigned, payload.TaskID, payload.GroupID, summary)
+}
+
+func (s *service) onCommentCreated(ctx context.Context, e events.Event) {
+	payload, ok := e.Payload.(comment.CreatedEvent)
+	if !ok {
+		return
+	}
+	s.record(ctx, e, TypeCommented, payload.TaskID, payload.GroupID, fmt.Sprintf("commented on %q", payload.Title))
+}
+
+// groupName 生成描述时的分组名，分组不存在时给出占位
+func (s *service) groupName(ctx context.Context, id *int64) string {
+	if id == nil {
+		return "no group"
+	}
+	g, err := s.groupRepo.GetByID(ctx, *id)
+	if err != nil {
+		return "a deleted group"
+	}
+	return strconv.Quote(g.Name)
+}
+
+func (s *service) record(ctx context.Context, e events.Event, typ string, taskID int64, groupID *int64, summary string) {
+	a := &Activity{
+		WorkspaceID: e.WorkspaceID,
+		GroupID:     groupID,
+		TaskID:      &taskID,
+		ActorID:     e.ActorID,
+		Type:        typ,
+		Summary:     summary,
+		CreatedAt:   e.At,
+	}
+	if err := s.repo.Create(ctx, a); err != nil {
+		log.Printf("[activity] failed to record %s for task %d: %v", e.Type, taskID, err)
+	}
+}
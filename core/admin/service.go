@@ -0,0 +1,234 @@
+package admin
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+)
+
+// CLIActor 通过 tasker admin 命令行操作时的操作人，审计里记为空
+const CLIActor int64 = 0
+
+// 审计动作
+const (
+	ActionSetRole     = "set_role"
+	ActionDisable     = "disable"
+	ActionEnable      = "enable"
+	ActionForceReset  = "force_password_reset"
+	ActionImpersonate = "impersonate"
+)
+
+// AuditEntry 一条管理操作记录，只追加不修改
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	// 命令行操作时为null
+	ActorID      *int64    `json:"actor_id"`
+	Action       string    `json:"action"`
+	TargetUserID int64     `json:"target_user_id"`
+	Detail       string    `json:"detail"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type UserFilter struct {
+	// 按用户名或邮箱模糊匹配
+	Query    string    `json:"query"`
+	Role     user.Role `json:"role"`
+	Disabled *bool     `json:"disabled"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+}
+
+type UserList struct {
+	Items    []*user.User `json:"items"`
+	Total    int64        `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+}
+
+type AuditFilter struct {
+	TargetUserID *int64 `json:"target_user_id"`
+	// 为空时不过滤
+	Action string `json:"action"`
+	Limit  int    `json:"limit"`
+}
+
+// Stats 系统概况
+type Stats struct {
+	Users         int64 `json:"users"`
+	Admins        int64 `json:"admins"`
+	DisabledUsers int64 `json:"disabled_users"`
+	Workspaces    int64 `json:"workspaces"`
+	Tasks         int64 `json:"tasks"`
+	// 当前数据库占用的字节数
+	DBSizeBytes int64 `json:"db_size_bytes"`
+}
+
+// Impersonation 代登录开启的会话，由handler签发JWT
+type Impersonation struct {
+	User    *user.User    `json:"user"`
+	Session *user.Session `json:"session"`
+}
+
+type ImpersonateInput struct {
+	// 必填，写进审计记录
+	Reason string `json:"reason"`
+}
+
+// Repository 管理查询和审计记录
+type Repository interface {
+	SearchUsers(ctx context.Context, filter UserFilter) ([]*user.User, int64, error)
+	Stats(ctx context.Context) (*Stats, error)
+	CreateAudit(ctx context.Context, e *AuditEntry) error
+	ListAudit(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+// Service 管理员业务，HTTP接口和命令行共用。actorID为CLIActor表示命令行
+type Service interface {
+	ListUsers(ctx context.Context, filter UserFilter) (*UserList, error)
+	GetUser(ctx context.Context, id int64) (*user.User, error)
+	SetRole(ctx context.Context, actorID, userID int64, role user.Role) (*user.User, error)
+	Disable(ctx context.Context, actorID, userID int64) (*user.User, error)
+	Enable(ctx context.Context, actorID, userID int64) (*user.User, error)
+	ForcePasswordReset(ctx context.Context, actorID, userID int64) (*user.ForcedReset, error)
+	Impersonate(ctx context.Context, actorID, userID int64, in ImpersonateInput, meta user.SessionMeta) (*Impersonation, error)
+	Stats(ctx context.Context) (*Stats, error)
+	ListAudit(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+type service struct {
+	repo  Repository
+	users user.Service
+	clock clock.Clock
+}
+
+func NewService(repo Repository, users user.Service) Service {
+	return &service{repo: repo, users: users, clock: clock.Real}
+}
+
+func (s *service) ListUsers(ctx context.Context, filter UserFilter) (*UserList, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 20
+	}
+	if filter.PageSize > 100 {
+		return nil, apperror.New("INVALID_PAGE_SIZE", "page_size must be at most 100")
+	}
+	if filter.Role != "" && !filter.Role.Valid() {
+		return nil, apperror.New("INVALID_ROLE", "role must be user or admin")
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+
+	items, total, err := s.repo.SearchUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &UserList{Items: items, Total: total, Page: filter.Page, PageSize: filter.PageSize}, nil
+}
+
+func (s *service) GetUser(ctx context.Context, id int64) (*user.User, error) {
+	return s.users.GetByID(ctx, id)
+}
+
+func (s *service) SetRole(ctx context.Context, actorID, userID int64, role user.Role) (*user.User, error) {
+	if actorID == userID && role != user.RoleAdmin {
+		return nil, apperror.New("SELF_ACTION", "you cannot remove your own admin role")
+	}
+	u, err := s.users.SetRole(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	return u, s.audit(ctx, actorID, ActionSetRole, userID, "role="+string(role))
+}
+
+func (s *service) Disable(ctx context.Context, actorID, userID int64) (*user.User, error) {
+	if actorID == userID {
+		return nil, apperror.New("SELF_ACTION", "you cannot disable your own account")
+	}
+	u, err := s.users.SetDisabled(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	return u, s.audit(ctx, actorID, ActionDisable, userID, "")
+}
+
+func (s *service) Enable(ctx context.Context, actorID, userID int64) (*user.User, error) {
+	u, err := s.users.SetDisabled(ctx, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	return u, s.audit(ctx, actorID, ActionEnable, userID, "")
+}
+
+func (s *service) ForcePasswordReset(ctx context.Context, actorID, userID int64) (*user.ForcedReset, error) {
+	res, err := s.users.ForcePasswordReset(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return res, s.audit(ctx, actorID, ActionForceReset, userID, "emailed="+strconv.FormatBool(res.Emailed))
+}
+
+// Impersonate 以目标用户身份开启会话，不能代登录其他管理员或已停用的账号
+func (s *service) Impersonate(ctx context.Context, actorID, userID int64, in ImpersonateInput, meta user.SessionMeta) (*Impersonation, error) {
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" {
+		return nil, apperror.New("INVALID_REASON", "reason is required")
+	}
+	if len(reason) > 500 {
+		return nil, apperror.New("INVALID_REASON", "reason must be at most 500 bytes")
+	}
+	if actorID == userID {
+		return nil, apperror.New("SELF_ACTION", "you cannot impersonate yourself")
+	}
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsAdmin() {
+		return nil, apperror.New("ADMIN_TARGET", "admins cannot be impersonated")
+	}
+
+	// 先记审计再开会话，审计写不进去时不允许代登录
+	if err := s.audit(ctx, actorID, ActionImpersonate, userID, reason); err != nil {
+		return nil, err
+	}
+	meta.ImpersonatorID = &actorID
+	sess, err := s.users.StartSession(ctx, userID, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &Impersonation{User: u, Session: sess}, nil
+}
+
+func (s *service) Stats(ctx context.Context) (*Stats, error) {
+	return s.repo.Stats(ctx)
+}
+
+func (s *service) ListAudit(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 200 {
+		return nil, apperror.New("INVALID_LIMIT", "limit must be at most 200")
+	}
+	return s.repo.ListAudit(ctx, filter)
+}
+
+func (s *service) audit(ctx context.Context, actorID int64, action string, targetID int64, detail string) error {
+	e := &AuditEntry{
+		Action:       action,
+		TargetUserID: targetID,
+		Detail:       detail,
+		CreatedAt:    s.clock.Now(),
+	}
+	if actorID != CLIActor {
+		e.ActorID = &actorID
+	}
+	return s.repo.CreateAudit(ctx, e)
+}
@@ -0,0 +1,489 @@
+package caldav
+
+/*
+CalDAV（RFC 4791）的业务部分：每个分组是一个只包含VTODO的日历集合，
+每个任务是集合里的一个日历对象资源，资源名就是VTODO的UID。
+读写都经过group.Service和task.Service，权限、工作流校验和事件与HTTP接口一致
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/core/transfer"
+	"tasker/pkg/apperror"
+)
+
+// ContentType 日历对象资源的媒体类型
+const ContentType = "text/calendar; charset=utf-8; component=VTODO"
+
+// MaxObjectBytes PUT一个日历对象的最大字节数
+const MaxObjectBytes = 1 << 20
+
+const syncTokenPrefix = "urn:tasker:sync:"
+
+// Calendar 一个日历集合，对应一个分组
+type Calendar struct {
+	Group *group.Group
+	// 当前用户能否在集合里创建、修改和删除任务
+	Writable bool
+	// 集合内容的版本，也用作getctag
+	SyncToken string
+	seq       int64
+}
+
+// Object 一个日历对象资源，对应一个任务
+type Object struct {
+	// 资源名（不含.ics后缀），就是VTODO的UID
+	Name string
+	// 强ETag，带双引号，按内容计算
+	ETag      string
+	Data      []byte
+	UpdatedAt time.Time
+}
+
+// Precondition PUT和DELETE的条件请求头
+type Precondition struct {
+	IfMatch     string
+	IfNoneMatch string
+}
+
+// Removal 离开集合的任务：被删除、移到别的分组或者换了外部ID
+type Removal struct {
+	TaskID     int64
+	ExternalID string
+}
+
+// Repository 同步令牌需要的查询，任务本身的读写走task.Repository。
+// 分组里的任务每次新增、修改、删除或移入移出，分组的变更序号都加一，
+// 并记在任务或者离开记录上；同一个分组的序号按事务提交的顺序递增
+type Repository interface {
+	// SyncSeq 分组当前的变更序号
+	SyncSeq(ctx context.Context, groupID int64) (int64, error)
+	// ChangesSince 变更序号大于since的任务，以及序号大于since的离开记录
+	ChangesSince(ctx context.Context, groupID, since int64) ([]*task.Task, []Removal, error)
+}
+
+// Service CalDAV的集合和对象
+type Service interface {
+	// Calendars 用户能看到的全部分组
+	Calendars(ctx context.Context, userID int64) ([]*Calendar, error)
+	Calendar(ctx context.Context, userID, groupID int64) (*Calendar, error)
+	// Objects 集合里的全部对象
+	Objects(ctx context.Context, userID, groupID int64) (*Calendar, []*Object, error)
+	// Object 不存在时返回 OBJECT_NOT_FOUND
+	Object(ctx context.Context, userID, groupID int64, name string) (*Object, error)
+	// Changes sync-collection：令牌之后变化的对象和离开集合的资源名；token为空时返回全部对象，
+	// token无法使用时返回 INVALID_SYNC_TOKEN，客户端应全量同步
+	Changes(ctx context.Context, userID, groupID int64, token string) (cal *Calendar, changed []*Object, removed []string, err error)
+	// Put 创建或整体替换一个对象，created表示是新建的
+	Put(ctx context.Context, userID, groupID int64, name string, body io.Reader, pre Precondition) (obj *Object, created bool, err error)
+	Delete(ctx context.Context, userID, groupID int64, name string, pre Precondition) error
+}
+
+type service struct {
+	repo      Repository
+	tasks     task.Service
+	taskRepo  task.Repository
+	groups    group.Service
+	groupRepo group.Repository
+}
+
+func NewService(repo Repository, tasks task.Service, taskRepo task.Repository, groups group.Service, groupRepo group.Repository) Service {
+	return &service{
+		repo:      repo,
+		tasks:     tasks,
+		taskRepo:  taskRepo,
+		groups:    groups,
+		groupRepo: groupRepo,
+	}
+}
+
+func (s *service) Calendars(ctx context.Context, userID int64) ([]*Calendar, error) {
+	groups, err := s.groupRepo.GetListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cals := make([]*Calendar, 0, len(*groups))
+	for i := range *groups {
+		g := &(*groups)[i]
+		cal, err := s.calendarOf(ctx, userID, g)
+		if err != nil {
+			return nil, err
+		}
+		cals = append(cals, cal)
+	}
+	return cals, nil
+}
+
+func (s *service) Calendar(ctx context.Context, userID, groupID int64) (*Calendar, error) {
+	g, err := s.groups.Authorize(ctx, userID, groupID, group.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	return s.calendarOf(ctx, userID, g)
+}
+
+func (s *service) calendarOf(ctx context.Context, userID int64, g *group.Group) (*Calendar, error) {
+	seq, err := s.repo.SyncSeq(ctx, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	// 没有写权限（viewer或只读共享）时Authorize返回错误
+	_, werr := s.groups.Authorize(ctx, userID, g.ID, group.AccessWrite)
+	return &Calendar{
+		Group:     g,
+		Writable:  werr == nil,
+		SyncToken: syncToken(g.ID, seq),
+		seq:       seq,
+	}, nil
+}
+
+func (s *service) Objects(ctx context.Context, userID, groupID int64) (*Calendar, []*Object, error) {
+	cal, err := s.Calendar(ctx, userID, groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks, err := s.taskRepo.ListByGroup(ctx, groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	objs, err := objectsOf(tasks)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cal, objs, nil
+}
+
+func (s *service) Object(ctx context.Context, userID, groupID int64, name string) (*Object, error) {
+	g, err := s.groups.Authorize(ctx, userID, groupID, group.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.find(ctx, g, name)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || !inGroup(t, g) {
+		return nil, objectNotFound()
+	}
+	return objectOf(t)
+}
+
+// 同步令牌：urn:tasker:sync:<分组ID>:<变更序号>
+func syncToken(groupID, seq int64) string {
+	return fmt.Sprintf("%s%d:%d", syncTokenPrefix, groupID, seq)
+}
+
+func parseSyncToken(token string, groupID int64) (int64, bool) {
+	rest, ok := strings.CutPrefix(token, syncTokenPrefix)
+	if !ok {
+		return 0, false
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 2 || parts[0] != strconv.FormatInt(groupID, 10) {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || seq < 0 {
+		return 0, false
+	}
+	return seq, true
+}
+
+func (s *service) Changes(ctx context.Context, userID, groupID int64, token string) (*Calendar, []*Object, []string, error) {
+	if token == "" {
+		cal, objs, err := s.Objects(ctx, userID, groupID)
+		return cal, objs, nil, err
+	}
+	// 先读序号再查变化：查询期间提交的修改序号更大，可能一起返回，下次同步时还会再返回一次
+	cal, err := s.Calendar(ctx, userID, groupID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	since, ok := parseSyncToken(token, groupID)
+	if !ok || since > cal.seq {
+		return nil, nil, nil, invalidSyncToken()
+	}
+	if since == cal.seq {
+		return cal, []*Object{}, nil, nil
+	}
+
+	tasks, removals, err := s.repo.ChangesSince(ctx, groupID, since)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	objs, err := objectsOf(tasks)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// 移出后又移回来的任务还在集合里，不报告删除
+	seen := make(map[string]bool, len(objs)+len(removals))
+	for _, obj := range objs {
+		seen[obj.Name] = true
+	}
+	removed := make([]string, 0, len(removals))
+	for _, r := range removals {
+		name := transfer.ICSUID(&transfer.Record{ID: r.TaskID, ExternalID: r.ExternalID})
+		if !seen[name] {
+			seen[name] = true
+			removed = append(removed, name)
+		}
+	}
+	return cal, objs, removed, nil
+}
+
+func (s *service) Put(ctx context.Context, userID, groupID int64, name string, body io.Reader, pre Precondition) (*Object, bool, error) {
+	g, err := s.groups.Authorize(ctx, userID, groupID, group.AccessWrite)
+	if err != nil {
+		return nil, false, err
+	}
+	rec, err := parseObject(body)
+	if err != nil {
+		return nil, false, err
+	}
+	if rec.ExternalID != name {
+		return nil, false, apperror.New("INVALID_CALENDAR_OBJECT", "the resource name must be the UID of the VTODO")
+	}
+	title := strings.TrimSpace(rec.Title)
+	if title == "" {
+		return nil, false, apperror.New("INVALID_CALENDAR_OBJECT", "SUMMARY is required")
+	}
+
+	current, err := s.find(ctx, g, name)
+	if err != nil {
+		return nil, false, err
+	}
+	var existing *Object
+	if current != nil && inGroup(current, g) {
+		if existing, err = objectOf(current); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := checkPrecondition(existing, pre); err != nil {
+		return nil, false, err
+	}
+
+	wf := group.WorkflowOf(g)
+	if current == nil {
+		if _, generated := transfer.TaskIDFromICSUID(name); generated {
+			return nil, false, apperror.New("INVALID_CALENDAR_OBJECT", "UIDs of the form task-<id>@tasker are reserved")
+		}
+		// 客户端创建的是已完成的任务时一次写成完成状态
+		t, err := s.tasks.CreateTask(ctx, userID, task.CreateTaskInput{
+			Title:       title,
+			Description: rec.Description,
+			DueDate:     rec.DueDate,
+			DueOn:       rec.DueOn,
+			Priority:    rec.Priority,
+			GroupID:     &g.ID,
+			ExternalID:  name,
+			Category:    rec.Category,
+		})
+		if err != nil {
+			// 检查之后别人抢先用同一个UID创建了任务
+			if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "EXTERNAL_ID_EXISTS" && pre.IfNoneMatch == "*" {
+				return nil, false, preconditionFailed()
+			}
+			return nil, false, err
+		}
+		obj, err := objectOf(t)
+		return obj, true, err
+	}
+
+	// 状态大类没变时保留原来的状态，工作流里可能有多个同类状态
+	status := current.Status
+	if rec.Category != "" && rec.Category != current.StatusCategory {
+		status = task.Status(wf.Map("", rec.Category).Key)
+	}
+	in := task.UpdateTaskInput{
+		Title:       title,
+		Description: rec.Description,
+		Status:      status,
+		ReplaceDue:  true,
+		DueDate:     rec.DueDate,
+		DueOn:       rec.DueOn,
+		Priority:    rec.Priority,
+	}
+	// 同一工作区另一个分组里的同一个UID：客户端把任务移到了这个集合
+	if !inGroup(current, g) {
+		in.GroupID = &g.ID
+	}
+	// 带条件的写入在UPDATE里再比一次变更序号，检查之后被别人改过的返回PRECONDITION_FAILED
+	if pre.IfMatch != "" || pre.IfNoneMatch != "" {
+		in.IfChangeSeq = &current.ChangeSeq
+	}
+	t, err := s.tasks.UpdateTask(ctx, userID, current.ID, in)
+	if err != nil {
+		return nil, false, err
+	}
+	obj, err := objectOf(t)
+	return obj, existing == nil, err
+}
+
+func (s *service) Delete(ctx context.Context, userID, groupID int64, name string, pre Precondition) error {
+	g, err := s.groups.Authorize(ctx, userID, groupID, group.AccessWrite)
+	if err != nil {
+		return err
+	}
+	t, err := s.find(ctx, g, name)
+	if err != nil {
+		return err
+	}
+	if t == nil || !inGroup(t, g) {
+		return objectNotFound()
+	}
+	obj, err := objectOf(t)
+	if err != nil {
+		return err
+	}
+	if err := checkPrecondition(obj, pre); err != nil {
+		return err
+	}
+	return s.tasks.DeleteTask(ctx, userID, t.ID)
+}
+
+// find 按资源名在分组所在的工作区里找任务：先按外部ID，再按生成的UID；没有时返回nil
+func (s *service) find(ctx context.Context, g *group.Group, name string) (*task.Task, error) {
+	t, err := s.taskRepo.GetByExternalID(ctx, g.WorkspaceID, name)
+	if err != nil || t != nil {
+		return t, err
+	}
+	id, ok := transfer.TaskIDFromICSUID(name)
+	if !ok {
+		return nil, nil
+	}
+	t, err = s.taskRepo.GetByIDUnscoped(ctx, id)
+	if err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "TASK_NOT_FOUND" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if t.WorkspaceID != g.WorkspaceID || t.ExternalID != "" {
+		return nil, nil
+	}
+	return t, nil
+}
+
+func inGroup(t *task.Task, g *group.Group) bool {
+	return t.GroupID != nil && *t.GroupID == g.ID
+}
+
+// checkPrecondition If-None-Match: * 要求资源不存在；If-Match 要求资源存在且ETag一致
+func checkPrecondition(existing *Object, pre Precondition) error {
+	failed := preconditionFailed()
+	if pre.IfNoneMatch == "*" && existing != nil {
+		return failed
+	}
+	if pre.IfMatch != "" {
+		if existing == nil {
+			return failed
+		}
+		if pre.IfMatch != "*" && !etagListContains(pre.IfMatch, existing.ETag) {
+			return failed
+		}
+	}
+	return nil
+}
+
+func etagListContains(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		if strings.TrimSpace(v) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseObject 一个日历对象资源只能包含一个VTODO
+func parseObject(body io.Reader) (*transfer.Record, error) {
+	f, _ := transfer.Lookup("ics")
+	doc, err := f.Decode(body)
+	if err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok {
+			return nil, apperror.New("INVALID_CALENDAR_DATA", appErr.Message)
+		}
+		return nil, err
+	}
+	switch {
+	case len(doc.Tasks) == 0:
+		return nil, apperror.New("UNSUPPORTED_COMPONENT", "only VTODO components are supported")
+	case len(doc.Tasks) > 1:
+		return nil, apperror.New("INVALID_CALENDAR_OBJECT", "a calendar object resource must contain exactly one VTODO")
+	case len(doc.Errors) > 0:
+		e := doc.Errors[0]
+		return nil, apperror.New("INVALID_CALENDAR_DATA", e.Field+" "+e.Message)
+	}
+	return doc.Tasks[0], nil
+}
+
+func objectsOf(tasks []*task.Task) ([]*Object, error) {
+	objs := make([]*Object, 0, len(tasks))
+	for _, t := range tasks {
+		obj, err := objectOf(t)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// objectOf 把任务写成只有一个VTODO的日历对象。分组就是集合本身，不写CATEGORIES
+func objectOf(t *task.Task) (*Object, error) {
+	createdAt, updatedAt := t.CreatedAt, t.UpdatedAt
+	rec := &transfer.Record{
+		ID:          t.ID,
+		ExternalID:  t.ExternalID,
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      string(t.Status),
+		Category:    t.StatusCategory,
+		Priority:    t.Priority,
+		DueDate:     t.DueDate,
+		DueOn:       t.DueOn,
+		CompletedAt: t.CompletedAt,
+		CreatedAt:   &createdAt,
+		UpdatedAt:   &updatedAt,
+	}
+	var buf bytes.Buffer
+	enc := transfer.NewICSEncoder(&buf, transfer.ICSOptions{Object: true})
+	if err := enc.Begin(nil); err != nil {
+		return nil, err
+	}
+	if err := enc.Task(rec); err != nil {
+		return nil, err
+	}
+	if err := enc.End(); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return &Object{
+		Name:      transfer.ICSUID(rec),
+		ETag:      `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Data:      buf.Bytes(),
+		UpdatedAt: t.UpdatedAt,
+	}, nil
+}
+
+func objectNotFound() error {
+	return apperror.New("OBJECT_NOT_FOUND", "calendar object not found")
+}
+
+func invalidSyncToken() error {
+	return apperror.New("INVALID_SYNC_TOKEN", "the sync token is no longer valid, do a full sync")
+}
+
+func preconditionFailed() error {
+	return apperror.New("PRECONDITION_FAILED", "the resource has been changed or does not match the condition")
+}
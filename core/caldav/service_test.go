@@ -0,0 +1,144 @@
+package caldav
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/pkg/apperror"
+)
+
+const testGroupID = int64(7)
+
+type fakeGroups struct{ group.Service }
+
+func (fakeGroups) Authorize(ctx context.Context, userID, groupID int64, need group.Access) (*group.Group, error) {
+	return &group.Group{ID: testGroupID, WorkspaceID: 1, Name: "工作"}, nil
+}
+
+type fakeTaskRepo struct {
+	task.Repository
+	byExternalID map[string]*task.Task
+}
+
+func (r *fakeTaskRepo) GetByExternalID(ctx context.Context, workspaceID int64, externalID string) (*task.Task, error) {
+	return r.byExternalID[externalID], nil
+}
+
+// fakeTasks 记下收到的入参；seq是任务在数据库里当前的变更序号，条件写入按它判断
+type fakeTasks struct {
+	task.Service
+	creates   []task.CreateTaskInput
+	updates   []task.UpdateTaskInput
+	seq       int64
+	createErr error
+}
+
+func (f *fakeTasks) CreateTask(ctx context.Context, userID int64, in task.CreateTaskInput) (*task.Task, error) {
+	f.creates = append(f.creates, in)
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	gid := testGroupID
+	return &task.Task{ID: 1, GroupID: &gid, Title: in.Title, ExternalID: in.ExternalID, StatusCategory: in.Category}, nil
+}
+
+func (f *fakeTasks) UpdateTask(ctx context.Context, userID, id int64, in task.UpdateTaskInput) (*task.Task, error) {
+	f.updates = append(f.updates, in)
+	if in.IfChangeSeq != nil && *in.IfChangeSeq != f.seq {
+		return nil, apperror.New("PRECONDITION_FAILED", "the resource has been changed or does not match the condition")
+	}
+	gid := testGroupID
+	return &task.Task{ID: id, GroupID: &gid, Title: in.Title, Status: in.Status}, nil
+}
+
+func vtodo(uid, summary, extra string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VTODO\r\n" +
+		"UID:" + uid + "\r\nSUMMARY:" + summary + "\r\n" + extra +
+		"END:VTODO\r\nEND:VCALENDAR\r\n"
+}
+
+func wantCode(t *testing.T, err error, code string) {
+	t.Helper()
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != code {
+		t.Fatalf("err = %v, want %s", err, code)
+	}
+}
+
+func TestPutCreatesCompletedTaskInOneCall(t *testing.T) {
+	tasks := &fakeTasks{}
+	svc := NewService(nil, tasks, &fakeTaskRepo{}, fakeGroups{}, nil)
+
+	body := vtodo("done@phone", "交报告", "STATUS:COMPLETED\r\nCOMPLETED:20261019T080000Z\r\n")
+	_, created, err := svc.Put(context.Background(), 1, testGroupID, "done@phone", strings.NewReader(body), Precondition{})
+	if err != nil || !created {
+		t.Fatalf("Put = %v, created %v", err, created)
+	}
+	if len(tasks.creates) != 1 || len(tasks.updates) != 0 {
+		t.Fatalf("CreateTask called %d times, UpdateTask %d times", len(tasks.creates), len(tasks.updates))
+	}
+	if in := tasks.creates[0]; in.Category != group.CategoryDone || in.ExternalID != "done@phone" {
+		t.Fatalf("CreateTask input = %+v", in)
+	}
+}
+
+func TestPutCreateLosesRaceWithIfNoneMatch(t *testing.T) {
+	tasks := &fakeTasks{createErr: apperror.New("EXTERNAL_ID_EXISTS", "a task with this external_id already exists in the workspace")}
+	svc := NewService(nil, tasks, &fakeTaskRepo{}, fakeGroups{}, nil)
+
+	// 检查时还不存在，写入时别人已经用同一个UID建好了
+	_, _, err := svc.Put(context.Background(), 1, testGroupID, "new@phone", strings.NewReader(vtodo("new@phone", "x", "")), Precondition{IfNoneMatch: "*"})
+	wantCode(t, err, "PRECONDITION_FAILED")
+
+	_, _, err = svc.Put(context.Background(), 1, testGroupID, "new@phone", strings.NewReader(vtodo("new@phone", "x", "")), Precondition{})
+	wantCode(t, err, "EXTERNAL_ID_EXISTS")
+}
+
+func TestPutChecksChangeSeqInTheWrite(t *testing.T) {
+	gid := testGroupID
+	current := &task.Task{
+		ID: 5, WorkspaceID: 1, GroupID: &gid, Title: "初稿", Status: "pending", StatusCategory: group.CategoryTodo,
+		Priority: "low", ExternalID: "doc@phone", ChangeSeq: 41, CreatedAt: time.Unix(0, 0), UpdatedAt: time.Unix(0, 0),
+	}
+	obj, err := objectOf(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &fakeTaskRepo{byExternalID: map[string]*task.Task{"doc@phone": current}}
+
+	tests := []struct {
+		name   string
+		pre    Precondition
+		dbSeq  int64
+		want   *int64
+		failed bool
+	}{
+		{"unconditional", Precondition{}, 42, nil, false},
+		{"if-match unchanged", Precondition{IfMatch: obj.ETag}, 41, &current.ChangeSeq, false},
+		// 读到的ETag还匹配，但写入前任务被别人改了
+		{"if-match changed concurrently", Precondition{IfMatch: obj.ETag}, 42, &current.ChangeSeq, true},
+		{"if-match any", Precondition{IfMatch: "*"}, 42, &current.ChangeSeq, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := &fakeTasks{seq: tt.dbSeq}
+			svc := NewService(nil, tasks, repo, fakeGroups{}, nil)
+			_, created, err := svc.Put(context.Background(), 1, testGroupID, "doc@phone", strings.NewReader(vtodo("doc@phone", "终稿", "")), tt.pre)
+			if tt.failed {
+				wantCode(t, err, "PRECONDITION_FAILED")
+			} else if err != nil || created {
+				t.Fatalf("Put = %v, created %v", err, created)
+			}
+			if len(tasks.updates) != 1 {
+				t.Fatalf("UpdateTask called %d times", len(tasks.updates))
+			}
+			got := tasks.updates[0].IfChangeSeq
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("IfChangeSeq = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
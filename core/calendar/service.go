@@ -0,0 +1,171 @@
+package calendar
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"tasker/core/group"
+	"tasker/core/transfer"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+)
+
+// Feed 用户的日历订阅地址，每个用户最多一个。
+// 只保存token哈希，明文只在生成时返回一次；重新生成后旧地址立即失效
+type Feed struct {
+	UserID         int64      `json:"user_id"`
+	Hash           string     `json:"-"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// FeedOptions 订阅地址上的查询参数
+type FeedOptions struct {
+	// 只包含这个分组的任务
+	GroupID *int64
+	// 输出VEVENT而不是VTODO
+	Events bool
+}
+
+// Repository 抽象订阅的持久化
+type Repository interface {
+	// Get 没有订阅时返回nil
+	Get(ctx context.Context, userID int64) (*Feed, error)
+	// Save 新建或替换用户的订阅
+	Save(ctx context.Context, f *Feed) error
+	Delete(ctx context.Context, userID int64) error
+	// GetByHash 找不到时返回nil
+	GetByHash(ctx context.Context, hash string) (*Feed, error)
+	RecordAccess(ctx context.Context, userID int64, at time.Time) error
+	// StreamDueTasks 按ID顺序分批读取用户能看到的、有截止时间的任务（带分组名），每批调用一次fn
+	StreamDueTasks(ctx context.Context, userID int64, groupID *int64, fn func(batch []*transfer.Record) error) error
+}
+
+// Service 日历订阅相关业务
+type Service interface {
+	// Get 没有订阅时返回 FEED_NOT_FOUND
+	Get(ctx context.Context, userID int64) (*Feed, error)
+	// Rotate 生成新的订阅地址并返回明文token，之前的地址失效
+	Rotate(ctx context.Context, userID int64) (*Feed, string, error)
+	Disable(ctx context.Context, userID int64) error
+	// Render 不需要登录，按token找到用户后把日历写到w；出错时可能已经写出了一部分
+	Render(ctx context.Context, raw string, opts FeedOptions, w io.Writer) error
+}
+
+// ActiveChecker 校验账号没有被停用，由user.Service实现，和认证中间件用的是同一个检查
+type ActiveChecker interface {
+	CheckActive(ctx context.Context, userID int64) error
+}
+
+type service struct {
+	repo   Repository
+	groups group.Service
+	users  ActiveChecker
+	clock  clock.Clock
+}
+
+func NewService(repo Repository, groups group.Service, users ActiveChecker) Service {
+	return &service{
+		repo:   repo,
+		groups: groups,
+		users:  users,
+		clock:  clock.Real,
+	}
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *service) Get(ctx context.Context, userID int64) (*Feed, error) {
+	f, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, apperror.New("FEED_NOT_FOUND", "calendar feed is not enabled")
+	}
+	return f, nil
+}
+
+func (s *service) Rotate(ctx context.Context, userID int64) (*Feed, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", apperror.New("INTERNAL_ERROR", "failed to generate feed token")
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+
+	f := &Feed{
+		UserID:    userID,
+		Hash:      hashToken(raw),
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.repo.Save(ctx, f); err != nil {
+		return nil, "", err
+	}
+	return f, raw, nil
+}
+
+func (s *service) Disable(ctx context.Context, userID int64) error {
+	return s.repo.Delete(ctx, userID)
+}
+
+func (s *service) Render(ctx context.Context, raw string, opts FeedOptions, w io.Writer) error {
+	notFound := apperror.New("FEED_NOT_FOUND", "calendar feed not found")
+	if raw == "" {
+		return notFound
+	}
+	f, err := s.repo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return notFound
+	}
+	// 订阅地址不经过认证中间件，停用的账号在这里拦下，和不存在的订阅一样处理
+	if err := s.users.CheckActive(ctx, f.UserID); err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && (appErr.Code == "ACCOUNT_DISABLED" || appErr.Code == "USER_NOT_FOUND") {
+			return notFound
+		}
+		return err
+	}
+
+	// 每次都按当前权限过滤，用户离开工作区后订阅里也看不到那些任务
+	name := "Tasker"
+	if opts.GroupID != nil {
+		g, err := s.groups.Authorize(ctx, f.UserID, *opts.GroupID, group.AccessRead)
+		if err != nil {
+			return err
+		}
+		name += " - " + g.Name
+	}
+
+	enc := transfer.NewICSEncoder(w, transfer.ICSOptions{Name: name, Events: opts.Events})
+	if err := enc.Begin(nil); err != nil {
+		return err
+	}
+	err = s.repo.StreamDueTasks(ctx, f.UserID, opts.GroupID, func(batch []*transfer.Record) error {
+		for _, r := range batch {
+			if err := enc.Task(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := enc.End(); err != nil {
+		return err
+	}
+
+	// 统计失败不影响订阅
+	_ = s.repo.RecordAccess(ctx, f.UserID, s.clock.Now())
+	return nil
+}
@@ -0,0 +1,70 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"tasker/core/transfer"
+	"tasker/pkg/apperror"
+)
+
+type fakeRepo struct {
+	Repository
+	feed     *Feed
+	accessed int
+}
+
+func (r *fakeRepo) GetByHash(ctx context.Context, hash string) (*Feed, error) {
+	if r.feed != nil && r.feed.Hash == hash {
+		return r.feed, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) StreamDueTasks(ctx context.Context, userID int64, groupID *int64, fn func(batch []*transfer.Record) error) error {
+	return fn([]*transfer.Record{{ID: 1, Title: "pay rent"}})
+}
+
+func (r *fakeRepo) RecordAccess(ctx context.Context, userID int64, at time.Time) error {
+	r.accessed++
+	return nil
+}
+
+// fakeUsers 停用的用户返回 ACCOUNT_DISABLED，和user.Service一致
+type fakeUsers struct {
+	disabled map[int64]bool
+}
+
+func (u fakeUsers) CheckActive(ctx context.Context, userID int64) error {
+	if u.disabled[userID] {
+		return apperror.New("ACCOUNT_DISABLED", "this account has been disabled")
+	}
+	return nil
+}
+
+func TestRenderRejectsDisabledAccounts(t *testing.T) {
+	repo := &fakeRepo{feed: &Feed{UserID: 7, Hash: hashToken("secret")}}
+	users := fakeUsers{disabled: map[int64]bool{}}
+	svc := NewService(repo, nil, users)
+
+	var buf bytes.Buffer
+	if err := svc.Render(context.Background(), "secret", FeedOptions{}, &buf); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(buf.String(), "pay rent") || repo.accessed != 1 {
+		t.Fatalf("rendered %q, accessed %d", buf.String(), repo.accessed)
+	}
+
+	users.disabled[7] = true
+	buf.Reset()
+	err := svc.Render(context.Background(), "secret", FeedOptions{}, &buf)
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "FEED_NOT_FOUND" {
+		t.Fatalf("Render for a disabled account = %v, want FEED_NOT_FOUND", err)
+	}
+	if buf.Len() != 0 || repo.accessed != 1 {
+		t.Fatalf("disabled account rendered %q, accessed %d", buf.String(), repo.accessed)
+	}
+}
@@ -0,0 +1,251 @@
+package comment
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tasker/core/group"
+	"tasker/core/mention"
+	"tasker/core/task"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+	"tasker/pkg/events"
+)
+
+// MaxBodyLength 评论最多字符数
+const MaxBodyLength = 5000
+
+// EventCreated 有人发表了评论，动态流订阅它
+const EventCreated = "comment.created"
+
+// CreatedEvent EventCreated的Payload
+type CreatedEvent struct {
+	CommentID int64
+	TaskID    int64
+	Title     string
+	GroupID   *int64
+}
+
+// Comment 任务下的评论
+type Comment struct {
+	ID       int64  `json:"id"`
+	TaskID   int64  `json:"task_id"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Body     string `json:"body"`
+	// 评论里@到的用户
+	Mentions  []mention.Mention `json:"mentions"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// 发表和修改评论的入参
+type CommentInput struct {
+	Body string `json:"body"`
+}
+
+// Repository 抽象评论的持久化
+type Repository interface {
+	Create(ctx context.Context, c *Comment) error
+	GetByID(ctx context.Context, id int64) (*Comment, error)
+	ListByTask(ctx context.Context, taskID int64) ([]*Comment, error)
+	Update(ctx context.Context, c *Comment) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// Service 评论相关业务，权限跟随任务：能看任务就能看评论，能改任务才能评论
+type Service interface {
+	Create(ctx context.Context, userID, taskID int64, in CommentInput) (*Comment, error)
+	List(ctx context.Context, userID, taskID int64) ([]*Comment, error)
+	// Update 只有作者能修改
+	Update(ctx context.Context, userID, id int64, in CommentInput) (*Comment, error)
+	// Delete 作者或能管理分组的人可以删除
+	Delete(ctx context.Context, userID, id int64) error
+}
+
+// Option 可选依赖
+type Option func(*service)
+
+// WithEvents 注入事件发布，默认丢弃
+func WithEvents(p events.Publisher) Option {
+	return func(s *service) {
+		s.events = p
+	}
+}
+
+type service struct {
+	repo     Repository
+	tasks    task.Service
+	groups   group.Service
+	mentions mention.Service
+	events   events.Publisher
+	clock    clock.Clock
+}
+
+func NewService(repo Repository, tasks task.Service, groups group.Service, mentions mention.Service, opts ...Option) Service {
+	s := &service{repo: repo, tasks: tasks, groups: groups, mentions: mentions, events: events.Discard{}, clock: clock.Real}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func validateBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", apperror.New("INVALID_COMMENT", "body is required")
+	}
+	if utf8.RuneCountInString(body) > MaxBodyLength {
+		return "", apperror.New("INVALID_COMMENT", "body must be at most 5000 characters")
+	}
+	return body, nil
+}
+
+func (s *service) Create(ctx context.Context, userID, taskID int64, in CommentInput) (*Comment, error) {
+	body, err := validateBody(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.tasks.Authorize(ctx, userID, taskID, group.AccessWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	c := &Comment{
+		TaskID:    t.ID,
+		UserID:    userID,
+		Body:      body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	if err := s.syncMentions(ctx, userID, t, c); err != nil {
+		return nil, err
+	}
+	s.events.Publish(ctx, events.Event{
+		Type:        EventCreated,
+		WorkspaceID: t.WorkspaceID,
+		ActorID:     userID,
+		Payload:     CreatedEvent{CommentID: c.ID, TaskID: t.ID, Title: t.Title, GroupID: t.GroupID},
+	})
+	// 带上作者用户名
+	return s.reload(ctx, c)
+}
+
+func (s *service) List(ctx context.Context, userID, taskID int64) ([]*Comment, error) {
+	if _, err := s.tasks.Authorize(ctx, userID, taskID, group.AccessRead); err != nil {
+		return nil, err
+	}
+	items, err := s.repo.ListByTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachMentions(ctx, items...); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *service) Update(ctx context.Context, userID, id int64, in CommentInput) (*Comment, error) {
+	body, err := validateBody(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	c, t, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserID != userID {
+		return nil, apperror.New("COMMENT_FORBIDDEN", "only the author can edit a comment")
+	}
+
+	c.Body = body
+	c.UpdatedAt = s.clock.Now()
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	if err := s.syncMentions(ctx, userID, t, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *service) Delete(ctx context.Context, userID, id int64) error {
+	c, t, err := s.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if c.UserID != userID {
+		if t.GroupID == nil {
+			return apperror.New("COMMENT_FORBIDDEN", "only the author can delete this comment")
+		}
+		if _, err := s.groups.Authorize(ctx, userID, *t.GroupID, group.AccessManage); err != nil {
+			return apperror.New("COMMENT_FORBIDDEN", "only the author or a group manager can delete this comment")
+		}
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// get 读取评论并确认能看到所在任务，看不到时当作评论不存在
+func (s *service) get(ctx context.Context, userID, id int64) (*Comment, *task.Task, error) {
+	c, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := s.tasks.Authorize(ctx, userID, c.TaskID, group.AccessRead)
+	if err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "TASK_NOT_FOUND" {
+			return nil, nil, apperror.New("COMMENT_NOT_FOUND", "comment not found")
+		}
+		return nil, nil, err
+	}
+	return c, t, nil
+}
+
+func (s *service) reload(ctx context.Context, c *Comment) (*Comment, error) {
+	fresh, err := s.repo.GetByID(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	fresh.Mentions = c.Mentions
+	return fresh, nil
+}
+
+func (s *service) syncMentions(ctx context.Context, actorID int64, t *task.Task, c *Comment) error {
+	mentions, err := s.mentions.Sync(ctx, actorID, mention.Source{
+		Type:        mention.SourceComment,
+		ID:          c.ID,
+		TaskID:      t.ID,
+		TaskTitle:   t.Title,
+		WorkspaceID: t.WorkspaceID,
+		GroupID:     t.GroupID,
+	}, c.Body)
+	if err != nil {
+		return err
+	}
+	c.Mentions = mentions
+	return nil
+}
+
+func (s *service) attachMentions(ctx context.Context, items ...*Comment) error {
+	ids := make([]int64, 0, len(items))
+	for _, c := range items {
+		c.Mentions = []mention.Mention{}
+		ids = append(ids, c.ID)
+	}
+	byComment, err := s.mentions.Load(ctx, mention.SourceComment, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range items {
+		if m, ok := byComment[c.ID]; ok {
+			c.Mentions = m
+		}
+	}
+	return nil
+}
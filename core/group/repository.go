@@ -3,19 +3,32 @@ package group
 import "context"
 
 type Repository interface {
-	// 查询用户是否有这个组
-	GetByID(ctx context.Context, userID int64, ID int64) (*Group, error)
+	// 按ID查询分组，权限由service按工作区成员校验
+	GetByID(ctx context.Context, ID int64) (*Group, error)
 
 	// 创建分组
 	Create(ctx context.Context, group *Group) error
 
-	GetByUserIDAndName(ctx context.Context, userID int64, name string) (*Group, error)
+	// 工作区内按名称查询，不存在时返回nil, nil
+	GetByWorkspaceAndName(ctx context.Context, workspaceID int64, name string) (*Group, error)
 
-	Delete(ctx context.Context, userID, ID uint64) error
+	Delete(ctx context.Context, ID int64) error
 
 	Update(ctx context.Context, group *Group) (*Group, error)
 
+	// 用户能访问的分组里按名称模糊查询
 	GetListByName(ctx context.Context, userID int64, name string) (*[]Group, error)
 
+	// 用户所在的全部工作区里的分组，以及共享给用户的分组
 	GetListByUserID(ctx context.Context, userID int64) (*[]Group, error)
+
+	// 共享给userID的记录，没有时返回nil, nil
+	GetShare(ctx context.Context, groupID, userID int64) (*Share, error)
+	// 新增共享，已存在时修改权限
+	UpsertShare(ctx context.Context, share *Share) error
+	ListShares(ctx context.Context, groupID int64) ([]*Share, error)
+	DeleteShare(ctx context.Context, groupID, userID int64) error
+
+	// 在一个事务里保存工作流，把分组里remap中的状态换成新状态，并按新工作流更新任务的状态大类
+	UpdateWorkflow(ctx context.Context, g *Group, remap map[string]State) error
 }
@@ -2,60 +2,107 @@ package group
 
 import (
 	"context"
+	"tasker/core/workspace"
 	"tasker/pkg/apperror"
 	"time"
+	"unicode/utf8"
 )
 
+// 分组名的最大长度，和groups.name的列宽一致
+const maxGroupName = 50
+
 type Group struct {
-	ID        int64 `json:"id"`
-	UserID    int64 `json:"user_id"`
-	Name      string `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time	`json:"updated_at"`
+	ID          int64 `json:"id"`
+	UserID      int64 `json:"user_id"`
+	WorkspaceID int64 `json:"workspace_id"`
+	Name        string `json:"name"`
+	// 自定义工作流，nil表示默认的 pending → completed
+	Workflow    *Workflow `json:"workflow"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time	`json:"updated_at"`
 }
 
 type service struct {
-	repo Repository
+	repo       Repository
+	workspaces workspace.Service
 }
 
 type Service interface {
+	// 工作区成员和被共享的用户都可以读取分组
 	GetGroup(ctx context.Context, userID int64, ID int64) (*Group, error)
-	CreateGroup(ctx context.Context, userID int64, name string) (*Group, error)
-	FindGroupByName(ctx context.Context, userID int64, name string) (*Group, error)
+	// Authorize 校验对分组的访问级别，任务的权限也走这里
+	Authorize(ctx context.Context, userID, groupID int64, need Access) (*Group, error)
+	// 在工作区里创建分组，需要member及以上角色
+	CreateGroup(ctx context.Context, userID int64, workspaceID int64, name string) (*Group, error)
+	// NewGroup 做CreateGroup的全部校验（名称、工作流、工作区角色）并组装分组，但不写入。
+	// 需要和其他数据在同一个事务里创建分组的模块（导入）用它，再在自己的事务里插入这一行
+	NewGroup(ctx context.Context, userID int64, workspaceID int64, name string, w *Workflow) (*Group, error)
+	FindGroupByName(ctx context.Context, workspaceID int64, name string) (*Group, error)
+
+	// 单独共享分组给工作区以外的用户
+	ShareGroup(ctx context.Context, userID, groupID int64, in ShareGroupInput) (*Share, error)
+	ListShares(ctx context.Context, userID, groupID int64) ([]*Share, error)
+	RevokeShare(ctx context.Context, userID, groupID, targetID int64) error
+
+	// 分组的任务状态流转，管理共享的人可以修改
+	GetWorkflow(ctx context.Context, userID, groupID int64) (*Workflow, error)
+	SetWorkflow(ctx context.Context, userID, groupID int64, w *Workflow) (*Workflow, error)
 }
 
-func NewService(repo Repository) Service {
+func NewService(repo Repository, workspaces workspace.Service) Service {
 	return &service{
 		repo: repo,
+		workspaces: workspaces,
 	}
 }
 
 func (s *service) GetGroup(ctx context.Context, userID int64, ID int64) (*Group, error) {
-	return s.repo.GetByID(ctx, userID, ID)
+	return s.Authorize(ctx, userID, ID, AccessRead)
+}
+
+func (s *service) CreateGroup(ctx context.Context, userID int64, workspaceID int64, name string) (*Group, error) {
+	g, err := s.NewGroup(ctx, userID, workspaceID, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, g); err != nil {
+		return nil, err
+	}
+
+	return g, nil
 }
 
-func (s *service) CreateGroup(ctx context.Context, userID int64, name string) (*Group, error) {
+func (s *service) NewGroup(ctx context.Context, userID int64, workspaceID int64, name string, w *Workflow) (*Group, error) {
 	// 校验name
-	if name == "" {
+	if name == "" || utf8.RuneCountInString(name) > maxGroupName {
 		return nil, apperror.New("INVALID_GROUP_NAME", "invalid group name")
 	}
+	// 工作流的规则和SetWorkflow一样，states为空表示默认工作流
+	var custom *Workflow
+	if w != nil && len(w.States) > 0 {
+		if err := w.Validate(); err != nil {
+			return nil, err
+		}
+		custom = w
+	}
+
+	if _, err := s.workspaces.Authorize(ctx, userID, workspaceID, workspace.RoleMember); err != nil {
+		return nil, err
+	}
 	
 	// 组装group
 	now := time.Now()
-	g := &Group{
+	return &Group{
 		UserID: userID,
+		WorkspaceID: workspaceID,
 		Name: name,
+		Workflow: custom,
 		CreatedAt: now,
 		UpdatedAt: now,
-	}
-
-	if err := s.repo.Create(ctx, g); err != nil {
-		return nil, err
-	}
-
-	return g, nil
+	}, nil
 }
 
-func (s *service) FindGroupByName(ctx context.Context, userID int64, name string) (*Group, error) {
-	return s.repo.GetByUserIDAndName(ctx, userID, name)
-}
\ No newline at end of file
+func (s *service) FindGroupByName(ctx context.Context, workspaceID int64, name string) (*Group, error) {
+	return s.repo.GetByWorkspaceAndName(ctx, workspaceID, name)
+}
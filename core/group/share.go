@@ -0,0 +1,149 @@
+package group
+
+import (
+	"context"
+	"time"
+
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+)
+
+// Permission 单独共享一个分组时给对方的权限
+type Permission string
+
+const (
+	PermissionRead  Permission = "read"
+	PermissionWrite Permission = "write"
+)
+
+// Access 访问分组需要的级别
+type Access int
+
+const (
+	// 查看分组和其中的任务
+	AccessRead Access = iota + 1
+	// 创建、修改、删除其中的任务
+	AccessWrite
+	// 管理共享
+	AccessManage
+)
+
+// Share 把分组共享给工作区以外的用户
+type Share struct {
+	GroupID    int64      `json:"group_id"`
+	UserID     int64      `json:"user_id"`
+	Username   string     `json:"username"`
+	Permission Permission `json:"permission"`
+	GrantedBy  int64      `json:"granted_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// 共享分组的入参，已共享时修改权限
+type ShareGroupInput struct {
+	UserID     int64      `json:"user_id"`
+	Permission Permission `json:"permission"`
+}
+
+// workspaceAccess 工作区角色对应的分组访问级别
+func workspaceAccess(role workspace.Role) Access {
+	switch {
+	case role.AtLeast(workspace.RoleAdmin):
+		return AccessManage
+	case role.AtLeast(workspace.RoleMember):
+		return AccessWrite
+	}
+	return AccessRead
+}
+
+func shareAccess(p Permission) Access {
+	if p == PermissionWrite {
+		return AccessWrite
+	}
+	return AccessRead
+}
+
+// Authorize 校验用户对分组的访问级别：工作区成员按角色，其他人按共享权限。
+// 都没有时返回 GROUP_NOT_FOUND；级别不够时返回 WORKSPACE_FORBIDDEN 或 GROUP_FORBIDDEN
+func (s *service) Authorize(ctx context.Context, userID, groupID int64, need Access) (*Group, error) {
+	g, err := s.repo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := s.workspaces.Authorize(ctx, userID, g.WorkspaceID, workspace.RoleViewer)
+	if err == nil {
+		have := workspaceAccess(m.Role)
+		// 分组创建者可以管理自己分组的共享
+		if need == AccessManage && have == AccessWrite && g.UserID == userID {
+			have = AccessManage
+		}
+		if have < need {
+			return nil, apperror.New("WORKSPACE_FORBIDDEN", "your workspace role does not allow this action")
+		}
+		return g, nil
+	}
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "WORKSPACE_NOT_FOUND" {
+		return nil, err
+	}
+
+	share, err := s.repo.GetShare(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil {
+		return nil, apperror.New("GROUP_NOT_FOUND", "group not found")
+	}
+	if shareAccess(share.Permission) < need {
+		return nil, apperror.New("GROUP_FORBIDDEN", "this group is shared with you read-only")
+	}
+	return g, nil
+}
+
+func (s *service) ShareGroup(ctx context.Context, userID, groupID int64, in ShareGroupInput) (*Share, error) {
+	if in.Permission != PermissionRead && in.Permission != PermissionWrite {
+		return nil, apperror.New("INVALID_PERMISSION", "permission must be read or write")
+	}
+	if in.UserID <= 0 || in.UserID == userID {
+		return nil, apperror.New("INVALID_SHARE_USER", "user_id must be another user's id")
+	}
+	g, err := s.Authorize(ctx, userID, groupID, AccessManage)
+	if err != nil {
+		return nil, err
+	}
+	// 工作区成员本来就能访问，按角色管理
+	if _, err := s.workspaces.Authorize(ctx, in.UserID, g.WorkspaceID, workspace.RoleViewer); err == nil {
+		return nil, apperror.New("ALREADY_MEMBER", "the user is already a member of the group's workspace")
+	}
+
+	now := time.Now()
+	share := &Share{
+		GroupID:    groupID,
+		UserID:     in.UserID,
+		Permission: in.Permission,
+		GrantedBy:  userID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.repo.UpsertShare(ctx, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (s *service) ListShares(ctx context.Context, userID, groupID int64) ([]*Share, error) {
+	if _, err := s.Authorize(ctx, userID, groupID, AccessManage); err != nil {
+		return nil, err
+	}
+	return s.repo.ListShares(ctx, groupID)
+}
+
+func (s *service) RevokeShare(ctx context.Context, userID, groupID, targetID int64) error {
+	// 被共享的人可以自己退出
+	if targetID != userID {
+		if _, err := s.Authorize(ctx, userID, groupID, AccessManage); err != nil {
+			return err
+		}
+	}
+	return s.repo.DeleteShare(ctx, groupID, targetID)
+}
@@ -0,0 +1,89 @@
+package group
+
+import (
+	"context"
+	"testing"
+
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+)
+
+func (r *fakeRepo) GetShare(ctx context.Context, groupID, userID int64) (*Share, error) {
+	return r.shares[userID], nil
+}
+
+// fakeMembers 按用户给出工作区角色，不在里面的用户不是成员
+type fakeMembers struct {
+	workspace.Service
+	roles map[int64]workspace.Role
+}
+
+func (w fakeMembers) Authorize(ctx context.Context, userID, workspaceID int64, min workspace.Role) (*workspace.Member, error) {
+	role, ok := w.roles[userID]
+	if !ok {
+		return nil, apperror.New("WORKSPACE_NOT_FOUND", "workspace not found")
+	}
+	if !role.AtLeast(min) {
+		return nil, apperror.New("WORKSPACE_FORBIDDEN", "your workspace role does not allow this action")
+	}
+	return &workspace.Member{WorkspaceID: workspaceID, UserID: userID, Role: role}, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	const (
+		creator  = 1 // 分组创建者，工作区member
+		admin    = 2
+		member   = 3
+		viewer   = 4
+		reader   = 5 // 共享了只读
+		writer   = 6 // 共享了读写
+		stranger = 7
+	)
+	repo := &fakeRepo{
+		group: &Group{ID: 10, WorkspaceID: 1, UserID: creator},
+		shares: map[int64]*Share{
+			reader: {GroupID: 10, UserID: reader, Permission: PermissionRead},
+			writer: {GroupID: 10, UserID: writer, Permission: PermissionWrite},
+		},
+	}
+	svc := NewService(repo, fakeMembers{roles: map[int64]workspace.Role{
+		creator: workspace.RoleMember,
+		admin:   workspace.RoleAdmin,
+		member:  workspace.RoleMember,
+		viewer:  workspace.RoleViewer,
+	}})
+
+	tests := []struct {
+		name   string
+		userID int64
+		need   Access
+		code   string // 空表示允许
+	}{
+		{"admin manages", admin, AccessManage, ""},
+		{"member writes", member, AccessWrite, ""},
+		{"member cannot manage", member, AccessManage, "WORKSPACE_FORBIDDEN"},
+		{"creator manages own group", creator, AccessManage, ""},
+		{"viewer reads", viewer, AccessRead, ""},
+		{"viewer cannot write", viewer, AccessWrite, "WORKSPACE_FORBIDDEN"},
+		{"read share reads", reader, AccessRead, ""},
+		{"read share cannot write", reader, AccessWrite, "GROUP_FORBIDDEN"},
+		{"write share writes", writer, AccessWrite, ""},
+		// 被共享的人不能再共享出去
+		{"write share cannot manage", writer, AccessManage, "GROUP_FORBIDDEN"},
+		{"stranger cannot see the group", stranger, AccessRead, "GROUP_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := svc.Authorize(context.Background(), tt.userID, 10, tt.need)
+			if tt.code == "" {
+				if err != nil || g.ID != 10 {
+					t.Fatalf("Authorize = %v, %v", g, err)
+				}
+				return
+			}
+			if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != tt.code {
+				t.Fatalf("Authorize = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
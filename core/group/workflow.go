@@ -0,0 +1,202 @@
+package group
+
+import (
+	"context"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"tasker/pkg/apperror"
+)
+
+// Category 状态的大类，筛选、逾期和完成事件都按大类判断
+type Category string
+
+const (
+	CategoryTodo  Category = "todo"
+	CategoryDoing Category = "doing"
+	CategoryDone  Category = "done"
+)
+
+func (c Category) Valid() bool {
+	return c == CategoryTodo || c == CategoryDoing || c == CategoryDone
+}
+
+// 默认工作流的两个状态；这两个key在自定义工作流里也只能用在对应大类上，
+// 这样 status=pending/completed 的筛选对所有分组都成立
+const (
+	StatePending   = "pending"
+	StateCompleted = "completed"
+)
+
+// 工作流的限制；key存在tasks.status里，列宽是20
+const (
+	maxStates    = 20
+	maxStateName = 50
+)
+
+var stateKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,19}$`)
+
+// State 工作流里的一个状态，key写进任务的status
+type State struct {
+	Key      string   `json:"key"`
+	Name     string   `json:"name"`
+	Category Category `json:"category"`
+}
+
+// Workflow 分组的有序状态列表，第一个状态是新任务的初始状态。
+// Transitions为空时可以任意切换；否则列出的状态只能切到对应的目标，没列出的不限
+type Workflow struct {
+	States      []State             `json:"states"`
+	Transitions map[string][]string `json:"transitions,omitempty"`
+}
+
+// DefaultWorkflow 没有自定义工作流的分组使用 pending → completed
+func DefaultWorkflow() *Workflow {
+	return &Workflow{States: []State{
+		{Key: StatePending, Name: "Pending", Category: CategoryTodo},
+		{Key: StateCompleted, Name: "Completed", Category: CategoryDone},
+	}}
+}
+
+// WorkflowOf 分组实际使用的工作流，g为nil（任务的分组被删掉了）时用默认工作流
+func WorkflowOf(g *Group) *Workflow {
+	if g == nil || g.Workflow == nil {
+		return DefaultWorkflow()
+	}
+	return g.Workflow
+}
+
+// Validate 校验自定义工作流
+func (w *Workflow) Validate() error {
+	if len(w.States) == 0 || len(w.States) > maxStates {
+		return apperror.New("INVALID_WORKFLOW", "a workflow needs 1 to 20 states")
+	}
+	seen := make(map[string]bool, len(w.States))
+	hasDone := false
+	for _, st := range w.States {
+		if !stateKeyPattern.MatchString(st.Key) {
+			return apperror.New("INVALID_WORKFLOW", "state key must be lowercase letters, digits or _ and at most 20 chars: "+st.Key)
+		}
+		if seen[st.Key] {
+			return apperror.New("INVALID_WORKFLOW", "duplicate state key: "+st.Key)
+		}
+		seen[st.Key] = true
+		if st.Name == "" || utf8.RuneCountInString(st.Name) > maxStateName {
+			return apperror.New("INVALID_WORKFLOW", "state name must be 1-50 chars: "+st.Key)
+		}
+		if !st.Category.Valid() {
+			return apperror.New("INVALID_WORKFLOW", "state category must be todo, doing or done: "+st.Key)
+		}
+		if (st.Key == StatePending && st.Category != CategoryTodo) || (st.Key == StateCompleted && st.Category != CategoryDone) {
+			return apperror.New("INVALID_WORKFLOW", "pending must be a todo state and completed a done state")
+		}
+		if st.Category == CategoryDone {
+			hasDone = true
+		}
+	}
+	if !hasDone {
+		return apperror.New("INVALID_WORKFLOW", "a workflow needs at least one done state")
+	}
+	if w.States[0].Category == CategoryDone {
+		return apperror.New("INVALID_WORKFLOW", "the first state is used for new tasks and cannot be a done state")
+	}
+	for from, targets := range w.Transitions {
+		if !seen[from] {
+			return apperror.New("INVALID_WORKFLOW", "unknown state in transitions: "+from)
+		}
+		for _, to := range targets {
+			if !seen[to] {
+				return apperror.New("INVALID_WORKFLOW", "unknown state in transitions: "+to)
+			}
+		}
+	}
+	return nil
+}
+
+// State 按key查找状态
+func (w *Workflow) State(key string) (State, bool) {
+	for _, st := range w.States {
+		if st.Key == key {
+			return st, true
+		}
+	}
+	return State{}, false
+}
+
+// Initial 新任务的初始状态
+func (w *Workflow) Initial() State {
+	return w.States[0]
+}
+
+// CanTransition 是否允许从from切到to，状态不变总是允许
+func (w *Workflow) CanTransition(from, to string) bool {
+	if from == to || len(w.Transitions) == 0 {
+		return true
+	}
+	targets, ok := w.Transitions[from]
+	if !ok {
+		return true
+	}
+	for _, t := range targets {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Map 把其他工作流里的状态换到这个工作流：同key优先，其次是同大类的第一个状态，
+// 都没有时回到初始状态（done大类一定存在，所以已完成的任务不会被重新打开）
+func (w *Workflow) Map(key string, category Category) State {
+	if st, ok := w.State(key); ok {
+		return st
+	}
+	for _, st := range w.States {
+		if st.Category == category {
+			return st
+		}
+	}
+	return w.Initial()
+}
+
+// GetWorkflow 读取分组的工作流，没有自定义时返回默认工作流
+func (s *service) GetWorkflow(ctx context.Context, userID, groupID int64) (*Workflow, error) {
+	g, err := s.Authorize(ctx, userID, groupID, AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	return WorkflowOf(g), nil
+}
+
+// SetWorkflow 替换分组的工作流，states为空时恢复默认工作流。
+// 分组里处于被删除状态的任务按Map换到新工作流，不产生完成/重新打开事件
+func (s *service) SetWorkflow(ctx context.Context, userID, groupID int64, w *Workflow) (*Workflow, error) {
+	g, err := s.Authorize(ctx, userID, groupID, AccessManage)
+	if err != nil {
+		return nil, err
+	}
+	var custom *Workflow
+	if w != nil && len(w.States) > 0 {
+		if err := w.Validate(); err != nil {
+			return nil, err
+		}
+		custom = w
+	}
+	next := WorkflowOf(&Group{Workflow: custom})
+
+	current := WorkflowOf(g)
+	remap := make(map[string]State)
+	for _, st := range current.States {
+		if _, ok := next.State(st.Key); !ok {
+			remap[st.Key] = next.Map(st.Key, st.Category)
+		}
+	}
+
+	g.Workflow = custom
+	g.UpdatedAt = time.Now()
+	if err := s.repo.UpdateWorkflow(ctx, g, remap); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
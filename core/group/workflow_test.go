@@ -0,0 +1,188 @@
+package group
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+)
+
+// kanban 待办 → 进行中 → 评审 → 完成，评审可以退回进行中
+func kanban() *Workflow {
+	return &Workflow{
+		States: []State{
+			{Key: "todo", Name: "待办", Category: CategoryTodo},
+			{Key: "doing", Name: "进行中", Category: CategoryDoing},
+			{Key: "review", Name: "评审", Category: CategoryDoing},
+			{Key: "done", Name: "完成", Category: CategoryDone},
+		},
+		Transitions: map[string][]string{
+			"todo":   {"doing"},
+			"doing":  {"review"},
+			"review": {"doing", "done"},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	states := func(sts ...State) *Workflow { return &Workflow{States: sts} }
+	todo := State{Key: "todo", Name: "待办", Category: CategoryTodo}
+	done := State{Key: "done", Name: "完成", Category: CategoryDone}
+	tests := []struct {
+		name string
+		w    *Workflow
+		msg  string // 空表示合法
+	}{
+		{"default", DefaultWorkflow(), ""},
+		{"kanban", kanban(), ""},
+		{"reserved keys in their categories", states(
+			State{Key: StatePending, Name: "待办", Category: CategoryTodo},
+			State{Key: StateCompleted, Name: "完成", Category: CategoryDone},
+		), ""},
+		{"chinese name of 50 runes", states(State{Key: "todo", Name: strings.Repeat("写", 50), Category: CategoryTodo}, done), ""},
+		{"no states", states(), "1 to 20 states"},
+		{"too many states", &Workflow{States: make([]State, 21)}, "1 to 20 states"},
+		{"uppercase key", states(State{Key: "Todo", Name: "待办", Category: CategoryTodo}, done), "state key"},
+		{"key starting with a digit", states(State{Key: "1st", Name: "待办", Category: CategoryTodo}, done), "state key"},
+		{"key longer than 20", states(State{Key: strings.Repeat("a", 21), Name: "待办", Category: CategoryTodo}, done), "state key"},
+		{"duplicate key", states(todo, todo, done), "duplicate state key: todo"},
+		{"empty name", states(State{Key: "todo", Category: CategoryTodo}, done), "state name"},
+		{"name of 51 runes", states(State{Key: "todo", Name: strings.Repeat("写", 51), Category: CategoryTodo}, done), "state name"},
+		{"unknown category", states(State{Key: "todo", Name: "待办", Category: "blocked"}, done), "state category"},
+		{"pending as a doing state", states(State{Key: StatePending, Name: "进行中", Category: CategoryDoing}, done), "pending must be a todo state"},
+		{"completed as a todo state", states(todo, State{Key: StateCompleted, Name: "完成", Category: CategoryTodo}, done), "pending must be a todo state and completed a done state"},
+		{"no done state", states(todo), "at least one done state"},
+		{"done first", states(done, todo), "first state"},
+		{"unknown transition source", &Workflow{States: []State{todo, done}, Transitions: map[string][]string{"doing": {"done"}}}, "unknown state in transitions: doing"},
+		{"unknown transition target", &Workflow{States: []State{todo, done}, Transitions: map[string][]string{"todo": {"archived"}}}, "unknown state in transitions: archived"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.w.Validate()
+			if tt.msg == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			appErr, ok := apperror.IsAppError(err)
+			if !ok || appErr.Code != "INVALID_WORKFLOW" || !strings.Contains(appErr.Message, tt.msg) {
+				t.Fatalf("Validate = %v, want INVALID_WORKFLOW containing %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	w := kanban()
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"todo", "doing", true},
+		{"todo", "done", false},
+		{"doing", "review", true},
+		{"doing", "todo", false},
+		{"review", "doing", true},
+		{"review", "done", true},
+		// 状态不变总是允许
+		{"todo", "todo", true},
+		// 没有列出的状态不限制
+		{"done", "todo", true},
+		{"done", "review", true},
+	}
+	for _, tt := range tests {
+		if got := w.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+	// 没有Transitions时可以任意切换
+	if !DefaultWorkflow().CanTransition(StateCompleted, StatePending) {
+		t.Error("default workflow restricts transitions")
+	}
+}
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		w        *Workflow
+		key      string
+		category Category
+		want     string
+	}{
+		{"same key", kanban(), "review", CategoryDoing, "review"},
+		{"same key wins over the category", kanban(), "doing", CategoryDone, "doing"},
+		{"first state of the category", kanban(), "in_progress", CategoryDoing, "doing"},
+		{"pending into a custom workflow", kanban(), StatePending, CategoryTodo, "todo"},
+		{"completed into a custom workflow", kanban(), StateCompleted, CategoryDone, "done"},
+		// 默认工作流没有doing大类，退回初始状态
+		{"doing into the default workflow", DefaultWorkflow(), "review", CategoryDoing, StatePending},
+		{"done into the default workflow", DefaultWorkflow(), "done", CategoryDone, StateCompleted},
+		{"todo into the default workflow", DefaultWorkflow(), "todo", CategoryTodo, StatePending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.w.Map(tt.key, tt.category); got.Key != tt.want {
+				t.Fatalf("Map(%s, %s) = %s, want %s", tt.key, tt.category, got.Key, tt.want)
+			}
+		})
+	}
+}
+
+type fakeRepo struct {
+	Repository
+	group  *Group
+	remap  map[string]State
+	shares map[int64]*Share // 按被共享的用户
+}
+
+func (r *fakeRepo) GetByID(ctx context.Context, id int64) (*Group, error) {
+	g := *r.group
+	return &g, nil
+}
+
+func (r *fakeRepo) UpdateWorkflow(ctx context.Context, g *Group, remap map[string]State) error {
+	r.group, r.remap = g, remap
+	return nil
+}
+
+type fakeWorkspaces struct{ workspace.Service }
+
+func (fakeWorkspaces) Authorize(ctx context.Context, userID, workspaceID int64, min workspace.Role) (*workspace.Member, error) {
+	return &workspace.Member{WorkspaceID: workspaceID, UserID: userID, Role: workspace.RoleAdmin}, nil
+}
+
+func TestSetWorkflowRemap(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to *Workflow
+		want     map[string]string
+	}{
+		{"default to kanban", nil, kanban(), map[string]string{StatePending: "todo", StateCompleted: "done"}},
+		{"kanban to default", kanban(), nil, map[string]string{"todo": StatePending, "doing": StatePending, "review": StatePending, "done": StateCompleted}},
+		{"kanban without review", kanban(), &Workflow{States: []State{
+			{Key: "todo", Name: "待办", Category: CategoryTodo},
+			{Key: "doing", Name: "进行中", Category: CategoryDoing},
+			{Key: "done", Name: "完成", Category: CategoryDone},
+		}}, map[string]string{"review": "doing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{group: &Group{ID: 1, WorkspaceID: 1, Workflow: tt.from}}
+			svc := NewService(repo, fakeWorkspaces{})
+			if _, err := svc.SetWorkflow(context.Background(), 1, 1, tt.to); err != nil {
+				t.Fatalf("SetWorkflow: %v", err)
+			}
+			got := make(map[string]string, len(repo.remap))
+			for from, st := range repo.remap {
+				got[from] = st.Key
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("remap = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
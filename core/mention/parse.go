@@ -0,0 +1,50 @@
+package mention
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 用户名允许的字符，和 user.usernamePattern 保持一致
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '.' || r == '-'
+}
+
+// Parse 按出现顺序返回文本里 @username 形式的用户名，去重。
+// @前面是字母数字时不算（邮箱地址），末尾的 '.' 和 '-' 当作标点去掉
+func Parse(text string) []string {
+	var names []string
+	seen := map[string]struct{}{}
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != '@' || isNameRune(prev) {
+			prev = r
+			i += size
+			continue
+		}
+
+		j := i + size
+		for j < len(text) {
+			nr, nsize := utf8.DecodeRuneInString(text[j:])
+			if !isNameRune(nr) {
+				break
+			}
+			j += nsize
+		}
+		name := strings.TrimRight(text[i+size:j], ".-")
+		if name != "" && utf8.RuneCountInString(name) <= 50 {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+		prev = '@'
+		if j > i+size {
+			prev, _ = utf8.DecodeLastRuneInString(text[:j])
+		}
+		i = j
+	}
+	return names
+}
@@ -0,0 +1,170 @@
+package mention
+
+import (
+	"context"
+	"time"
+
+	"tasker/core/group"
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+	"tasker/pkg/events"
+)
+
+// 提及出现的位置
+const (
+	SourceTask    = "task"
+	SourceComment = "comment"
+)
+
+// EventMentioned 有人被新提及，通知模块订阅它
+const EventMentioned = "mention.created"
+
+// Mention 解析出来的提及，客户端按user_id渲染链接
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Source 写入提及的文本所在的位置，任务描述或评论
+type Source struct {
+	Type        string
+	ID          int64
+	TaskID      int64
+	TaskTitle   string
+	WorkspaceID int64
+	// 任务所在分组，只有能访问分组的人才能被提及；nil时按工作区成员判断
+	GroupID *int64
+}
+
+// MentionedEvent EventMentioned的Payload，只包含这次新增的用户
+type MentionedEvent struct {
+	TaskID int64
+	// 在评论里提及时才有
+	CommentID *int64
+	Title     string
+	UserIDs   []int64
+}
+
+// Repository 抽象提及记录的持久化
+type Repository interface {
+	// ResolveUsernames 按用户名查用户，不存在的忽略
+	ResolveUsernames(ctx context.Context, names []string) ([]Mention, error)
+	// Replace 用userIDs替换source上的提及记录，返回新增的用户
+	Replace(ctx context.Context, src Source, userIDs []int64, at time.Time) ([]int64, error)
+	// List 一次读取一批source的提及，按source id分组
+	List(ctx context.Context, sourceType string, sourceIDs []int64) (map[int64][]Mention, error)
+}
+
+// Service 提及相关业务，由任务和评论模块在写入文本时调用
+type Service interface {
+	// Sync 解析text里的提及并保存，重复调用结果相同；只对新增的提及发事件
+	Sync(ctx context.Context, actorID int64, src Source, text string) ([]Mention, error)
+	Load(ctx context.Context, sourceType string, sourceIDs []int64) (map[int64][]Mention, error)
+}
+
+// Option 可选依赖
+type Option func(*service)
+
+// WithEvents 注入事件发布，默认丢弃
+func WithEvents(p events.Publisher) Option {
+	return func(s *service) {
+		s.events = p
+	}
+}
+
+type service struct {
+	repo       Repository
+	groups     group.Service
+	workspaces workspace.Service
+	events     events.Publisher
+	clock      clock.Clock
+}
+
+func NewService(repo Repository, groups group.Service, workspaces workspace.Service, opts ...Option) Service {
+	s := &service{repo: repo, groups: groups, workspaces: workspaces, events: events.Discard{}, clock: clock.Real}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *service) Sync(ctx context.Context, actorID int64, src Source, text string) ([]Mention, error) {
+	mentions := []Mention{}
+	if names := Parse(text); len(names) > 0 {
+		resolved, err := s.repo.ResolveUsernames(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+		byName := make(map[string]Mention, len(resolved))
+		for _, m := range resolved {
+			byName[m.Username] = m
+		}
+		// 按文本里的顺序返回，看不到任务的人不算提及
+		for _, name := range names {
+			m, ok := byName[name]
+			if !ok {
+				continue
+			}
+			ok, err := s.canAccess(ctx, m.UserID, src)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				mentions = append(mentions, m)
+			}
+		}
+	}
+
+	ids := make([]int64, 0, len(mentions))
+	for _, m := range mentions {
+		ids = append(ids, m.UserID)
+	}
+	added, err := s.repo.Replace(ctx, src, ids, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, actorID, src, added)
+	return mentions, nil
+}
+
+func (s *service) Load(ctx context.Context, sourceType string, sourceIDs []int64) (map[int64][]Mention, error) {
+	if len(sourceIDs) == 0 {
+		return map[int64][]Mention{}, nil
+	}
+	return s.repo.List(ctx, sourceType, sourceIDs)
+}
+
+// canAccess 被提及的人是否能看到任务
+func (s *service) canAccess(ctx context.Context, userID int64, src Source) (bool, error) {
+	var err error
+	if src.GroupID != nil {
+		_, err = s.groups.Authorize(ctx, userID, *src.GroupID, group.AccessRead)
+	} else {
+		_, err = s.workspaces.Authorize(ctx, userID, src.WorkspaceID, workspace.RoleViewer)
+	}
+	if err == nil {
+		return true, nil
+	}
+	if appErr, ok := apperror.IsAppError(err); ok && (appErr.Code == "GROUP_NOT_FOUND" || appErr.Code == "WORKSPACE_NOT_FOUND") {
+		return false, nil
+	}
+	return false, err
+}
+
+func (s *service) publish(ctx context.Context, actorID int64, src Source, added []int64) {
+	if len(added) == 0 {
+		return
+	}
+	payload := MentionedEvent{TaskID: src.TaskID, Title: src.TaskTitle, UserIDs: added}
+	if src.Type == SourceComment {
+		commentID := src.ID
+		payload.CommentID = &commentID
+	}
+	s.events.Publish(ctx, events.Event{
+		Type:        EventMentioned,
+		WorkspaceID: src.WorkspaceID,
+		ActorID:     actorID,
+		Payload:     payload,
+	})
+}
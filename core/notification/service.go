@@ -0,0 +1,145 @@
+package notification
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tasker/core/mention"
+	"tasker/core/task"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+	"tasker/pkg/events"
+)
+
+// 通知类型
+const (
+	TypeTaskAssigned = "task_assigned"
+	TypeMentioned    = "mentioned"
+)
+
+// Notification 站内通知，由事件生成
+type Notification struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"user_id"`
+	Type        string `json:"type"`
+	WorkspaceID int64  `json:"workspace_id"`
+	ActorID     int64  `json:"actor_id"`
+	TaskID      *int64 `json:"task_id"`
+	// 生成通知时的任务标题，任务改名或删除后仍能展示
+	Title     string     `json:"title"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ListFilter struct {
+	UnreadOnly bool `json:"unread_only"`
+	Limit      int  `json:"limit"`
+}
+
+type ListResult struct {
+	Items  []*Notification `json:"items"`
+	Unread int64           `json:"unread"`
+}
+
+// Repository 抽象通知的持久化
+type Repository interface {
+	CreateMany(ctx context.Context, items []*Notification) error
+	List(ctx context.Context, userID int64, filter ListFilter) ([]*Notification, error)
+	CountUnread(ctx context.Context, userID int64) (int64, error)
+	MarkRead(ctx context.Context, userID, id int64, at time.Time) error
+	MarkAllRead(ctx context.Context, userID int64, at time.Time) error
+}
+
+// Service 通知相关业务
+type Service interface {
+	List(ctx context.Context, userID int64, filter ListFilter) (*ListResult, error)
+	MarkRead(ctx context.Context, userID, id int64) error
+	MarkAllRead(ctx context.Context, userID int64) error
+
+	// Subscribe 订阅会产生通知的事件
+	Subscribe(bus *events.Bus)
+}
+
+type service struct {
+	repo  Repository
+	clock clock.Clock
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo, clock: clock.Real}
+}
+
+func (s *service) List(ctx context.Context, userID int64, filter ListFilter) (*ListResult, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 200 {
+		return nil, apperror.New("INVALID_LIMIT", "limit must be at most 200")
+	}
+	items, err := s.repo.List(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &ListResult{Items: items, Unread: unread}, nil
+}
+
+func (s *service) MarkRead(ctx context.Context, userID, id int64) error {
+	return s.repo.MarkRead(ctx, userID, id, s.clock.Now())
+}
+
+func (s *service) MarkAllRead(ctx context.Context, userID int64) error {
+	return s.repo.MarkAllRead(ctx, userID, s.clock.Now())
+}
+
+func (s *service) Subscribe(bus *events.Bus) {
+	bus.Subscribe(task.EventAssigned, s.onTaskAssigned)
+	bus.Subscribe(mention.EventMentioned, s.onMentioned)
+}
+
+// onTaskAssigned 给新负责人发通知，自己指派给自己的不发
+func (s *service) onTaskAssigned(ctx context.Context, e events.Event) {
+	payload, ok := e.Payload.(task.AssignedEvent)
+	if !ok {
+		return
+	}
+	s.notify(ctx, e, TypeTaskAssigned, payload.TaskID, payload.Title, payload.AssigneeIDs)
+}
+
+// onMentioned 给新被@的人发通知，@自己的不发
+func (s *service) onMentioned(ctx context.Context, e events.Event) {
+	payload, ok := e.Payload.(mention.MentionedEvent)
+	if !ok {
+		return
+	}
+	s.notify(ctx, e, TypeMentioned, payload.TaskID, payload.Title, payload.UserIDs)
+}
+
+// notify 给一批用户各生成一条通知，跳过触发事件的人
+func (s *service) notify(ctx context.Context, e events.Event, typ string, taskID int64, title string, userIDs []int64) {
+	items := make([]*Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if userID == e.ActorID {
+			continue
+		}
+		items = append(items, &Notification{
+			UserID:      userID,
+			Type:        typ,
+			WorkspaceID: e.WorkspaceID,
+			ActorID:     e.ActorID,
+			TaskID:      &taskID,
+			Title:       title,
+			CreatedAt:   e.At,
+		})
+	}
+	if len(items) == 0 {
+		return
+	}
+	if err := s.repo.CreateMany(ctx, items); err != nil {
+		log.Printf("[notification] failed to create %s notifications for task %d: %v", e.Type, taskID, err)
+	}
+}
@@ -0,0 +1,353 @@
+package sharelink
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+	"tasker/pkg/date"
+)
+
+// 链接指向的对象
+const (
+	TargetTask  = "task"
+	TargetGroup = "group"
+)
+
+// Link 公开只读链接，不需要登录即可查看任务或分组。
+// 只保存token哈希，明文只在创建时返回一次
+type Link struct {
+	ID          int64  `json:"id"`
+	TargetType  string `json:"target_type"`
+	TargetID    int64  `json:"target_id"`
+	WorkspaceID int64  `json:"workspace_id"`
+	CreatedBy   int64  `json:"created_by"`
+	Hash        string `json:"-"`
+	// bcrypt哈希，空表示不需要密码
+	PasswordHash   string     `json:"-"`
+	HasPassword    bool       `json:"has_password"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	ViewCount      int64      `json:"view_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// 创建链接的入参
+type CreateLinkInput struct {
+	TargetType string     `json:"target_type"`
+	TargetID   int64      `json:"target_id"`
+	Password   string     `json:"password"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// PublicTask 公开页面上展示的任务字段，不包含用户和工作区信息
+type PublicTask struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Status      task.Status `json:"status"`
+	// todo/doing/done，不同分组的状态名不同，页面按大类显示
+	StatusCategory group.Category `json:"status_category"`
+	DueDate        *time.Time     `json:"due_date"`
+	DueOn          *date.Date     `json:"due_on"`
+	Priority       string         `json:"priority"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// PublicGroup 公开页面上的分组
+type PublicGroup struct {
+	Name  string        `json:"name"`
+	Tasks []*PublicTask `json:"tasks"`
+}
+
+// View 通过链接看到的内容，Task和Group二选一
+type View struct {
+	TargetType string       `json:"target_type"`
+	Task       *PublicTask  `json:"task,omitempty"`
+	Group      *PublicGroup `json:"group,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+}
+
+// Repository 抽象链接的持久化
+type Repository interface {
+	Create(ctx context.Context, l *Link) error
+	ListByCreator(ctx context.Context, userID int64) ([]*Link, error)
+	// ListByTarget 某个任务或分组上所有人创建的链接
+	ListByTarget(ctx context.Context, targetType string, targetID int64) ([]*Link, error)
+	// GetByID 找不到时返回nil, nil
+	GetByID(ctx context.Context, id int64) (*Link, error)
+	GetByHash(ctx context.Context, hash string) (*Link, error)
+	// Revoke 重复撤销保留第一次的时间，权限由service校验
+	Revoke(ctx context.Context, id int64, at time.Time) error
+	// RecordView 原子地增加访问次数并更新最后访问时间
+	RecordView(ctx context.Context, id int64, at time.Time) error
+}
+
+// Service 公开链接相关业务
+type Service interface {
+	// Create 返回链接记录和明文token，明文之后无法再取回
+	Create(ctx context.Context, userID int64, in CreateLinkInput) (*Link, string, error)
+	// List 自己创建的链接
+	List(ctx context.Context, userID int64) ([]*Link, error)
+	// ListForTarget 任务或分组上的全部链接，需要目标的管理权限（分组管理者、工作区管理员）
+	ListForTarget(ctx context.Context, userID int64, targetType string, targetID int64) ([]*Link, error)
+	// Revoke 创建者或目标的管理者可以撤销
+	Revoke(ctx context.Context, userID, id int64) error
+	// View 不需要登录，password只在链接设置了密码时校验；ip用于限制密码的失败次数
+	View(ctx context.Context, raw, password, ip string) (*View, error)
+}
+
+type service struct {
+	repo   Repository
+	tasks  task.Service
+	groups group.Service
+	// 公开访问时不带用户，直接读库
+	taskRepo  task.Repository
+	groupRepo group.Repository
+	clock     clock.Clock
+	// 密码错误次数限制，nil表示不限制
+	limiter *user.Limiter
+}
+
+// Option 可选依赖
+type Option func(*service)
+
+// WithLimiter 限制链接密码的失败次数，和登录共用失败计数的存储
+func WithLimiter(l *user.Limiter) Option {
+	return func(s *service) {
+		s.limiter = l
+	}
+}
+
+func NewService(repo Repository, tasks task.Service, groups group.Service, taskRepo task.Repository, groupRepo group.Repository, opts ...Option) Service {
+	s := &service{
+		repo:      repo,
+		tasks:     tasks,
+		groups:    groups,
+		taskRepo:  taskRepo,
+		groupRepo: groupRepo,
+		clock:     clock.Real,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// checkPassword 锁定中直接拒绝，不再跑bcrypt。按链接+IP计数防单个来源猜密码，
+// 按链接计数（阈值同登录的IP阈值）防换着IP猜
+func (s *service) checkPassword(ctx context.Context, l *Link, password, ip string) error {
+	linkKey := "link:" + strconv.FormatInt(l.ID, 10)
+	addrKey := linkKey + ":ip:" + ip
+	if err := s.limiter.Check(ctx, linkKey, addrKey); err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(l.PasswordHash), []byte(password)) != nil {
+		policy := s.limiter.Policy()
+		if err := s.limiter.RecordFailure(ctx, addrKey, policy.UsernameThreshold); err != nil {
+			return err
+		}
+		if err := s.limiter.RecordFailure(ctx, linkKey, policy.IPThreshold); err != nil {
+			return err
+		}
+		return apperror.New("INVALID_LINK_PASSWORD", "incorrect password")
+	}
+	s.limiter.Reset(ctx, addrKey)
+	return nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *service) Create(ctx context.Context, userID int64, in CreateLinkInput) (*Link, string, error) {
+	now := s.clock.Now()
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(now) {
+		return nil, "", apperror.New("INVALID_EXPIRES_AT", "expires_at must be in the future")
+	}
+	if len(in.Password) > 72 {
+		return nil, "", apperror.New("INVALID_PASSWORD", "password must be at most 72 bytes")
+	}
+
+	// 公开内容相当于对外发布，需要写权限
+	workspaceID, err := s.authorizeTarget(ctx, userID, in.TargetType, in.TargetID, group.AccessWrite)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", apperror.New("INTERNAL_ERROR", "failed to generate link")
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+
+	l := &Link{
+		TargetType:  in.TargetType,
+		TargetID:    in.TargetID,
+		WorkspaceID: workspaceID,
+		CreatedBy:   userID,
+		Hash:        hashToken(raw),
+		ExpiresAt:   in.ExpiresAt,
+		CreatedAt:   now,
+	}
+	if in.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, "", apperror.New("INTERNAL_ERROR", "failed to hash password")
+		}
+		l.PasswordHash = string(hash)
+		l.HasPassword = true
+	}
+
+	if err := s.repo.Create(ctx, l); err != nil {
+		return nil, "", err
+	}
+	return l, raw, nil
+}
+
+// authorizeTarget 按任务或分组的权限校验，返回目标所在的工作区
+func (s *service) authorizeTarget(ctx context.Context, userID int64, targetType string, targetID int64, need group.Access) (int64, error) {
+	switch targetType {
+	case TargetTask:
+		t, err := s.tasks.Authorize(ctx, userID, targetID, need)
+		if err != nil {
+			return 0, err
+		}
+		return t.WorkspaceID, nil
+	case TargetGroup:
+		g, err := s.groups.Authorize(ctx, userID, targetID, need)
+		if err != nil {
+			return 0, err
+		}
+		return g.WorkspaceID, nil
+	}
+	return 0, apperror.New("INVALID_TARGET", "target_type must be task or group")
+}
+
+func (s *service) List(ctx context.Context, userID int64) ([]*Link, error) {
+	return s.repo.ListByCreator(ctx, userID)
+}
+
+func (s *service) ListForTarget(ctx context.Context, userID int64, targetType string, targetID int64) ([]*Link, error) {
+	if _, err := s.authorizeTarget(ctx, userID, targetType, targetID, group.AccessManage); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByTarget(ctx, targetType, targetID)
+}
+
+func (s *service) Revoke(ctx context.Context, userID, id int64) error {
+	notFound := apperror.New("LINK_NOT_FOUND", "share link not found")
+	l, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return notFound
+	}
+	// 不是创建者时需要目标的管理权限；看不到目标的人当作链接不存在
+	if l.CreatedBy != userID {
+		if _, err := s.authorizeTarget(ctx, userID, l.TargetType, l.TargetID, group.AccessManage); err != nil {
+			return notFoundOr(err, notFound)
+		}
+	}
+	return s.repo.Revoke(ctx, id, s.clock.Now())
+}
+
+func (s *service) View(ctx context.Context, raw, password, ip string) (*View, error) {
+	notFound := apperror.New("LINK_NOT_FOUND", "link not found or no longer available")
+	if raw == "" {
+		return nil, notFound
+	}
+
+	l, err := s.repo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	// 撤销和过期的链接与不存在的一样处理
+	if l == nil || l.RevokedAt != nil || (l.ExpiresAt != nil && !l.ExpiresAt.After(now)) {
+		return nil, notFound
+	}
+	if l.PasswordHash != "" {
+		if password == "" {
+			return nil, apperror.New("PASSWORD_REQUIRED", "this link is password protected")
+		}
+		if err := s.checkPassword(ctx, l, password, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	// 每次都按创建者当前的权限校验，创建者离开工作区或失去写权限后链接失效，和日历订阅一样
+	if _, err := s.authorizeTarget(ctx, l.CreatedBy, l.TargetType, l.TargetID, group.AccessWrite); err != nil {
+		return nil, notFoundOr(err, notFound)
+	}
+
+	v := &View{TargetType: l.TargetType, ExpiresAt: l.ExpiresAt}
+	switch l.TargetType {
+	case TargetTask:
+		t, err := s.taskRepo.GetByIDUnscoped(ctx, l.TargetID)
+		if err != nil {
+			return nil, notFoundOr(err, notFound)
+		}
+		v.Task = toPublicTask(t)
+	case TargetGroup:
+		g, err := s.groupRepo.GetByID(ctx, l.TargetID)
+		if err != nil {
+			return nil, notFoundOr(err, notFound)
+		}
+		tasks, err := s.taskRepo.ListByGroup(ctx, g.ID)
+		if err != nil {
+			return nil, err
+		}
+		pg := &PublicGroup{Name: g.Name, Tasks: make([]*PublicTask, 0, len(tasks))}
+		for _, t := range tasks {
+			pg.Tasks = append(pg.Tasks, toPublicTask(t))
+		}
+		v.Group = pg
+	default:
+		return nil, notFound
+	}
+
+	// 统计失败不影响查看
+	_ = s.repo.RecordView(ctx, l.ID, now)
+	return v, nil
+}
+
+// notFoundOr 目标已被删除或无权访问时按链接不存在处理
+func notFoundOr(err error, notFound error) error {
+	appErr, ok := apperror.IsAppError(err)
+	if !ok {
+		return err
+	}
+	switch appErr.Code {
+	case "TASK_NOT_FOUND", "GROUP_NOT_FOUND", "WORKSPACE_NOT_FOUND", "WORKSPACE_FORBIDDEN", "GROUP_FORBIDDEN", "INVALID_TARGET":
+		return notFound
+	}
+	return err
+}
+
+func toPublicTask(t *task.Task) *PublicTask {
+	return &PublicTask{
+		Title:          t.Title,
+		Description:    t.Description,
+		Status:         t.Status,
+		StatusCategory: t.StatusCategory,
+		DueDate:        t.DueDate,
+		DueOn:          t.DueOn,
+		Priority:       t.Priority,
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+	}
+}
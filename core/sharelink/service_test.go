@@ -0,0 +1,183 @@
+package sharelink_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tasker/core/group"
+	"tasker/core/sharelink"
+	"tasker/core/task"
+	"tasker/pkg/apperror"
+)
+
+// fakeRepo 内存版的sharelink.Repository
+type fakeRepo struct {
+	sharelink.Repository
+	links []*sharelink.Link
+}
+
+func (r *fakeRepo) Create(ctx context.Context, l *sharelink.Link) error {
+	l.ID = int64(len(r.links) + 1)
+	r.links = append(r.links, l)
+	return nil
+}
+
+func (r *fakeRepo) ListByTarget(ctx context.Context, targetType string, targetID int64) ([]*sharelink.Link, error) {
+	var out []*sharelink.Link
+	for _, l := range r.links {
+		if l.TargetType == targetType && l.TargetID == targetID {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) GetByID(ctx context.Context, id int64) (*sharelink.Link, error) {
+	for _, l := range r.links {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) GetByHash(ctx context.Context, hash string) (*sharelink.Link, error) {
+	for _, l := range r.links {
+		if l.Hash == hash {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) Revoke(ctx context.Context, id int64, at time.Time) error {
+	l, _ := r.GetByID(ctx, id)
+	if l.RevokedAt == nil {
+		l.RevokedAt = &at
+	}
+	return nil
+}
+
+func (r *fakeRepo) RecordView(ctx context.Context, id int64, at time.Time) error {
+	return nil
+}
+
+// fakeGroups 分组1上每个用户的访问级别，没有记录的用户看不到分组
+type fakeGroups struct {
+	group.Service
+	access map[int64]group.Access
+}
+
+func (g *fakeGroups) Authorize(ctx context.Context, userID, groupID int64, need group.Access) (*group.Group, error) {
+	have, ok := g.access[userID]
+	if !ok || groupID != 1 {
+		return nil, apperror.New("GROUP_NOT_FOUND", "group not found")
+	}
+	if have < need {
+		return nil, apperror.New("WORKSPACE_FORBIDDEN", "your workspace role does not allow this action")
+	}
+	return &group.Group{ID: 1, WorkspaceID: 1, Name: "team"}, nil
+}
+
+type fakeTaskRepo struct {
+	task.Repository
+}
+
+func (fakeTaskRepo) ListByGroup(ctx context.Context, groupID int64) ([]*task.Task, error) {
+	return []*task.Task{{ID: 1, Title: "public"}}, nil
+}
+
+type fakeGroupRepo struct {
+	group.Repository
+}
+
+func (fakeGroupRepo) GetByID(ctx context.Context, id int64) (*group.Group, error) {
+	return &group.Group{ID: id, WorkspaceID: 1, Name: "team"}, nil
+}
+
+const (
+	creator = int64(1)
+	manager = int64(2)
+	member  = int64(3)
+)
+
+func newService() (sharelink.Service, *fakeRepo, *fakeGroups) {
+	repo := &fakeRepo{}
+	groups := &fakeGroups{access: map[int64]group.Access{
+		creator: group.AccessWrite,
+		manager: group.AccessManage,
+		member:  group.AccessWrite,
+	}}
+	return sharelink.NewService(repo, nil, groups, fakeTaskRepo{}, fakeGroupRepo{}), repo, groups
+}
+
+func wantCode(t *testing.T, err error, code string) {
+	t.Helper()
+	appErr, ok := apperror.IsAppError(err)
+	if !ok || appErr.Code != code {
+		t.Fatalf("err = %v, want %s", err, code)
+	}
+}
+
+func TestViewRechecksCreatorAccess(t *testing.T) {
+	svc, _, groups := newService()
+	ctx := context.Background()
+	_, raw, err := svc.Create(ctx, creator, sharelink.CreateLinkInput{TargetType: sharelink.TargetGroup, TargetID: 1})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if v, err := svc.View(ctx, raw, "", "127.0.0.1"); err != nil || v.Group == nil || len(v.Group.Tasks) != 1 {
+		t.Fatalf("View = %+v, %v", v, err)
+	}
+
+	// 创建者降为只读后链接失效，恢复写权限后又能访问
+	groups.access[creator] = group.AccessRead
+	_, err = svc.View(ctx, raw, "", "127.0.0.1")
+	wantCode(t, err, "LINK_NOT_FOUND")
+
+	delete(groups.access, creator)
+	_, err = svc.View(ctx, raw, "", "127.0.0.1")
+	wantCode(t, err, "LINK_NOT_FOUND")
+
+	groups.access[creator] = group.AccessWrite
+	if _, err := svc.View(ctx, raw, "", "127.0.0.1"); err != nil {
+		t.Fatalf("View after restoring access: %v", err)
+	}
+}
+
+func TestManagersListAndRevokeLinks(t *testing.T) {
+	svc, repo, _ := newService()
+	ctx := context.Background()
+	l, _, err := svc.Create(ctx, creator, sharelink.CreateLinkInput{TargetType: sharelink.TargetGroup, TargetID: 1})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	links, err := svc.ListForTarget(ctx, manager, sharelink.TargetGroup, 1)
+	if err != nil || len(links) != 1 || links[0].ID != l.ID {
+		t.Fatalf("ListForTarget(manager) = %v, %v", links, err)
+	}
+	_, err = svc.ListForTarget(ctx, member, sharelink.TargetGroup, 1)
+	wantCode(t, err, "WORKSPACE_FORBIDDEN")
+	_, err = svc.ListForTarget(ctx, member, "workspace", 1)
+	wantCode(t, err, "INVALID_TARGET")
+
+	// 没有管理权限的成员当作链接不存在
+	wantCode(t, svc.Revoke(ctx, member, l.ID), "LINK_NOT_FOUND")
+	wantCode(t, svc.Revoke(ctx, 99, l.ID), "LINK_NOT_FOUND")
+	if repo.links[0].RevokedAt != nil {
+		t.Fatal("link revoked by a member")
+	}
+	if err := svc.Revoke(ctx, manager, l.ID); err != nil {
+		t.Fatalf("Revoke(manager): %v", err)
+	}
+	if repo.links[0].RevokedAt == nil {
+		t.Fatal("link not revoked")
+	}
+	// 创建者重复撤销不报错
+	if err := svc.Revoke(ctx, creator, l.ID); err != nil {
+		t.Fatalf("Revoke(creator): %v", err)
+	}
+	wantCode(t, svc.Revoke(ctx, creator, 42), "LINK_NOT_FOUND")
+}
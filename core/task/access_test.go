@@ -0,0 +1,117 @@
+package task
+
+import (
+	"context"
+	"testing"
+
+	"tasker/core/group"
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+)
+
+// shareGroups 分组服务的仓储：一个分组，按用户共享
+type shareGroups struct {
+	group.Repository
+	g      *group.Group
+	shares map[int64]*group.Share
+}
+
+func (r *shareGroups) GetByID(ctx context.Context, id int64) (*group.Group, error) {
+	if id != r.g.ID {
+		return nil, apperror.New("GROUP_NOT_FOUND", "group not found")
+	}
+	g := *r.g
+	return &g, nil
+}
+
+func (r *shareGroups) GetShare(ctx context.Context, groupID, userID int64) (*group.Share, error) {
+	return r.shares[userID], nil
+}
+
+// fakeMembers 按用户给出工作区角色，不在里面的用户不是成员
+type fakeMembers struct {
+	workspace.Service
+	roles map[int64]workspace.Role
+}
+
+func (w fakeMembers) Authorize(ctx context.Context, userID, workspaceID int64, min workspace.Role) (*workspace.Member, error) {
+	role, ok := w.roles[userID]
+	if !ok {
+		return nil, apperror.New("WORKSPACE_NOT_FOUND", "workspace not found")
+	}
+	if !role.AtLeast(min) {
+		return nil, apperror.New("WORKSPACE_FORBIDDEN", "your workspace role does not allow this action")
+	}
+	return &workspace.Member{WorkspaceID: workspaceID, UserID: userID, Role: role}, nil
+}
+
+// 共享的分组里读写分开校验：只读共享能看不能删，读写共享两者都能；
+// 没有分组的任务只按工作区角色判断，共享不起作用
+func TestTaskAccess(t *testing.T) {
+	const (
+		member   = 1
+		viewer   = 2
+		reader   = 3
+		writer   = 4
+		stranger = 5
+	)
+	groupID := int64(10)
+	members := fakeMembers{roles: map[int64]workspace.Role{member: workspace.RoleMember, viewer: workspace.RoleViewer}}
+	groups := group.NewService(&shareGroups{
+		g: &group.Group{ID: groupID, WorkspaceID: 1, UserID: member},
+		shares: map[int64]*group.Share{
+			reader: {GroupID: groupID, UserID: reader, Permission: group.PermissionRead},
+			writer: {GroupID: groupID, UserID: writer, Permission: group.PermissionWrite},
+		},
+	}, members)
+
+	tests := []struct {
+		name       string
+		userID     int64
+		ungrouped  bool
+		readCode   string // 空表示允许
+		deleteCode string
+	}{
+		{name: "member", userID: member},
+		{name: "viewer", userID: viewer, deleteCode: "WORKSPACE_FORBIDDEN"},
+		{name: "read share", userID: reader, deleteCode: "GROUP_FORBIDDEN"},
+		{name: "write share", userID: writer},
+		// 访问不到的任务当作不存在
+		{name: "stranger", userID: stranger, readCode: "TASK_NOT_FOUND", deleteCode: "TASK_NOT_FOUND"},
+		{name: "member, ungrouped", userID: member, ungrouped: true},
+		{name: "viewer, ungrouped", userID: viewer, ungrouped: true, deleteCode: "WORKSPACE_FORBIDDEN"},
+		{name: "write share, ungrouped", userID: writer, ungrouped: true, readCode: "TASK_NOT_FOUND", deleteCode: "TASK_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := &Task{ID: 7, WorkspaceID: 1, Title: "周报", Status: StatusPending}
+			if !tt.ungrouped {
+				tk.GroupID = &groupID
+			}
+			repo := &fakeRepo{tasks: map[int64]*Task{tk.ID: tk}}
+			svc := NewService(repo, groups, fakeUsers{}, members)
+			ctx := context.Background()
+
+			_, err := svc.GetTask(ctx, tt.userID, tk.ID)
+			assertAccess(t, "GetTask", err, tt.readCode)
+			err = svc.DeleteTask(ctx, tt.userID, tk.ID)
+			assertAccess(t, "DeleteTask", err, tt.deleteCode)
+			if deleted := len(repo.deleted) == 1; deleted != (tt.deleteCode == "") {
+				t.Fatalf("deleted = %v", repo.deleted)
+			}
+		})
+	}
+}
+
+func assertAccess(t *testing.T, op string, err error, code string) {
+	t.Helper()
+	if code == "" {
+		if err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+		return
+	}
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != code {
+		t.Fatalf("%s = %v, want %s", op, err, code)
+	}
+}
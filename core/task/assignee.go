@@ -0,0 +1,109 @@
+package task
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+	"tasker/pkg/events"
+)
+
+// EventAssigned 有人被指派到任务上，通知模块订阅它
+const EventAssigned = "task.assigned"
+
+// AssignedEvent EventAssigned的Payload，只包含这次新增的负责人
+type AssignedEvent struct {
+	TaskID      int64
+	Title       string
+	GroupID     *int64
+	AssigneeIDs []int64
+}
+
+// Option 可选依赖
+type Option func(*service)
+
+// WithEvents 注入事件发布，默认丢弃
+func WithEvents(p events.Publisher) Option {
+	return func(s *service) {
+		s.events = p
+	}
+}
+
+// parseAssigneeFilter 解析 assignee=me|unassigned|<用户id>
+func parseAssigneeFilter(userID int64, filter *ListTaskerFilter) error {
+	switch filter.Assignee {
+	case "":
+		return nil
+	case "me":
+		filter.AssigneeID = &userID
+		return nil
+	case "unassigned":
+		filter.Unassigned = true
+		return nil
+	}
+	id, err := strconv.ParseInt(filter.Assignee, 10, 64)
+	if err != nil || id <= 0 {
+		return apperror.New("INVALID_ASSIGNEE", "assignee must be me, unassigned or a user id")
+	}
+	filter.AssigneeID = &id
+	return nil
+}
+
+// checkAssignees 去重并确认每个负责人都能访问任务所在的分组
+func (s *service) checkAssignees(ctx context.Context, groupID int64, ids []int64) ([]int64, error) {
+	seen := make(map[int64]struct{}, len(ids))
+	result := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		if id <= 0 {
+			return nil, apperror.New("INVALID_ASSIGNEE", "assignee ids must be positive integers")
+		}
+		if _, err := s.groupSvc.Authorize(ctx, id, groupID, group.AccessRead); err != nil {
+			if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "GROUP_NOT_FOUND" {
+				return nil, apperror.New("INVALID_ASSIGNEE", "user "+strconv.FormatInt(id, 10)+" has no access to this task's group")
+			}
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
+// newAssignees 返回after里有、before里没有的负责人
+func newAssignees(before, after []int64) []int64 {
+	old := make(map[int64]struct{}, len(before))
+	for _, id := range before {
+		old[id] = struct{}{}
+	}
+	added := make([]int64, 0)
+	for _, id := range after {
+		if _, ok := old[id]; !ok {
+			added = append(added, id)
+		}
+	}
+	return added
+}
+
+// publishAssigned 有新增负责人时发布事件
+func (s *service) publishAssigned(ctx context.Context, actorID int64, t *Task, added []int64) {
+	if len(added) == 0 {
+		return
+	}
+	s.events.Publish(ctx, events.Event{
+		Type:        EventAssigned,
+		WorkspaceID: t.WorkspaceID,
+		ActorID:     actorID,
+		Payload: AssignedEvent{
+			TaskID:      t.ID,
+			Title:       t.Title,
+			GroupID:     t.GroupID,
+			AssigneeIDs: added,
+		},
+	})
+}
@@ -0,0 +1,244 @@
+package task
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+	"tasker/pkg/rank"
+)
+
+// rank超过这个长度时在后台重排整个分组
+const rebalanceAt = 24
+
+// 移动任务的入参：after_id/before_id是目标列里移动后紧挨着的上一个/下一个任务，
+// 都不填时放到列的最后
+type MoveTaskInput struct {
+	// 移动到同一工作区的另一个分组，nil表示不换分组
+	GroupID *int64 `json:"group_id"`
+	// 目标列，为空表示不换状态
+	Status   Status `json:"status"`
+	AfterID  *int64 `json:"after_id"`
+	BeforeID *int64 `json:"before_id"`
+}
+
+// Column 看板的一列，对应工作流里的一个状态
+type Column struct {
+	State group.State `json:"state"`
+	Tasks []*Task     `json:"tasks"`
+}
+
+// Board 分组的看板，列按工作流顺序，列内按rank排序
+type Board struct {
+	Group   *group.Group `json:"group"`
+	Columns []*Column    `json:"columns"`
+}
+
+func (s *service) MoveTask(ctx context.Context, userID, id int64, in MoveTaskInput) (*Task, error) {
+	t, g, err := s.authorize(ctx, userID, id, group.AccessWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	fromGroupID := t.GroupID
+	target, moved, err := s.moveTarget(ctx, userID, t, in.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	if moved {
+		g = target
+		t.GroupID = &target.ID
+	}
+	if g == nil {
+		return nil, apperror.New("INVALID_GROUP", "move the task into a group first")
+	}
+
+	status := in.Status
+	if status == "" {
+		status = t.Status
+	}
+	st, err := nextState(t, group.WorkflowOf(g), status, moved)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []int64
+	if moved {
+		// 负责人也要能访问新分组
+		assignees, err := s.checkAssignees(ctx, g.ID, t.AssigneeIDs)
+		if err != nil {
+			return nil, err
+		}
+		added = newAssignees(t.AssigneeIDs, assignees)
+		t.AssigneeIDs = assignees
+	}
+
+	prevCategory := t.StatusCategory
+	now := time.Now()
+	setState(t, st, now)
+	t.UpdatedAt = now
+
+	// 算rank和保存在同一把分组锁里，避免和其他移动或后台重排交错
+	err = s.repo.Reorder(ctx, userID, t, func(store RankStore) error {
+		var err error
+		t.Rank, err = s.rankBetween(ctx, store, userID, t, in.AfterID, in.BeforeID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Rank) > rebalanceAt {
+		s.rebalance(g.ID)
+	}
+	if moved {
+		s.publishChanged(ctx, EventMoved, userID, t, fromGroupID)
+	}
+	s.publishStatusChange(ctx, userID, t, prevCategory)
+	s.publishAssigned(ctx, userID, t, added)
+	if moved {
+		// 能看到任务的人变了，重新检查提及
+		s.syncSavedMentions(ctx, userID, t)
+	} else if err := s.attachMentions(ctx, t); err != nil {
+		return nil, err
+	}
+	loc, err := s.location(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	localize(t, loc)
+	return t, nil
+}
+
+// rankBetween 算出t在目标列（t.GroupID, t.Status）里after和before之间的rank。
+// 只给一边时另一边取列里相邻的任务
+func (s *service) rankBetween(ctx context.Context, store RankStore, userID int64, t *Task, afterID, beforeID *int64) (string, error) {
+	neighbor := func(id *int64) (*Task, error) {
+		if id == nil {
+			return nil, nil
+		}
+		if *id == t.ID {
+			return nil, apperror.New("INVALID_POSITION", "a task cannot be placed next to itself")
+		}
+		n, err := store.GetByID(ctx, userID, *id)
+		if err != nil {
+			if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "TASK_NOT_FOUND" {
+				return nil, apperror.New("INVALID_POSITION", "after_id and before_id must be tasks in the target column")
+			}
+			return nil, err
+		}
+		if n.GroupID == nil || *n.GroupID != *t.GroupID || n.Status != t.Status {
+			return nil, apperror.New("INVALID_POSITION", "after_id and before_id must be tasks in the target column")
+		}
+		return n, nil
+	}
+
+	// 并发移动可能让相邻任务的rank相同，重排一次后再试
+	for attempt := 0; ; attempt++ {
+		after, err := neighbor(afterID)
+		if err != nil {
+			return "", err
+		}
+		before, err := neighbor(beforeID)
+		if err != nil {
+			return "", err
+		}
+
+		var lo, hi string
+		switch {
+		case after != nil && before != nil:
+			lo, hi = after.Rank, before.Rank
+		case after != nil:
+			lo = after.Rank
+			hi, err = store.AdjacentRank(ctx, *t.GroupID, t.Status, after.Rank, true, t.ID)
+		case before != nil:
+			hi = before.Rank
+			lo, err = store.AdjacentRank(ctx, *t.GroupID, t.Status, before.Rank, false, t.ID)
+		default:
+			lo, err = store.LastRank(ctx, *t.GroupID, t.Status, t.ID)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		r, err := rank.Between(lo, hi)
+		if err == nil {
+			return r, nil
+		}
+		if attempt > 0 {
+			return "", apperror.New("INVALID_POSITION", "after_id must come before before_id")
+		}
+		if err := store.RebalanceRanks(ctx, *t.GroupID); err != nil {
+			return "", err
+		}
+	}
+}
+
+// rankAtEnd 分组里最后一个任务之后的rank，新建和换分组的任务放到最后
+func (s *service) rankAtEnd(ctx context.Context, groupID, excludeID int64) (string, error) {
+	last, err := s.repo.LastRank(ctx, groupID, "", excludeID)
+	if err != nil {
+		return "", err
+	}
+	r, err := rank.Between(last, "")
+	if err != nil {
+		return "", apperror.New("INTERNAL_ERROR", "invalid task rank")
+	}
+	if len(r) > rebalanceAt {
+		s.rebalance(groupID)
+	}
+	return r, nil
+}
+
+// rebalance 在后台重排分组的rank，同一个分组同时只跑一次
+func (s *service) rebalance(groupID int64) {
+	if _, running := s.rebalancing.LoadOrStore(groupID, true); running {
+		return
+	}
+	go func() {
+		defer s.rebalancing.Delete(groupID)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := s.repo.RebalanceRanks(ctx, groupID); err != nil {
+			log.Printf("[task] rebalance ranks of group %d failed: %v", groupID, err)
+		}
+	}()
+}
+
+func (s *service) Board(ctx context.Context, userID, groupID int64) (*Board, error) {
+	g, err := s.groupSvc.Authorize(ctx, userID, groupID, group.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.repo.ListRanked(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachMentions(ctx, tasks...); err != nil {
+		return nil, err
+	}
+	loc, err := s.location(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	wf := group.WorkflowOf(g)
+	board := &Board{Group: g, Columns: make([]*Column, 0, len(wf.States))}
+	columns := make(map[string]*Column, len(wf.States))
+	for _, st := range wf.States {
+		col := &Column{State: st, Tasks: []*Task{}}
+		board.Columns = append(board.Columns, col)
+		columns[st.Key] = col
+	}
+	for _, t := range tasks {
+		localize(t, loc)
+		// 不在工作流里的状态（不应该出现）按大类放
+		col := columns[string(t.Status)]
+		if col == nil {
+			col = columns[wf.Map(string(t.Status), t.StatusCategory).Key]
+		}
+		col.Tasks = append(col.Tasks, t)
+	}
+	return board, nil
+}
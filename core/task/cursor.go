@@ -0,0 +1,65 @@
+package task
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+)
+
+// 列表的两种分页方式：page按页码（OFFSET），cursor按上一页最后一个任务的排序键（keyset）
+const (
+	PaginationPage   = "page"
+	PaginationCursor = "cursor"
+)
+
+// Cursor 游标分页的位置：当前排序的各个键在某个任务上的取值，最后用id区分取值相同的任务。
+// 只填当前排序用到的字段，编码后作为next_cursor/prev_cursor交给客户端
+type Cursor struct {
+	Sort           string         `json:"s"`
+	ID             int64          `json:"i"`
+	CreatedAt      *time.Time     `json:"c,omitempty"`
+	StatusCategory group.Category `json:"g,omitempty"`
+	Status         Status         `json:"t,omitempty"`
+	Rank           string         `json:"r,omitempty"`
+	// 没完成的任务为nil
+	CompletedAt *time.Time `json:"d,omitempty"`
+	// 相关度，sort=relevance时使用
+	Score float64 `json:"v,omitempty"`
+	// 全文检索没有结果，后续页继续用模糊匹配
+	Fuzzy bool `json:"f,omitempty"`
+	// 为true时取这个位置之前的一页
+	Backward bool `json:"b,omitempty"`
+	// 为true时这个位置上的任务也在页里；往前翻到头时，next_cursor用它回到原来那一页
+	Inclusive bool `json:"n,omitempty"`
+}
+
+func encodeCursor(c *Cursor) *string {
+	if c == nil {
+		return nil
+	}
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return nil
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+	return &raw
+}
+
+// decodeCursor 游标对客户端不透明，内容被改过时只会影响这个用户自己的查询
+func decodeCursor(raw, sort string) (*Cursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, apperror.New("INVALID_CURSOR", "cursor is invalid")
+	}
+	var c Cursor
+	if err := json.Unmarshal(buf, &c); err != nil || c.ID <= 0 {
+		return nil, apperror.New("INVALID_CURSOR", "cursor is invalid")
+	}
+	if c.Sort != sort {
+		return nil, apperror.New("INVALID_CURSOR", "cursor was created with a different sort")
+	}
+	return &c, nil
+}
@@ -0,0 +1,165 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"tasker/pkg/date"
+)
+
+// matchesDue 按infra/db里dueExpr的语义判断任务是否落在区间里：
+// 带时刻的截止时间或只有日期的截止日，任意一个在左闭右开区间内即可
+func matchesDue(w *DueWindow, dueAt *time.Time, dueOn *date.Date) bool {
+	if dueAt != nil {
+		if (w.From == nil || !dueAt.Before(*w.From)) && (w.To == nil || dueAt.Before(*w.To)) {
+			return true
+		}
+	}
+	if dueOn != nil {
+		if (w.FromDate == nil || !dueOn.Before(*w.FromDate)) && (w.ToDate == nil || dueOn.Before(*w.ToDate)) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestDayWindowAcrossDST(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	tests := []struct {
+		name     string
+		day      date.Date
+		from, to time.Time
+	}{
+		{"spring forward is 23 hours", date.Date{Year: 2026, Month: time.March, Day: 8},
+			time.Date(2026, 3, 8, 5, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 4, 0, 0, 0, time.UTC)},
+		{"fall back is 25 hours", date.Date{Year: 2026, Month: time.November, Day: 1},
+			time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC), time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC)},
+		{"ordinary day", date.Date{Year: 2026, Month: time.June, Day: 15},
+			time.Date(2026, 6, 15, 4, 0, 0, 0, time.UTC), time.Date(2026, 6, 16, 4, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := dayWindow(tt.day, ny)
+			if !w.From.Equal(tt.from) || !w.To.Equal(tt.to) {
+				t.Fatalf("window = [%s, %s), want [%s, %s)", w.From.UTC(), w.To.UTC(), tt.from, tt.to)
+			}
+			if *w.FromDate != tt.day || *w.ToDate != tt.day.AddDays(1) {
+				t.Fatalf("dates = [%s, %s)", w.FromDate, w.ToDate)
+			}
+		})
+	}
+}
+
+func TestDueWindowToday(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	at := func(month time.Month, day, hour, min int) *time.Time {
+		v := time.Date(2026, month, day, hour, min, 0, 0, ny)
+		return &v
+	}
+	on := func(month time.Month, day int) *date.Date {
+		return &date.Date{Year: 2026, Month: month, Day: day}
+	}
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		dueAt *time.Time
+		dueOn *date.Date
+		want  bool
+	}{
+		// 3月8日只有23小时：0点到次日0点
+		{"spring forward just after midnight", *at(3, 8, 12, 0), at(3, 8, 0, 30), nil, true},
+		{"spring forward last minute", *at(3, 8, 12, 0), at(3, 8, 23, 59), nil, true},
+		{"spring forward next midnight", *at(3, 8, 12, 0), at(3, 9, 0, 0), nil, false},
+		{"spring forward day before", *at(3, 8, 12, 0), at(3, 7, 23, 59), nil, false},
+		// 11月1日有25小时，1点到2点出现两次
+		{"fall back second 1:30", *at(11, 1, 12, 0), ptrTime(time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC)), nil, true},
+		{"fall back last minute", *at(11, 1, 12, 0), at(11, 1, 23, 59), nil, true},
+		{"fall back next midnight", *at(11, 1, 12, 0), at(11, 2, 0, 0), nil, false},
+		// 晚上11点的纽约已经是UTC的第二天，today仍然按用户时区
+		{"late evening uses local day", *at(11, 1, 23, 0), at(11, 1, 9, 0), nil, true},
+		{"date-only due today", *at(3, 8, 23, 30), nil, on(3, 8), true},
+		{"date-only due tomorrow", *at(3, 8, 23, 30), nil, on(3, 9), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := dueWindow("today", tt.now, ny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchesDue(w, tt.dueAt, tt.dueOn); got != tt.want {
+				t.Fatalf("matches = %v, want %v (window [%s, %s))", got, tt.want, w.From.UTC(), w.To.UTC())
+			}
+		})
+	}
+}
+
+func TestDueWindowOverdue(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	now := time.Date(2026, 11, 1, 10, 0, 0, 0, ny)
+	at := func(day, hour int) *time.Time {
+		v := time.Date(2026, 11, day, hour, 0, 0, 0, ny)
+		return &v
+	}
+	on := func(month time.Month, day int) *date.Date {
+		return &date.Date{Year: 2026, Month: month, Day: day}
+	}
+
+	tests := []struct {
+		name  string
+		dueAt *time.Time
+		dueOn *date.Date
+		want  bool
+	}{
+		// 带时刻的过了那一刻就逾期
+		{"due_date earlier today", at(1, 9), nil, true},
+		{"due_date later today", at(1, 11), nil, false},
+		{"due_date yesterday", at(0, 23), nil, true},
+		// 只有日期的要等那一天过完
+		{"due_on today", nil, on(11, 1), false},
+		{"due_on yesterday", nil, on(10, 31), true},
+		{"due_on tomorrow", nil, on(11, 2), false},
+		{"no due date", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := dueWindow("overdue", now, ny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchesDue(w, tt.dueAt, tt.dueOn); got != tt.want {
+				t.Fatalf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDueWindowDate(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, ny)
+
+	w, err := dueWindow("2026-11-01", now, ny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := w.To.Sub(*w.From); got != 25*time.Hour {
+		t.Fatalf("2026-11-01 window is %s long, want 25h", got)
+	}
+
+	if _, err := dueWindow("2026-13-01", now, ny); err == nil {
+		t.Fatal("invalid date accepted")
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,83 @@
+package task
+
+import (
+	"context"
+
+	"tasker/core/group"
+	"tasker/pkg/events"
+)
+
+// 任务生命周期事件，动态流等模块订阅
+const (
+	EventCreated   = "task.created"
+	EventCompleted = "task.completed"
+	EventReopened  = "task.reopened"
+	// 在同一工作区的分组之间移动
+	EventMoved = "task.moved"
+)
+
+// ChangedEvent 生命周期事件的Payload
+type ChangedEvent struct {
+	TaskID  int64
+	Title   string
+	GroupID *int64
+	// 只有EventMoved才有，移动前的分组
+	FromGroupID *int64
+}
+
+// publishChanged 发布一条生命周期事件
+func (s *service) publishChanged(ctx context.Context, eventType string, actorID int64, t *Task, from *int64) {
+	s.events.Publish(ctx, events.Event{
+		Type:        eventType,
+		WorkspaceID: t.WorkspaceID,
+		ActorID:     actorID,
+		Payload: ChangedEvent{
+			TaskID:      t.ID,
+			Title:       t.Title,
+			GroupID:     t.GroupID,
+			FromGroupID: from,
+		},
+	})
+}
+
+// publishStatusChange 按状态大类判断完成和重新打开
+func (s *service) publishStatusChange(ctx context.Context, actorID int64, t *Task, prev group.Category) {
+	switch {
+	case prev != group.CategoryDone && t.StatusCategory == group.CategoryDone:
+		s.publishChanged(ctx, EventCompleted, actorID, t, nil)
+	case prev == group.CategoryDone && t.StatusCategory != group.CategoryDone:
+		s.publishChanged(ctx, EventReopened, actorID, t, nil)
+	}
+}
+
+// ImportedTask 导入写入的一个任务，更新的任务带上写入前的分组、状态大类和描述
+type ImportedTask struct {
+	Task            *Task
+	Created         bool
+	PrevGroupID     *int64
+	PrevCategory    group.Category
+	PrevDescription string
+}
+
+// PublishImported 导入的事务提交后，按CreateTask、UpdateTask的规则发布事件，并同步变了的描述里的提及
+func (s *service) PublishImported(ctx context.Context, actorID int64, tasks []ImportedTask) {
+	for _, it := range tasks {
+		t := it.Task
+		if it.Created {
+			s.publishChanged(ctx, EventCreated, actorID, t, nil)
+		} else if !sameGroup(it.PrevGroupID, t.GroupID) {
+			s.publishChanged(ctx, EventMoved, actorID, t, it.PrevGroupID)
+		}
+		s.publishStatusChange(ctx, actorID, t, it.PrevCategory)
+		if t.Description != it.PrevDescription {
+			s.syncSavedMentions(ctx, actorID, t)
+		}
+	}
+}
+
+func sameGroup(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,62 @@
+package task
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"tasker/core/group"
+	"tasker/pkg/events"
+)
+
+type recordingPublisher struct {
+	types []string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e events.Event) {
+	p.types = append(p.types, e.Type)
+}
+
+func TestPublishImported(t *testing.T) {
+	inbox, later := int64(1), int64(2)
+	tests := []struct {
+		name string
+		in   ImportedTask
+		want []string
+	}{
+		{
+			name: "created",
+			in:   ImportedTask{Task: &Task{GroupID: &inbox, StatusCategory: group.CategoryTodo}, Created: true},
+			want: []string{EventCreated},
+		},
+		{
+			name: "created done",
+			in:   ImportedTask{Task: &Task{GroupID: &inbox, StatusCategory: group.CategoryDone}, Created: true},
+			want: []string{EventCreated, EventCompleted},
+		},
+		{
+			name: "updated in place",
+			in:   ImportedTask{Task: &Task{GroupID: &inbox, StatusCategory: group.CategoryTodo}, PrevGroupID: &inbox, PrevCategory: group.CategoryTodo},
+		},
+		{
+			name: "moved and reopened",
+			in:   ImportedTask{Task: &Task{GroupID: &later, StatusCategory: group.CategoryDoing}, PrevGroupID: &inbox, PrevCategory: group.CategoryDone},
+			want: []string{EventMoved, EventReopened},
+		},
+		{
+			name: "ungrouped task moved into a group",
+			in:   ImportedTask{Task: &Task{GroupID: &inbox, StatusCategory: group.CategoryTodo}, PrevCategory: group.CategoryTodo},
+			want: []string{EventMoved},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &recordingPublisher{}
+			s := &service{events: pub}
+			s.PublishImported(context.Background(), 1, []ImportedTask{tt.in})
+			if !reflect.DeepEqual(pub.types, tt.want) {
+				t.Fatalf("published %v, want %v", pub.types, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package task
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+	"tasker/pkg/date"
+	"tasker/pkg/filterql"
+)
+
+/*
+列表的filter参数，语法见pkg/filterql，例如
+
+	status:pending priority>=high due<7d group:"Work" tag:urgent -tag:someday
+
+这里把语法树换成Condition，字段和取值都在这里校验，repo只负责翻译成参数化的SQL。
+日期按用户时区解释
+*/
+
+// Condition filter解析后的条件树
+type Condition interface {
+	condition()
+}
+
+type AndCondition []Condition
+
+type OrCondition []Condition
+
+type NotCondition struct {
+	Condition Condition
+}
+
+// StatusCondition 状态key精确匹配
+type StatusCondition struct {
+	Status Status
+}
+
+type CategoryCondition struct {
+	Categories []group.Category
+}
+
+type PriorityCondition struct {
+	Priorities []string
+}
+
+// DueCondition Window为nil表示没有截止时间
+type DueCondition struct {
+	Window *DueWindow
+}
+
+// TimeField 可以按区间筛选的时间字段
+type TimeField string
+
+const (
+	TimeCreated   TimeField = "created"
+	TimeUpdated   TimeField = "updated"
+	TimeCompleted TimeField = "completed"
+)
+
+// TimeCondition 左闭右开，nil表示不限；None表示字段为空（只有completed可能为空）
+type TimeCondition struct {
+	Field    TimeField
+	From, To *time.Time
+	None     bool
+}
+
+// GroupCondition 按分组名匹配，不区分大小写；None表示不在任何分组里
+type GroupCondition struct {
+	Name string
+	None bool
+}
+
+// AssigneeCondition UserID和Username二选一；None表示没有负责人
+type AssigneeCondition struct {
+	UserID   *int64
+	Username string
+	None     bool
+}
+
+// TagCondition 带有名为Name的标签，不区分大小写；None表示没有任何标签
+type TagCondition struct {
+	Name string
+	None bool
+}
+
+// TitleCondition 标题包含Text，不区分大小写
+type TitleCondition struct {
+	Text string
+}
+
+// TextCondition 不带字段的词，在标题和描述里做全文检索，引号里的词按短语匹配
+type TextCondition struct {
+	Text string
+}
+
+func (AndCondition) condition()      {}
+func (OrCondition) condition()       {}
+func (NotCondition) condition()      {}
+func (StatusCondition) condition()   {}
+func (CategoryCondition) condition() {}
+func (PriorityCondition) condition() {}
+func (DueCondition) condition()      {}
+func (TimeCondition) condition()     {}
+func (GroupCondition) condition()    {}
+func (AssigneeCondition) condition() {}
+func (TagCondition) condition()      {}
+func (TitleCondition) condition()    {}
+func (TextCondition) condition()     {}
+
+// priorityLevels 优先级从低到高，比较运算按这个顺序
+var priorityLevels = []string{"low", "medium", "high"}
+
+// filterResolver 解析时需要的上下文
+type filterResolver struct {
+	userID int64
+	now    time.Time
+	loc    *time.Location
+}
+
+// parseFilter 解析filter参数，空白返回nil
+func parseFilter(input string, userID int64, now time.Time, loc *time.Location) (Condition, error) {
+	expr, err := filterql.Parse(input)
+	if err != nil {
+		return nil, invalidFilter(err)
+	}
+	if expr == nil {
+		return nil, nil
+	}
+	r := &filterResolver{userID: userID, now: now, loc: loc}
+	c, err := r.resolve(expr)
+	if err != nil {
+		return nil, invalidFilter(err)
+	}
+	return c, nil
+}
+
+func invalidFilter(err error) error {
+	return apperror.New("INVALID_FILTER", "invalid filter: "+err.Error())
+}
+
+func (r *filterResolver) resolve(e filterql.Expr) (Condition, error) {
+	switch e := e.(type) {
+	case *filterql.And:
+		left, err := r.resolve(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := r.resolve(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		// 把a b c展开成一层
+		if and, ok := left.(AndCondition); ok {
+			return append(and, right), nil
+		}
+		return AndCondition{left, right}, nil
+	case *filterql.Or:
+		left, err := r.resolve(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := r.resolve(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		if or, ok := left.(OrCondition); ok {
+			return append(or, right), nil
+		}
+		return OrCondition{left, right}, nil
+	case *filterql.Not:
+		x, err := r.resolve(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return NotCondition{Condition: x}, nil
+	case *filterql.Term:
+		c, err := r.term(e)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == filterql.OpNe {
+			return NotCondition{Condition: c}, nil
+		}
+		return c, nil
+	}
+	return nil, filterql.Errorf(e.Pos(), "unsupported expression")
+}
+
+// term 解析单个条件。!=按=解析，由调用方取反
+func (r *filterResolver) term(t *filterql.Term) (Condition, error) {
+	if t.Field == "" {
+		return TextCondition{Text: t.Value}, nil
+	}
+	// 值是否是不带引号的关键字，"none"可以匹配名字就叫none的分组
+	is := func(keyword string) bool {
+		return !t.Quoted && strings.EqualFold(t.Value, keyword)
+	}
+	switch t.Field {
+	case "status":
+		if err := equalityOnly(t); err != nil {
+			return nil, err
+		}
+		switch {
+		case is(string(StatusPending)):
+			return CategoryCondition{Categories: openCategories}, nil
+		case is(string(StatusCompleted)):
+			return CategoryCondition{Categories: []group.Category{group.CategoryDone}}, nil
+		}
+		if t.Value == "" || len(t.Value) > 20 {
+			return nil, filterql.Errorf(t.ValuePos, "unknown status %q", t.Value)
+		}
+		return StatusCondition{Status: Status(t.Value)}, nil
+
+	case "category":
+		if err := equalityOnly(t); err != nil {
+			return nil, err
+		}
+		c := group.Category(strings.ToLower(t.Value))
+		if !c.Valid() {
+			return nil, filterql.Errorf(t.ValuePos, "category must be todo, doing or done")
+		}
+		return CategoryCondition{Categories: []group.Category{c}}, nil
+
+	case "priority":
+		return priorityCondition(t)
+
+	case "due":
+		if is("overdue") {
+			if err := equalityOnly(t); err != nil {
+				return nil, err
+			}
+			// 和due=overdue一致：已完成的任务不算逾期
+			window, _ := dueWindow("overdue", r.now, r.loc)
+			return AndCondition{
+				DueCondition{Window: window},
+				CategoryCondition{Categories: openCategories},
+			}, nil
+		}
+		if is("none") {
+			if err := equalityOnly(t); err != nil {
+				return nil, err
+			}
+			return DueCondition{}, nil
+		}
+		day, err := r.date(t)
+		if err != nil {
+			return nil, err
+		}
+		from, to := dateRange(t.Op, day)
+		w := &DueWindow{FromDate: from, ToDate: to}
+		if from != nil {
+			at := from.StartIn(r.loc)
+			w.From = &at
+		}
+		if to != nil {
+			at := to.StartIn(r.loc)
+			w.To = &at
+		}
+		return DueCondition{Window: w}, nil
+
+	case "created", "updated", "completed":
+		field := TimeField(t.Field)
+		if field == TimeCompleted && is("none") {
+			if err := equalityOnly(t); err != nil {
+				return nil, err
+			}
+			return TimeCondition{Field: field, None: true}, nil
+		}
+		day, err := r.date(t)
+		if err != nil {
+			return nil, err
+		}
+		from, to := dateRange(t.Op, day)
+		c := TimeCondition{Field: field}
+		if from != nil {
+			at := from.StartIn(r.loc)
+			c.From = &at
+		}
+		if to != nil {
+			at := to.StartIn(r.loc)
+			c.To = &at
+		}
+		return c, nil
+
+	case "group":
+		if err := equalityOnly(t); err != nil {
+			return nil, err
+		}
+		if is("none") {
+			return GroupCondition{None: true}, nil
+		}
+		if t.Value == "" {
+			return nil, filterql.Errorf(t.ValuePos, "group name is required")
+		}
+		return GroupCondition{Name: t.Value}, nil
+
+	case "assignee":
+		if err := equalityOnly(t); err != nil {
+			return nil, err
+		}
+		switch {
+		case is("me"):
+			return AssigneeCondition{UserID: &r.userID}, nil
+		case is("none"):
+			return AssigneeCondition{None: true}, nil
+		}
+		if id, err := strconv.ParseInt(t.Value, 10, 64); err == nil && !t.Quoted {
+			if id <= 0 {
+				return nil, filterql.Errorf(t.ValuePos, "user id must be a positive integer")
+			}
+			return AssigneeCondition{UserID: &id}, nil
+		}
+		if t.Value == "" {
+			return nil, filterql.Errorf(t.ValuePos, "username is required")
+		}
+		return AssigneeCondition{Username: t.Value}, nil
+
+	case "title":
+		if t.Op != filterql.OpHas && t.Op != filterql.OpNe {
+			return nil, filterql.Errorf(t.FieldPos, `title only supports ":" and "!="`)
+		}
+		if t.Value == "" {
+			return nil, filterql.Errorf(t.ValuePos, "title text is required")
+		}
+		return TitleCondition{Text: t.Value}, nil
+
+	case "tag":
+		if err := equalityOnly(t); err != nil {
+			return nil, err
+		}
+		if is("none") {
+			return TagCondition{None: true}, nil
+		}
+		if t.Value == "" {
+			return nil, filterql.Errorf(t.ValuePos, "tag name is required")
+		}
+		return TagCondition{Name: t.Value}, nil
+	}
+	return nil, filterql.Errorf(t.FieldPos, "unknown field %q, expected status, category, priority, due, created, updated, completed, group, assignee, tag or title", t.Field)
+}
+
+// equalityOnly 不能比较大小的字段只支持: = !=
+func equalityOnly(t *filterql.Term) error {
+	switch t.Op {
+	case filterql.OpHas, filterql.OpEq, filterql.OpNe:
+		return nil
+	}
+	return filterql.Errorf(t.FieldPos, "%s does not support %q", t.Field, t.Op)
+}
+
+// priorityCondition 比较运算按low<medium<high展开成取值列表
+func priorityCondition(t *filterql.Term) (Condition, error) {
+	level := -1
+	for i, p := range priorityLevels {
+		if strings.EqualFold(t.Value, p) {
+			level = i
+		}
+	}
+	if level < 0 {
+		return nil, filterql.Errorf(t.ValuePos, "priority must be low, medium or high")
+	}
+	var from, to int
+	switch t.Op {
+	case filterql.OpLt:
+		from, to = 0, level
+	case filterql.OpLe:
+		from, to = 0, level+1
+	case filterql.OpGt:
+		from, to = level+1, len(priorityLevels)
+	case filterql.OpGe:
+		from, to = level, len(priorityLevels)
+	default:
+		from, to = level, level+1
+	}
+	return PriorityCondition{Priorities: append([]string{}, priorityLevels[from:to]...)}, nil
+}
+
+// date 解析日期值：today、tomorrow、yesterday、YYYY-MM-DD，
+// 或相对今天的天数/周数，例如7d、2w、-3d
+func (r *filterResolver) date(t *filterql.Term) (date.Date, error) {
+	today := date.Today(r.now, r.loc)
+	v := strings.ToLower(t.Value)
+	switch v {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDays(1), nil
+	case "yesterday":
+		return today.AddDays(-1), nil
+	}
+	if d, err := date.Parse(v); err == nil {
+		return d, nil
+	}
+	if n := utf8.RuneCountInString(v); n >= 2 && n <= 5 {
+		unit := 0
+		switch v[len(v)-1] {
+		case 'd':
+			unit = 1
+		case 'w':
+			unit = 7
+		}
+		if k, err := strconv.Atoi(v[:len(v)-1]); err == nil && unit > 0 {
+			return today.AddDays(k * unit), nil
+		}
+	}
+	return date.Date{}, filterql.Errorf(t.ValuePos, "%s must be a date (YYYY-MM-DD), today, tomorrow, yesterday or a relative day like 7d, 2w, -3d", t.Field)
+}
+
+// dateRange 把对某一天的比较换成左闭右开的日期区间，nil表示不限
+func dateRange(op filterql.Op, day date.Date) (from, to *date.Date) {
+	next := day.AddDays(1)
+	switch op {
+	case filterql.OpLt:
+		return nil, &day
+	case filterql.OpLe:
+		return nil, &next
+	case filterql.OpGt:
+		return &next, nil
+	case filterql.OpGe:
+		return &day, nil
+	}
+	return &day, &next
+}
@@ -0,0 +1,77 @@
+package task
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"tasker/pkg/apperror"
+)
+
+func TestParseFilterErrors(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		// 语法错误和字段错误都带列号，列号按字符计
+		{`group:"Work`, `invalid filter: column 7: unterminated string`},
+		{`(status:pending OR priority:high`, `invalid filter: column 33: expected ")" to close "(" at column 1, found end of input`},
+		{`status:pending)`, `invalid filter: column 15: unexpected ")"`},
+		{`status:pending colour:red`, `invalid filter: column 16: unknown field "colour", expected status, category, priority, due, created, updated, completed, group, assignee, tag or title`},
+		{`group:"工作" 颜色:红`, `invalid filter: column 12: unknown field "颜色", expected status, category, priority, due, created, updated, completed, group, assignee, tag or title`},
+		{`(a OR Foo>1)`, `invalid filter: column 7: unknown field "foo", expected status, category, priority, due, created, updated, completed, group, assignee, tag or title`},
+		{`tag>urgent`, `invalid filter: column 1: tag does not support ">"`},
+		{`tag:""`, `invalid filter: column 5: tag name is required`},
+		{`priority:urgent`, `invalid filter: column 10: priority must be low, medium or high`},
+		{`group>Work`, `invalid filter: column 1: group does not support ">"`},
+		{`due:someday`, `invalid filter: column 5: `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parseFilter(tt.input, 1, now, time.UTC)
+			appErr, ok := apperror.IsAppError(err)
+			if !ok || appErr.Code != "INVALID_FILTER" {
+				t.Fatalf("parseFilter(%q) error = %v, want INVALID_FILTER", tt.input, err)
+			}
+			if !strings.HasPrefix(appErr.Message, tt.msg) {
+				t.Fatalf("parseFilter(%q) = %q, want prefix %q", tt.input, appErr.Message, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseFilterConditions(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	c, err := parseFilter(`status:pending priority>=medium -group:none`, 1, now, time.UTC)
+	if err != nil {
+		t.Fatalf("parseFilter: %v", err)
+	}
+	and, ok := c.(AndCondition)
+	if !ok || len(and) != 3 {
+		t.Fatalf("condition = %#v", c)
+	}
+	if p, ok := and[1].(PriorityCondition); !ok || len(p.Priorities) != 2 || p.Priorities[0] != "medium" || p.Priorities[1] != "high" {
+		t.Fatalf("priority = %#v", and[1])
+	}
+	if n, ok := and[2].(NotCondition); !ok || n.Condition != (GroupCondition{None: true}) {
+		t.Fatalf("group = %#v", and[2])
+	}
+	c, err = parseFilter(`tag:urgent -tag:someday tag!=none tag:"none"`, 1, now, time.UTC)
+	if err != nil {
+		t.Fatalf("parseFilter: %v", err)
+	}
+	want := AndCondition{
+		TagCondition{Name: "urgent"},
+		NotCondition{Condition: TagCondition{Name: "someday"}},
+		NotCondition{Condition: TagCondition{None: true}},
+		TagCondition{Name: "none"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Fatalf("tags = %#v", c)
+	}
+	if c, err := parseFilter("   ", 1, now, time.UTC); c != nil || err != nil {
+		t.Fatalf("blank filter = %v, %v", c, err)
+	}
+}
@@ -0,0 +1,68 @@
+package task
+
+import (
+	"context"
+	"log"
+
+	"tasker/core/mention"
+)
+
+// WithMentions 解析描述里的@提及，不注入时不处理提及
+func WithMentions(m mention.Service) Option {
+	return func(s *service) {
+		s.mentions = m
+	}
+}
+
+// syncMentions 按当前描述重新保存提及，描述没变时结果不变
+func (s *service) syncMentions(ctx context.Context, actorID int64, t *Task) error {
+	if s.mentions == nil {
+		return nil
+	}
+	mentions, err := s.mentions.Sync(ctx, actorID, mention.Source{
+		Type:        mention.SourceTask,
+		ID:          t.ID,
+		TaskID:      t.ID,
+		TaskTitle:   t.Title,
+		WorkspaceID: t.WorkspaceID,
+		GroupID:     t.GroupID,
+	}, t.Description)
+	if err != nil {
+		return err
+	}
+	t.Mentions = mentions
+	return nil
+}
+
+// syncSavedMentions 任务已经提交后再同步提及。写入已经成功，提及同步失败只记日志，
+// 照常返回保存好的任务，不让客户端以为没保存而重试
+func (s *service) syncSavedMentions(ctx context.Context, actorID int64, t *Task) {
+	if err := s.syncMentions(ctx, actorID, t); err != nil {
+		log.Printf("[task] sync mentions of task %d failed: %v", t.ID, err)
+		if t.Mentions == nil {
+			t.Mentions = []mention.Mention{}
+		}
+	}
+}
+
+// attachMentions 一次读出一批任务描述里的提及
+func (s *service) attachMentions(ctx context.Context, tasks ...*Task) error {
+	ids := make([]int64, 0, len(tasks))
+	for _, t := range tasks {
+		t.Mentions = []mention.Mention{}
+		ids = append(ids, t.ID)
+	}
+	if s.mentions == nil {
+		return nil
+	}
+	byTask, err := s.mentions.Load(ctx, mention.SourceTask, ids)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if m, ok := byTask[t.ID]; ok {
+			t.Mentions = m
+		}
+	}
+	return nil
+}
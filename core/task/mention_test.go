@@ -0,0 +1,38 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tasker/core/mention"
+)
+
+// failingMentions 模拟提交之后提及表写入失败
+type failingMentions struct {
+	mention.Service
+	calls int
+}
+
+func (m *failingMentions) Sync(ctx context.Context, actorID int64, src mention.Source, text string) ([]mention.Mention, error) {
+	m.calls++
+	return nil, errors.New("connection reset")
+}
+
+func TestCreateTaskReturnsSavedTaskWhenMentionSyncFails(t *testing.T) {
+	repo := &fakeRepo{}
+	mentions := &failingMentions{}
+	svc := NewService(repo, fakeGroups{}, fakeUsers{}, nil, WithMentions(mentions))
+	groupID := int64(3)
+
+	got, err := svc.CreateTask(context.Background(), 1, CreateTaskInput{Title: "写周报", Description: "@bob", GroupID: &groupID})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if len(repo.created) != 1 || got.ID != repo.created[0].ID || mentions.calls != 1 {
+		t.Fatalf("created %d tasks, returned %+v, synced %d times", len(repo.created), got, mentions.calls)
+	}
+	if got.Mentions == nil || len(got.Mentions) != 0 {
+		t.Fatalf("mentions = %#v, want an empty list", got.Mentions)
+	}
+}
@@ -7,8 +7,40 @@ import (
 // Repository抽象了对task的 持久化操作
 type Repository interface {
 	Create(ctx context.Context, t *Task) error
+	// GetByID 按ID读取，和List一样只在用户能访问的任务里找（所在工作区或共享给用户的分组），
+	// 读写权限再由service按分组校验
 	GetByID(ctx context.Context, userID, id int64) (*Task, error)
+	// GetByIDUnscoped 不做权限过滤，只给公开链接、CalDAV等已经校验过访问范围的场景用
+	GetByIDUnscoped(ctx context.Context, id int64) (*Task, error)
+	// 只返回用户所在工作区的任务
 	List(ctx context.Context, userID int64, filter ListTaskerFilter) (*ListResult, error)
-	Update(ctx context.Context, t *Task) error
+	// Update和Delete同GetByID，用户访问不到的任务返回TASK_NOT_FOUND
+	Update(ctx context.Context, userID int64, t *Task) error
+	// UpdateIfUnchanged 同Update，但只在任务的change_seq还是changeSeq时写入，已经被改过时返回PRECONDITION_FAILED
+	UpdateIfUnchanged(ctx context.Context, userID int64, t *Task, changeSeq int64) error
 	Delete(ctx context.Context, userID, id int64) error
+	// ListByGroup 分组里的全部任务，不做权限过滤，只给公开链接等已校验过的场景用
+	ListByGroup(ctx context.Context, groupID int64) ([]*Task, error)
+	// GetByExternalID 工作区里外部ID为externalID的任务，没有时返回nil, nil；不做权限过滤
+	GetByExternalID(ctx context.Context, workspaceID int64, externalID string) (*Task, error)
+
+	// ListRanked 分组里的全部任务按rank排序，不做权限过滤
+	ListRanked(ctx context.Context, groupID int64) ([]*Task, error)
+	// LastRank 列里（status为空表示整个分组）最大的rank，没有任务时返回空串
+	LastRank(ctx context.Context, groupID int64, status Status, excludeID int64) (string, error)
+	// AdjacentRank 列里紧挨着rank的下一个（next）或上一个任务的rank，没有时返回空串
+	AdjacentRank(ctx context.Context, groupID int64, status Status, rank string, next bool, excludeID int64) (string, error)
+	// RebalanceRanks 按当前顺序给分组的任务重新分配等长的rank，和Reorder持有同一把分组锁
+	RebalanceRanks(ctx context.Context, groupID int64) error
+	// Reorder 持有t所在分组的排序锁，在同一个事务里调用place算出t.Rank后保存t，
+	// 读相邻rank和写回之间不会有别的移动或重排插进来
+	Reorder(ctx context.Context, userID int64, t *Task, place func(store RankStore) error) error
+}
+
+// RankStore Reorder事务里可用的读rank操作
+type RankStore interface {
+	GetByID(ctx context.Context, userID, id int64) (*Task, error)
+	LastRank(ctx context.Context, groupID int64, status Status, excludeID int64) (string, error)
+	AdjacentRank(ctx context.Context, groupID int64, status Status, rank string, next bool, excludeID int64) (string, error)
+	RebalanceRanks(ctx context.Context, groupID int64) error
 }
@@ -2,36 +2,77 @@ package task
 
 import (
 	"context"
-	// "sync"
+	"strings"
+	"sync"
 	"tasker/pkg/apperror"
 	"time"
+	"unicode/utf8"
 
 	// 注入group service
 	"tasker/core/group"
+	"tasker/core/mention"
+	"tasker/core/user"
+	"tasker/core/workspace"
+	"tasker/pkg/date"
+	"tasker/pkg/events"
 )
 
+// Status 分组工作流里的状态key，没有自定义工作流时只有pending/completed
 type Status string
 
 const (
-	StatusPending   Status = "pending"
-	StatusCompleted Status = "completed"
+	StatusPending   Status = group.StatePending
+	StatusCompleted Status = group.StateCompleted
 )
 
+// 外部ID（导入或CalDAV客户端的UID）的最大长度
+const maxExternalID = 100
+
+// 搜索词的最大长度
+const maxQuery = 200
+
 type Task struct {
 	ID          int64  `json:"id"`
 	UserID      int64  `json:"user_id"` // 在写完auth之后新增：任务属于哪个用户
+	WorkspaceID int64  `json:"workspace_id"` // 任务所在工作区，跟随分组
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Status      Status `json:"status"`
+	// 状态所属的大类，由工作流决定
+	StatusCategory group.Category `json:"status_category"`
 
+	// 截止时间二选一：due_date是具体时刻，due_on只有日期，按用户时区理解
 	DueDate  *time.Time `json:"due_date"`
+	DueOn    *date.Date `json:"due_on"`
 	Priority string     `json:"priority"`
 	GroupID  *int64     `json:"group_id"`
+	// 负责人，和创建者UserID分开；必须能访问任务所在的工作区
+	AssigneeIDs []int64 `json:"assignee_ids"`
+	// 标签，不区分大小写，见NormalizeTags
+	Tags []string `json:"tags"`
+	// 描述里@到的用户，写入时解析
+	Mentions []mention.Mention `json:"mentions"`
+	// 看板里的手动排序，分数索引，按字节比较
+	Rank string `json:"rank"`
+	// 进入done大类的时间，重新打开时清空
+	CompletedAt *time.Time `json:"completed_at"`
+	// 从其他系统导入时的ID，重复导入按它更新
+	ExternalID string `json:"external_id"`
+	// 只在带q的列表结果里有
+	Highlight *Highlight `json:"highlight,omitempty"`
+	// 所在分组的CalDAV变更序号，内容每次变化都会变，由数据库触发器写入
+	ChangeSeq int64 `json:"-"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Highlight 搜索命中的片段，已经做过HTML转义，命中的词用<mark>包起来
+type Highlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
 type Group struct {
 	ID        int64     `json:"id"`
 	UserID    int64     `json:"user_id"`
@@ -45,8 +86,17 @@ type CreateTaskInput struct {
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
 	DueDate     *time.Time `json:"due_date"`
+	DueOn       *date.Date `json:"due_on"`
 	Priority    string     `json:"priority"`
 	GroupID     *int64     `json:"group_id"`
+	// 不指定分组时，放到这个工作区的"默认"分组
+	WorkspaceID *int64  `json:"workspace_id"`
+	AssigneeIDs []int64 `json:"assignee_ids"`
+	Tags        []string `json:"tags"`
+	// CalDAV客户端创建的任务记下它的UID，HTTP接口不开放
+	ExternalID string `json:"-"`
+	// CalDAV客户端创建已完成的任务时直接放进工作流里这个大类的第一个状态，空表示初始状态；HTTP接口不开放
+	Category group.Category `json:"-"`
 }
 
 // 更新任务时用的入参（目前设置的必填)
@@ -54,21 +104,94 @@ type UpdateTaskInput struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Status      Status `json:"status"`
+	// 移动到同一工作区的另一个分组，nil表示不移动
+	GroupID *int64 `json:"group_id"`
+	// nil表示不修改，空数组表示清空
+	AssigneeIDs *[]int64 `json:"assignee_ids"`
+	// nil表示不修改，空数组表示清空
+	Tags *[]string `json:"tags"`
+
+	// 以下字段只给CalDAV这类整体替换任务的调用方用，HTTP接口不开放。
+	// ReplaceDue为true时用DueDate/DueOn覆盖截止时间
+	ReplaceDue bool       `json:"-"`
+	DueDate    *time.Time `json:"-"`
+	DueOn      *date.Date `json:"-"`
+	// 空表示不修改
+	Priority string `json:"-"`
+	// 非nil时只有任务的ChangeSeq还是这个值才写入，否则返回PRECONDITION_FAILED；
+	// 检查和写入在同一条UPDATE里，CalDAV的If-Match用它
+	IfChangeSeq *int64 `json:"-"`
 }
 
 type ListTaskerFilter struct {
+	// pending/completed按大类筛选，其他值按状态key精确匹配
 	Status   Status `json:"status"`
+	// todo/doing/done
+	Category group.Category `json:"category"`
 	Page     int    `json:"page"`
 	PageSize int    `json:"page_size"`
 	Query    string `json:"query"`
-	Sort     string `json:"sort"` // created_desc/created_asc/status
+	Sort     string `json:"sort"` // created_desc/created_asc/status/rank/completed_desc/relevance
+	// today/overdue/YYYY-MM-DD，按用户时区解释
+	Due string `json:"due"`
+	// 只看某个工作区，nil表示用户所在的全部工作区
+	WorkspaceID *int64 `json:"workspace_id"`
+	// 只看某个分组，共享给用户的分组也可以
+	GroupID *int64 `json:"group_id"`
+	// me/unassigned/用户id
+	Assignee string `json:"assignee"`
+	// 完成时间区间，左闭右开，nil表示不限
+	CompletedAfter  *time.Time `json:"completed_after"`
+	CompletedBefore *time.Time `json:"completed_before"`
+	// 过滤表达式，语法见filter.go，和其他条件是AND的关系
+	Filter string `json:"filter"`
+	// page（默认）或cursor，带了Cursor时按cursor
+	Pagination string `json:"pagination"`
+	// 上一页返回的next_cursor或prev_cursor，空表示第一页
+	Cursor string `json:"cursor"`
+	// 游标分页时是否计算总数，需要多一次COUNT
+	IncludeTotal bool `json:"include_total"`
+
+	// 由service根据Due和用户时区算出，repo直接使用
+	DueWindow *DueWindow `json:"-"`
+	// 由service根据Assignee解析
+	AssigneeID *int64 `json:"-"`
+	Unassigned bool   `json:"-"`
+	// 由service根据Status、Category和Due算出，nil表示不限
+	Categories []group.Category `json:"-"`
+	// 由service根据Filter解析，nil表示不限
+	Condition Condition `json:"-"`
+	// 由service根据Pagination和Cursor算出；After为nil表示从第一页开始
+	CursorMode bool    `json:"-"`
+	After      *Cursor `json:"-"`
+}
+
+// DueWindow 截止时间的查询区间，都是左闭右开，nil表示不限
+type DueWindow struct {
+	// 带时刻的截止时间
+	From *time.Time
+	To   *time.Time
+	// 只有日期的截止日
+	FromDate *date.Date
+	ToDate   *date.Date
 }
 
 type ListResult struct {
-	Items    []*Task `json:"items"`
-	Total    int64   `json:"total"`
-	Page     int     `json:"page"`
-	PageSize int     `json:"page_size"`
+	Items []*Task `json:"items"`
+	// 游标分页时只在include_total=true时返回
+	Total *int64 `json:"total,omitempty"`
+	// 游标分页时没有页码
+	Page     int `json:"page,omitempty"`
+	PageSize int `json:"page_size"`
+	// 全文检索没有结果，改为按标题相似度的模糊匹配
+	Fuzzy bool `json:"fuzzy,omitempty"`
+	// 游标分页：没有下一页/上一页时不返回
+	NextCursor *string `json:"next_cursor,omitempty"`
+	PrevCursor *string `json:"prev_cursor,omitempty"`
+
+	// repo返回的下一页/上一页位置，由service编码成NextCursor/PrevCursor
+	Next *Cursor `json:"-"`
+	Prev *Cursor `json:"-"`
 }
 
 // Service把task相关业务抽象出来
@@ -78,15 +201,39 @@ type Service interface {
 	ListTasks(ctx context.Context, userID int64, filter ListTaskerFilter) (*ListResult, error)
 	UpdateTask(ctx context.Context, userID int64, id int64, in UpdateTaskInput) (*Task, error)
 	DeleteTask(ctx context.Context, userID int64, id int64) error
+	// Authorize 按任务所在分组校验访问级别，供评论、公开链接等模块复用
+	Authorize(ctx context.Context, userID, id int64, need group.Access) (*Task, error)
+
+	// 看板：拖动任务换列、换位置，按工作流分列读取分组
+	MoveTask(ctx context.Context, userID, id int64, in MoveTaskInput) (*Task, error)
+	Board(ctx context.Context, userID, groupID int64) (*Board, error)
+
+	// 导入不经过CreateTask/UpdateTask，写入后由这里补发事件和提及
+	PublishImported(ctx context.Context, actorID int64, tasks []ImportedTask)
+}
+
+// UserLookup 读取用户偏好（默认分组等），由user.Service实现
+type UserLookup interface {
+	GetByID(ctx context.Context, id int64) (*user.User, error)
 }
 
 type service struct {
 	repo Repository
 	groupSvc group.Service
+	users    UserLookup
+	workspaces workspace.Service
+	events   events.Publisher
+	mentions mention.Service
+	// 正在后台重排rank的分组
+	rebalancing sync.Map
 }
 
-func NewService(repo Repository, groupSvc group.Service) Service {
-	return &service{repo: repo, groupSvc: groupSvc}
+func NewService(repo Repository, groupSvc group.Service, users UserLookup, workspaces workspace.Service, opts ...Option) Service {
+	s := &service{repo: repo, groupSvc: groupSvc, users: users, workspaces: workspaces, events: events.Discard{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // 实现Service方法
@@ -95,14 +242,44 @@ func (s *service) CreateTask(ctx context.Context, userID int64, in CreateTaskInp
 		return nil, apperror.New("INVALID_TITLE", "title is required")
 	}
 
+	if in.DueDate != nil && in.DueOn != nil {
+		return nil, apperror.New("INVALID_DUE", "set either due_date or due_on, not both")
+	}
+
 	if in.Priority == "" {
 		in.Priority = "low"
 	}
+	if utf8.RuneCountInString(in.ExternalID) > maxExternalID {
+		return nil, apperror.New("INVALID_EXTERNAL_ID", "external_id must be at most 100 characters")
+	}
+
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分组和工作区都没指定时优先用用户设置的默认分组（分组可能已被删除或已经没有写权限）
+	if in.GroupID == nil && in.WorkspaceID == nil && u.DefaultGroupID != nil {
+		if g, err := s.groupSvc.Authorize(ctx, userID, *u.DefaultGroupID, group.AccessWrite); err == nil {
+			in.GroupID = &g.ID
+		}
+	}
 
 	if in.GroupID == nil {
+		// 指定了工作区就用它的"默认"分组，否则用个人工作区的
+		var workspaceID int64
+		if in.WorkspaceID != nil {
+			workspaceID = *in.WorkspaceID
+		} else {
+			personal, err := s.workspaces.Personal(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			workspaceID = personal.ID
+		}
 
 		// TODO：查询默认分组是否存在，不存在再查询，如果存在，直接把in.GroupID设置为默认分组的id
-		exists, err := s.groupSvc.FindGroupByName(ctx, userID, "默认")
+		exists, err := s.groupSvc.FindGroupByName(ctx, workspaceID, "默认")
 		if err != nil {
 			return nil, err
 		}
@@ -110,7 +287,7 @@ func (s *service) CreateTask(ctx context.Context, userID int64, in CreateTaskInp
 		if exists != nil {
 			in.GroupID = &exists.ID
 		} else { // 默认分组不存在，先创建
-			g, err := s.groupSvc.CreateGroup(ctx, userID, "默认")
+			g, err := s.groupSvc.CreateGroup(ctx, userID, workspaceID, "默认")
 			if err !=nil {
 				return nil, err
 			}
@@ -120,34 +297,77 @@ func (s *service) CreateTask(ctx context.Context, userID int64, in CreateTaskInp
 		
 	}
 
-	// 确认分组属于用户
-	_, err := s.groupSvc.GetGroup(ctx, userID, *in.GroupID)
+	// 确认用户对分组有写权限（工作区member及以上，或被共享了write）
+	g, err := s.groupSvc.Authorize(ctx, userID, *in.GroupID, group.AccessWrite)
 	if err!= nil {
 		// 向上抛出（groupService已经处理好了错误）
 		return nil, err
 	}
+	if in.WorkspaceID != nil && *in.WorkspaceID != g.WorkspaceID {
+		return nil, apperror.New("INVALID_GROUP", "group does not belong to the workspace")
+	}
+	assignees, err := s.checkAssignees(ctx, g.ID, in.AssigneeIDs)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := NormalizeTags(in.Tags)
+	if err != nil {
+		return nil, err
+	}
 
+	wf := group.WorkflowOf(g)
+	initial := wf.Initial()
+	if in.Category != "" {
+		initial = wf.Map("", in.Category)
+	}
+	rank, err := s.rankAtEnd(ctx, g.ID, 0)
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now()
 	t := &Task{
 		UserID:      userID,
+		WorkspaceID: g.WorkspaceID,
 		Title:       in.Title,
 		Description: in.Description,
-		Status:      StatusPending,
 		DueDate:     in.DueDate,
+		DueOn:       in.DueOn,
 		Priority:    in.Priority,
 		GroupID:     in.GroupID,
+		AssigneeIDs: assignees,
+		Tags:        tags,
+		Rank:        rank,
+		ExternalID:  in.ExternalID,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
+	setState(t, initial, now)
 
 	if err := s.repo.Create(ctx, t); err != nil {
 		return nil, err
 	}
+	s.publishChanged(ctx, EventCreated, userID, t, nil)
+	s.publishStatusChange(ctx, userID, t, wf.Initial().Category)
+	s.publishAssigned(ctx, userID, t, assignees)
+	s.syncSavedMentions(ctx, userID, t)
+	localize(t, u.Location())
 	return t, nil
 }
 
 func (s *service) GetTask(ctx context.Context, userID int64, id int64) (*Task, error) {
-	return s.repo.GetByID(ctx, userID, id)
+	t, err := s.access(ctx, userID, id, group.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachMentions(ctx, t); err != nil {
+		return nil, err
+	}
+	loc, err := s.location(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	localize(t, loc)
+	return t, nil
 }
 
 func (s *service) ListTasks(ctx context.Context, userID int64, filter ListTaskerFilter) (*ListResult, error) {
@@ -161,47 +381,316 @@ func (s *service) ListTasks(ctx context.Context, userID int64, filter ListTasker
 		pageSize = 10
 	}
 
-	if filter.Status != "" && filter.Status != StatusPending && filter.Status != StatusCompleted {
-		return nil, apperror.New("INVALID_STATUS", "status must be 'pending' or 'completed'")
+	filter.Query = strings.TrimSpace(filter.Query)
+	if utf8.RuneCountInString(filter.Query) > maxQuery {
+		return nil, apperror.New("INVALID_QUERY", "q must be at most 200 characters")
 	}
 
 	// sort allowlist
 	switch filter.Sort {
-	case "", "created_desc", "created_asc", "status":
+	case "":
+		// 默认按创建时间倒序，搜索时按相关度
+		filter.Sort = "created_desc"
+		if filter.Query != "" {
+			filter.Sort = "relevance"
+		}
+	case "created_desc", "created_asc", "status", "rank", "completed_desc":
+	case "relevance":
+		if filter.Query == "" {
+			return nil, apperror.New("INVALID_SORT", "sort=relevance requires q")
+		}
 	default:
-		return nil, apperror.New("INVALID_SORT", "sort must be created_desc/created_asc/status")
+		return nil, apperror.New("INVALID_SORT", "sort must be created_desc/created_asc/status/rank/completed_desc/relevance")
+	}
+	if filter.CompletedAfter != nil && filter.CompletedBefore != nil && !filter.CompletedAfter.Before(*filter.CompletedBefore) {
+		return nil, apperror.New("INVALID_COMPLETED_RANGE", "completed_after must be before completed_before")
 	}
 
 	filter.Page = page
 	filter.PageSize = pageSize
 
-	return s.repo.List(ctx, userID, filter)
+	switch filter.Pagination {
+	case "", PaginationPage:
+		filter.CursorMode = filter.Cursor != ""
+	case PaginationCursor:
+		filter.CursorMode = true
+	default:
+		return nil, apperror.New("INVALID_PAGINATION", "pagination must be page or cursor")
+	}
+	if filter.Cursor != "" {
+		after, err := decodeCursor(filter.Cursor, filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = after
+	}
+
+	if err := parseAssigneeFilter(userID, &filter); err != nil {
+		return nil, err
+	}
+	matches, err := resolveStatusFilter(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// 指定了工作区或分组时先确认能访问，repo只会返回用户能访问的任务
+	if filter.WorkspaceID != nil {
+		if _, err := s.workspaces.Authorize(ctx, userID, *filter.WorkspaceID, workspace.RoleViewer); err != nil {
+			return nil, err
+		}
+	}
+	if filter.GroupID != nil {
+		if _, err := s.groupSvc.Authorize(ctx, userID, *filter.GroupID, group.AccessRead); err != nil {
+			return nil, err
+		}
+	}
+
+	loc, err := s.location(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Due != "" {
+		window, err := dueWindow(filter.Due, time.Now(), loc)
+		if err != nil {
+			return nil, err
+		}
+		filter.DueWindow = window
+	}
+	filter.Condition, err = parseFilter(filter.Filter, userID, time.Now(), loc)
+	if err != nil {
+		return nil, err
+	}
+	if !matches {
+		res := &ListResult{Items: []*Task{}, PageSize: pageSize}
+		if !filter.CursorMode {
+			res.Page = page
+		}
+		if !filter.CursorMode || filter.IncludeTotal {
+			res.Total = new(int64)
+		}
+		return res, nil
+	}
+
+	res, err := s.repo.List(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	res.NextCursor = encodeCursor(res.Next)
+	res.PrevCursor = encodeCursor(res.Prev)
+	if err := s.attachMentions(ctx, res.Items...); err != nil {
+		return nil, err
+	}
+	for _, t := range res.Items {
+		localize(t, loc)
+	}
+	return res, nil
 }
 
 func (s *service) UpdateTask(ctx context.Context, userID int64, id int64, in UpdateTaskInput) (*Task, error) {
 	if in.Title == "" {
 		return nil, apperror.New("INVALID_TITLE", "title is required")
 	}
-	if in.Status != StatusPending && in.Status != StatusCompleted {
-		return nil, apperror.New("INVALID_STATUS", "status must be 'pending' or 'completed'")
+	if in.Status == "" {
+		return nil, apperror.New("INVALID_STATUS", "status is required")
+	}
+	if in.ReplaceDue && in.DueDate != nil && in.DueOn != nil {
+		return nil, apperror.New("INVALID_DUE", "set either due_date or due_on, not both")
 	}
 
-	t, err := s.repo.GetByID(ctx, userID, id)
+	t, g, err := s.authorize(ctx, userID, id, group.AccessWrite)
 	if err != nil {
 		return nil, err
 	}
+	if in.IfChangeSeq != nil && *in.IfChangeSeq != t.ChangeSeq {
+		return nil, preconditionFailed()
+	}
 
+	fromGroupID := t.GroupID
+	target, moved, err := s.moveTarget(ctx, userID, t, in.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	if moved {
+		g = target
+		t.GroupID = &target.ID
+		// 放到新分组看板的最后
+		if t.Rank, err = s.rankAtEnd(ctx, target.ID, t.ID); err != nil {
+			return nil, err
+		}
+		// 负责人也要能访问新分组
+		if in.AssigneeIDs == nil {
+			in.AssigneeIDs = &t.AssigneeIDs
+		}
+	}
+
+	// 状态必须属于（移动后）分组的工作流
+	st, err := nextState(t, group.WorkflowOf(g), in.Status, moved)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []int64
+	if in.AssigneeIDs != nil {
+		if t.GroupID == nil {
+			return nil, apperror.New("INVALID_ASSIGNEE", "move the task into a group before assigning it")
+		}
+		assignees, err := s.checkAssignees(ctx, *t.GroupID, *in.AssigneeIDs)
+		if err != nil {
+			return nil, err
+		}
+		added = newAssignees(t.AssigneeIDs, assignees)
+		t.AssigneeIDs = assignees
+	}
+	if in.Tags != nil {
+		tags, err := NormalizeTags(*in.Tags)
+		if err != nil {
+			return nil, err
+		}
+		t.Tags = tags
+	}
+
+	prevCategory := t.StatusCategory
+	now := time.Now()
 	t.Title = in.Title
 	t.Description = in.Description
-	t.Status = in.Status
-	t.UpdatedAt = time.Now()
+	setState(t, st, now)
+	if in.ReplaceDue {
+		t.DueDate, t.DueOn = in.DueDate, in.DueOn
+	}
+	if in.Priority != "" {
+		t.Priority = in.Priority
+	}
+	t.UpdatedAt = now
 
-	if err := s.repo.Update(ctx, t); err != nil {
+	if in.IfChangeSeq != nil {
+		err = s.repo.UpdateIfUnchanged(ctx, userID, t, *in.IfChangeSeq)
+	} else {
+		err = s.repo.Update(ctx, userID, t)
+	}
+	if err != nil {
 		return nil, err
 	}
+	if moved {
+		s.publishChanged(ctx, EventMoved, userID, t, fromGroupID)
+	}
+	s.publishStatusChange(ctx, userID, t, prevCategory)
+	s.publishAssigned(ctx, userID, t, added)
+	s.syncSavedMentions(ctx, userID, t)
+	loc, err := s.location(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	localize(t, loc)
 	return t, nil
 }
 
+// moveTarget 校验移动分组：目标分组要有写权限，而且不能跨工作区。
+// groupID为nil或就是当前分组时返回false
+func (s *service) moveTarget(ctx context.Context, userID int64, t *Task, groupID *int64) (*group.Group, bool, error) {
+	if groupID == nil || (t.GroupID != nil && *groupID == *t.GroupID) {
+		return nil, false, nil
+	}
+	target, err := s.groupSvc.Authorize(ctx, userID, *groupID, group.AccessWrite)
+	if err != nil {
+		return nil, false, err
+	}
+	if target.WorkspaceID != t.WorkspaceID {
+		return nil, false, apperror.New("INVALID_GROUP", "tasks can only move between groups of the same workspace")
+	}
+	return target, true, nil
+}
+
 func (s *service) DeleteTask(ctx context.Context, userID int64, id int64) error {
+	if _, err := s.access(ctx, userID, id, group.AccessWrite); err != nil {
+		return err
+	}
 	return s.repo.Delete(ctx, userID, id)
 }
+
+func (s *service) Authorize(ctx context.Context, userID, id int64, need group.Access) (*Task, error) {
+	return s.access(ctx, userID, id, need)
+}
+
+// access 读取任务并按所在分组校验权限（工作区角色或分组共享），无权访问时当作任务不存在。
+// 分组被删掉的任务只按工作区角色判断
+func (s *service) access(ctx context.Context, userID, id int64, need group.Access) (*Task, error) {
+	t, _, err := s.authorize(ctx, userID, id, need)
+	return t, err
+}
+
+// authorize 同access，另外返回任务所在的分组（分组被删掉时为nil）
+func (s *service) authorize(ctx context.Context, userID, id int64, need group.Access) (*Task, *group.Group, error) {
+	t, err := s.repo.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	var g *group.Group
+	if t.GroupID != nil {
+		g, err = s.groupSvc.Authorize(ctx, userID, *t.GroupID, need)
+	} else {
+		min := workspace.RoleViewer
+		if need >= group.AccessWrite {
+			min = workspace.RoleMember
+		}
+		_, err = s.workspaces.Authorize(ctx, userID, t.WorkspaceID, min)
+	}
+	if err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && (appErr.Code == "WORKSPACE_NOT_FOUND" || appErr.Code == "GROUP_NOT_FOUND") {
+			return nil, nil, apperror.New("TASK_NOT_FOUND", "task not found")
+		}
+		return nil, nil, err
+	}
+	return t, g, nil
+}
+
+func preconditionFailed() error {
+	return apperror.New("PRECONDITION_FAILED", "the resource has been changed or does not match the condition")
+}
+
+// location 取用户时区
+func (s *service) location(ctx context.Context, userID int64) (*time.Location, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.Location(), nil
+}
+
+// localize 把时间戳换到用户时区，JSON里带上对应的偏移（如+08:00）。
+// 只是换个表示方式，时刻不变；due_on是日期，不需要换
+func localize(t *Task, loc *time.Location) {
+	t.CreatedAt = t.CreatedAt.In(loc)
+	t.UpdatedAt = t.UpdatedAt.In(loc)
+	if t.DueDate != nil {
+		due := t.DueDate.In(loc)
+		t.DueDate = &due
+	}
+	if t.CompletedAt != nil {
+		done := t.CompletedAt.In(loc)
+		t.CompletedAt = &done
+	}
+}
+
+// dueWindow 把 today/overdue/YYYY-MM-DD 换算成查询区间。
+// 一天的起止用loc里的0点计算，夏令时切换那天可能是23或25小时
+func dueWindow(due string, now time.Time, loc *time.Location) (*DueWindow, error) {
+	today := date.Today(now, loc)
+	switch due {
+	case "today":
+		return dayWindow(today, loc), nil
+	case "overdue":
+		// 带时刻的过了那一刻就算逾期；只有日期的要等那一天整天过完
+		return &DueWindow{To: &now, ToDate: &today}, nil
+	}
+	day, err := date.Parse(due)
+	if err != nil {
+		return nil, apperror.New("INVALID_DUE", "due must be today, overdue or a date in YYYY-MM-DD format")
+	}
+	return dayWindow(day, loc), nil
+}
+
+func dayWindow(day date.Date, loc *time.Location) *DueWindow {
+	next := day.AddDays(1)
+	from, to := day.StartIn(loc), next.StartIn(loc)
+	return &DueWindow{From: &from, To: &to, FromDate: &day, ToDate: &next}
+}
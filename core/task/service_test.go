@@ -0,0 +1,92 @@
+package task
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"tasker/core/group"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+)
+
+type fakeRepo struct {
+	Repository
+	created []*Task
+	tasks   map[int64]*Task
+	deleted []int64
+}
+
+func (r *fakeRepo) Create(ctx context.Context, t *Task) error {
+	t.ID = int64(len(r.created) + 1)
+	r.created = append(r.created, t)
+	return nil
+}
+
+func (r *fakeRepo) GetByID(ctx context.Context, userID, id int64) (*Task, error) {
+	t, ok := r.tasks[id]
+	if !ok {
+		return nil, apperror.New("TASK_NOT_FOUND", "task not found")
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (r *fakeRepo) Delete(ctx context.Context, userID, id int64) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func (r *fakeRepo) LastRank(ctx context.Context, groupID int64, status Status, excludeID int64) (string, error) {
+	return "", nil
+}
+
+type fakeGroups struct {
+	group.Service
+}
+
+func (fakeGroups) Authorize(ctx context.Context, userID, groupID int64, need group.Access) (*group.Group, error) {
+	return &group.Group{ID: groupID, WorkspaceID: 1, Name: "team"}, nil
+}
+
+type fakeUsers struct{}
+
+func (fakeUsers) GetByID(ctx context.Context, id int64) (*user.User, error) {
+	return &user.User{ID: id, Timezone: "UTC"}, nil
+}
+
+func TestCreateTaskInCategory(t *testing.T) {
+	groupID := int64(3)
+	tests := []struct {
+		name     string
+		category group.Category
+		status   Status
+		events   []string
+	}{
+		{"initial state", "", "pending", []string{EventCreated}},
+		{"completed", group.CategoryDone, "completed", []string{EventCreated, EventCompleted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{}
+			pub := &recordingPublisher{}
+			svc := NewService(repo, fakeGroups{}, fakeUsers{}, nil, WithEvents(pub))
+			before := time.Now()
+			got, err := svc.CreateTask(context.Background(), 1, CreateTaskInput{Title: "交报告", GroupID: &groupID, Category: tt.category})
+			if err != nil {
+				t.Fatalf("CreateTask: %v", err)
+			}
+			// 一次写入就是最终状态
+			if len(repo.created) != 1 || got.Status != tt.status {
+				t.Fatalf("created %d tasks, status %q, want %q", len(repo.created), got.Status, tt.status)
+			}
+			if done := tt.category == group.CategoryDone; (got.CompletedAt != nil) != done || (done && got.CompletedAt.Before(before)) {
+				t.Fatalf("completed_at = %v", got.CompletedAt)
+			}
+			if !reflect.DeepEqual(pub.types, tt.events) {
+				t.Fatalf("published %v, want %v", pub.types, tt.events)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package task
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"tasker/pkg/apperror"
+)
+
+const (
+	// 一个任务最多的标签数
+	maxTags = 20
+	// 单个标签的最大长度
+	maxTagLength = 50
+)
+
+// NormalizeTags 去掉首尾空白，按不区分大小写去重并保留第一次出现的写法，结果按不区分大小写排序，
+// 和repo读出来的顺序一致。标签不能为空，也不能包含空白，这样todo.txt的@context可以原样表示
+func NormalizeTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, apperror.New("INVALID_TAG", "tags must not be empty")
+		}
+		if strings.IndexFunc(tag, unicode.IsSpace) >= 0 {
+			return nil, apperror.New("INVALID_TAG", "tags must not contain spaces")
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, apperror.New("INVALID_TAG", "tags must be at most 50 characters")
+		}
+		key := strings.ToLower(tag)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, tag)
+	}
+	if len(result) > maxTags {
+		return nil, apperror.New("INVALID_TAG", "a task can have at most 20 tags")
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return strings.ToLower(result[i]) < strings.ToLower(result[j])
+	})
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package task
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"tasker/pkg/apperror"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := NormalizeTags([]string{" urgent ", "Home", "URGENT", "errand", "home"})
+	if err != nil {
+		t.Fatalf("NormalizeTags: %v", err)
+	}
+	if want := []string{"errand", "Home", "urgent"}; !reflect.DeepEqual(tags, want) {
+		t.Fatalf("tags = %v, want %v", tags, want)
+	}
+	if tags, err := NormalizeTags(nil); err != nil || len(tags) != 0 {
+		t.Fatalf("nil tags = %v, %v", tags, err)
+	}
+
+	many := make([]string, maxTags+1)
+	for i := range many {
+		many[i] = strings.Repeat("x", i+1)
+	}
+	for _, in := range [][]string{{""}, {"  "}, {"two words"}, {"tab\there"}, {strings.Repeat("标", maxTagLength+1)}, many} {
+		_, err := NormalizeTags(in)
+		if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "INVALID_TAG" {
+			t.Fatalf("NormalizeTags(%q) error = %v, want INVALID_TAG", in, err)
+		}
+	}
+	if _, err := NormalizeTags([]string{strings.Repeat("标", maxTagLength)}); err != nil {
+		t.Fatalf("50 characters: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package task
+
+import (
+	"time"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+)
+
+// openCategories 没有完成的任务
+var openCategories = []group.Category{group.CategoryTodo, group.CategoryDoing}
+
+// resolveStatusFilter 把Status和Category换成repo使用的Categories。
+// pending/completed在所有工作流里分别表示未完成/已完成，其他值按状态key精确匹配。
+// 返回false表示条件互相矛盾，结果一定为空
+func resolveStatusFilter(filter *ListTaskerFilter) (bool, error) {
+	var categories []group.Category
+	switch filter.Status {
+	case "":
+	case StatusPending:
+		categories = openCategories
+		filter.Status = ""
+	case StatusCompleted:
+		categories = []group.Category{group.CategoryDone}
+		filter.Status = ""
+	default:
+		if len(filter.Status) > 20 {
+			return false, apperror.New("INVALID_STATUS", "unknown status")
+		}
+	}
+
+	if filter.Category != "" {
+		if !filter.Category.Valid() {
+			return false, apperror.New("INVALID_CATEGORY", "category must be todo, doing or done")
+		}
+		only := []group.Category{filter.Category}
+		if categories != nil {
+			only = intersectCategories(categories, only)
+		}
+		categories = only
+	}
+
+	// 已完成的任务不算逾期
+	if filter.Due == "overdue" {
+		if categories == nil {
+			categories = openCategories
+		} else {
+			categories = intersectCategories(categories, openCategories)
+		}
+	}
+
+	filter.Categories = categories
+	return categories == nil || len(categories) > 0, nil
+}
+
+func intersectCategories(a, b []group.Category) []group.Category {
+	out := []group.Category{}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				out = append(out, x)
+			}
+		}
+	}
+	return out
+}
+
+// nextState 校验UpdateTask里的status。
+// 状态没变时直接保留（分组被删后任务可能停在默认工作流里没有的状态）；
+// 移动分组且状态没变时按大类换到新分组的工作流
+func nextState(t *Task, wf *group.Workflow, status Status, moved bool) (group.State, error) {
+	if status == t.Status {
+		if st, ok := wf.State(string(status)); ok {
+			return st, nil
+		}
+		if moved {
+			return wf.Map(string(status), t.StatusCategory), nil
+		}
+		return group.State{Key: string(t.Status), Category: t.StatusCategory}, nil
+	}
+
+	st, ok := wf.State(string(status))
+	if !ok {
+		return group.State{}, apperror.New("INVALID_STATUS", "status is not a state of the group's workflow")
+	}
+	// 原状态在这个工作流里时才检查流转规则
+	if _, ok := wf.State(string(t.Status)); ok && !wf.CanTransition(string(t.Status), st.Key) {
+		return group.State{}, apperror.New("INVALID_TRANSITION", "the group's workflow does not allow moving from "+string(t.Status)+" to "+st.Key)
+	}
+	return st, nil
+}
+
+// setState 切换状态：进入done大类时记下完成时间，离开时清空，done里换状态保留原来的时间
+func setState(t *Task, st group.State, now time.Time) {
+	wasDone := t.StatusCategory == group.CategoryDone
+	t.Status = Status(st.Key)
+	t.StatusCategory = st.Category
+	switch {
+	case st.Category != group.CategoryDone:
+		t.CompletedAt = nil
+	case !wasDone || t.CompletedAt == nil:
+		t.CompletedAt = &now
+	}
+}
@@ -0,0 +1,177 @@
+package task
+
+import (
+	"reflect"
+	"testing"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+)
+
+// kanban 待办 → 进行中 → 评审 → 完成，评审可以退回进行中
+func kanban() *group.Workflow {
+	return &group.Workflow{
+		States: []group.State{
+			{Key: "todo", Name: "待办", Category: group.CategoryTodo},
+			{Key: "doing", Name: "进行中", Category: group.CategoryDoing},
+			{Key: "review", Name: "评审", Category: group.CategoryDoing},
+			{Key: "done", Name: "完成", Category: group.CategoryDone},
+		},
+		Transitions: map[string][]string{
+			"todo":   {"doing"},
+			"doing":  {"review"},
+			"review": {"doing", "done"},
+		},
+	}
+}
+
+func TestNextState(t *testing.T) {
+	tests := []struct {
+		name   string
+		task   Task
+		wf     *group.Workflow
+		status Status
+		moved  bool
+		want   group.State
+		code   string
+	}{
+		{
+			name: "allowed transition",
+			task: Task{Status: "doing", StatusCategory: group.CategoryDoing}, wf: kanban(), status: "review",
+			want: group.State{Key: "review", Name: "评审", Category: group.CategoryDoing},
+		},
+		{
+			name: "forbidden transition",
+			task: Task{Status: "todo", StatusCategory: group.CategoryTodo}, wf: kanban(), status: "done",
+			code: "INVALID_TRANSITION",
+		},
+		{
+			name: "unknown status",
+			task: Task{Status: "todo", StatusCategory: group.CategoryTodo}, wf: kanban(), status: "archived",
+			code: "INVALID_STATUS",
+		},
+		{
+			name: "completed is not a state of the kanban workflow",
+			task: Task{Status: "review", StatusCategory: group.CategoryDoing}, wf: kanban(), status: StatusCompleted,
+			code: "INVALID_STATUS",
+		},
+		{
+			name: "default workflow allows reopening",
+			task: Task{Status: StatusCompleted, StatusCategory: group.CategoryDone}, wf: group.DefaultWorkflow(), status: StatusPending,
+			want: group.State{Key: group.StatePending, Name: "Pending", Category: group.CategoryTodo},
+		},
+		{
+			name: "unchanged status",
+			task: Task{Status: "todo", StatusCategory: group.CategoryTodo}, wf: kanban(), status: "todo",
+			want: group.State{Key: "todo", Name: "待办", Category: group.CategoryTodo},
+		},
+		// 分组换了工作流后任务可能停在已经不存在的状态，不改状态时原样保留
+		{
+			name: "unchanged status missing from the workflow",
+			task: Task{Status: "blocked", StatusCategory: group.CategoryDoing}, wf: kanban(), status: "blocked",
+			want: group.State{Key: "blocked", Category: group.CategoryDoing},
+		},
+		// 原状态不在这个工作流里时不检查流转规则
+		{
+			name: "leaving a state missing from the workflow",
+			task: Task{Status: "blocked", StatusCategory: group.CategoryDoing}, wf: kanban(), status: "done",
+			want: group.State{Key: "done", Name: "完成", Category: group.CategoryDone},
+		},
+		// 移动到另一个分组且状态不变时按大类换到新分组的工作流
+		{
+			name: "moved into a kanban group",
+			task: Task{Status: StatusPending, StatusCategory: group.CategoryTodo}, wf: kanban(), status: StatusPending, moved: true,
+			want: group.State{Key: "todo", Name: "待办", Category: group.CategoryTodo},
+		},
+		{
+			name: "moved done task into a kanban group",
+			task: Task{Status: StatusCompleted, StatusCategory: group.CategoryDone}, wf: kanban(), status: StatusCompleted, moved: true,
+			want: group.State{Key: "done", Name: "完成", Category: group.CategoryDone},
+		},
+		{
+			name: "moved doing task into the default workflow",
+			task: Task{Status: "review", StatusCategory: group.CategoryDoing}, wf: group.DefaultWorkflow(), status: "review", moved: true,
+			want: group.State{Key: group.StatePending, Name: "Pending", Category: group.CategoryTodo},
+		},
+		{
+			name: "moved with a state the new workflow shares",
+			task: Task{Status: "doing", StatusCategory: group.CategoryDoing}, wf: kanban(), status: "doing", moved: true,
+			want: group.State{Key: "doing", Name: "进行中", Category: group.CategoryDoing},
+		},
+		{
+			name: "moved and changed to a state of the new workflow",
+			task: Task{Status: StatusPending, StatusCategory: group.CategoryTodo}, wf: kanban(), status: "review", moved: true,
+			want: group.State{Key: "review", Name: "评审", Category: group.CategoryDoing},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextState(&tt.task, tt.wf, tt.status, tt.moved)
+			if tt.code != "" {
+				if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != tt.code {
+					t.Fatalf("nextState = %v, %v, want %s", got, err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nextState: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("nextState = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveStatusFilter(t *testing.T) {
+	todo, doing, done := group.CategoryTodo, group.CategoryDoing, group.CategoryDone
+	tests := []struct {
+		name       string
+		in         ListTaskerFilter
+		status     Status
+		categories []group.Category
+		nonEmpty   bool
+		code       string
+	}{
+		{name: "no filter", nonEmpty: true},
+		// pending/completed是保留key，在所有工作流里都按大类筛选
+		{name: "pending", in: ListTaskerFilter{Status: StatusPending}, categories: []group.Category{todo, doing}, nonEmpty: true},
+		{name: "completed", in: ListTaskerFilter{Status: StatusCompleted}, categories: []group.Category{done}, nonEmpty: true},
+		{name: "custom status key", in: ListTaskerFilter{Status: "review"}, status: "review", nonEmpty: true},
+		{name: "status key too long", in: ListTaskerFilter{Status: "a_very_long_status_key"}, code: "INVALID_STATUS"},
+		{name: "category", in: ListTaskerFilter{Category: doing}, categories: []group.Category{doing}, nonEmpty: true},
+		{name: "invalid category", in: ListTaskerFilter{Category: "blocked"}, code: "INVALID_CATEGORY"},
+		{name: "pending and doing", in: ListTaskerFilter{Status: StatusPending, Category: doing}, categories: []group.Category{doing}, nonEmpty: true},
+		{name: "pending and done", in: ListTaskerFilter{Status: StatusPending, Category: done}, categories: []group.Category{}},
+		{name: "completed and done", in: ListTaskerFilter{Status: StatusCompleted, Category: done}, categories: []group.Category{done}, nonEmpty: true},
+		{name: "custom status and category", in: ListTaskerFilter{Status: "review", Category: doing}, status: "review", categories: []group.Category{doing}, nonEmpty: true},
+		// 已完成的任务不算逾期
+		{name: "overdue", in: ListTaskerFilter{Due: "overdue"}, categories: []group.Category{todo, doing}, nonEmpty: true},
+		{name: "overdue and todo", in: ListTaskerFilter{Due: "overdue", Category: todo}, categories: []group.Category{todo}, nonEmpty: true},
+		{name: "overdue and doing", in: ListTaskerFilter{Due: "overdue", Category: doing}, categories: []group.Category{doing}, nonEmpty: true},
+		{name: "overdue and done", in: ListTaskerFilter{Due: "overdue", Category: done}, categories: []group.Category{}},
+		{name: "overdue and completed", in: ListTaskerFilter{Due: "overdue", Status: StatusCompleted}, categories: []group.Category{}},
+		{name: "overdue and pending", in: ListTaskerFilter{Due: "overdue", Status: StatusPending}, categories: []group.Category{todo, doing}, nonEmpty: true},
+		{name: "overdue, pending and doing", in: ListTaskerFilter{Due: "overdue", Status: StatusPending, Category: doing}, categories: []group.Category{doing}, nonEmpty: true},
+		{name: "today is not restricted", in: ListTaskerFilter{Due: "today"}, nonEmpty: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.in
+			nonEmpty, err := resolveStatusFilter(&f)
+			if tt.code != "" {
+				if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != tt.code {
+					t.Fatalf("resolveStatusFilter = %v, want %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveStatusFilter: %v", err)
+			}
+			if nonEmpty != tt.nonEmpty || f.Status != tt.status || !reflect.DeepEqual(f.Categories, tt.categories) {
+				t.Fatalf("got %v, status %q, categories %#v; want %v, %q, %#v",
+					nonEmpty, f.Status, f.Categories, tt.nonEmpty, tt.status, tt.categories)
+			}
+		})
+	}
+}
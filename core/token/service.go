@@ -0,0 +1,203 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"tasker/pkg/apperror"
+)
+
+// Scope 限定个人访问令牌能调用哪些接口
+type Scope string
+
+const (
+	ScopeTasksRead   Scope = "tasks:read"
+	ScopeTasksWrite  Scope = "tasks:write"
+	ScopeGroupsWrite Scope = "groups:write"
+)
+
+// AllScopes 用于校验入参；JWT登录态视为拥有全部scope
+var AllScopes = []Scope{ScopeTasksRead, ScopeTasksWrite, ScopeGroupsWrite}
+
+// Prefix 明文令牌的固定前缀，中间件靠它区分PAT和JWT
+const Prefix = "tsk_"
+
+// 列表里展示的明文前缀长度（含tsk_），方便用户辨认是哪一个令牌
+const displayPrefixLen = len(Prefix) + 8
+
+// last_used_at 的最小刷新间隔，避免每个请求都写一次库
+const touchInterval = time.Minute
+
+// Token 个人访问令牌，只保存哈希，明文只在创建时返回一次
+type Token struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []Scope    `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope 判断令牌是否带有某个scope
+func (t *Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 创建令牌时用的入参
+type CreateTokenInput struct {
+	Name      string     `json:"name"`
+	Scopes    []Scope    `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Repository 抽象令牌的持久化
+type Repository interface {
+	Create(ctx context.Context, t *Token) error
+	ListByUserID(ctx context.Context, userID int64) ([]*Token, error)
+	GetByHash(ctx context.Context, hash string) (*Token, error)
+	Revoke(ctx context.Context, userID, id int64, at time.Time) error
+	TouchLastUsed(ctx context.Context, id int64, at time.Time) error
+}
+
+// Service 令牌相关业务
+type Service interface {
+	// Create 返回令牌记录和明文，明文之后无法再取回
+	Create(ctx context.Context, userID int64, in CreateTokenInput) (*Token, string, error)
+	List(ctx context.Context, userID int64) ([]*Token, error)
+	Revoke(ctx context.Context, userID, id int64) error
+	// Authenticate 校验明文令牌，成功后顺带刷新last_used_at
+	Authenticate(ctx context.Context, raw string) (*Token, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) Create(ctx context.Context, userID int64, in CreateTokenInput) (*Token, string, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 100 {
+		return nil, "", apperror.New("INVALID_TOKEN_NAME", "token name is required and must be at most 100 characters")
+	}
+	if len(in.Scopes) == 0 {
+		return nil, "", apperror.New("INVALID_SCOPE", "at least one scope is required")
+	}
+	scopes, err := normalizeScopes(in.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(now) {
+		return nil, "", apperror.New("INVALID_EXPIRES_AT", "expires_at must be in the future")
+	}
+
+	raw, err := generate()
+	if err != nil {
+		return nil, "", apperror.New("INTERNAL_ERROR", "failed to generate token")
+	}
+
+	t := &Token{
+		UserID:    userID,
+		Name:      in.Name,
+		Prefix:    raw[:displayPrefixLen],
+		Hash:      hash(raw),
+		Scopes:    scopes,
+		ExpiresAt: in.ExpiresAt,
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(ctx, t); err != nil {
+		return nil, "", err
+	}
+	return t, raw, nil
+}
+
+func (s *service) List(ctx context.Context, userID int64) ([]*Token, error) {
+	return s.repo.ListByUserID(ctx, userID)
+}
+
+func (s *service) Revoke(ctx context.Context, userID, id int64) error {
+	return s.repo.Revoke(ctx, userID, id, time.Now())
+}
+
+func (s *service) Authenticate(ctx context.Context, raw string) (*Token, error) {
+	if !strings.HasPrefix(raw, Prefix) {
+		return nil, apperror.New("INVALID_TOKEN", "invalid token")
+	}
+
+	t, err := s.repo.GetByHash(ctx, hash(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if t.RevokedAt != nil {
+		return nil, apperror.New("INVALID_TOKEN", "token has been revoked")
+	}
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(now) {
+		return nil, apperror.New("INVALID_TOKEN", "token has expired")
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= touchInterval {
+		// 审计字段，写失败不影响本次请求
+		if err := s.repo.TouchLastUsed(ctx, t.ID, now); err == nil {
+			t.LastUsedAt = &now
+		}
+	}
+	return t, nil
+}
+
+// 去重并校验scope是否合法
+func normalizeScopes(in []Scope) ([]Scope, error) {
+	seen := make(map[Scope]bool, len(in))
+	out := make([]Scope, 0, len(in))
+	for _, sc := range in {
+		valid := false
+		for _, known := range AllScopes {
+			if sc == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, apperror.New("INVALID_SCOPE", "unknown scope: "+string(sc))
+		}
+		if !seen[sc] {
+			seen[sc] = true
+			out = append(out, sc)
+		}
+	}
+	return out, nil
+}
+
+// 生成 tsk_ + 32字节随机数
+func generate() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return Prefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// 令牌本身是高熵随机串，用sha256即可，不需要bcrypt
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
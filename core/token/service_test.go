@@ -0,0 +1,237 @@
+package token_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"tasker/core/token"
+	"tasker/pkg/apperror"
+)
+
+// fakeRepo 内存版的token.Repository，按哈希查找
+type fakeRepo struct {
+	tokens  []*token.Token
+	touched int
+}
+
+func (r *fakeRepo) Create(ctx context.Context, t *token.Token) error {
+	t.ID = int64(len(r.tokens) + 1)
+	cp := *t
+	r.tokens = append(r.tokens, &cp)
+	return nil
+}
+
+func (r *fakeRepo) ListByUserID(ctx context.Context, userID int64) ([]*token.Token, error) {
+	var out []*token.Token
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) GetByHash(ctx context.Context, hash string) (*token.Token, error) {
+	for _, t := range r.tokens {
+		if t.Hash == hash {
+			cp := *t
+			return &cp, nil
+		}
+	}
+	return nil, apperror.New("INVALID_TOKEN", "invalid token")
+}
+
+func (r *fakeRepo) Revoke(ctx context.Context, userID, id int64, at time.Time) error {
+	for _, t := range r.tokens {
+		if t.ID == id && t.UserID == userID {
+			t.RevokedAt = &at
+			return nil
+		}
+	}
+	return apperror.New("TOKEN_NOT_FOUND", "token not found")
+}
+
+func (r *fakeRepo) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	r.touched++
+	r.tokens[id-1].LastUsedAt = &at
+	return nil
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != code {
+		t.Fatalf("err = %v, want %s", err, code)
+	}
+}
+
+func TestCreateStoresOnlyTheHash(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := token.NewService(repo)
+	ctx := context.Background()
+
+	tk, raw, err := svc.Create(ctx, 1, token.CreateTokenInput{
+		Name:   "  ci  ",
+		Scopes: []token.Scope{token.ScopeTasksRead, token.ScopeTasksWrite, token.ScopeTasksRead},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(raw, token.Prefix) || len(raw) != len(token.Prefix)+43 {
+		t.Fatalf("raw token = %q", raw)
+	}
+	sum := sha256.Sum256([]byte(raw))
+	stored := repo.tokens[0]
+	if stored.Hash != hex.EncodeToString(sum[:]) || strings.Contains(stored.Hash, raw[len(token.Prefix):]) {
+		t.Fatalf("stored hash = %q", stored.Hash)
+	}
+	if tk.Name != "ci" || tk.Prefix != raw[:len(token.Prefix)+8] || len(tk.Scopes) != 2 {
+		t.Fatalf("token = %+v", tk)
+	}
+
+	// 同一个明文能找回这条记录，改一个字符就找不到
+	got, err := svc.Authenticate(ctx, raw)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got.ID != tk.ID || got.UserID != 1 {
+		t.Fatalf("authenticated %+v", got)
+	}
+	tampered := raw[:len(raw)-1] + "A"
+	if tampered == raw {
+		tampered = raw[:len(raw)-1] + "B"
+	}
+	_, err = svc.Authenticate(ctx, tampered)
+	assertCode(t, err, "INVALID_TOKEN")
+
+	// 第二次创建的令牌明文和哈希都不同
+	_, raw2, err := svc.Create(ctx, 1, token.CreateTokenInput{Name: "ci", Scopes: []token.Scope{token.ScopeTasksRead}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if raw2 == raw || repo.tokens[1].Hash == stored.Hash {
+		t.Fatal("two tokens share a secret")
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name string
+		in   token.CreateTokenInput
+		code string
+	}{
+		{"empty name", token.CreateTokenInput{Name: "  ", Scopes: token.AllScopes}, "INVALID_TOKEN_NAME"},
+		{"name too long", token.CreateTokenInput{Name: strings.Repeat("a", 101), Scopes: token.AllScopes}, "INVALID_TOKEN_NAME"},
+		{"no scopes", token.CreateTokenInput{Name: "ci"}, "INVALID_SCOPE"},
+		{"unknown scope", token.CreateTokenInput{Name: "ci", Scopes: []token.Scope{"admin"}}, "INVALID_SCOPE"},
+		{"expired", token.CreateTokenInput{Name: "ci", Scopes: token.AllScopes, ExpiresAt: &past}, "INVALID_EXPIRES_AT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{}
+			_, _, err := token.NewService(repo).Create(context.Background(), 1, tt.in)
+			assertCode(t, err, tt.code)
+			if len(repo.tokens) != 0 {
+				t.Fatal("invalid token was stored")
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	now := time.Now()
+	soon, past := now.Add(time.Hour), now.Add(-time.Second)
+	recently, longAgo := now.Add(-10*time.Second), now.Add(-time.Hour)
+	tests := []struct {
+		name    string
+		raw     string
+		edit    func(t *token.Token)
+		code    string
+		touched int
+	}{
+		{name: "never used", touched: 1},
+		{name: "not expired yet", edit: func(t *token.Token) { t.ExpiresAt = &soon }, touched: 1},
+		{name: "expired", edit: func(t *token.Token) { t.ExpiresAt = &past }, code: "INVALID_TOKEN"},
+		{name: "revoked", edit: func(t *token.Token) { t.RevokedAt = &past }, code: "INVALID_TOKEN"},
+		// last_used_at一分钟内刷新过就不再写库
+		{name: "used recently", edit: func(t *token.Token) { t.LastUsedAt = &recently }, touched: 0},
+		{name: "used long ago", edit: func(t *token.Token) { t.LastUsedAt = &longAgo }, touched: 1},
+		{name: "jwt is not a token", raw: "eyJhbGciOiJSUzI1NiJ9.e30.sig", code: "INVALID_TOKEN"},
+		{name: "unknown token", raw: token.Prefix + "unknown", code: "INVALID_TOKEN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{}
+			svc := token.NewService(repo)
+			_, raw, err := svc.Create(context.Background(), 1, token.CreateTokenInput{Name: "ci", Scopes: token.AllScopes})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if tt.raw != "" {
+				raw = tt.raw
+			}
+			if tt.edit != nil {
+				tt.edit(repo.tokens[0])
+			}
+
+			got, err := svc.Authenticate(context.Background(), raw)
+			if tt.code != "" {
+				assertCode(t, err, tt.code)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if repo.touched != tt.touched {
+				t.Fatalf("touched %d times, want %d", repo.touched, tt.touched)
+			}
+			if tt.touched == 1 && (got.LastUsedAt == nil || got.LastUsedAt.Before(now)) {
+				t.Fatalf("last_used_at = %v", got.LastUsedAt)
+			}
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := token.NewService(repo)
+	ctx := context.Background()
+	tk, raw, err := svc.Create(ctx, 1, token.CreateTokenInput{Name: "ci", Scopes: token.AllScopes})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// 别人的令牌撤销不了
+	assertCode(t, svc.Revoke(ctx, 2, tk.ID), "TOKEN_NOT_FOUND")
+	if err := svc.Revoke(ctx, 1, tk.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	_, err = svc.Authenticate(ctx, raw)
+	assertCode(t, err, "INVALID_TOKEN")
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes []token.Scope
+		scope  token.Scope
+		want   bool
+	}{
+		{[]token.Scope{token.ScopeTasksRead}, token.ScopeTasksRead, true},
+		// 写权限不包含读权限
+		{[]token.Scope{token.ScopeTasksWrite}, token.ScopeTasksRead, false},
+		{[]token.Scope{token.ScopeTasksRead, token.ScopeTasksWrite}, token.ScopeTasksWrite, true},
+		{[]token.Scope{token.ScopeTasksWrite}, token.ScopeGroupsWrite, false},
+		{nil, token.ScopeTasksRead, false},
+		{token.AllScopes, token.ScopeGroupsWrite, true},
+	}
+	for _, tt := range tests {
+		tk := &token.Token{Scopes: tt.scopes}
+		if got := tk.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%v.HasScope(%s) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
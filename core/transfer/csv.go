@@ -0,0 +1,153 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasker/pkg/apperror"
+	"tasker/pkg/date"
+)
+
+// CSV格式：每行一个任务，第一行是表头；标签用空格分隔，评论是JSON数组，分组只有名称
+var csvColumns = []string{
+	"id", "external_id", "group", "title", "description", "status", "priority", "tags",
+	"due_date", "due_on", "completed_at", "created_at", "updated_at", "comments",
+}
+
+func init() {
+	register(&Format{
+		Name:        "csv",
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		NewEncoder:  newCSVEncoder,
+		Decode:      decodeCSV,
+	})
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) Encoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Begin(groups []*GroupRecord) error {
+	return e.w.Write(csvColumns)
+}
+
+func (e *csvEncoder) Task(r *Record) error {
+	comments := ""
+	if len(r.Comments) > 0 {
+		buf, err := json.Marshal(r.Comments)
+		if err != nil {
+			return err
+		}
+		comments = string(buf)
+	}
+	dueOn := ""
+	if r.DueOn != nil {
+		dueOn = r.DueOn.String()
+	}
+	return e.w.Write([]string{
+		strconv.FormatInt(r.ID, 10), r.ExternalID, r.Group, r.Title, r.Description, r.Status, r.Priority, strings.Join(r.Tags, " "),
+		formatTime(r.DueDate), dueOn, formatTime(r.CompletedAt), formatTime(r.CreatedAt), formatTime(r.UpdatedAt), comments,
+	})
+}
+
+func (e *csvEncoder) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// decodeCSV 按表头取列，不认识的列忽略，只有title是必需的列；
+// 单元格格式错误记到Document.Errors里，和其他校验错误一起报告
+func decodeCSV(r io.Reader) (*Document, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, apperror.New("INVALID_IMPORT", "the CSV file is empty")
+		}
+		return nil, apperror.New("INVALID_IMPORT", "invalid CSV: "+err.Error())
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// Excel导出的文件可能带BOM
+		name = strings.TrimPrefix(name, "\uFEFF")
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["title"]; !ok {
+		return nil, apperror.New("INVALID_IMPORT", "the CSV header must contain a title column")
+	}
+
+	doc := &Document{}
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			return nil, apperror.New("INVALID_IMPORT", "invalid CSV: "+err.Error())
+		}
+		cell := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		rec := &Record{
+			ExternalID:  cell("external_id"),
+			Group:       cell("group"),
+			Title:       cell("title"),
+			Description: cell("description"),
+			Status:      cell("status"),
+			Priority:    cell("priority"),
+		}
+		// 没有tags列时保留已有任务的标签，有这一列时空单元格表示没有标签
+		if _, ok := index["tags"]; ok {
+			rec.Tags = strings.Fields(cell("tags"))
+		}
+		parseTime := func(name string) *time.Time {
+			v := cell(name)
+			if v == "" {
+				return nil
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				doc.Errors = append(doc.Errors, RowError{Row: line, Field: name, Message: "must be an RFC3339 timestamp"})
+				return nil
+			}
+			return &t
+		}
+		rec.DueDate = parseTime("due_date")
+		rec.CompletedAt = parseTime("completed_at")
+		rec.CreatedAt = parseTime("created_at")
+		if v := cell("due_on"); v != "" {
+			if d, err := date.Parse(v); err != nil {
+				doc.Errors = append(doc.Errors, RowError{Row: line, Field: "due_on", Message: "must be a date in YYYY-MM-DD format"})
+			} else {
+				rec.DueOn = &d
+			}
+		}
+		doc.Tasks = append(doc.Tasks, rec)
+		doc.Rows = append(doc.Rows, line)
+	}
+	return doc, nil
+}
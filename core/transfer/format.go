@@ -0,0 +1,102 @@
+package transfer
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"tasker/core/group"
+	"tasker/pkg/date"
+)
+
+// Record 导出和导入的一条任务
+type Record struct {
+	// 只在导出时填写，导入时忽略
+	ID int64 `json:"id,omitempty"`
+	// 重复导入时按它更新已有任务，空表示每次都新建
+	ExternalID  string `json:"external_id"`
+	Group       string `json:"group"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	// 状态所属的大类；status为空时按它取工作流里同一大类的第一个状态
+	Category    group.Category `json:"status_category,omitempty"`
+	Priority    string         `json:"priority"`
+	DueDate     *time.Time     `json:"due_date"`
+	DueOn       *date.Date     `json:"due_on"`
+	CompletedAt *time.Time     `json:"completed_at"`
+	CreatedAt   *time.Time     `json:"created_at"`
+	UpdatedAt   *time.Time     `json:"updated_at,omitempty"`
+	// 导入时nil表示不修改已有任务的标签，空数组表示清空
+	Tags []string `json:"tags,omitempty"`
+	// 只导出，导入时忽略
+	Comments []CommentRecord `json:"comments,omitempty"`
+}
+
+// CommentRecord 导出的评论，作者注销后Author为空
+type CommentRecord struct {
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GroupRecord 导出的分组，导入时按名称对应
+type GroupRecord struct {
+	Name     string          `json:"name"`
+	Workflow *group.Workflow `json:"workflow"`
+}
+
+// Document 解析后的导入内容，Rows和Tasks一一对应，是每条任务在文件里的位置（CSV是行号）
+type Document struct {
+	Groups []*GroupRecord
+	Tasks  []*Record
+	Rows   []int
+	// 单元格格式不对等解析时发现的问题
+	Errors []RowError
+}
+
+// RowError 校验报告里的一条错误，Row对应Document.Rows
+type RowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Encoder 流式写出导出内容：先写全部分组，再逐条写任务
+type Encoder interface {
+	Begin(groups []*GroupRecord) error
+	Task(r *Record) error
+	End() error
+}
+
+// Format 一种导入导出格式
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	NewEncoder  func(w io.Writer) Encoder
+	// Decode 解析失败返回 INVALID_IMPORT
+	Decode func(r io.Reader) (*Document, error)
+}
+
+var formats = map[string]*Format{}
+
+func register(f *Format) {
+	formats[f.Name] = f
+}
+
+// Lookup 按名称查找格式
+func Lookup(name string) (*Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// Names 支持的格式名，用于错误信息
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,274 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+	"tasker/pkg/date"
+	"tasker/pkg/ical"
+)
+
+// ICS格式：一个VCALENDAR，每个任务一个VTODO，分组名写在CATEGORIES里。
+// 只导出任务，工作流和评论不在里面
+const icsProdID = "-//Tasker//Tasker//EN"
+
+func init() {
+	register(&Format{
+		Name:        "ics",
+		ContentType: "text/calendar; charset=utf-8",
+		Extension:   "ics",
+		NewEncoder: func(w io.Writer) Encoder {
+			return NewICSEncoder(w, ICSOptions{})
+		},
+		Decode: decodeICS,
+	})
+}
+
+// ICSOptions 导出和日历订阅共用的输出选项
+type ICSOptions struct {
+	// 日历名称，订阅后显示在日历列表里
+	Name string
+	// 输出VEVENT而不是VTODO，给不显示待办的日历应用用；没有截止时间的任务跳过
+	Events bool
+	// 输出单个CalDAV日历对象资源：不写METHOD和订阅相关的属性
+	Object bool
+}
+
+type icsEncoder struct {
+	w    *ical.Writer
+	opts ICSOptions
+	now  time.Time
+}
+
+func NewICSEncoder(w io.Writer, opts ICSOptions) Encoder {
+	return &icsEncoder{w: ical.NewWriter(w), opts: opts, now: time.Now()}
+}
+
+func (e *icsEncoder) Begin(groups []*GroupRecord) error {
+	e.w.Begin("VCALENDAR")
+	e.w.Prop("VERSION", "2.0")
+	e.w.Prop("PRODID", icsProdID)
+	e.w.Prop("CALSCALE", "GREGORIAN")
+	if e.opts.Object {
+		return e.w.Err()
+	}
+	e.w.Prop("METHOD", "PUBLISH")
+	if e.opts.Name != "" {
+		e.w.Text("X-WR-CALNAME", e.opts.Name)
+	}
+	// 订阅方的建议刷新间隔
+	e.w.Prop("REFRESH-INTERVAL", "PT1H", "VALUE", "DURATION")
+	e.w.Prop("X-PUBLISHED-TTL", "PT1H")
+	return e.w.Err()
+}
+
+func (e *icsEncoder) Task(r *Record) error {
+	if e.opts.Events && r.DueDate == nil && r.DueOn == nil {
+		return nil
+	}
+	name, due := "VTODO", "DUE"
+	if e.opts.Events {
+		name, due = "VEVENT", "DTSTART"
+	}
+
+	w := e.w
+	w.Begin(name)
+	w.Text("UID", ICSUID(r))
+	stamp := e.now
+	if r.UpdatedAt != nil {
+		stamp = *r.UpdatedAt
+	}
+	w.Prop("DTSTAMP", ical.FormatDateTime(stamp))
+	if r.CreatedAt != nil {
+		w.Prop("CREATED", ical.FormatDateTime(*r.CreatedAt))
+	}
+	if r.UpdatedAt != nil {
+		w.Prop("LAST-MODIFIED", ical.FormatDateTime(*r.UpdatedAt))
+	}
+	w.Text("SUMMARY", r.Title)
+	if r.Description != "" {
+		w.Text("DESCRIPTION", r.Description)
+	}
+	if r.Group != "" {
+		w.Text("CATEGORIES", r.Group)
+	}
+	switch {
+	case r.DueOn != nil:
+		w.Prop(due, ical.FormatDate(*r.DueOn), "VALUE", "DATE")
+	case r.DueDate != nil:
+		w.Prop(due, ical.FormatDateTime(*r.DueDate))
+	}
+	if p := icsPriority(r.Priority); p > 0 {
+		w.Prop("PRIORITY", strconv.Itoa(p))
+	}
+	if e.opts.Events {
+		// 任务不占用空闲时间
+		w.Prop("TRANSP", "TRANSPARENT")
+	} else {
+		switch r.Category {
+		case group.CategoryDone:
+			w.Prop("STATUS", "COMPLETED")
+			w.Prop("PERCENT-COMPLETE", "100")
+			if r.CompletedAt != nil {
+				w.Prop("COMPLETED", ical.FormatDateTime(*r.CompletedAt))
+			}
+		case group.CategoryDoing:
+			w.Prop("STATUS", "IN-PROCESS")
+		default:
+			w.Prop("STATUS", "NEEDS-ACTION")
+		}
+	}
+	w.End(name)
+	return w.Err()
+}
+
+func (e *icsEncoder) End() error {
+	e.w.End("VCALENDAR")
+	return e.w.Flush()
+}
+
+// ICSUID 导入的任务沿用原来的UID，其他任务按ID生成
+func ICSUID(r *Record) string {
+	if r.ExternalID != "" {
+		return r.ExternalID
+	}
+	return fmt.Sprintf("task-%d@tasker", r.ID)
+}
+
+// TaskIDFromICSUID 按ICSUID生成规则解析出任务ID，不是这种格式时返回false
+func TaskIDFromICSUID(uid string) (int64, bool) {
+	s, ok := strings.CutPrefix(uid, "task-")
+	if !ok {
+		return 0, false
+	}
+	s, ok = strings.CutSuffix(s, "@tasker")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 || strconv.FormatInt(id, 10) != s {
+		return 0, false
+	}
+	return id, true
+}
+
+// icsPriority 优先级对应到PRIORITY：1最高，9最低，0表示未定义
+func icsPriority(p string) int {
+	switch strings.ToLower(p) {
+	case "high", "urgent":
+		return 1
+	case "medium", "normal":
+		return 5
+	case "low":
+		return 9
+	}
+	return 0
+}
+
+// priorityFromICS icsPriority的逆操作，1-4为high，5为medium，6-9为low
+func priorityFromICS(v string) string {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	switch {
+	case err != nil:
+		return ""
+	case n >= 1 && n <= 4:
+		return "high"
+	case n == 5:
+		return "medium"
+	case n >= 6 && n <= 9:
+		return "low"
+	}
+	return ""
+}
+
+// decodeICS 只读取VTODO，其他组件忽略。UID作为external_id，重复上传同一个文件会更新而不是重复创建；
+// 行号是BEGIN:VTODO所在的行，不带时区的时间按UTC解释
+func decodeICS(r io.Reader) (*Document, error) {
+	cal, err := ical.Parse(r)
+	if err != nil {
+		return nil, apperror.New("INVALID_IMPORT", "invalid iCalendar: "+strings.TrimPrefix(err.Error(), ical.ErrInvalid.Error()+": "))
+	}
+	if cal.Name != "VCALENDAR" {
+		return nil, apperror.New("INVALID_IMPORT", "the file must contain a VCALENDAR")
+	}
+
+	doc := &Document{}
+	for _, c := range cal.Children {
+		if c.Name != "VTODO" {
+			continue
+		}
+		line := c.Line
+		fail := func(field, msg string) {
+			doc.Errors = append(doc.Errors, RowError{Row: line, Field: field, Message: msg})
+		}
+		timeOf := func(name string) *ical.Property {
+			p := c.Get(name)
+			if p == nil || p.Value == "" {
+				return nil
+			}
+			return p
+		}
+
+		rec := &Record{}
+		if p := c.Get("UID"); p != nil {
+			rec.ExternalID = p.Text()
+		}
+		if p := c.Get("SUMMARY"); p != nil {
+			rec.Title = p.Text()
+		}
+		if p := c.Get("DESCRIPTION"); p != nil {
+			rec.Description = p.Text()
+		}
+		if p := c.Get("CATEGORIES"); p != nil {
+			// 只用第一个分类作为分组
+			rec.Group = ical.SplitText(p.Value)[0]
+		}
+		if p := c.Get("PRIORITY"); p != nil {
+			rec.Priority = priorityFromICS(p.Value)
+		}
+		if p := timeOf("DUE"); p != nil {
+			t, isDate, err := p.Time(time.UTC)
+			switch {
+			case err != nil:
+				fail("due", "must be a date or a date-time")
+			case isDate:
+				d := date.Of(t)
+				rec.DueOn = &d
+			default:
+				rec.DueDate = &t
+			}
+		}
+		if p := timeOf("COMPLETED"); p != nil {
+			if t, _, err := p.Time(time.UTC); err != nil {
+				fail("completed", "must be a date-time")
+			} else {
+				rec.CompletedAt = &t
+				rec.Category = group.CategoryDone
+			}
+		}
+		if p := timeOf("CREATED"); p != nil {
+			if t, _, err := p.Time(time.UTC); err == nil {
+				rec.CreatedAt = &t
+			}
+		}
+		if p := c.Get("STATUS"); p != nil {
+			switch strings.ToUpper(p.Value) {
+			case "COMPLETED", "CANCELLED":
+				rec.Category = group.CategoryDone
+			case "IN-PROCESS":
+				rec.Category = group.CategoryDoing
+			case "NEEDS-ACTION":
+				rec.Category = group.CategoryTodo
+				rec.CompletedAt = nil
+			}
+		}
+		doc.Tasks = append(doc.Tasks, rec)
+		doc.Rows = append(doc.Rows, line)
+	}
+	return doc, nil
+}
@@ -0,0 +1,105 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"tasker/pkg/apperror"
+)
+
+// JSON格式：{"version": 1, "groups": [...], "tasks": [...]}
+const jsonVersion = 1
+
+func init() {
+	register(&Format{
+		Name:        "json",
+		ContentType: "application/json; charset=utf-8",
+		Extension:   "json",
+		NewEncoder:  newJSONEncoder,
+		Decode:      decodeJSON,
+	})
+}
+
+type jsonDocument struct {
+	Version int            `json:"version"`
+	Groups  []*GroupRecord `json:"groups"`
+	Tasks   []*Record      `json:"tasks"`
+}
+
+// jsonEncoder 手写外层结构，任务逐条编码，不需要把全部任务放进内存
+type jsonEncoder struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newJSONEncoder(w io.Writer) Encoder {
+	return &jsonEncoder{w: bufio.NewWriter(w)}
+}
+
+func (e *jsonEncoder) Begin(groups []*GroupRecord) error {
+	if groups == nil {
+		groups = []*GroupRecord{}
+	}
+	buf, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(e.w, `{"version":%d,"groups":`, jsonVersion); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(buf); err != nil {
+		return err
+	}
+	_, err = e.w.WriteString(`,"tasks":[`)
+	return err
+}
+
+func (e *jsonEncoder) Task(r *Record) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if e.count > 0 {
+		if err := e.w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	e.count++
+	if _, err := e.w.WriteString("\n"); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(buf); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *jsonEncoder) End() error {
+	if _, err := e.w.WriteString("\n]}\n"); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func decodeJSON(r io.Reader) (*Document, error) {
+	var doc jsonDocument
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, apperror.New("INVALID_IMPORT", "invalid JSON: "+err.Error())
+	}
+	if doc.Version != 0 && doc.Version != jsonVersion {
+		return nil, apperror.New("INVALID_IMPORT", "unsupported export version")
+	}
+	out := &Document{Groups: doc.Groups, Tasks: make([]*Record, 0, len(doc.Tasks)), Rows: make([]int, 0, len(doc.Tasks))}
+	for i, t := range doc.Tasks {
+		if t == nil {
+			t = &Record{}
+		}
+		out.Tasks = append(out.Tasks, t)
+		// JSON里按任务在数组中的序号（从1开始）报告错误
+		out.Rows = append(out.Rows, i+1)
+	}
+	return out, nil
+}
@@ -0,0 +1,173 @@
+package transfer
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+)
+
+/*
+Markdown格式：GitHub风格的任务列表，标题是分组
+
+	# 工作
+
+	- [ ] 写周报
+	  周五之前发出
+	- [x] 提交报销
+
+条目下面缩进的行是描述。其他内容（段落、普通列表）导入时忽略。
+只有分组、标题、描述和是否完成，其余字段不在里面。
+描述里看起来像任务条目的行前面加\转义，以#结尾的分组名在标题后面补一个#
+*/
+
+func init() {
+	register(&Format{
+		Name:        "markdown",
+		ContentType: "text/markdown; charset=utf-8",
+		Extension:   "md",
+		NewEncoder:  newMarkdownEncoder,
+		Decode:      decodeMarkdown,
+	})
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^#{1,6}\s+(.*?)(\s+#+)?\s*$`)
+	markdownItem    = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	// 转义过的任务条目，可能本来就以\开头，所以前面可以有多个\
+	markdownEscaped = regexp.MustCompile(`^(\s*)\\(\\*[-*+]\s+\[[ xX]\]\s)`)
+)
+
+// markdownEncoder 同一分组的任务要写在同一个标题下，而任务按ID顺序到达，
+// 所以先按分组攒起来，End时再写出。每个任务只保留一行文本，占用不大
+type markdownEncoder struct {
+	w      *bufio.Writer
+	order  []string
+	groups map[string][]string
+}
+
+func newMarkdownEncoder(w io.Writer) Encoder {
+	return &markdownEncoder{w: bufio.NewWriter(w), groups: map[string][]string{}}
+}
+
+// Begin 分组按导出时的顺序排列，没有分组的任务写在最前面、不带标题
+func (e *markdownEncoder) Begin(groups []*GroupRecord) error {
+	e.order = append(e.order, "")
+	for _, g := range groups {
+		e.order = append(e.order, g.Name)
+		e.groups[g.Name] = nil
+	}
+	return nil
+}
+
+func (e *markdownEncoder) Task(r *Record) error {
+	if _, ok := e.groups[r.Group]; !ok && r.Group != "" {
+		e.order = append(e.order, r.Group)
+	}
+	box := "[ ]"
+	if r.Category == group.CategoryDone {
+		box = "[x]"
+	}
+	var b strings.Builder
+	b.WriteString("- " + box + " " + strings.Join(strings.Fields(r.Title), " ") + "\n")
+	if desc := strings.TrimRight(r.Description, " \t\r\n"); desc != "" {
+		for _, line := range strings.Split(desc, "\n") {
+			line = strings.TrimRight(line, " \t\r")
+			if line == "" {
+				b.WriteString("\n")
+				continue
+			}
+			b.WriteString("  " + escapeMarkdownLine(line) + "\n")
+		}
+	}
+	e.groups[r.Group] = append(e.groups[r.Group], b.String())
+	return nil
+}
+
+// escapeMarkdownLine 描述里的任务条目导入时会变成新任务，在条目符号前加\；
+// 本来就以\开头的条目多加一个，导入时只去掉一个
+func escapeMarkdownLine(line string) string {
+	body := strings.TrimLeft(line, " \t")
+	if markdownItem.MatchString(strings.TrimLeft(body, `\`)) {
+		return line[:len(line)-len(body)] + `\` + body
+	}
+	return line
+}
+
+func (e *markdownEncoder) End() error {
+	first := true
+	for _, name := range e.order {
+		items := e.groups[name]
+		if len(items) == 0 {
+			continue
+		}
+		if !first {
+			e.w.WriteString("\n")
+		}
+		first = false
+		switch {
+		case strings.HasSuffix(name, "#"):
+			// 结尾的#会被当成标题的收尾符号，补一个收尾符号保住它
+			e.w.WriteString("# " + name + " #\n\n")
+		case name != "":
+			e.w.WriteString("# " + name + "\n\n")
+		}
+		for _, item := range items {
+			e.w.WriteString(item)
+		}
+	}
+	return e.w.Flush()
+}
+
+// decodeMarkdown 标题之前的条目放进默认分组，行号就是row
+func decodeMarkdown(r io.Reader) (*Document, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), MaxImportBytes)
+	doc := &Document{}
+	current := ""
+	var last *Record
+	// 描述里的空行，后面还有缩进行时才算进描述
+	blank := 0
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimRight(sc.Text(), " \t\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\uFEFF")
+		}
+
+		if m := markdownItem.FindStringSubmatch(text); m != nil {
+			last = &Record{Group: current, Title: strings.TrimSpace(m[2])}
+			if m[1] != " " {
+				last.Category = group.CategoryDone
+			}
+			doc.Tasks = append(doc.Tasks, last)
+			doc.Rows = append(doc.Rows, line)
+			blank = 0
+			continue
+		}
+		switch {
+		case text == "":
+			blank++
+		case last != nil && (strings.HasPrefix(text, "  ") || strings.HasPrefix(text, "\t")):
+			if last.Description != "" {
+				last.Description += strings.Repeat("\n", blank+1)
+			}
+			last.Description += markdownEscaped.ReplaceAllString(strings.TrimPrefix(strings.TrimPrefix(text, "\t"), "  "), "$1$2")
+			blank = 0
+		default:
+			if m := markdownHeading.FindStringSubmatch(text); m != nil {
+				current = strings.TrimSpace(m[1])
+			}
+			last = nil
+			blank = 0
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, apperror.New("INVALID_IMPORT", "invalid Markdown: "+err.Error())
+	}
+	return doc, nil
+}
@@ -0,0 +1,43 @@
+package transfer
+
+import (
+	"testing"
+
+	"tasker/core/group"
+)
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	groups := []*GroupRecord{{Name: "工作"}, {Name: "C#"}, {Name: "空分组"}}
+	in := []*Record{
+		{Title: "写周报", Group: "工作", Description: "周五之前发出\n\n抄送组长"},
+		{Title: "提交报销", Group: "工作", Category: group.CategoryDone},
+		{Title: "没有分组的任务", Description: "  缩进的第一行\n\t制表符"},
+		{Title: "学习 [x] 语法", Group: "C#", Description: "- [ ] 不是子任务\n  * [x] 也不是\n\\- [ ] 本来就有反斜杠\n# 不是标题"},
+		{Title: "结尾的任务", Group: "工作"},
+	}
+	body := encode(t, "markdown", groups, in...)
+	doc := decode(t, "markdown", body)
+
+	// 同一分组的任务写在一起，没有分组的在最前面
+	want := []*Record{in[2], in[0], in[1], in[4], in[3]}
+	if len(doc.Tasks) != len(want) {
+		t.Fatalf("decoded %d tasks, want %d\n%s", len(doc.Tasks), len(want), body)
+	}
+	for i, w := range want {
+		assertRecord(t, body, doc.Tasks[i], w)
+	}
+}
+
+func TestMarkdownDecodesHandWrittenFile(t *testing.T) {
+	body := "intro paragraph\n\n- [ ] before any heading\n\n## Later ##\n\n* [X] done\n  details\n\n+ [ ] open\n- plain list item\n  not a description\n"
+	doc := decode(t, "markdown", body)
+	if len(doc.Tasks) != 3 {
+		t.Fatalf("decoded %d tasks", len(doc.Tasks))
+	}
+	assertRecord(t, body, doc.Tasks[0], &Record{Title: "before any heading"})
+	assertRecord(t, body, doc.Tasks[1], &Record{Title: "done", Group: "Later", Category: group.CategoryDone, Description: "details"})
+	assertRecord(t, body, doc.Tasks[2], &Record{Title: "open", Group: "Later"})
+	if doc.Rows[0] != 3 || doc.Rows[1] != 7 || doc.Rows[2] != 10 {
+		t.Fatalf("rows = %v", doc.Rows)
+	}
+}
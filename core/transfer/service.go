@@ -0,0 +1,447 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/core/user"
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+)
+
+// 导入的限制
+const (
+	MaxImportBytes = 10 << 20
+	MaxImportRows  = 10000
+
+	maxExternalID = 100
+	maxTitle      = 255
+	maxGroupName  = 50
+	maxPriority   = 20
+)
+
+// 没有写分组的任务放到工作区的"默认"分组，和新建任务一致
+const defaultGroupName = "默认"
+
+// ImportInput 导入的入参，Body最多读MaxImportBytes
+type ImportInput struct {
+	// nil表示个人工作区
+	WorkspaceID *int64
+	Format      string
+	// 只校验，不写入
+	DryRun bool
+	Body   io.Reader
+}
+
+// Report 导入的校验报告；有错误时什么都不写，Applied为false
+type Report struct {
+	DryRun  bool `json:"dry_run"`
+	Applied bool `json:"applied"`
+	Rows    int  `json:"rows"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	// 需要新建的分组
+	GroupsCreated []string   `json:"groups_created"`
+	Errors        []RowError `json:"errors"`
+}
+
+// Repository 导入导出直接读写任务表，权限由service按工作区校验
+type Repository interface {
+	ListGroups(ctx context.Context, workspaceID int64) ([]*group.Group, error)
+	// StreamTasks 按ID顺序分批读取工作区的任务（带分组名和评论），每批调用一次fn
+	StreamTasks(ctx context.Context, workspaceID int64, fn func(batch []*Record) error) error
+	// FindByExternalIDs 工作区里已有的任务，按外部ID索引
+	FindByExternalIDs(ctx context.Context, workspaceID int64, ids []string) (map[string]*task.Task, error)
+	// Apply 在一个事务里新建分组、新建和更新任务，任何一条失败都整体回滚。
+	// 新分组已经由group.Service.NewGroup校验过，Apply只负责插入
+	Apply(ctx context.Context, plan *Plan) error
+}
+
+// Plan 校验通过后要写入的内容
+type Plan struct {
+	// 需要新建的分组，Apply写入后回填ID
+	Groups  []*group.Group
+	Creates []*task.Task
+	Updates []*task.Task
+	// 按导入顺序排到分组最后的任务（新建的和换了分组的），由Apply设置GroupID和Rank
+	Placed []Placement
+}
+
+// Placement 排到Group最后的任务
+type Placement struct {
+	Task  *task.Task
+	Group *group.Group
+}
+
+// Service 任务和分组的导入导出，一次处理一个工作区
+type Service interface {
+	// Export 校验权限后把工作区的分组和任务写到enc，出错时可能已经写出了一部分
+	Export(ctx context.Context, userID int64, workspaceID *int64, enc Encoder) error
+	Import(ctx context.Context, userID int64, in ImportInput) (*Report, error)
+}
+
+// UserLookup 读取用户时区，由user.Service实现
+type UserLookup interface {
+	GetByID(ctx context.Context, id int64) (*user.User, error)
+}
+
+type service struct {
+	repo       Repository
+	workspaces workspace.Service
+	users      UserLookup
+	groups     group.Service
+	tasks      task.Service
+}
+
+// NewService groups校验和组装新分组，tasks在导入写入后发布任务事件
+func NewService(repo Repository, workspaces workspace.Service, users UserLookup, groups group.Service, tasks task.Service) Service {
+	return &service{
+		repo:       repo,
+		workspaces: workspaces,
+		users:      users,
+		groups:     groups,
+		tasks:      tasks,
+	}
+}
+
+// workspace 解析目标工作区并校验角色
+func (s *service) workspace(ctx context.Context, userID int64, workspaceID *int64, min workspace.Role) (int64, error) {
+	if workspaceID == nil {
+		personal, err := s.workspaces.Personal(ctx, userID)
+		if err != nil {
+			return 0, err
+		}
+		return personal.ID, nil
+	}
+	if _, err := s.workspaces.Authorize(ctx, userID, *workspaceID, min); err != nil {
+		return 0, err
+	}
+	return *workspaceID, nil
+}
+
+func (s *service) Export(ctx context.Context, userID int64, workspaceID *int64, enc Encoder) error {
+	wsID, err := s.workspace(ctx, userID, workspaceID, workspace.RoleViewer)
+	if err != nil {
+		return err
+	}
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	loc := u.Location()
+
+	groups, err := s.repo.ListGroups(ctx, wsID)
+	if err != nil {
+		return err
+	}
+	records := make([]*GroupRecord, 0, len(groups))
+	for _, g := range groups {
+		records = append(records, &GroupRecord{Name: g.Name, Workflow: g.Workflow})
+	}
+	if err := enc.Begin(records); err != nil {
+		return err
+	}
+
+	err = s.repo.StreamTasks(ctx, wsID, func(batch []*Record) error {
+		for _, r := range batch {
+			for _, t := range []*time.Time{r.DueDate, r.CompletedAt, r.CreatedAt, r.UpdatedAt} {
+				if t != nil {
+					*t = t.In(loc)
+				}
+			}
+			for i := range r.Comments {
+				r.Comments[i].CreatedAt = r.Comments[i].CreatedAt.In(loc)
+			}
+			if err := enc.Task(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return enc.End()
+}
+
+// importGroup 导入过程中的分组：已有的，或者需要新建的
+type importGroup struct {
+	existing *group.Group
+	name     string
+	// 需要新建时由group.Service组装，Apply写入
+	created *group.Group
+}
+
+func (g *importGroup) flow() *group.Workflow {
+	if g.existing != nil {
+		return group.WorkflowOf(g.existing)
+	}
+	return group.WorkflowOf(g.created)
+}
+
+// target 任务要放进的分组
+func (g *importGroup) target() *group.Group {
+	if g.existing != nil {
+		return g.existing
+	}
+	return g.created
+}
+
+func (s *service) Import(ctx context.Context, userID int64, in ImportInput) (*Report, error) {
+	format, ok := Lookup(in.Format)
+	if !ok {
+		return nil, apperror.New("INVALID_FORMAT", "format must be one of "+strings.Join(Names(), ", "))
+	}
+	wsID, err := s.workspace(ctx, userID, in.WorkspaceID, workspace.RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := format.Decode(io.LimitReader(in.Body, MaxImportBytes))
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Tasks) > MaxImportRows {
+		return nil, apperror.New("INVALID_IMPORT", "an import can contain at most 10000 tasks")
+	}
+
+	report := &Report{DryRun: in.DryRun, Rows: len(doc.Tasks), GroupsCreated: []string{}, Errors: append([]RowError{}, doc.Errors...)}
+	fail := func(row int, field, msg string) {
+		report.Errors = append(report.Errors, RowError{Row: row, Field: field, Message: msg})
+	}
+
+	// 分组按名称对应，不存在的在Apply里和任务一起新建
+	existing, err := s.repo.ListGroups(ctx, wsID)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]*importGroup, len(existing))
+	for _, g := range existing {
+		groups[g.Name] = &importGroup{existing: g, name: g.Name}
+	}
+	workflows := make(map[string]*group.Workflow, len(doc.Groups))
+	for _, g := range doc.Groups {
+		if g != nil && g.Workflow != nil && len(g.Workflow.States) > 0 {
+			workflows[strings.TrimSpace(g.Name)] = g.Workflow
+		}
+	}
+	// 新分组和手动创建的一样经过group.Service校验，只是写入留给Apply的事务
+	groupFor := func(name string) (*importGroup, error) {
+		if g, ok := groups[name]; ok {
+			return g, nil
+		}
+		w := workflows[name]
+		if w != nil && w.Validate() != nil {
+			// 工作流不合法时按默认工作流导入，状态校验会报出不匹配的任务
+			w = nil
+		}
+		created, err := s.groups.NewGroup(ctx, userID, wsID, name, w)
+		if err != nil {
+			return nil, err
+		}
+		g := &importGroup{name: name, created: created}
+		groups[name] = g
+		report.GroupsCreated = append(report.GroupsCreated, name)
+		return g, nil
+	}
+
+	// 先找出要更新的任务
+	externalIDs := make([]string, 0, len(doc.Tasks))
+	seen := make(map[string]int, len(doc.Tasks))
+	for i, r := range doc.Tasks {
+		r.ExternalID = strings.TrimSpace(r.ExternalID)
+		if r.ExternalID == "" {
+			continue
+		}
+		if utf8.RuneCountInString(r.ExternalID) > maxExternalID {
+			fail(doc.Rows[i], "external_id", "must be at most 100 characters")
+			continue
+		}
+		if first, dup := seen[r.ExternalID]; dup {
+			fail(doc.Rows[i], "external_id", "duplicates the external_id of row "+strconv.Itoa(first))
+			continue
+		}
+		seen[r.ExternalID] = doc.Rows[i]
+		externalIDs = append(externalIDs, r.ExternalID)
+	}
+	current, err := s.repo.FindByExternalIDs(ctx, wsID, externalIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	type planned struct {
+		t     *task.Task
+		group *importGroup
+		// 更新前的分组、状态大类和描述，用来补发事件
+		prevGroupID     *int64
+		prevCategory    group.Category
+		prevDescription string
+	}
+	var creates, updates []planned
+	for i, r := range doc.Tasks {
+		row := doc.Rows[i]
+		errs := len(report.Errors)
+
+		title := strings.TrimSpace(r.Title)
+		if title == "" {
+			fail(row, "title", "is required")
+		} else if utf8.RuneCountInString(title) > maxTitle {
+			fail(row, "title", "must be at most 255 characters")
+		}
+		name := strings.TrimSpace(r.Group)
+		if name == "" {
+			name = defaultGroupName
+		}
+		if utf8.RuneCountInString(name) > maxGroupName {
+			fail(row, "group", "must be at most 50 characters")
+			continue
+		}
+		if r.DueDate != nil && r.DueOn != nil {
+			fail(row, "due_date", "set either due_date or due_on, not both")
+		}
+		priority := strings.TrimSpace(r.Priority)
+		if utf8.RuneCountInString(priority) > maxPriority {
+			fail(row, "priority", "must be at most 20 characters")
+		}
+		// 格式里没有标签时Tags是nil，保留已有任务的标签
+		var tags []string
+		if r.Tags != nil {
+			var err error
+			if tags, err = task.NormalizeTags(r.Tags); err != nil {
+				fail(row, "tags", err.Error())
+			}
+		}
+
+		g, err := groupFor(name)
+		if err != nil {
+			return nil, err
+		}
+		wf := g.flow()
+		prev := current[r.ExternalID]
+		var st group.State
+		switch status := strings.TrimSpace(r.Status); {
+		case status != "":
+			var ok bool
+			if st, ok = wf.State(status); !ok {
+				fail(row, "status", "is not a state of group "+name+"'s workflow")
+			}
+		case r.Category != "":
+			if !r.Category.Valid() {
+				fail(row, "status_category", "must be todo, doing or done")
+			} else {
+				st = wf.Map("", r.Category)
+			}
+		case prev != nil:
+			// 没写状态时保留原状态，换了分组按大类对应到新工作流
+			if prev.GroupID != nil && g.existing != nil && *prev.GroupID == g.existing.ID {
+				st = group.State{Key: string(prev.Status), Category: prev.StatusCategory}
+			} else {
+				st = wf.Map(string(prev.Status), prev.StatusCategory)
+			}
+		default:
+			st = wf.Initial()
+		}
+		if len(report.Errors) > errs {
+			continue
+		}
+
+		t := &task.Task{}
+		p := planned{t: t, group: g}
+		if prev != nil {
+			t = prev
+			p = planned{t: t, group: g, prevGroupID: prev.GroupID, prevCategory: prev.StatusCategory, prevDescription: prev.Description}
+		} else {
+			t.UserID = userID
+			t.WorkspaceID = wsID
+			t.ExternalID = r.ExternalID
+			t.CreatedAt = now
+			if r.CreatedAt != nil {
+				t.CreatedAt = *r.CreatedAt
+			}
+			t.AssigneeIDs = []int64{}
+			t.Tags = []string{}
+		}
+		if tags != nil {
+			t.Tags = tags
+		}
+		if priority == "" {
+			priority = "low"
+		}
+		t.Title = title
+		t.Description = r.Description
+		t.Priority = priority
+		t.DueDate = r.DueDate
+		t.DueOn = r.DueOn
+		t.UpdatedAt = now
+
+		wasDone := t.StatusCategory == group.CategoryDone
+		t.Status = task.Status(st.Key)
+		t.StatusCategory = st.Category
+		switch {
+		case st.Category != group.CategoryDone:
+			t.CompletedAt = nil
+		case r.CompletedAt != nil:
+			t.CompletedAt = r.CompletedAt
+		case !wasDone || t.CompletedAt == nil:
+			t.CompletedAt = &now
+		}
+
+		if prev != nil {
+			updates = append(updates, p)
+		} else {
+			creates = append(creates, p)
+		}
+	}
+	report.Created = len(creates)
+	report.Updated = len(updates)
+	if len(report.Errors) > 0 || in.DryRun {
+		if len(report.Errors) > 0 {
+			report.Created, report.Updated = 0, 0
+		}
+		return report, nil
+	}
+
+	// 新分组和任务在同一个事务里写入，导入者是分组的创建者，可以直接带上工作流
+	plan := &Plan{
+		Groups:  make([]*group.Group, 0, len(report.GroupsCreated)),
+		Creates: make([]*task.Task, 0, len(creates)),
+		Updates: make([]*task.Task, 0, len(updates)),
+	}
+	for _, name := range report.GroupsCreated {
+		plan.Groups = append(plan.Groups, groups[name].created)
+	}
+	imported := make([]task.ImportedTask, 0, len(creates)+len(updates))
+	for _, p := range creates {
+		plan.Creates = append(plan.Creates, p.t)
+		plan.Placed = append(plan.Placed, Placement{Task: p.t, Group: p.group.target()})
+		imported = append(imported, task.ImportedTask{Task: p.t, Created: true})
+	}
+	for _, p := range updates {
+		plan.Updates = append(plan.Updates, p.t)
+		// 换了分组的放到新分组最后
+		if p.group.existing == nil || p.t.GroupID == nil || *p.t.GroupID != p.group.existing.ID {
+			plan.Placed = append(plan.Placed, Placement{Task: p.t, Group: p.group.target()})
+		}
+		imported = append(imported, task.ImportedTask{
+			Task:            p.t,
+			PrevGroupID:     p.prevGroupID,
+			PrevCategory:    p.prevCategory,
+			PrevDescription: p.prevDescription,
+		})
+	}
+
+	if err := s.repo.Apply(ctx, plan); err != nil {
+		return nil, err
+	}
+	report.Applied = true
+	// 事件在事务提交后发布，和CreateTask、UpdateTask一样生成动态、通知和提及
+	s.tasks.PublishImported(ctx, userID, imported)
+	return report, nil
+}
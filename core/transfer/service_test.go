@@ -0,0 +1,289 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+)
+
+type fakeRepo struct {
+	Repository
+	groups  []*group.Group
+	current map[string]*task.Task
+	plans   []*Plan
+	err     error
+}
+
+func (r *fakeRepo) ListGroups(ctx context.Context, workspaceID int64) ([]*group.Group, error) {
+	return r.groups, nil
+}
+
+func (r *fakeRepo) FindByExternalIDs(ctx context.Context, workspaceID int64, ids []string) (map[string]*task.Task, error) {
+	out := make(map[string]*task.Task)
+	for _, id := range ids {
+		if t, ok := r.current[id]; ok {
+			out[id] = t
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) Apply(ctx context.Context, plan *Plan) error {
+	r.plans = append(r.plans, plan)
+	return r.err
+}
+
+type fakeWorkspaces struct {
+	workspace.Service
+}
+
+func (fakeWorkspaces) Personal(ctx context.Context, userID int64) (*workspace.Workspace, error) {
+	return &workspace.Workspace{ID: 1, OwnerID: userID, Personal: true}, nil
+}
+
+func (fakeWorkspaces) Authorize(ctx context.Context, userID, workspaceID int64, min workspace.Role) (*workspace.Member, error) {
+	return &workspace.Member{WorkspaceID: workspaceID, UserID: userID, Role: workspace.RoleOwner}, nil
+}
+
+// fakeTasks 记录导入后补发的事件
+type fakeTasks struct {
+	task.Service
+	imported [][]task.ImportedTask
+}
+
+func (f *fakeTasks) PublishImported(ctx context.Context, actorID int64, tasks []task.ImportedTask) {
+	f.imported = append(f.imported, tasks)
+}
+
+// newService 新分组走真实的group.Service校验，NewGroup不读写分组repo
+func newService(repo *fakeRepo) (Service, *fakeTasks) {
+	tasks := &fakeTasks{}
+	return NewService(repo, fakeWorkspaces{}, nil, group.NewService(nil, fakeWorkspaces{}), tasks), tasks
+}
+
+// importCSV 生成n行CSV，偶数行放到已有的Inbox分组，奇数行放到新分组Later
+func importCSV(n int, extra ...string) string {
+	var b strings.Builder
+	b.WriteString("external_id,group,title\n")
+	for i := 0; i < n; i++ {
+		name := "Inbox"
+		if i%2 == 1 {
+			name = "Later"
+		}
+		fmt.Fprintf(&b, ",%s,task %d\n", name, i)
+	}
+	for _, line := range extra {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func TestImportManyRows(t *testing.T) {
+	inboxID := int64(7)
+	repo := &fakeRepo{
+		groups: []*group.Group{{ID: inboxID, WorkspaceID: 1, Name: "Inbox"}},
+		current: map[string]*task.Task{
+			"keep": {ID: 100, WorkspaceID: 1, GroupID: &inboxID, Status: "todo", StatusCategory: group.CategoryTodo, Rank: "00000001V"},
+			"move": {ID: 101, WorkspaceID: 1, GroupID: &inboxID, Status: "todo", StatusCategory: group.CategoryTodo, Rank: "00000002V"},
+		},
+	}
+	svc, tasks := newService(repo)
+
+	const rows = 2500
+	body := importCSV(rows, "keep,Inbox,kept", "move,Later,moved")
+	report, err := svc.Import(context.Background(), 1, ImportInput{Format: "csv", Body: strings.NewReader(body)})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(report.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", report.Errors[0])
+	}
+	if !report.Applied || report.Created != rows || report.Updated != 2 {
+		t.Fatalf("report = applied %v, created %d, updated %d", report.Applied, report.Created, report.Updated)
+	}
+	if len(repo.plans) != 1 {
+		t.Fatalf("Apply called %d times, want 1", len(repo.plans))
+	}
+
+	plan := repo.plans[0]
+	// 新分组交给Apply在同一个事务里创建
+	if len(plan.Groups) != 1 || plan.Groups[0].Name != "Later" || plan.Groups[0].ID != 0 || plan.Groups[0].WorkspaceID != 1 ||
+		plan.Groups[0].UserID != 1 || plan.Groups[0].CreatedAt.IsZero() {
+		t.Fatalf("plan.Groups = %+v", plan.Groups)
+	}
+	later := plan.Groups[0]
+	if len(plan.Creates) != rows || len(plan.Updates) != 2 {
+		t.Fatalf("plan has %d creates, %d updates", len(plan.Creates), len(plan.Updates))
+	}
+
+	// 新建的任务按导入顺序排队，换了分组的排在后面，留在原分组的不动
+	if len(plan.Placed) != rows+1 {
+		t.Fatalf("placed %d tasks, want %d", len(plan.Placed), rows+1)
+	}
+	for i, p := range plan.Placed[:rows] {
+		want := repo.groups[0]
+		if i%2 == 1 {
+			want = later
+		}
+		if p.Task != plan.Creates[i] || p.Group != want {
+			t.Fatalf("placement %d = %q in %q", i, p.Task.Title, p.Group.Name)
+		}
+		// rank由Apply在事务里分配
+		if p.Task.Rank != "" || p.Task.GroupID != nil {
+			t.Fatalf("placement %d already has rank %q", i, p.Task.Rank)
+		}
+	}
+	if last := plan.Placed[rows]; last.Task.ID != 101 || last.Group != later {
+		t.Fatalf("moved task placement = %d in %q", last.Task.ID, last.Group.Name)
+	}
+	if keep := repo.current["keep"]; keep.Rank != "00000001V" || *keep.GroupID != inboxID {
+		t.Fatalf("kept task changed to rank %q", keep.Rank)
+	}
+
+	// Apply之后补发事件：新建的任务，以及带着原分组的更新
+	if len(tasks.imported) != 1 || len(tasks.imported[0]) != rows+2 {
+		t.Fatalf("PublishImported calls = %d", len(tasks.imported))
+	}
+	imported := tasks.imported[0]
+	if !imported[0].Created || imported[0].Task != plan.Creates[0] {
+		t.Fatalf("first imported = %+v", imported[0])
+	}
+	moved := imported[rows+1]
+	if moved.Created || moved.Task.ID != 101 || moved.PrevGroupID == nil || *moved.PrevGroupID != inboxID ||
+		moved.PrevCategory != group.CategoryTodo {
+		t.Fatalf("moved imported = %+v", moved)
+	}
+}
+
+func TestImportNothingWrittenOnErrorOrDryRun(t *testing.T) {
+	repo := &fakeRepo{}
+	svc, tasks := newService(repo)
+
+	report, err := svc.Import(context.Background(), 1, ImportInput{Format: "csv", DryRun: true, Body: strings.NewReader(importCSV(10))})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.Applied || report.Created != 10 || len(report.GroupsCreated) != 2 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+
+	report, err = svc.Import(context.Background(), 1, ImportInput{Format: "csv", Body: strings.NewReader(importCSV(10, ",Inbox,"))})
+	if err != nil {
+		t.Fatalf("invalid import: %v", err)
+	}
+	if report.Applied || len(report.Errors) != 1 || report.Errors[0].Field != "title" {
+		t.Fatalf("invalid import report = %+v", report)
+	}
+	if len(repo.plans) != 0 {
+		t.Fatalf("Apply called %d times, want 0", len(repo.plans))
+	}
+
+	// Apply失败时直接返回错误，分组由事务回滚，不需要补偿
+	repo.err = apperror.New("IMPORT_CONFLICT", "conflict")
+	if _, err := svc.Import(context.Background(), 1, ImportInput{Format: "csv", Body: strings.NewReader(importCSV(10))}); err != repo.err {
+		t.Fatalf("Import error = %v, want %v", err, repo.err)
+	}
+	if len(tasks.imported) != 0 {
+		t.Fatalf("PublishImported called %d times, want 0", len(tasks.imported))
+	}
+}
+
+func TestImportGroupWorkflows(t *testing.T) {
+	repo := &fakeRepo{}
+	svc, _ := newService(repo)
+
+	body := `{"groups": [
+		{"name": "Board", "workflow": {"states": [
+			{"key": "backlog", "name": "Backlog", "category": "todo"},
+			{"key": "shipped", "name": "Shipped", "category": "done"}
+		]}},
+		{"name": "Broken", "workflow": {"states": [{"key": "Bad Key", "name": "x", "category": "todo"}]}}
+	], "tasks": [
+		{"title": "a", "group": "Board"},
+		{"title": "b", "group": "Broken"}
+	]}`
+	report, err := svc.Import(context.Background(), 1, ImportInput{Format: "json", Body: strings.NewReader(body)})
+	if err != nil || len(report.Errors) > 0 {
+		t.Fatalf("Import: %v %+v", err, report)
+	}
+	plan := repo.plans[0]
+	if len(plan.Groups) != 2 {
+		t.Fatalf("plan.Groups = %+v", plan.Groups)
+	}
+	// 合法的工作流经group.Service带到新分组，不合法的按默认工作流创建
+	if w := plan.Groups[0].Workflow; w == nil || w.Initial().Key != "backlog" || plan.Creates[0].Status != "backlog" {
+		t.Fatalf("Board workflow = %+v, status %q", w, plan.Creates[0].Status)
+	}
+	if plan.Groups[1].Workflow != nil || plan.Creates[1].Status != group.StatePending {
+		t.Fatalf("Broken workflow = %+v, status %q", plan.Groups[1].Workflow, plan.Creates[1].Status)
+	}
+}
+
+func TestImportTags(t *testing.T) {
+	inboxID := int64(7)
+	repo := &fakeRepo{
+		groups: []*group.Group{{ID: inboxID, WorkspaceID: 1, Name: "Inbox"}},
+		current: map[string]*task.Task{
+			"keep":  {ID: 100, WorkspaceID: 1, GroupID: &inboxID, Status: "todo", StatusCategory: group.CategoryTodo, Tags: []string{"old"}},
+			"clear": {ID: 101, WorkspaceID: 1, GroupID: &inboxID, Status: "todo", StatusCategory: group.CategoryTodo, Tags: []string{"old"}},
+		},
+	}
+	svc, _ := newService(repo)
+
+	body := `{"tasks": [
+		{"title": "new", "group": "Inbox", "tags": ["Work", " home ", "work"]},
+		{"external_id": "keep", "title": "kept", "group": "Inbox"},
+		{"external_id": "clear", "title": "cleared", "group": "Inbox", "tags": []}
+	]}`
+	report, err := svc.Import(context.Background(), 1, ImportInput{Format: "json", Body: strings.NewReader(body)})
+	if err != nil || len(report.Errors) > 0 {
+		t.Fatalf("Import: %v %+v", err, report)
+	}
+	plan := repo.plans[0]
+	if got := plan.Creates[0].Tags; strings.Join(got, ",") != "home,Work" {
+		t.Fatalf("created tags = %q", got)
+	}
+	// 没写tags的记录保留原标签，空数组清空
+	if got := repo.current["keep"].Tags; strings.Join(got, ",") != "old" {
+		t.Fatalf("kept tags = %q", got)
+	}
+	if got := repo.current["clear"].Tags; len(got) != 0 {
+		t.Fatalf("cleared tags = %q", got)
+	}
+
+	// CSV的tags列用空格分隔，空单元格清空，没有这一列时保留
+	repo.current["keep"].Tags = []string{"old"}
+	repo.current["clear"].Tags = []string{"old"}
+	body = "external_id,title,tags\nclear,cleared,\nnew,created,a b\n"
+	if _, err := svc.Import(context.Background(), 1, ImportInput{Format: "csv", Body: strings.NewReader(body)}); err != nil {
+		t.Fatalf("Import csv: %v", err)
+	}
+	if got := repo.current["clear"].Tags; got == nil || len(got) != 0 {
+		t.Fatalf("csv cleared tags = %q", got)
+	}
+	if got := repo.plans[1].Creates[0].Tags; strings.Join(got, ",") != "a,b" {
+		t.Fatalf("csv created tags = %q", got)
+	}
+	body = "external_id,title\nkeep,kept\n"
+	if _, err := svc.Import(context.Background(), 1, ImportInput{Format: "csv", Body: strings.NewReader(body)}); err != nil {
+		t.Fatalf("Import csv: %v", err)
+	}
+	if got := repo.current["keep"].Tags; strings.Join(got, ",") != "old" {
+		t.Fatalf("csv kept tags = %q", got)
+	}
+
+	body = `{"tasks": [{"title": "bad", "tags": ["two words"]}]}`
+	report, err = svc.Import(context.Background(), 1, ImportInput{Format: "json", Body: strings.NewReader(body)})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Applied || len(report.Errors) != 1 || report.Errors[0].Field != "tags" {
+		t.Fatalf("invalid tags report = %+v", report)
+	}
+}
@@ -0,0 +1,304 @@
+package transfer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+	"tasker/pkg/date"
+)
+
+/*
+todo.txt格式（https://github.com/todotxt/todo.txt）：每行一个任务
+
+	x 2026-10-19 2026-10-01 写周报 @office +工作 due:2026-10-20 pri:A
+
+开头的x表示完成，后面依次是完成日期和创建日期；未完成的任务以(A)这样的优先级开头。
+最后一个+project是分组，分组名里的空格写成_；每个@context是一个标签；due:是截止日期。
+描述、评论和工作流不在里面
+
+todo.txt没有转义规则，导出时用%XX转义会被误读的字符，导入时还原：
+标题开头的x、(A)和日期，标题里的+word、@word、due:和pri:，分组名里的_和其他空白，
+以及后面跟着两位十六进制、会被当成转义的%
+*/
+
+func init() {
+	register(&Format{
+		Name:        "todotxt",
+		ContentType: "text/plain; charset=utf-8",
+		Extension:   "txt",
+		NewEncoder:  newTodoTxtEncoder,
+		Decode:      decodeTodoTxt,
+	})
+}
+
+type todoTxtEncoder struct {
+	w *bufio.Writer
+}
+
+func newTodoTxtEncoder(w io.Writer) Encoder {
+	return &todoTxtEncoder{w: bufio.NewWriter(w)}
+}
+
+func (e *todoTxtEncoder) Begin(groups []*GroupRecord) error {
+	return nil
+}
+
+func (e *todoTxtEncoder) Task(r *Record) error {
+	var parts []string
+	done := r.Category == group.CategoryDone
+	pri := todoTxtPriority(r.Priority)
+	if done {
+		parts = append(parts, "x")
+		if r.CompletedAt != nil {
+			parts = append(parts, date.Of(*r.CompletedAt).String())
+		}
+	} else if pri != "" {
+		parts = append(parts, "("+pri+")")
+	}
+	// 规范要求有完成日期时必须有创建日期；完成的任务没有完成日期时，
+	// 创建日期会被读成完成日期，只好不写
+	if r.CreatedAt != nil && (!done || r.CompletedAt != nil) {
+		parts = append(parts, date.Of(*r.CreatedAt).String())
+	}
+	for i, word := range strings.Fields(r.Title) {
+		parts = append(parts, escapeTodoTxtWord(word, i == 0))
+	}
+	if r.Group != "" {
+		parts = append(parts, "+"+escapeTodoTxtGroup(r.Group))
+	}
+	for _, tag := range r.Tags {
+		// 标签不含空白，只需要转义%
+		parts = append(parts, "@"+escapePercent(tag, func(int, rune) bool { return false }))
+	}
+	switch {
+	case r.DueOn != nil:
+		parts = append(parts, "due:"+r.DueOn.String())
+	case r.DueDate != nil:
+		// 只有日期，导入时成为due_on
+		parts = append(parts, "due:"+date.Of(*r.DueDate).String())
+	}
+	// 完成的任务不能以优先级开头，按惯例写成pri:
+	if done && pri != "" {
+		parts = append(parts, "pri:"+pri)
+	}
+	_, err := e.w.WriteString(strings.Join(parts, " ") + "\n")
+	return err
+}
+
+func (e *todoTxtEncoder) End() error {
+	return e.w.Flush()
+}
+
+// escapeTodoTxtWord 转义标题里会被当成元数据的词，first表示标题的第一个词
+func escapeTodoTxtWord(word string, first bool) string {
+	at := -1
+	switch {
+	case first && (word == "x" || isTodoTxtPriority(word) || isDate(word)):
+		at = 0
+	case len(word) > 1 && (word[0] == '+' || word[0] == '@'):
+		at = 0
+	case strings.HasPrefix(word, "due:") || strings.HasPrefix(word, "pri:"):
+		at = 3
+	}
+	return escapePercent(word, func(i int, r rune) bool { return i == at })
+}
+
+// escapeTodoTxtGroup 分组名里的_和空格以外的空白转义后，空格写成_
+func escapeTodoTxtGroup(name string) string {
+	name = escapePercent(strings.TrimSpace(name), func(i int, r rune) bool {
+		return r == '_' || (r != ' ' && unicode.IsSpace(r))
+	})
+	return strings.ReplaceAll(name, " ", "_")
+}
+
+// escapePercent 把need为true的字符按UTF-8字节写成%XX；
+// 后面跟着两位十六进制的%也要转义，否则导入时会被还原成别的字符
+func escapePercent(s string, need func(i int, r rune) bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		if need(i, r) || (r == '%' && isHexPair(s[i+1:])) {
+			var buf [utf8.UTFMax]byte
+			for _, c := range buf[:utf8.EncodeRune(buf[:], r)] {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescapePercent 还原%XX，不构成转义的%原样保留
+func unescapePercent(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && isHexPair(s[i+1:]) {
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isHexPair(s string) bool {
+	return len(s) >= 2 && strings.IndexByte(hexDigits, upper(s[0])) >= 0 && strings.IndexByte(hexDigits, upper(s[1])) >= 0
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+func unhex(c byte) byte {
+	return byte(strings.IndexByte(hexDigits, upper(c)))
+}
+
+// isTodoTxtPriority (A)这样的优先级
+func isTodoTxtPriority(word string) bool {
+	v, ok := strings.CutPrefix(word, "(")
+	if !ok || !strings.HasSuffix(v, ")") {
+		return false
+	}
+	_, ok = priorityFromTodoTxt(strings.TrimSuffix(v, ")"))
+	return ok
+}
+
+func isDate(word string) bool {
+	_, err := date.Parse(word)
+	return err == nil
+}
+
+// todoTxtPriority high为A，medium为B；low是默认值，不写
+func todoTxtPriority(p string) string {
+	switch strings.ToLower(p) {
+	case "high", "urgent":
+		return "A"
+	case "medium", "normal":
+		return "B"
+	}
+	return ""
+}
+
+// priorityFromTodoTxt A为high，B为medium，其余字母为low
+func priorityFromTodoTxt(v string) (string, bool) {
+	if len(v) != 1 || v[0] < 'A' || v[0] > 'Z' {
+		return "", false
+	}
+	switch v {
+	case "A":
+		return "high", true
+	case "B":
+		return "medium", true
+	}
+	return "low", true
+}
+
+// decodeTodoTxt 空行跳过，行号就是row；不带时间的日期按UTC的0点解释
+func decodeTodoTxt(r io.Reader) (*Document, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), MaxImportBytes)
+	doc := &Document{}
+	line := 0
+	for sc.Scan() {
+		line++
+		text := sc.Text()
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\uFEFF")
+		}
+		tokens := strings.Fields(text)
+		if len(tokens) == 0 {
+			continue
+		}
+		row := line
+		fail := func(field, msg string) {
+			doc.Errors = append(doc.Errors, RowError{Row: row, Field: field, Message: msg})
+		}
+		dateAt := func(i int) (time.Time, bool) {
+			if i >= len(tokens) {
+				return time.Time{}, false
+			}
+			d, err := date.Parse(tokens[i])
+			if err != nil {
+				return time.Time{}, false
+			}
+			return d.StartIn(time.UTC), true
+		}
+
+		// todo.txt总是能表示标签，没有@context的任务导入后没有标签
+		rec := &Record{Tags: []string{}}
+		i := 0
+		if tokens[0] == "x" {
+			rec.Category = group.CategoryDone
+			i++
+			if t, ok := dateAt(i); ok {
+				rec.CompletedAt = &t
+				i++
+			}
+		} else if isTodoTxtPriority(tokens[0]) {
+			rec.Priority, _ = priorityFromTodoTxt(tokens[0][1:2])
+			i++
+		}
+		if t, ok := dateAt(i); ok {
+			rec.CreatedAt = &t
+			i++
+		}
+
+		var title []string
+		project := -1
+		for _, tok := range tokens[i:] {
+			switch {
+			case len(tok) > 1 && tok[0] == '+':
+				project = len(title)
+				title = append(title, tok)
+			case len(tok) > 1 && tok[0] == '@':
+				rec.Tags = append(rec.Tags, unescapePercent(tok[1:]))
+			case strings.HasPrefix(tok, "due:"):
+				d, err := date.Parse(strings.TrimPrefix(tok, "due:"))
+				if err != nil {
+					fail("due", "must be a date in YYYY-MM-DD format")
+					continue
+				}
+				rec.DueOn = &d
+			case strings.HasPrefix(tok, "pri:") && rec.Category == group.CategoryDone:
+				if p, ok := priorityFromTodoTxt(strings.TrimPrefix(tok, "pri:")); ok {
+					rec.Priority = p
+				} else {
+					title = append(title, tok)
+				}
+			default:
+				title = append(title, tok)
+			}
+		}
+		// 多个+project时最后一个作为分组，其余留在标题里
+		if project >= 0 {
+			rec.Group = unescapePercent(strings.ReplaceAll(title[project][1:], "_", " "))
+			title = append(title[:project], title[project+1:]...)
+		}
+		for j := range title {
+			title[j] = unescapePercent(title[j])
+		}
+		rec.Title = strings.Join(title, " ")
+		doc.Tasks = append(doc.Tasks, rec)
+		doc.Rows = append(doc.Rows, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, apperror.New("INVALID_IMPORT", "invalid todo.txt: "+err.Error())
+	}
+	return doc, nil
+}
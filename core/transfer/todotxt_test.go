@@ -0,0 +1,185 @@
+package transfer
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"tasker/core/group"
+	"tasker/pkg/date"
+)
+
+// encode 用格式的Encoder写出records
+func encode(t *testing.T, format string, groups []*GroupRecord, records ...*Record) string {
+	t.Helper()
+	f, ok := Lookup(format)
+	if !ok {
+		t.Fatalf("format %s not registered", format)
+	}
+	var buf bytes.Buffer
+	enc := f.NewEncoder(&buf)
+	if err := enc.Begin(groups); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	for _, r := range records {
+		if err := enc.Task(r); err != nil {
+			t.Fatalf("Task: %v", err)
+		}
+	}
+	if err := enc.End(); err != nil {
+		t.Fatalf("End: %v", err)
+	}
+	return buf.String()
+}
+
+func decode(t *testing.T, format, body string) *Document {
+	t.Helper()
+	f, _ := Lookup(format)
+	doc, err := f.Decode(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(doc.Errors) > 0 {
+		t.Fatalf("Decode errors: %+v\n%s", doc.Errors, body)
+	}
+	return doc
+}
+
+func utcDay(s string) *time.Time {
+	d, err := date.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	t := d.StartIn(time.UTC)
+	return &t
+}
+
+func dateOf(s string) *date.Date {
+	d, err := date.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return &d
+}
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *Record
+		// 导入后的结果，nil表示和in一样
+		want *Record
+	}{
+		{
+			name: "open task with every field",
+			in:   &Record{Title: "写周报", Group: "工作", Priority: "high", DueOn: dateOf("2026-10-20"), CreatedAt: utcDay("2026-10-01")},
+		},
+		{
+			name: "done task keeps completion date and priority",
+			in:   &Record{Title: "提交报销", Category: group.CategoryDone, Priority: "medium", CompletedAt: utcDay("2026-10-19"), CreatedAt: utcDay("2026-10-01")},
+		},
+		{
+			name: "low priority is the default",
+			in:   &Record{Title: "整理桌面", Priority: "low"},
+			want: &Record{Title: "整理桌面"},
+		},
+		{
+			name: "due_date becomes due_on",
+			in:   &Record{Title: "交房租", DueDate: utcDay("2026-11-01")},
+			want: &Record{Title: "交房租", DueOn: dateOf("2026-11-01")},
+		},
+		{
+			name: "done task without completion date drops the creation date",
+			in:   &Record{Title: "旧任务", Category: group.CategoryDone, CreatedAt: utcDay("2026-01-01")},
+			want: &Record{Title: "旧任务", Category: group.CategoryDone},
+		},
+		{
+			name: "group names keep underscores, spaces and percent signs",
+			in:   &Record{Title: "deploy", Group: "ops_team night shift 100%25　east"},
+		},
+		{
+			name: "ungrouped title with +project",
+			in:   &Record{Title: "compare +1 votes"},
+		},
+		{
+			name: "title words that look like metadata",
+			in:   &Record{Title: "x marks the spot due:soon pri:A +project"},
+		},
+		{
+			name: "title starting with a priority",
+			in:   &Record{Title: "(A) is not a priority", Group: "g"},
+		},
+		{
+			name: "title starting with a date",
+			in:   &Record{Title: "2026-12-25 party", Priority: "high"},
+		},
+		{
+			name: "done task with metadata-like title",
+			in:   &Record{Title: "x (B) 2026-01-01 due:later", Category: group.CategoryDone, Priority: "high", CompletedAt: utcDay("2026-10-19"), CreatedAt: utcDay("2026-10-01")},
+		},
+		{
+			name: "percent escapes in titles are kept literally",
+			in:   &Record{Title: "discount %2B50% off"},
+		},
+		{
+			name: "tags become contexts",
+			in:   &Record{Title: "call mom", Group: "home", Tags: []string{"phone", "家里", "50%25"}},
+		},
+		{
+			name: "title words that look like contexts",
+			in:   &Record{Title: "reply to @alice about @ and email", Tags: []string{"work"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := encode(t, "todotxt", nil, tt.in)
+			doc := decode(t, "todotxt", body)
+			if len(doc.Tasks) != 1 {
+				t.Fatalf("decoded %d tasks from %q", len(doc.Tasks), body)
+			}
+			want := tt.want
+			if want == nil {
+				want = tt.in
+			}
+			assertRecord(t, body, doc.Tasks[0], want)
+		})
+	}
+}
+
+func TestTodoTxtDecodesHandWrittenLines(t *testing.T) {
+	doc := decode(t, "todotxt", "x 2026-10-19 2026-10-01 写周报 @office +工作 due:2026-10-20 pri:A\n\n(B) call +old_project +new_project\n")
+	if len(doc.Tasks) != 2 || doc.Rows[0] != 1 || doc.Rows[1] != 3 {
+		t.Fatalf("tasks %d, rows %v", len(doc.Tasks), doc.Rows)
+	}
+	assertRecord(t, "line 1", doc.Tasks[0], &Record{
+		Title: "写周报", Group: "工作", Tags: []string{"office"}, Category: group.CategoryDone, Priority: "high",
+		CompletedAt: utcDay("2026-10-19"), CreatedAt: utcDay("2026-10-01"), DueOn: dateOf("2026-10-20"),
+	})
+	// 多个+project时最后一个是分组
+	assertRecord(t, "line 3", doc.Tasks[1], &Record{Title: "call +old_project", Group: "new project", Priority: "medium"})
+}
+
+func assertRecord(t *testing.T, body string, got, want *Record) {
+	t.Helper()
+	if got.Title != want.Title || got.Group != want.Group || got.Description != want.Description ||
+		got.Category != want.Category || got.Priority != want.Priority || !slices.Equal(got.Tags, want.Tags) {
+		t.Fatalf("got %+v, want %+v\n%s", got, want, body)
+	}
+	times := []struct {
+		field     string
+		got, want *time.Time
+	}{
+		{"due_date", got.DueDate, want.DueDate},
+		{"completed_at", got.CompletedAt, want.CompletedAt},
+		{"created_at", got.CreatedAt, want.CreatedAt},
+	}
+	for _, tt := range times {
+		if (tt.got == nil) != (tt.want == nil) || (tt.got != nil && !tt.got.Equal(*tt.want)) {
+			t.Fatalf("%s = %v, want %v\n%s", tt.field, tt.got, tt.want, body)
+		}
+	}
+	if (got.DueOn == nil) != (want.DueOn == nil) || (got.DueOn != nil && *got.DueOn != *want.DueOn) {
+		t.Fatalf("due_on = %v, want %v\n%s", got.DueOn, want.DueOn, body)
+	}
+}
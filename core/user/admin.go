@@ -0,0 +1,128 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"tasker/pkg/apperror"
+	"tasker/pkg/mailer"
+)
+
+// Role 系统角色，和工作区角色无关
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// ForcedReset 管理员强制重置密码的结果，Token只返回这一次
+type ForcedReset struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// 用户有邮箱时同时发了重置邮件
+	Emailed bool `json:"emailed"`
+}
+
+// SetRole 修改系统角色，不能撤掉最后一个管理员；计数和修改在repo的同一个事务里，并发撤销也不会撤光
+func (s *service) SetRole(ctx context.Context, userID int64, role Role) (*User, error) {
+	if !role.Valid() {
+		return nil, apperror.New("INVALID_ROLE", "role must be user or admin")
+	}
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Role == role {
+		return s.GetByID(ctx, userID)
+	}
+	if err := s.repo.UpdateRole(ctx, userID, role, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, userID)
+}
+
+// SetDisabled 停用或恢复账号；停用时所有会话立即下线，访问令牌在停用期间失效
+func (s *service) SetDisabled(ctx context.Context, userID int64, disabled bool) (*User, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	if disabled == (u.DisabledAt != nil) {
+		return s.GetByID(ctx, userID)
+	}
+	var at *time.Time
+	if disabled {
+		at = &now
+	}
+	if err := s.repo.UpdateDisabled(ctx, userID, at, now); err != nil {
+		return nil, err
+	}
+	if disabled {
+		if err := s.repo.RevokeSessions(ctx, userID, "", now); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetByID(ctx, userID)
+}
+
+// ForcePasswordReset 让用户下次登录前必须重置密码：会话全部下线，访问令牌全部撤销，
+// 生成一个重置token返回给管理员转交，用户有邮箱时同时发邮件。
+// 强制重置通常意味着账号可能泄漏，令牌不会在重置密码后恢复，需要用户重新创建
+func (s *service) ForcePasswordReset(ctx context.Context, userID int64) (*ForcedReset, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := randomHex(32)
+	if err != nil {
+		return nil, apperror.New("INTERNAL_ERROR", "failed to create reset token")
+	}
+	now := s.clock.Now()
+	pr := &PasswordReset{
+		UserID:    u.ID,
+		Hash:      hashResetToken(raw),
+		ExpiresAt: now.Add(passwordResetTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.ForcePasswordReset(ctx, pr); err != nil {
+		return nil, err
+	}
+
+	result := &ForcedReset{Token: raw, ExpiresAt: pr.ExpiresAt}
+	if u.Email != "" {
+		link := s.resetURL + "?token=" + url.QueryEscape(raw)
+		err := s.mailer.Send(ctx, mailer.Message{
+			To:      u.Email,
+			Subject: "Your go-tasker password must be reset",
+			Body: fmt.Sprintf("Hi %s,\n\nAn administrator requires you to choose a new password. Open the link below within %d minutes:\n\n%s\n",
+				u.Username, int(passwordResetTTL/time.Minute), link),
+		})
+		result.Emailed = err == nil
+	}
+	return result, nil
+}
+
+// CheckActive 账号被停用时返回 ACCOUNT_DISABLED，每次请求认证时调用
+func (s *service) CheckActive(ctx context.Context, userID int64) error {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u.DisabledAt != nil {
+		return apperror.New("ACCOUNT_DISABLED", "this account has been disabled")
+	}
+	return nil
+}
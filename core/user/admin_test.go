@@ -0,0 +1,91 @@
+package user_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+	"tasker/pkg/mailer"
+)
+
+// ForcePasswordReset 一次写入重置token、标记和撤销，和真实repo的事务一样要么全做要么全不做
+func (r *fakeRepo) ForcePasswordReset(ctx context.Context, pr *user.PasswordReset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.forceResetErr != nil {
+		return r.forceResetErr
+	}
+	u, ok := r.users[pr.UserID]
+	if !ok {
+		return apperror.New("USER_NOT_FOUND", "user not found")
+	}
+	u.PasswordResetRequired = true
+	r.forcedResets = append(r.forcedResets, *pr)
+	return nil
+}
+
+type fakeMailer struct {
+	sent []mailer.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	repo := newFakeRepo()
+	clk := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	mail := &fakeMailer{}
+	svc := user.NewService(repo,
+		user.WithClock(clk),
+		user.WithBcryptCost(bcrypt.MinCost),
+		user.WithMailer(mail, "https://tasker.example/reset"),
+	)
+	ctx := context.Background()
+	u, err := svc.Register(ctx, user.RegisterInput{Username: "alice", Password: testPassword})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	repo.users[u.ID].Email = "alice@example.com"
+
+	// 写入失败时什么都不返回，也不发邮件
+	repo.forceResetErr = apperror.New("DB_ERROR", "failed to force password reset")
+	_, err = svc.ForcePasswordReset(ctx, u.ID)
+	assertCode(t, err, "DB_ERROR")
+	if len(mail.sent) != 0 || repo.users[u.ID].PasswordResetRequired {
+		t.Fatalf("failed reset sent %d mails, flag %v", len(mail.sent), repo.users[u.ID].PasswordResetRequired)
+	}
+
+	repo.forceResetErr = nil
+	result, err := svc.ForcePasswordReset(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("ForcePasswordReset: %v", err)
+	}
+	if len(repo.forcedResets) != 1 {
+		t.Fatalf("repo called %d times", len(repo.forcedResets))
+	}
+	pr := repo.forcedResets[0]
+	sum := sha256.Sum256([]byte(result.Token))
+	if pr.UserID != u.ID || pr.Hash == result.Token || !pr.CreatedAt.Equal(clk.Now()) || !pr.ExpiresAt.Equal(result.ExpiresAt) ||
+		!result.ExpiresAt.Equal(clk.Now().Add(time.Hour)) {
+		t.Fatalf("reset = %+v, result = %+v", pr, result)
+	}
+	if pr.Hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("stored hash %q is not the token's sha256", pr.Hash)
+	}
+	if !result.Emailed || len(mail.sent) != 1 || !strings.Contains(mail.sent[0].Body, result.Token) {
+		t.Fatalf("emailed %v, sent %+v", result.Emailed, mail.sent)
+	}
+
+	_, err = svc.ForcePasswordReset(ctx, 404)
+	assertCode(t, err, "USER_NOT_FOUND")
+}
@@ -0,0 +1,217 @@
+package user
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+)
+
+// AttemptState 某个key（用户名 / IP / 两步验证）的失败记录
+type AttemptState struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// LimiterStore 失败次数的存储，多副本部署时必须用共享存储（Postgres）
+type LimiterStore interface {
+	// Get 没有记录时返回 nil, nil
+	Get(ctx context.Context, key string) (*AttemptState, error)
+	// RecordFailure 原子地把失败次数+1；上次失败早于resetBefore时从1重新计数
+	RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*AttemptState, error)
+	SetLockedUntil(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// LimiterPolicy 超过免费次数后，每多失败一次锁定时间翻倍，直到MaxLockout
+type LimiterPolicy struct {
+	// 按用户名计数的免费失败次数
+	UsernameThreshold int
+	// 按IP计数的免费失败次数，IP可能是公司出口，给得宽松一些
+	IPThreshold int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// 超过这么久没有失败，计数清零
+	ResetAfter time.Duration
+}
+
+func DefaultLimiterPolicy() LimiterPolicy {
+	return LimiterPolicy{
+		UsernameThreshold: 5,
+		IPThreshold:       20,
+		BaseLockout:       30 * time.Second,
+		MaxLockout:        15 * time.Minute,
+		ResetAfter:        15 * time.Minute,
+	}
+}
+
+// LockoutError 触发锁定时返回，handler据此设置429和Retry-After
+type LockoutError struct {
+	*apperror.AppError
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Unwrap() error {
+	return e.AppError
+}
+
+func newLockoutError(retryAfter time.Duration) *LockoutError {
+	return &LockoutError{
+		AppError:   apperror.New("TOO_MANY_ATTEMPTS", "too many failed attempts, try again later"),
+		RetryAfter: retryAfter,
+	}
+}
+
+// WithLimiter 开启登录失败限制；不配置时不做限制
+func WithLimiter(store LimiterStore, policy LimiterPolicy) Option {
+	return func(s *service) {
+		s.limiter = store
+		s.limiterPolicy = policy
+	}
+}
+
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func mfaKey(userID int64) string {
+	return "mfa:" + strconv.FormatInt(userID, 10)
+}
+
+// Limiter 按key记录失败次数并锁定，登录之外需要防暴力猜测的地方（公开链接的密码等）也用它。
+// nil表示不限制
+type Limiter struct {
+	store  LimiterStore
+	policy LimiterPolicy
+	clock  clock.Clock
+}
+
+func NewLimiter(store LimiterStore, policy LimiterPolicy, c clock.Clock) *Limiter {
+	return &Limiter{store: store, policy: policy, clock: c}
+}
+
+// Policy 阈值和锁定时长，调用方按key的性质选UsernameThreshold或IPThreshold
+func (l *Limiter) Policy() LimiterPolicy {
+	if l == nil {
+		return LimiterPolicy{}
+	}
+	return l.policy
+}
+
+// limit 当前配置下的Limiter，没有配置store时为nil
+func (s *service) limit() *Limiter {
+	if s.limiter == nil {
+		return nil
+	}
+	return NewLimiter(s.limiter, s.limiterPolicy, s.clock)
+}
+
+func (s *service) checkLocked(ctx context.Context, keys ...string) error {
+	return s.limit().Check(ctx, keys...)
+}
+
+func (s *service) recordFailure(ctx context.Context, key string, threshold int) error {
+	return s.limit().RecordFailure(ctx, key, threshold)
+}
+
+func (s *service) resetFailures(ctx context.Context, key string) {
+	s.limit().Reset(ctx, key)
+}
+
+// Check 任意一个key处于锁定中就拒绝，并返回最长的剩余时间
+func (l *Limiter) Check(ctx context.Context, keys ...string) error {
+	if l == nil {
+		return nil
+	}
+	now := l.clock.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		st, err := l.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if st != nil && st.LockedUntil != nil && st.LockedUntil.After(now) {
+			if d := st.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return newLockoutError(wait)
+	}
+	return nil
+}
+
+// RecordFailure 记录一次失败，超过阈值时按指数退避锁定
+func (l *Limiter) RecordFailure(ctx context.Context, key string, threshold int) error {
+	if l == nil {
+		return nil
+	}
+	p := l.policy
+	now := l.clock.Now()
+	st, err := l.store.RecordFailure(ctx, key, now, now.Add(-p.ResetAfter))
+	if err != nil {
+		return err
+	}
+	if st.Failures < threshold {
+		return nil
+	}
+
+	lockout := p.BaseLockout
+	for i := threshold; i < st.Failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	return l.store.SetLockedUntil(ctx, key, now.Add(lockout))
+}
+
+func (l *Limiter) Reset(ctx context.Context, key string) {
+	if l == nil {
+		return
+	}
+	// 清零失败只影响体验，不影响本次结果
+	_ = l.store.Reset(ctx, key)
+}
+
+// Unlock 管理员手动解除锁定，username和ip可以只填一个
+func (s *service) Unlock(ctx context.Context, in UnlockInput) error {
+	if in.Username == "" && in.IP == "" {
+		return apperror.New("INVALID_UNLOCK", "username or ip is required")
+	}
+	if s.limiter == nil {
+		return nil
+	}
+	if in.Username != "" {
+		if err := s.limiter.Reset(ctx, usernameKey(in.Username)); err != nil {
+			return err
+		}
+		// 两步验证的失败计数按用户ID记，一并清掉
+		if u, err := s.repo.GetByUsername(ctx, in.Username); err == nil {
+			if err := s.limiter.Reset(ctx, mfaKey(u.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	if in.IP != "" {
+		if err := s.limiter.Reset(ctx, ipKey(in.IP)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 管理员解锁时用的入参
+type UnlockInput struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
@@ -0,0 +1,189 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"tasker/pkg/apperror"
+	"tasker/pkg/totp"
+)
+
+const (
+	totpIssuer = "go-tasker"
+	// 允许前后各一个时间步（±30秒）的时钟偏差
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+	// 去掉了容易混淆的 0/O、1/I/L
+	recoveryAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// TOTPEnrollment enroll返回给客户端的内容，用户扫码或手动输入secret
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+func (s *service) EnrollTOTP(ctx context.Context, userID int64) (*TOTPEnrollment, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		return nil, apperror.New("MFA_ALREADY_ENABLED", "two-factor authentication is already enabled")
+	}
+
+	// 重复enroll会覆盖之前未确认的secret
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, apperror.New("INTERNAL_ERROR", "failed to generate secret")
+	}
+	if err := s.repo.UpdateTOTP(ctx, userID, secret, false); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, u.Username, secret),
+	}, nil
+}
+
+func (s *service) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		return nil, apperror.New("MFA_ALREADY_ENABLED", "two-factor authentication is already enabled")
+	}
+	if u.TOTPSecret == "" {
+		return nil, apperror.New("MFA_NOT_ENROLLED", "call enroll before confirming")
+	}
+
+	if err := s.checkTOTP(ctx, u, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, apperror.New("INTERNAL_ERROR", "failed to generate recovery codes")
+	}
+	if err := s.repo.SetTOTP(ctx, userID, u.TOTPSecret, true, hashes); err != nil {
+		return nil, err
+	}
+
+	// 恢复码明文只返回这一次
+	return codes, nil
+}
+
+func (s *service) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !u.TOTPEnabled {
+		return apperror.New("MFA_NOT_ENABLED", "two-factor authentication is not enabled")
+	}
+
+	// 关闭必须提供当前验证码，恢复码不行
+	if err := s.checkTOTP(ctx, u, code); err != nil {
+		return err
+	}
+
+	return s.repo.SetTOTP(ctx, userID, "", false, nil)
+}
+
+func (s *service) VerifyMFA(ctx context.Context, userID int64, code string) (*User, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !u.TOTPEnabled {
+		return nil, apperror.New("MFA_NOT_ENABLED", "two-factor authentication is not enabled")
+	}
+
+	// 6位验证码只有一百万种，同样要限制失败次数
+	key := mfaKey(userID)
+	if err := s.checkLocked(ctx, key); err != nil {
+		return nil, err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		if err := s.checkTOTP(ctx, u, code); err != nil {
+			return nil, s.mfaFailed(ctx, key, err)
+		}
+	} else {
+		used, err := s.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code), s.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		if !used {
+			return nil, s.mfaFailed(ctx, key, apperror.New("INVALID_MFA_CODE", "invalid verification code"))
+		}
+	}
+	s.resetFailures(ctx, key)
+
+	u.Password = ""
+	u.TOTPSecret = ""
+	return u, nil
+}
+
+// 验证码错误时计一次失败，其他错误原样返回
+func (s *service) mfaFailed(ctx context.Context, key string, err error) error {
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "INVALID_MFA_CODE" {
+		return err
+	}
+	if rerr := s.recordFailure(ctx, key, s.limiterPolicy.UsernameThreshold); rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+// 校验TOTP验证码并记录时间步，同一个验证码不能用两次
+func (s *service) checkTOTP(ctx context.Context, u *User, code string) error {
+	step, ok := totp.Validate(u.TOTPSecret, code, s.clock.Now(), totpSkew)
+	if !ok || step <= u.TOTPLastStep {
+		return apperror.New("INVALID_MFA_CODE", "invalid verification code")
+	}
+	if err := s.repo.UpdateTOTPLastStep(ctx, u.ID, step); err != nil {
+		return err
+	}
+	u.TOTPLastStep = step
+	return nil
+}
+
+// 生成恢复码，格式 XXXXX-XXXXX
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	max := big.NewInt(int64(len(recoveryAlphabet)))
+	for i := 0; i < recoveryCodeCount; i++ {
+		var b strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, nil, err
+			}
+			b.WriteByte(recoveryAlphabet[n.Int64()])
+		}
+		code := b.String()
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// 恢复码本身是随机串，sha256即可；输入时忽略大小写和连字符
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,345 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"tasker/core/user"
+	"tasker/infra/memory"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+	"tasker/pkg/totp"
+)
+
+// fakeRepo 内存版的user.Repository，只实现两步验证流程用到的方法
+type fakeRepo struct {
+	user.Repository
+
+	mu       sync.Mutex
+	users    map[int64]*user.User
+	recovery map[int64]map[string]bool // hash -> 已使用
+	// 不为nil时SetTOTP返回这个错误，模拟事务失败
+	setTOTPErr error
+	// ForcePasswordReset写入的记录；forceResetErr不为nil时模拟事务失败
+	forcedResets  []user.PasswordReset
+	forceResetErr error
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{users: map[int64]*user.User{}, recovery: map[int64]map[string]bool{}}
+}
+
+func (r *fakeRepo) Create(ctx context.Context, u *user.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u.ID = int64(len(r.users) + 1)
+	cp := *u
+	r.users[u.ID] = &cp
+	return nil
+}
+
+func (r *fakeRepo) GetByUsername(ctx context.Context, username string) (*user.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, apperror.New("USER_NOT_FOUND", "user not found")
+}
+
+func (r *fakeRepo) GetByID(ctx context.Context, id int64) (*user.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, apperror.New("USER_NOT_FOUND", "user not found")
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *fakeRepo) UpdateTOTP(ctx context.Context, userID int64, secret string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[userID].TOTPSecret = secret
+	r.users[userID].TOTPEnabled = enabled
+	return nil
+}
+
+func (r *fakeRepo) UpdateTOTPLastStep(ctx context.Context, userID int64, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := r.users[userID]
+	if u.TOTPLastStep >= step {
+		return apperror.New("INVALID_MFA_CODE", "invalid verification code")
+	}
+	u.TOTPLastStep = step
+	return nil
+}
+
+func (r *fakeRepo) SetTOTP(ctx context.Context, userID int64, secret string, enabled bool, recoveryHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.setTOTPErr != nil {
+		return r.setTOTPErr
+	}
+	r.users[userID].TOTPSecret = secret
+	r.users[userID].TOTPEnabled = enabled
+	codes := map[string]bool{}
+	for _, h := range recoveryHashes {
+		codes[h] = false
+	}
+	r.recovery[userID] = codes
+	return nil
+}
+
+func (r *fakeRepo) UseRecoveryCode(ctx context.Context, userID int64, hash string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used, ok := r.recovery[userID][hash]
+	if !ok || used {
+		return false, nil
+	}
+	r.recovery[userID][hash] = true
+	return true, nil
+}
+
+func (r *fakeRepo) UpdatePassword(ctx context.Context, userID int64, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[userID].Password = hash
+	return nil
+}
+
+func (r *fakeRepo) recoveryCount(userID int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.recovery[userID])
+}
+
+const testPassword = "correct horse battery"
+
+type mfaFixture struct {
+	repo  *fakeRepo
+	clock *clock.Fake
+	svc   user.Service
+	user  *user.User
+}
+
+func newMFAFixture(t *testing.T) *mfaFixture {
+	t.Helper()
+	f := &mfaFixture{
+		repo:  newFakeRepo(),
+		clock: clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)),
+	}
+	f.svc = user.NewService(f.repo,
+		user.WithClock(f.clock),
+		user.WithBcryptCost(bcrypt.MinCost),
+		user.WithLimiter(memory.NewLimiterStore(), user.DefaultLimiterPolicy()),
+	)
+	u, err := f.svc.Register(context.Background(), user.RegisterInput{Username: "alice", Password: testPassword})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	f.user = u
+	return f
+}
+
+// code 假时钟当前时间步的验证码
+func (f *mfaFixture) code(t *testing.T, secret string) string {
+	t.Helper()
+	c, err := totp.CodeAt(secret, totp.Step(f.clock.Now()))
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	return c
+}
+
+// enable 完成enroll和confirm，返回secret和恢复码
+func (f *mfaFixture) enable(t *testing.T) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := f.svc.EnrollTOTP(ctx, f.user.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	codes, err := f.svc.ConfirmTOTP(ctx, f.user.ID, f.code(t, enrollment.Secret))
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	return enrollment.Secret, codes
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+	appErr, ok := apperror.IsAppError(err)
+	if !ok || appErr.Code != code {
+		t.Fatalf("err = %v, want %s", err, code)
+	}
+}
+
+func TestEnrollAndConfirmTOTP(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+
+	_, err := f.svc.ConfirmTOTP(ctx, f.user.ID, "123456")
+	assertCode(t, err, "MFA_NOT_ENROLLED")
+
+	enrollment, err := f.svc.EnrollTOTP(ctx, f.user.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || !strings.Contains(enrollment.URI, "secret="+enrollment.Secret) {
+		t.Fatalf("uri = %q", enrollment.URI)
+	}
+
+	// 两个时间步之前的验证码超出允许的偏差
+	stale, _ := totp.CodeAt(enrollment.Secret, totp.Step(f.clock.Now())-2)
+	_, err = f.svc.ConfirmTOTP(ctx, f.user.ID, stale)
+	assertCode(t, err, "INVALID_MFA_CODE")
+	if u, _ := f.repo.GetByID(ctx, f.user.ID); u.TOTPEnabled {
+		t.Fatal("enabled after a wrong code")
+	}
+
+	codes, err := f.svc.ConfirmTOTP(ctx, f.user.ID, f.code(t, enrollment.Secret))
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if len(codes) != 10 || f.repo.recoveryCount(f.user.ID) != 10 {
+		t.Fatalf("got %d codes, stored %d", len(codes), f.repo.recoveryCount(f.user.ID))
+	}
+	if u, _ := f.repo.GetByID(ctx, f.user.ID); !u.TOTPEnabled {
+		t.Fatal("not enabled after confirm")
+	}
+
+	_, err = f.svc.EnrollTOTP(ctx, f.user.ID)
+	assertCode(t, err, "MFA_ALREADY_ENABLED")
+}
+
+func TestConfirmTOTPFailureKeepsDisabled(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+	enrollment, err := f.svc.EnrollTOTP(ctx, f.user.ID)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+
+	f.repo.setTOTPErr = apperror.New("DB_ERROR", "failed to save two-factor settings")
+	_, err = f.svc.ConfirmTOTP(ctx, f.user.ID, f.code(t, enrollment.Secret))
+	assertCode(t, err, "DB_ERROR")
+	u, _ := f.repo.GetByID(ctx, f.user.ID)
+	if u.TOTPEnabled || f.repo.recoveryCount(f.user.ID) != 0 {
+		t.Fatalf("enabled=%v codes=%d after a failed confirm", u.TOTPEnabled, f.repo.recoveryCount(f.user.ID))
+	}
+
+	// 下一个时间步重试成功
+	f.repo.setTOTPErr = nil
+	f.clock.Advance(totp.Period)
+	if _, err := f.svc.ConfirmTOTP(ctx, f.user.ID, f.code(t, enrollment.Secret)); err != nil {
+		t.Fatalf("retry confirm: %v", err)
+	}
+}
+
+func TestLoginWithTOTP(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+	secret, _ := f.enable(t)
+
+	u, err := f.svc.Login(ctx, user.LoginInput{Username: "alice", Password: testPassword, IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if !u.TOTPEnabled || u.TOTPSecret != "" {
+		t.Fatalf("login returned enabled=%v secret=%q", u.TOTPEnabled, u.TOTPSecret)
+	}
+
+	// confirm用掉的验证码不能再用来登录
+	_, err = f.svc.VerifyMFA(ctx, u.ID, f.code(t, secret))
+	assertCode(t, err, "INVALID_MFA_CODE")
+
+	f.clock.Advance(totp.Period)
+	code := f.code(t, secret)
+	if _, err := f.svc.VerifyMFA(ctx, u.ID, code); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	_, err = f.svc.VerifyMFA(ctx, u.ID, code)
+	assertCode(t, err, "INVALID_MFA_CODE")
+
+	// 前一个时间步的验证码在偏差范围内，但早于上次成功的时间步
+	f.clock.Advance(totp.Period)
+	prev, _ := totp.CodeAt(secret, totp.Step(f.clock.Now())-1)
+	_, err = f.svc.VerifyMFA(ctx, u.ID, prev)
+	assertCode(t, err, "INVALID_MFA_CODE")
+}
+
+func TestVerifyMFALockout(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+	secret, _ := f.enable(t)
+	policy := user.DefaultLimiterPolicy()
+
+	for i := 0; i < policy.UsernameThreshold; i++ {
+		_, err := f.svc.VerifyMFA(ctx, f.user.ID, "000000")
+		assertCode(t, err, "INVALID_MFA_CODE")
+	}
+	// 锁定期间正确的验证码也被拒绝
+	f.clock.Advance(10 * time.Second)
+	_, err := f.svc.VerifyMFA(ctx, f.user.ID, f.code(t, secret))
+	var lockout *user.LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("err = %v, want lockout", err)
+	}
+
+	f.clock.Advance(policy.BaseLockout)
+	if _, err := f.svc.VerifyMFA(ctx, f.user.ID, f.code(t, secret)); err != nil {
+		t.Fatalf("verify after lockout: %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+	_, codes := f.enable(t)
+
+	// 忽略大小写和连字符
+	relaxed := strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))
+	if _, err := f.svc.VerifyMFA(ctx, f.user.ID, relaxed); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	_, err := f.svc.VerifyMFA(ctx, f.user.ID, codes[0])
+	assertCode(t, err, "INVALID_MFA_CODE")
+
+	if _, err := f.svc.VerifyMFA(ctx, f.user.ID, codes[1]); err != nil {
+		t.Fatalf("second recovery code: %v", err)
+	}
+
+	// 关闭两步验证只接受验证码，恢复码一并作废
+	err = f.svc.DisableTOTP(ctx, f.user.ID, codes[2])
+	assertCode(t, err, "INVALID_MFA_CODE")
+}
+
+func TestDisableTOTP(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+	secret, codes := f.enable(t)
+
+	f.clock.Advance(totp.Period)
+	if err := f.svc.DisableTOTP(ctx, f.user.ID, f.code(t, secret)); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	u, _ := f.repo.GetByID(ctx, f.user.ID)
+	if u.TOTPEnabled || u.TOTPSecret != "" || f.repo.recoveryCount(f.user.ID) != 0 {
+		t.Fatalf("enabled=%v secret=%q codes=%d after disable", u.TOTPEnabled, u.TOTPSecret, f.repo.recoveryCount(f.user.ID))
+	}
+	_, err := f.svc.VerifyMFA(ctx, f.user.ID, codes[0])
+	assertCode(t, err, "MFA_NOT_ENABLED")
+}
@@ -0,0 +1,153 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"tasker/pkg/apperror"
+	"tasker/pkg/mailer"
+)
+
+// 重置token有效期
+const passwordResetTTL = time.Hour
+
+// PasswordReset 找回密码的一次性token，只存sha256
+type PasswordReset struct {
+	ID        int64
+	UserID    int64
+	Hash      string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// 登录态下修改密码的入参
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// 申请找回密码的入参
+type ForgotPasswordInput struct {
+	Username string `json:"username"`
+}
+
+// 用邮件里的token重置密码的入参
+type ResetPasswordInput struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (s *service) ChangePassword(ctx context.Context, userID int64, sessionID string, in ChangePasswordInput) error {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// 防止拿到会话的人暴力猜当前密码，和登录共用用户名的失败计数
+	key := usernameKey(u.Username)
+	if err := s.checkLocked(ctx, key); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(in.CurrentPassword)); err != nil {
+		if err := s.recordFailure(ctx, key, s.limiterPolicy.UsernameThreshold); err != nil {
+			return err
+		}
+		return apperror.New("INVALID_CREDENTIALS", "current password is incorrect")
+	}
+	if in.NewPassword == in.CurrentPassword {
+		return apperror.New("INVALID_PASSWORD", "new password must differ from the current one")
+	}
+
+	if err := s.setPassword(ctx, u, in.NewPassword); err != nil {
+		return err
+	}
+
+	// 保留当前会话，其他设备全部下线
+	return s.repo.RevokeSessions(ctx, userID, sessionID, s.clock.Now())
+}
+
+func (s *service) RequestPasswordReset(ctx context.Context, in ForgotPasswordInput) error {
+	// 用户不存在或没有邮箱时同样返回成功，避免用户名枚举
+	u, err := s.repo.GetByUsername(ctx, in.Username)
+	if err != nil || u.Email == "" {
+		return nil
+	}
+
+	raw, err := randomHex(32)
+	if err != nil {
+		return apperror.New("INTERNAL_ERROR", "failed to create reset token")
+	}
+
+	now := s.clock.Now()
+	pr := &PasswordReset{
+		UserID:    u.ID,
+		Hash:      hashResetToken(raw),
+		ExpiresAt: now.Add(passwordResetTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.CreatePasswordReset(ctx, pr); err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(raw)
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your go-tasker password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below within %d minutes to choose a new password:\n\n%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			u.Username, int(passwordResetTTL/time.Minute), link),
+	})
+}
+
+func (s *service) ResetPassword(ctx context.Context, in ResetPasswordInput) error {
+	if in.Token == "" {
+		return apperror.New("INVALID_RESET_TOKEN", "invalid or expired reset token")
+	}
+
+	now := s.clock.Now()
+	hash := hashResetToken(in.Token)
+	pr, err := s.repo.GetPasswordReset(ctx, hash, now)
+	if err != nil {
+		return err
+	}
+	u, err := s.repo.GetByID(ctx, pr.UserID)
+	if err != nil {
+		return err
+	}
+	// 先校验新密码再作废token，密码太弱时用户还能用同一个链接重试
+	if err := s.policy.Validate(in.NewPassword, u.Username); err != nil {
+		return err
+	}
+	if _, err := s.repo.ConsumePasswordReset(ctx, hash, now); err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, u, in.NewPassword); err != nil {
+		return err
+	}
+
+	// 找回密码意味着旧密码可能已泄漏：所有会话下线，并解除登录锁定
+	s.resetFailures(ctx, usernameKey(u.Username))
+	return s.repo.RevokeSessions(ctx, u.ID, "", now)
+}
+
+// setPassword 校验强度后用当前cost哈希并保存
+func (s *service) setPassword(ctx context.Context, u *User, password string) error {
+	if err := s.policy.Validate(password, u.Username); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return apperror.New("INTERNAL_ERROR", "failed to hash password")
+	}
+	return s.repo.UpdatePassword(ctx, u.ID, string(hash))
+}
+
+func hashResetToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package user
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"tasker/pkg/apperror"
+)
+
+// bcrypt只使用前72个字节，超出的部分会被静默忽略
+const bcryptMaxBytes = 72
+
+// PasswordPolicy 密码强度规则
+type PasswordPolicy struct {
+	MinLength int
+	// 至少包含几类字符（大写/小写/数字/符号）
+	MinClasses    int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// 已泄漏密码列表，统一存小写
+	Breached map[string]struct{}
+}
+
+// 内置的常见弱密码，生产环境用 LoadBreachedList 加载完整列表
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "password", "password1",
+	"password123", "qwerty", "qwerty123", "qwertyuiop", "abc123", "abcd1234",
+	"111111", "000000", "iloveyou", "admin123", "welcome1", "letmein1",
+	"1q2w3e4r", "1qaz2wsx", "zaq12wsx", "passw0rd", "p@ssw0rd", "a1b2c3d4",
+	"woaini1314", "5201314", "88888888", "66666666", "aa123456", "asdf1234",
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	breached := make(map[string]struct{}, len(commonPasswords))
+	for _, p := range commonPasswords {
+		breached[p] = struct{}{}
+	}
+	return PasswordPolicy{
+		MinLength:  8,
+		MinClasses: 2,
+		Breached:   breached,
+	}
+}
+
+// LoadBreachedList 从文件追加泄漏密码，每行一个，#开头为注释
+func (p *PasswordPolicy) LoadBreachedList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if p.Breached == nil {
+		p.Breached = map[string]struct{}{}
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.Breached[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate 校验新密码，不通过时返回 INVALID_PASSWORD 和具体原因
+func (p PasswordPolicy) Validate(password, username string) error {
+	if len([]rune(password)) < p.MinLength {
+		return invalidPassword("password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > bcryptMaxBytes {
+		return invalidPassword("password must be at most %d bytes", bcryptMaxBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		return invalidPassword("password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		return invalidPassword("password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		return invalidPassword("password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		return invalidPassword("password must contain a symbol")
+	}
+	classes := 0
+	for _, ok := range []bool{upper, lower, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < p.MinClasses {
+		return invalidPassword("password must mix at least %d of: uppercase, lowercase, digits, symbols", p.MinClasses)
+	}
+
+	lowered := strings.ToLower(password)
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		return invalidPassword("password must not contain the username")
+	}
+	if _, ok := p.Breached[lowered]; ok {
+		return invalidPassword("password is too common or has appeared in a data breach")
+	}
+	return nil
+}
+
+func invalidPassword(format string, args ...any) error {
+	return apperror.New("INVALID_PASSWORD", fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,219 @@
+package user
+
+import (
+	"context"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+)
+
+// 新用户的默认偏好，和原来写死在DSN里的时区保持一致
+const (
+	DefaultTimezone  = "Asia/Shanghai"
+	DefaultLocale    = "zh-CN"
+	DefaultWeekStart = time.Monday
+)
+
+var (
+	// 用户名：字母（含中文）、数字、下划线、点、横线，便于在描述里@提及
+	usernamePattern = regexp.MustCompile(`^[\p{L}\p{N}_.\-]{1,50}$`)
+	// 语言标签，例如 zh-CN、en、en-US
+	localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+)
+
+// 修改资料的入参，字段为nil表示不修改
+type UpdateProfileInput struct {
+	DisplayName *string `json:"display_name"`
+	Email       *string `json:"email"`
+	AvatarURL   *string `json:"avatar_url"`
+	Timezone    *string `json:"timezone"`
+	Locale      *string `json:"locale"`
+	// 0=周日 1=周一 ... 6=周六
+	WeekStart *int `json:"week_start"`
+	// 传0表示清除默认分组
+	DefaultGroupID *int64 `json:"default_group_id"`
+}
+
+// 修改用户名的入参
+type ChangeUsernameInput struct {
+	Username string `json:"username"`
+}
+
+// 注销账号的入参，必须再次输入密码
+type DeleteAccountInput struct {
+	Password string `json:"password"`
+}
+
+// WithGroups 用于校验默认分组归属
+func WithGroups(groups group.Service) Option {
+	return func(s *service) {
+		s.groups = groups
+	}
+}
+
+// Location 用户所在时区，"今天"、"逾期"和只有日期的截止日都按它计算；
+// 老数据没有时区或时区名失效时退回默认时区
+func (u *User) Location() *time.Location {
+	if u.Timezone != "" {
+		if loc, err := time.LoadLocation(u.Timezone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func validateUsername(username string) error {
+	if username == "" {
+		return apperror.New("INVALID_USERNAME", "username is required")
+	}
+	if !usernamePattern.MatchString(username) {
+		return apperror.New("INVALID_USERNAME", "username must be 1-50 letters, digits, '_', '.' or '-'")
+	}
+	return nil
+}
+
+func (s *service) UpdateProfile(ctx context.Context, userID int64, in UpdateProfileInput) (*User, error) {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.DisplayName != nil {
+		name := strings.TrimSpace(*in.DisplayName)
+		if utf8.RuneCountInString(name) > 50 {
+			return nil, apperror.New("INVALID_DISPLAY_NAME", "display name must be at most 50 characters")
+		}
+		u.DisplayName = name
+	}
+	if in.Email != nil {
+		email := strings.TrimSpace(*in.Email)
+		if email != "" {
+			if _, err := mail.ParseAddress(email); err != nil {
+				return nil, apperror.New("INVALID_EMAIL", "invalid email address")
+			}
+		}
+		u.Email = email
+	}
+	if in.AvatarURL != nil {
+		avatar := strings.TrimSpace(*in.AvatarURL)
+		if avatar != "" {
+			parsed, err := url.Parse(avatar)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(avatar) > 512 {
+				return nil, apperror.New("INVALID_AVATAR_URL", "avatar_url must be an http(s) URL of at most 512 characters")
+			}
+		}
+		u.AvatarURL = avatar
+	}
+	if in.Timezone != nil {
+		if _, err := time.LoadLocation(*in.Timezone); err != nil || *in.Timezone == "" || *in.Timezone == "Local" {
+			return nil, apperror.New("INVALID_TIMEZONE", "timezone must be an IANA name such as Asia/Shanghai")
+		}
+		u.Timezone = *in.Timezone
+	}
+	if in.Locale != nil {
+		if !localePattern.MatchString(*in.Locale) {
+			return nil, apperror.New("INVALID_LOCALE", "locale must be a language tag such as zh-CN")
+		}
+		u.Locale = *in.Locale
+	}
+	if in.WeekStart != nil {
+		if *in.WeekStart < 0 || *in.WeekStart > 6 {
+			return nil, apperror.New("INVALID_WEEK_START", "week_start must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		u.WeekStart = time.Weekday(*in.WeekStart)
+	}
+	if in.DefaultGroupID != nil {
+		if *in.DefaultGroupID == 0 {
+			u.DefaultGroupID = nil
+		} else {
+			if s.groups == nil {
+				return nil, apperror.New("INTERNAL_ERROR", "group service not configured")
+			}
+			// 分组必须属于当前用户
+			if _, err := s.groups.GetGroup(ctx, userID, *in.DefaultGroupID); err != nil {
+				return nil, err
+			}
+			id := *in.DefaultGroupID
+			u.DefaultGroupID = &id
+		}
+	}
+
+	u.UpdatedAt = s.clock.Now()
+	if err := s.repo.UpdateProfile(ctx, u); err != nil {
+		return nil, err
+	}
+
+	u.Password = ""
+	u.TOTPSecret = ""
+	return u, nil
+}
+
+func (s *service) ChangeUsername(ctx context.Context, userID int64, in ChangeUsernameInput) (*User, error) {
+	username := strings.TrimSpace(in.Username)
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Username == username {
+		u.Password = ""
+		u.TOTPSecret = ""
+		return u, nil
+	}
+
+	// 先查一遍给出友好提示，并发改名由唯一索引兜底
+	existing, err := s.repo.GetByUsername(ctx, username)
+	if err == nil && existing != nil && existing.ID != userID {
+		return nil, apperror.New("USERNAME_EXISTS", "username already exists")
+	}
+
+	u.Username = username
+	u.UpdatedAt = s.clock.Now()
+	if err := s.repo.UpdateUsername(ctx, userID, username, u.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	u.Password = ""
+	u.TOTPSecret = ""
+	return u, nil
+}
+
+func (s *service) DeleteAccount(ctx context.Context, userID int64, in DeleteAccountInput) error {
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	key := usernameKey(u.Username)
+	if err := s.checkLocked(ctx, key); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(in.Password)); err != nil {
+		if err := s.recordFailure(ctx, key, s.limiterPolicy.UsernameThreshold); err != nil {
+			return err
+		}
+		return apperror.New("INVALID_CREDENTIALS", "password is incorrect")
+	}
+
+	// 任务、分组、会话、令牌等在同一个事务里删除
+	if err := s.repo.DeleteAccount(ctx, userID); err != nil {
+		return err
+	}
+	s.resetFailures(ctx, key)
+	s.resetFailures(ctx, mfaKey(userID))
+	return nil
+}
@@ -2,29 +2,57 @@ package user
 
 import (
 	"context"
-	"time"
-	"tasker/pkg/apperror"
 	"golang.org/x/crypto/bcrypt"
+	"net/mail"
+	"tasker/core/group"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+	"tasker/pkg/mailer"
+	"time"
 )
 
 type User struct {
-	ID int64 `json:"id"`
+	ID       int64  `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	// 可选，用于接收找回密码邮件
+	Email string `json:"email"`
+
+	// 个人资料与偏好
+	DisplayName    string       `json:"display_name"`
+	AvatarURL      string       `json:"avatar_url"`
+	Timezone       string       `json:"timezone"`
+	Locale         string       `json:"locale"`
+	WeekStart      time.Weekday `json:"week_start"`
+	DefaultGroupID *int64       `json:"default_group_id"`
+
+	// 系统角色和账号状态，由管理员维护
+	Role       Role       `json:"role"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	// 为true时必须先通过重置token改密码才能登录
+	PasswordResetRequired bool `json:"password_reset_required"`
+
+	// TOTP两步验证：secret在enroll时写入，confirm后才enabled
+	TOTPSecret   string    `json:"-"`
+	TOTPEnabled  bool      `json:"mfa_enabled"`
+	TOTPLastStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // 注册时用的输入
 type RegisterInput struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email"`
 }
 
 // 登录时用的输入
 type LoginInput struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// 由handler填入，用于按IP限制失败次数
+	IP string `json:"-"`
 }
 
 // Service定义用户相关业务行为
@@ -32,6 +60,36 @@ type Service interface {
 	Register(ctx context.Context, in RegisterInput) (*User, error)
 	Login(ctx context.Context, in LoginInput) (*User, error)
 	GetByID(ctx context.Context, id int64) (*User, error)
+
+	// TOTP两步验证
+	EnrollTOTP(ctx context.Context, userID int64) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int64, code string) error
+	// VerifyMFA 完成登录的第二步，code可以是TOTP验证码或恢复码
+	VerifyMFA(ctx context.Context, userID int64, code string) (*User, error)
+
+	// Unlock 管理员解除登录锁定
+	Unlock(ctx context.Context, in UnlockInput) error
+
+	// 会话：登录成功后开启，JWT里带会话ID，改密码时可以撤销其他会话
+	StartSession(ctx context.Context, userID int64, meta SessionMeta) (*Session, error)
+	CheckSession(ctx context.Context, userID int64, sessionID string) error
+
+	// 密码修改与找回
+	ChangePassword(ctx context.Context, userID int64, sessionID string, in ChangePasswordInput) error
+	RequestPasswordReset(ctx context.Context, in ForgotPasswordInput) error
+	ResetPassword(ctx context.Context, in ResetPasswordInput) error
+
+	// 个人资料与账号
+	UpdateProfile(ctx context.Context, userID int64, in UpdateProfileInput) (*User, error)
+	ChangeUsername(ctx context.Context, userID int64, in ChangeUsernameInput) (*User, error)
+	DeleteAccount(ctx context.Context, userID int64, in DeleteAccountInput) error
+
+	// 管理员对账号的操作，调用方负责校验权限和记录审计
+	SetRole(ctx context.Context, userID int64, role Role) (*User, error)
+	SetDisabled(ctx context.Context, userID int64, disabled bool) (*User, error)
+	ForcePasswordReset(ctx context.Context, userID int64) (*ForcedReset, error)
+	CheckActive(ctx context.Context, userID int64) error
 }
 
 // Repository 抽象用户数据存取（后面用Postgres实现）
@@ -39,22 +97,119 @@ type Repository interface {
 	Create(ctx context.Context, u *User) error
 	GetByUsername(ctx context.Context, username string) (*User, error)
 	GetByID(ctx context.Context, id int64) (*User, error)
+
+	UpdateTOTP(ctx context.Context, userID int64, secret string, enabled bool) error
+	UpdateTOTPLastStep(ctx context.Context, userID int64, step int64) error
+	// 在一个事务里更新TOTP状态并覆盖用户的全部恢复码（只存哈希）
+	SetTOTP(ctx context.Context, userID int64, secret string, enabled bool, recoveryHashes []string) error
+	// 把未使用的恢复码标记为已用，没有匹配时返回false
+	UseRecoveryCode(ctx context.Context, userID int64, hash string, at time.Time) (bool, error)
+
+	UpdatePassword(ctx context.Context, userID int64, hash string) error
+
+	CreateSession(ctx context.Context, sess *Session) error
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// 撤销用户的全部会话，exceptID不为空时保留这一个
+	RevokeSessions(ctx context.Context, userID int64, exceptID string, at time.Time) error
+
+	CreatePasswordReset(ctx context.Context, pr *PasswordReset) error
+	// 读取未过期、未使用的重置token，不修改状态
+	GetPasswordReset(ctx context.Context, hash string, at time.Time) (*PasswordReset, error)
+	// 原子地把未过期、未使用的重置token标记为已用，返回对应记录
+	ConsumePasswordReset(ctx context.Context, hash string, at time.Time) (*PasswordReset, error)
+
+	UpdateProfile(ctx context.Context, u *User) error
+	UpdateUsername(ctx context.Context, userID int64, username string, at time.Time) error
+	// 在一个事务里删除用户、用户拥有的工作区（含分组和任务）、会话、令牌等数据；
+	// 在别人工作区里创建的分组、任务、评论和动态保留，创建者和作者置空；
+	// 拥有的共享工作区里还有其他成员时返回 OWNS_SHARED_WORKSPACE
+	DeleteAccount(ctx context.Context, userID int64) error
+
+	// UpdateRole 撤掉管理员时在同一个事务里检查，只剩这一个管理员时返回 LAST_ADMIN
+	UpdateRole(ctx context.Context, userID int64, role Role, at time.Time) error
+	// disabledAt为nil表示恢复
+	UpdateDisabled(ctx context.Context, userID int64, disabledAt *time.Time, at time.Time) error
+	// 在一个事务里写入重置token、标记必须重置密码，并撤销用户的全部会话和访问令牌
+	ForcePasswordReset(ctx context.Context, pr *PasswordReset) error
 }
 
 type service struct {
-	repo Repository
+	repo  Repository
+	clock clock.Clock
+
+	// 登录失败限制，nil表示不限制
+	limiter       LimiterStore
+	limiterPolicy LimiterPolicy
+
+	policy     PasswordPolicy
+	bcryptCost int
+
+	// 找回密码：邮件发送器和前端重置页面地址（后面拼 ?token=）
+	mailer   mailer.Mailer
+	resetURL string
+
+	// 校验默认分组归属
+	groups group.Service
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+// Option 可选依赖，NewService默认使用系统时钟
+type Option func(*service)
+
+// WithClock 注入时钟，测试时用clock.Fake
+func WithClock(c clock.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
+}
+
+// WithPasswordPolicy 替换默认的密码强度规则
+func WithPasswordPolicy(p PasswordPolicy) Option {
+	return func(s *service) {
+		s.policy = p
+	}
+}
+
+// WithBcryptCost 调高cost后，老用户会在下次登录时自动重新哈希
+func WithBcryptCost(cost int) Option {
+	return func(s *service) {
+		s.bcryptCost = cost
+	}
+}
+
+// WithMailer 配置找回密码邮件
+func WithMailer(m mailer.Mailer, resetURL string) Option {
+	return func(s *service) {
+		s.mailer = m
+		s.resetURL = resetURL
+	}
+}
+
+func NewService(repo Repository, opts ...Option) Service {
+	s := &service{
+		repo:       repo,
+		clock:      clock.Real,
+		policy:     DefaultPasswordPolicy(),
+		bcryptCost: bcrypt.DefaultCost,
+		mailer:     mailer.NewLogMailer(),
+		resetURL:   "http://localhost:5173/reset-password",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *service) Register(ctx context.Context, in RegisterInput) (*User, error) {
-	if in.Username == "" {
-		return nil, apperror.New("INVALID_USERNAME", "username is required")
+	if err := validateUsername(in.Username); err != nil {
+		return nil, err
 	}
-	if len(in.Password) < 6 {
-		return nil, apperror.New("INVALID_PASSWORD", "password must be at least 6 characters")
+	if err := s.policy.Validate(in.Password, in.Username); err != nil {
+		return nil, err
+	}
+	if in.Email != "" {
+		if _, err := mail.ParseAddress(in.Email); err != nil {
+			return nil, apperror.New("INVALID_EMAIL", "invalid email address")
+		}
 	}
 
 	// 检查用户名是否已存在
@@ -64,20 +219,25 @@ func (s *service) Register(ctx context.Context, in RegisterInput) (*User, error)
 	}
 
 	// bcrypt 哈希密码
-	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), s.bcryptCost)
 	if err != nil {
 		return nil, apperror.New("INTERNAL_ERROR", "failed to hash password")
 	}
 
-	now := time.Now()
+	now := s.clock.Now()
 	u := &User{
 		Username:  in.Username,
 		Password:  string(hash),
+		Email:     in.Email,
+		Timezone:  DefaultTimezone,
+		Locale:    DefaultLocale,
+		WeekStart: DefaultWeekStart,
+		Role:      RoleUser,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
-	if err := s.repo.Create(ctx, u); err !=nil {
+	if err := s.repo.Create(ctx, u); err != nil {
 		return nil, err
 	}
 
@@ -91,23 +251,64 @@ func (s *service) Login(ctx context.Context, in LoginInput) (*User, error) {
 		return nil, apperror.New("INVALID_CREDENTIALS", "username and password are required")
 	}
 
+	// 锁定中直接拒绝，不再跑bcrypt
+	userKey, addrKey := usernameKey(in.Username), ipKey(in.IP)
+	if err := s.checkLocked(ctx, userKey, addrKey); err != nil {
+		return nil, err
+	}
+
 	u, err := s.repo.GetByUsername(ctx, in.Username)
 	if err != nil {
 		// 对外统一成账号密码错误，避免信息泄漏
-		return nil, apperror.New("INVALID_CREDENTIALS", "invalid username or password")
+		return nil, s.loginFailed(ctx, userKey, addrKey)
 	}
 
 	// 校验密码
 	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(in.Password)); err != nil {
-		return nil, apperror.New("INVALID_CREDENTIALS", "invalid username or password")
+		return nil, s.loginFailed(ctx, userKey, addrKey)
+	}
+
+	// IP的计数不清零，避免攻击者用自己的账号刷掉别人IP上的失败记录
+	s.resetFailures(ctx, userKey)
+
+	// 密码正确后才告知账号状态，避免不知道密码的人探测
+	if u.DisabledAt != nil {
+		return nil, apperror.New("ACCOUNT_DISABLED", "this account has been disabled")
+	}
+	if u.PasswordResetRequired {
+		return nil, apperror.New("PASSWORD_RESET_REQUIRED", "an administrator requires you to reset your password")
+	}
+
+	// 老哈希的cost低于当前配置时顺手升级，失败不影响登录
+	if cost, err := bcrypt.Cost([]byte(u.Password)); err == nil && cost < s.bcryptCost {
+		if hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), s.bcryptCost); err == nil {
+			_ = s.repo.UpdatePassword(ctx, u.ID, string(hash))
+		}
 	}
 
-	// 登录成功，隐藏密码
+	// 密码正确，隐藏密码；开启了两步验证的由handler发放mfa挑战token
 	u.Password = ""
+	u.TOTPSecret = ""
 	return u, nil
 }
 
-
 func (s *service) GetByID(ctx context.Context, id int64) (*User, error) {
-	return s.repo.GetByID(ctx, id)
-}
\ No newline at end of file
+	u, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	u.Password = ""
+	u.TOTPSecret = ""
+	return u, nil
+}
+
+// loginFailed 记录用户名和IP的失败次数，统一返回账号密码错误
+func (s *service) loginFailed(ctx context.Context, userKey, addrKey string) error {
+	if err := s.recordFailure(ctx, userKey, s.limiterPolicy.UsernameThreshold); err != nil {
+		return err
+	}
+	if err := s.recordFailure(ctx, addrKey, s.limiterPolicy.IPThreshold); err != nil {
+		return err
+	}
+	return apperror.New("INVALID_CREDENTIALS", "invalid username or password")
+}
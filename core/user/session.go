@@ -0,0 +1,87 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"tasker/pkg/apperror"
+)
+
+// SessionTTL 会话和登录态JWT的有效期
+const SessionTTL = 2 * time.Hour
+
+// Session 一次登录，JWT的jti就是会话ID
+type Session struct {
+	ID        string `json:"id"`
+	UserID    int64  `json:"user_id"`
+	UserAgent string `json:"user_agent"`
+	IP        string `json:"ip"`
+	// 管理员代登录时记录管理员ID
+	ImpersonatorID *int64     `json:"impersonator_id"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// SessionMeta 登录时由handler提供的客户端信息
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+	// 管理员代登录时填写
+	ImpersonatorID *int64
+}
+
+func (s *service) StartSession(ctx context.Context, userID int64, meta SessionMeta) (*Session, error) {
+	if err := s.CheckActive(ctx, userID); err != nil {
+		return nil, err
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, apperror.New("INTERNAL_ERROR", "failed to create session")
+	}
+
+	// user_agent列是varchar(255)
+	ua := meta.UserAgent
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+
+	now := s.clock.Now()
+	sess := &Session{
+		ID:             id,
+		UserID:         userID,
+		UserAgent:      ua,
+		IP:             meta.IP,
+		ImpersonatorID: meta.ImpersonatorID,
+		ExpiresAt:      now.Add(SessionTTL),
+		CreatedAt:      now,
+	}
+	if err := s.repo.CreateSession(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func (s *service) CheckSession(ctx context.Context, userID int64, sessionID string) error {
+	if sessionID == "" {
+		return apperror.New("SESSION_REVOKED", "session is no longer valid")
+	}
+	sess, err := s.repo.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess.UserID != userID || sess.RevokedAt != nil || !sess.ExpiresAt.After(s.clock.Now()) {
+		return apperror.New("SESSION_REVOKED", "session is no longer valid")
+	}
+	return s.CheckActive(ctx, userID)
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
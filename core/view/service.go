@@ -0,0 +1,298 @@
+package view
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+)
+
+// MaxNameLength 视图名称最多字符数
+const MaxNameLength = 100
+
+// Query 视图保存的列表条件，字段和GET /tasks的查询参数一一对应
+type Query struct {
+	Status          string     `json:"status,omitempty"`
+	Category        string     `json:"category,omitempty"`
+	Q               string     `json:"q,omitempty"`
+	Sort            string     `json:"sort,omitempty"`
+	Due             string     `json:"due,omitempty"`
+	WorkspaceID     *int64     `json:"workspace_id,omitempty"`
+	GroupID         *int64     `json:"group_id,omitempty"`
+	Assignee        string     `json:"assignee,omitempty"`
+	CompletedAfter  *time.Time `json:"completed_after,omitempty"`
+	CompletedBefore *time.Time `json:"completed_before,omitempty"`
+	Filter          string     `json:"filter,omitempty"`
+}
+
+// View 保存的任务列表。系统视图只有Key，用户保存的视图只有ID
+type View struct {
+	ID  int64  `json:"id,omitempty"`
+	Key string `json:"key,omitempty"`
+	// 创建者，系统视图为0
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	Query  Query  `json:"query"`
+	// 共享到的分组，能看到分组的人都能看到这个视图；nil表示只有自己
+	GroupID *int64 `json:"shared_group_id"`
+	System  bool   `json:"system"`
+	// 当前用户是否固定了这个视图
+	Pinned    bool       `json:"pinned"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// ViewInput 创建和修改视图的入参，修改时整体替换
+type ViewInput struct {
+	Name    string `json:"name"`
+	Query   Query  `json:"query"`
+	GroupID *int64 `json:"shared_group_id"`
+}
+
+// Repository 抽象视图的持久化，Pinned按viewerID填写
+type Repository interface {
+	Create(ctx context.Context, v *View) error
+	GetByID(ctx context.Context, viewerID, id int64) (*View, error)
+	// ListVisible 自己的视图和共享到用户能访问的分组里的视图
+	ListVisible(ctx context.Context, userID int64) ([]*View, error)
+	Update(ctx context.Context, v *View) error
+	// Delete 连同所有人的固定记录一起删除
+	Delete(ctx context.Context, id int64) error
+	SetPinned(ctx context.Context, userID, id int64, pinned bool, at time.Time) error
+}
+
+// Service 视图相关业务。运行视图时按当前用户的权限查询，assignee=me指当前用户
+type Service interface {
+	// List 系统视图在前，然后是固定的视图，其余按名称排序
+	List(ctx context.Context, userID int64) ([]*View, error)
+	// Get ref是系统视图的key或视图id
+	Get(ctx context.Context, userID int64, ref string) (*View, error)
+	Create(ctx context.Context, userID int64, in ViewInput) (*View, error)
+	// Update 只有创建者能修改
+	Update(ctx context.Context, userID, id int64, in ViewInput) (*View, error)
+	// Delete 创建者或能管理共享分组的人可以删除
+	Delete(ctx context.Context, userID, id int64) error
+	// Pin 固定只对自己生效，系统视图总是显示，不能固定
+	Pin(ctx context.Context, userID, id int64, pinned bool) (*View, error)
+	// Tasks 运行视图
+	Tasks(ctx context.Context, userID int64, ref string, paging Paging) (*task.ListResult, error)
+}
+
+// Paging 运行视图时的分页参数，含义同GET /tasks，零值用默认值
+type Paging struct {
+	Page         int
+	PageSize     int
+	Pagination   string
+	Cursor       string
+	IncludeTotal bool
+}
+
+// systemViews 内置视图，按顺序显示在侧边栏
+var systemViews = []*View{
+	{Key: "today", Name: "Today", Query: Query{Status: "pending", Due: "today"}},
+	{Key: "upcoming", Name: "Upcoming", Query: Query{Status: "pending", Filter: "due>today due<=7d"}},
+	{Key: "overdue", Name: "Overdue", Query: Query{Due: "overdue"}},
+	{Key: "no_due_date", Name: "No due date", Query: Query{Status: "pending", Filter: "due:none"}},
+	{Key: "recently_completed", Name: "Recently completed", Query: Query{Status: "completed", Sort: "completed_desc", Filter: "completed>=-7d"}},
+}
+
+func systemView(key string) (*View, bool) {
+	for _, v := range systemViews {
+		if v.Key == key {
+			out := *v
+			out.System = true
+			return &out, true
+		}
+	}
+	return nil, false
+}
+
+type service struct {
+	repo   Repository
+	tasks  task.Service
+	groups group.Service
+	clock  clock.Clock
+}
+
+func NewService(repo Repository, tasks task.Service, groups group.Service) Service {
+	return &service{repo: repo, tasks: tasks, groups: groups, clock: clock.Real}
+}
+
+func (s *service) List(ctx context.Context, userID int64) ([]*View, error) {
+	saved, err := s.repo.ListVisible(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*View, 0, len(systemViews)+len(saved))
+	for _, v := range systemViews {
+		sv, _ := systemView(v.Key)
+		out = append(out, sv)
+	}
+	return append(out, saved...), nil
+}
+
+func (s *service) Get(ctx context.Context, userID int64, ref string) (*View, error) {
+	if v, ok := systemView(ref); ok {
+		return v, nil
+	}
+	id, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, apperror.New("VIEW_NOT_FOUND", "view not found")
+	}
+	return s.get(ctx, userID, id)
+}
+
+func (s *service) Create(ctx context.Context, userID int64, in ViewInput) (*View, error) {
+	in, err := s.validate(ctx, userID, in)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	v := &View{
+		UserID:    userID,
+		Name:      in.Name,
+		Query:     in.Query,
+		GroupID:   in.GroupID,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	if err := s.repo.Create(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *service) Update(ctx context.Context, userID, id int64, in ViewInput) (*View, error) {
+	v, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if v.UserID != userID {
+		return nil, apperror.New("VIEW_FORBIDDEN", "only the owner can edit a view")
+	}
+	in, err = s.validate(ctx, userID, in)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	v.Name = in.Name
+	v.Query = in.Query
+	v.GroupID = in.GroupID
+	v.UpdatedAt = &now
+	if err := s.repo.Update(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *service) Delete(ctx context.Context, userID, id int64) error {
+	v, err := s.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if v.UserID != userID {
+		if v.GroupID == nil {
+			return apperror.New("VIEW_FORBIDDEN", "only the owner can delete this view")
+		}
+		if _, err := s.groups.Authorize(ctx, userID, *v.GroupID, group.AccessManage); err != nil {
+			return apperror.New("VIEW_FORBIDDEN", "only the owner or a group manager can delete this view")
+		}
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *service) Pin(ctx context.Context, userID, id int64, pinned bool) (*View, error) {
+	v, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetPinned(ctx, userID, id, pinned, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	v.Pinned = pinned
+	return v, nil
+}
+
+func (s *service) Tasks(ctx context.Context, userID int64, ref string, paging Paging) (*task.ListResult, error) {
+	v, err := s.Get(ctx, userID, ref)
+	if err != nil {
+		return nil, err
+	}
+	filter := listFilter(v.Query)
+	filter.Page = paging.Page
+	filter.PageSize = paging.PageSize
+	filter.Pagination = paging.Pagination
+	filter.Cursor = paging.Cursor
+	filter.IncludeTotal = paging.IncludeTotal
+	return s.tasks.ListTasks(ctx, userID, filter)
+}
+
+// get 读取视图并确认当前用户能看到：自己创建的，或共享到能访问的分组里的
+func (s *service) get(ctx context.Context, userID, id int64) (*View, error) {
+	v, err := s.repo.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if v.UserID == userID {
+		return v, nil
+	}
+	if v.GroupID != nil {
+		if _, err := s.groups.Authorize(ctx, userID, *v.GroupID, group.AccessRead); err == nil {
+			return v, nil
+		}
+	}
+	return nil, apperror.New("VIEW_NOT_FOUND", "view not found")
+}
+
+// validate 校验名称和共享分组，并按创建者的权限试运行一次条件，
+// 条件写错（排序、filter语法、看不到的工作区等）在保存时就报出来
+func (s *service) validate(ctx context.Context, userID int64, in ViewInput) (ViewInput, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return in, apperror.New("INVALID_VIEW_NAME", "name is required")
+	}
+	if utf8.RuneCountInString(in.Name) > MaxNameLength {
+		return in, apperror.New("INVALID_VIEW_NAME", "name must be at most 100 characters")
+	}
+	if in.GroupID != nil {
+		// 共享到分组需要有写权限，只读共享的用户不能往分组里加东西
+		if _, err := s.groups.Authorize(ctx, userID, *in.GroupID, group.AccessWrite); err != nil {
+			return in, err
+		}
+	}
+
+	filter := listFilter(in.Query)
+	filter.PageSize = 1
+	if _, err := s.tasks.ListTasks(ctx, userID, filter); err != nil {
+		return in, err
+	}
+	return in, nil
+}
+
+// listFilter 和TaskHandler.ListTasks解析查询参数的方式一致
+func listFilter(q Query) task.ListTaskerFilter {
+	filter := task.ListTaskerFilter{
+		Status:          task.Status(q.Status),
+		Category:        group.Category(q.Category),
+		Query:           q.Q,
+		Sort:            q.Sort,
+		Due:             q.Due,
+		WorkspaceID:     q.WorkspaceID,
+		GroupID:         q.GroupID,
+		Assignee:        q.Assignee,
+		CompletedAfter:  q.CompletedAfter,
+		CompletedBefore: q.CompletedBefore,
+		Filter:          q.Filter,
+	}
+	if q.Status == "all" {
+		filter.Status = ""
+	}
+	return filter
+}
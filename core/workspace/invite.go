@@ -0,0 +1,114 @@
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"tasker/pkg/apperror"
+)
+
+// 邀请链接的有效期
+const InviteTTL = 7 * 24 * time.Hour
+
+// Invite 工作区邀请，只保存token哈希，明文只在创建时返回一次
+type Invite struct {
+	ID          int64      `json:"id"`
+	WorkspaceID int64      `json:"workspace_id"`
+	Role        Role       `json:"role"`
+	InvitedBy   int64      `json:"invited_by"`
+	Hash        string     `json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedBy  *int64     `json:"accepted_by"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// 创建邀请的入参，默认member
+type CreateInviteInput struct {
+	Role Role `json:"role"`
+}
+
+// 接受邀请的入参
+type AcceptInviteInput struct {
+	Token string `json:"token"`
+}
+
+func hashInviteToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *service) CreateInvite(ctx context.Context, userID, id int64, in CreateInviteInput) (*Invite, string, error) {
+	if in.Role == "" {
+		in.Role = RoleMember
+	}
+	if !in.Role.Valid() || in.Role == RoleOwner {
+		return nil, "", apperror.New("INVALID_ROLE", "role must be admin, member or viewer")
+	}
+	if _, err := s.Authorize(ctx, userID, id, RoleAdmin); err != nil {
+		return nil, "", err
+	}
+	w, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if w.Personal {
+		return nil, "", apperror.New("PERSONAL_WORKSPACE", "create a shared workspace to invite teammates")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", apperror.New("INTERNAL_ERROR", "failed to generate invite")
+	}
+	raw := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := s.clock.Now()
+	inv := &Invite{
+		WorkspaceID: id,
+		Role:        in.Role,
+		InvitedBy:   userID,
+		Hash:        hashInviteToken(raw),
+		ExpiresAt:   now.Add(InviteTTL),
+		CreatedAt:   now,
+	}
+	if err := s.repo.CreateInvite(ctx, inv); err != nil {
+		return nil, "", err
+	}
+	return inv, raw, nil
+}
+
+func (s *service) ListInvites(ctx context.Context, userID, id int64) ([]*Invite, error) {
+	if _, err := s.Authorize(ctx, userID, id, RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.repo.ListInvites(ctx, id)
+}
+
+func (s *service) RevokeInvite(ctx context.Context, userID, id, inviteID int64) error {
+	if _, err := s.Authorize(ctx, userID, id, RoleAdmin); err != nil {
+		return err
+	}
+	return s.repo.DeleteInvite(ctx, id, inviteID)
+}
+
+func (s *service) AcceptInvite(ctx context.Context, userID int64, in AcceptInviteInput) (*Workspace, error) {
+	if in.Token == "" {
+		return nil, apperror.New("INVALID_INVITE", "invalid or expired invite")
+	}
+
+	inv, err := s.repo.AcceptInvite(ctx, hashInviteToken(in.Token), userID, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := s.repo.GetByID(ctx, inv.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	w.Role = inv.Role
+	return w, nil
+}
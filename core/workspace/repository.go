@@ -0,0 +1,33 @@
+package workspace
+
+import (
+	"context"
+	"time"
+)
+
+// Repository 抽象工作区、成员和邀请的持久化
+type Repository interface {
+	// Create 在一个事务里创建工作区并把owner加为成员
+	Create(ctx context.Context, w *Workspace, owner *Member) error
+	GetByID(ctx context.Context, id int64) (*Workspace, error)
+	// GetPersonal 返回用户的个人工作区，不存在时返回nil, nil
+	GetPersonal(ctx context.Context, userID int64) (*Workspace, error)
+	// ListByMember 用户加入的全部工作区，Role填为该用户的角色
+	ListByMember(ctx context.Context, userID int64) ([]*Workspace, error)
+	Update(ctx context.Context, w *Workspace) error
+	// Delete 在一个事务里删除工作区及其分组、任务、成员和邀请
+	Delete(ctx context.Context, id int64) error
+
+	GetMember(ctx context.Context, workspaceID, userID int64) (*Member, error)
+	ListMembers(ctx context.Context, workspaceID int64) ([]*Member, error)
+	UpdateMemberRole(ctx context.Context, workspaceID, userID int64, role Role) error
+	// TransferOwnership 新owner升级为owner，原owner降为admin
+	TransferOwnership(ctx context.Context, workspaceID, fromUserID, toUserID int64, at time.Time) error
+	RemoveMember(ctx context.Context, workspaceID, userID int64) error
+
+	CreateInvite(ctx context.Context, inv *Invite) error
+	ListInvites(ctx context.Context, workspaceID int64) ([]*Invite, error)
+	DeleteInvite(ctx context.Context, workspaceID, id int64) error
+	// AcceptInvite 在一个事务里把未过期、未使用的邀请标记为已用并加入成员
+	AcceptInvite(ctx context.Context, hash string, userID int64, at time.Time) (*Invite, error)
+}
@@ -0,0 +1,39 @@
+package workspace
+
+// Role 成员在工作区里的角色，权限从高到低 owner > admin > member > viewer
+type Role string
+
+const (
+	// 工作区的所有者，每个工作区有且只有一个
+	RoleOwner Role = "owner"
+	// 管理成员、邀请和工作区设置
+	RoleAdmin Role = "admin"
+	// 可以创建和修改分组、任务
+	RoleMember Role = "member"
+	// 只读
+	RoleViewer Role = "viewer"
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleOwner:
+		return 4
+	case RoleAdmin:
+		return 3
+	case RoleMember:
+		return 2
+	case RoleViewer:
+		return 1
+	}
+	return 0
+}
+
+// Valid 是否是合法的角色
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// AtLeast 角色是否不低于min
+func (r Role) AtLeast(min Role) bool {
+	return r.rank() >= min.rank()
+}
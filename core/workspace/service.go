@@ -0,0 +1,287 @@
+package workspace
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tasker/pkg/apperror"
+	"tasker/pkg/clock"
+)
+
+// Workspace 工作区拥有分组和任务，成员按角色共享访问。
+// 每个用户都有一个个人工作区（Personal），老数据迁移后都在里面
+type Workspace struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	OwnerID  int64  `json:"owner_id"`
+	Personal bool   `json:"personal"`
+	// 当前用户在这个工作区里的角色，列表和详情里返回
+	Role      Role      `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Member 工作区成员
+type Member struct {
+	WorkspaceID int64     `json:"workspace_id"`
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	Role        Role      `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// 创建工作区的入参
+type CreateWorkspaceInput struct {
+	Name string `json:"name"`
+}
+
+// 修改工作区的入参
+type UpdateWorkspaceInput struct {
+	Name string `json:"name"`
+}
+
+// 修改成员角色的入参，role为owner表示转让所有权
+type UpdateMemberInput struct {
+	Role Role `json:"role"`
+}
+
+// Service 工作区相关业务，同时负责其他模块的权限校验
+type Service interface {
+	Create(ctx context.Context, userID int64, in CreateWorkspaceInput) (*Workspace, error)
+	List(ctx context.Context, userID int64) ([]*Workspace, error)
+	Get(ctx context.Context, userID, id int64) (*Workspace, error)
+	Update(ctx context.Context, userID, id int64, in UpdateWorkspaceInput) (*Workspace, error)
+	Delete(ctx context.Context, userID, id int64) error
+	// Personal 返回用户的个人工作区，不存在时创建
+	Personal(ctx context.Context, userID int64) (*Workspace, error)
+
+	// Authorize 校验用户在工作区里的角色不低于min。
+	// 不是成员返回 WORKSPACE_NOT_FOUND，角色不够返回 WORKSPACE_FORBIDDEN
+	Authorize(ctx context.Context, userID, workspaceID int64, min Role) (*Member, error)
+
+	ListMembers(ctx context.Context, userID, id int64) ([]*Member, error)
+	UpdateMember(ctx context.Context, userID, id, memberID int64, in UpdateMemberInput) (*Member, error)
+	// RemoveMember 管理员移除成员，或成员自己退出
+	RemoveMember(ctx context.Context, userID, id, memberID int64) error
+
+	// 邀请：生成一次性链接token，被邀请人登录后接受
+	CreateInvite(ctx context.Context, userID, id int64, in CreateInviteInput) (*Invite, string, error)
+	ListInvites(ctx context.Context, userID, id int64) ([]*Invite, error)
+	RevokeInvite(ctx context.Context, userID, id, inviteID int64) error
+	AcceptInvite(ctx context.Context, userID int64, in AcceptInviteInput) (*Workspace, error)
+}
+
+type service struct {
+	repo  Repository
+	clock clock.Clock
+}
+
+// Option 可选依赖，NewService默认使用系统时钟
+type Option func(*service)
+
+// WithClock 注入时钟，测试时用clock.Fake
+func WithClock(c clock.Clock) Option {
+	return func(s *service) {
+		s.clock = c
+	}
+}
+
+func NewService(repo Repository, opts ...Option) Service {
+	s := &service{repo: repo, clock: clock.Real}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func validateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return "", apperror.New("INVALID_WORKSPACE_NAME", "workspace name must be 1-100 characters")
+	}
+	return name, nil
+}
+
+func (s *service) Create(ctx context.Context, userID int64, in CreateWorkspaceInput) (*Workspace, error) {
+	name, err := validateName(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	return s.create(ctx, userID, name, false)
+}
+
+func (s *service) create(ctx context.Context, userID int64, name string, personal bool) (*Workspace, error) {
+	now := s.clock.Now()
+	w := &Workspace{
+		Name:      name,
+		OwnerID:   userID,
+		Personal:  personal,
+		Role:      RoleOwner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	owner := &Member{UserID: userID, Role: RoleOwner, CreatedAt: now}
+	if err := s.repo.Create(ctx, w, owner); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *service) Personal(ctx context.Context, userID int64) (*Workspace, error) {
+	w, err := s.repo.GetPersonal(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		w.Role = RoleOwner
+		return w, nil
+	}
+
+	w, err = s.create(ctx, userID, "个人", true)
+	if err != nil {
+		// 并发请求可能已经建好了（唯一索引兜底），再查一次
+		if existing, getErr := s.repo.GetPersonal(ctx, userID); getErr == nil && existing != nil {
+			existing.Role = RoleOwner
+			return existing, nil
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *service) List(ctx context.Context, userID int64) ([]*Workspace, error) {
+	// 保证个人工作区存在，新注册的用户第一次访问时创建
+	if _, err := s.Personal(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByMember(ctx, userID)
+}
+
+func (s *service) Authorize(ctx context.Context, userID, workspaceID int64, min Role) (*Member, error) {
+	m, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		if appErr, ok := apperror.IsAppError(err); ok && appErr.Code == "MEMBER_NOT_FOUND" {
+			// 不是成员时不暴露工作区是否存在
+			return nil, apperror.New("WORKSPACE_NOT_FOUND", "workspace not found")
+		}
+		return nil, err
+	}
+	if !m.Role.AtLeast(min) {
+		return nil, apperror.New("WORKSPACE_FORBIDDEN", "this action requires the "+string(min)+" role")
+	}
+	return m, nil
+}
+
+func (s *service) Get(ctx context.Context, userID, id int64) (*Workspace, error) {
+	m, err := s.Authorize(ctx, userID, id, RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	w.Role = m.Role
+	return w, nil
+}
+
+func (s *service) Update(ctx context.Context, userID, id int64, in UpdateWorkspaceInput) (*Workspace, error) {
+	name, err := validateName(in.Name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.Authorize(ctx, userID, id, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Name = name
+	w.UpdatedAt = s.clock.Now()
+	if err := s.repo.Update(ctx, w); err != nil {
+		return nil, err
+	}
+	w.Role = m.Role
+	return w, nil
+}
+
+func (s *service) Delete(ctx context.Context, userID, id int64) error {
+	if _, err := s.Authorize(ctx, userID, id, RoleOwner); err != nil {
+		return err
+	}
+	w, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if w.Personal {
+		return apperror.New("PERSONAL_WORKSPACE", "the personal workspace cannot be deleted")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *service) ListMembers(ctx context.Context, userID, id int64) ([]*Member, error) {
+	if _, err := s.Authorize(ctx, userID, id, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, id)
+}
+
+func (s *service) UpdateMember(ctx context.Context, userID, id, memberID int64, in UpdateMemberInput) (*Member, error) {
+	if !in.Role.Valid() {
+		return nil, apperror.New("INVALID_ROLE", "role must be owner, admin, member or viewer")
+	}
+	actor, err := s.Authorize(ctx, userID, id, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.repo.GetMember(ctx, id, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if target.Role == RoleOwner {
+		return nil, apperror.New("WORKSPACE_FORBIDDEN", "the owner's role can only change by transferring ownership")
+	}
+
+	// 转让所有权只能由owner发起
+	if in.Role == RoleOwner {
+		if actor.Role != RoleOwner {
+			return nil, apperror.New("WORKSPACE_FORBIDDEN", "only the owner can transfer ownership")
+		}
+		if err := s.repo.TransferOwnership(ctx, id, userID, memberID, s.clock.Now()); err != nil {
+			return nil, err
+		}
+		target.Role = RoleOwner
+		return target, nil
+	}
+
+	if err := s.repo.UpdateMemberRole(ctx, id, memberID, in.Role); err != nil {
+		return nil, err
+	}
+	target.Role = in.Role
+	return target, nil
+}
+
+func (s *service) RemoveMember(ctx context.Context, userID, id, memberID int64) error {
+	target, err := s.repo.GetMember(ctx, id, memberID)
+	if err != nil {
+		if _, authErr := s.Authorize(ctx, userID, id, RoleViewer); authErr != nil {
+			return authErr
+		}
+		return err
+	}
+	if target.Role == RoleOwner {
+		return apperror.New("WORKSPACE_FORBIDDEN", "transfer ownership before the owner leaves")
+	}
+	// 自己退出不需要管理员权限
+	if memberID != userID {
+		if _, err := s.Authorize(ctx, userID, id, RoleAdmin); err != nil {
+			return err
+		}
+	}
+	return s.repo.RemoveMember(ctx, id, memberID)
+}
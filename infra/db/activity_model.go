@@ -0,0 +1,21 @@
+package db
+
+import "time"
+
+// ActivityModel 动态流。按工作区和分组各建一个带id的复合索引，
+// 翻页查询 "workspace_id IN ... OR group_id IN ... AND id < ?" 都能走索引
+type ActivityModel struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement;index:idx_activities_workspace_id,priority:2;index:idx_activities_group_id,priority:2"`
+	WorkspaceID int64  `gorm:"not null;index:idx_activities_workspace_id,priority:1"`
+	GroupID     *int64 `gorm:"index:idx_activities_group_id,priority:1"`
+	TaskID      *int64 `gorm:"index"`
+	// 操作人注销后为NULL
+	ActorID   *int64    `gorm:"index"`
+	Type      string    `gorm:"type:varchar(20);not null"`
+	Summary   string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (ActivityModel) TableName() string {
+	return "activities"
+}
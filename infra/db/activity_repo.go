@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+
+	"tasker/core/activity"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type ActivityRepository struct {
+	db *gorm.DB
+}
+
+func NewActivityRepository(db *gorm.DB) *ActivityRepository {
+	return &ActivityRepository{db: db}
+}
+
+// 列表带上操作人当前的用户名，操作人注销后为空
+type activityWithUsername struct {
+	ActivityModel
+	Username string
+}
+
+func (r *ActivityRepository) Create(ctx context.Context, a *activity.Activity) error {
+	m := ActivityModel{
+		WorkspaceID: a.WorkspaceID,
+		GroupID:     a.GroupID,
+		TaskID:      a.TaskID,
+		ActorID:     nullUserID(a.ActorID),
+		Type:        a.Type,
+		Summary:     a.Summary,
+		CreatedAt:   a.CreatedAt,
+	}
+	if err := r.db.WithContext(ctx).Create(&m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create activity")
+	}
+	a.ID = m.ID
+	return nil
+}
+
+// List 和任务一样：所在工作区的全部动态，加上共享给用户的分组里的动态
+func (r *ActivityRepository) List(ctx context.Context, userID int64, filter activity.ListFilter) ([]*activity.Activity, error) {
+	visible := r.db.Where("activities.workspace_id IN (?)", memberWorkspaceIDs(r.db, userID)).
+		Or("activities.group_id IN (?)", sharedGroupIDs(r.db, userID))
+
+	db := r.db.WithContext(ctx).Model(&ActivityModel{}).
+		Select("activities.*, COALESCE(users.username, '') AS username").
+		Joins("LEFT JOIN users ON users.id = activities.actor_id").
+		Where(visible)
+	if filter.WorkspaceID != nil {
+		db = db.Where("activities.workspace_id = ?", *filter.WorkspaceID)
+	}
+	if filter.GroupID != nil {
+		db = db.Where("activities.group_id = ?", *filter.GroupID)
+	}
+	if filter.ActorID != nil {
+		db = db.Where("activities.actor_id = ?", *filter.ActorID)
+	}
+	if filter.Type != "" {
+		db = db.Where("activities.type = ?", filter.Type)
+	}
+	if filter.BeforeID > 0 {
+		db = db.Where("activities.id < ?", filter.BeforeID)
+	}
+
+	var rows []activityWithUsername
+	if err := db.Order("activities.id DESC").Limit(filter.Limit).Scan(&rows).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list activities")
+	}
+	items := make([]*activity.Activity, 0, len(rows))
+	for i := range rows {
+		m := &rows[i]
+		items = append(items, &activity.Activity{
+			ID:            m.ID,
+			WorkspaceID:   m.WorkspaceID,
+			GroupID:       m.GroupID,
+			TaskID:        m.TaskID,
+			ActorID:       derefUserID(m.ActorID),
+			ActorUsername: m.Username,
+			Type:          m.Type,
+			Summary:       m.Summary,
+			CreatedAt:     m.CreatedAt,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,17 @@
+package db
+
+import "time"
+
+// AdminAuditModel 管理操作审计，actor_id为空表示命令行操作
+type AdminAuditModel struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement"`
+	ActorID      *int64    `gorm:"index"`
+	Action       string    `gorm:"type:varchar(50);not null"`
+	TargetUserID int64     `gorm:"not null;index"`
+	Detail       string    `gorm:"type:text;not null;default:''"`
+	CreatedAt    time.Time `gorm:"not null;index"`
+}
+
+func (AdminAuditModel) TableName() string {
+	return "admin_audit_logs"
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+
+	"tasker/core/admin"
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type AdminRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminRepository(db *gorm.DB) *AdminRepository {
+	return &AdminRepository{db: db}
+}
+
+func (r *AdminRepository) SearchUsers(ctx context.Context, filter admin.UserFilter) ([]*user.User, int64, error) {
+	db := r.db.WithContext(ctx).Model(&UserModel{})
+	if filter.Query != "" {
+		q := "%" + filter.Query + "%"
+		db = db.Where("username ILIKE ? OR email ILIKE ?", q, q)
+	}
+	if filter.Role != "" {
+		db = db.Where("role = ?", string(filter.Role))
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			db = db.Where("disabled_at IS NOT NULL")
+		} else {
+			db = db.Where("disabled_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, apperror.New("DB_ERROR", "failed to count users")
+	}
+	var models []UserModel
+	if err := db.Order("id ASC").Limit(filter.PageSize).Offset((filter.Page - 1) * filter.PageSize).Find(&models).Error; err != nil {
+		return nil, 0, apperror.New("DB_ERROR", "failed to list users")
+	}
+	items := make([]*user.User, 0, len(models))
+	for i := range models {
+		u := userToDomain(&models[i])
+		u.Password = ""
+		u.TOTPSecret = ""
+		items = append(items, u)
+	}
+	return items, total, nil
+}
+
+func (r *AdminRepository) Stats(ctx context.Context) (*admin.Stats, error) {
+	db := r.db.WithContext(ctx)
+	var st admin.Stats
+	counts := []struct {
+		dst   *int64
+		model any
+		where string
+	}{
+		{&st.Users, &UserModel{}, ""},
+		{&st.Admins, &UserModel{}, "role = 'admin'"},
+		{&st.DisabledUsers, &UserModel{}, "disabled_at IS NOT NULL"},
+		{&st.Workspaces, &WorkspaceModel{}, ""},
+		{&st.Tasks, &TaskModel{}, ""},
+	}
+	for _, c := range counts {
+		q := db.Model(c.model)
+		if c.where != "" {
+			q = q.Where(c.where)
+		}
+		if err := q.Count(c.dst).Error; err != nil {
+			return nil, apperror.New("DB_ERROR", "failed to collect stats")
+		}
+	}
+	if err := db.Raw("SELECT pg_database_size(current_database())").Scan(&st.DBSizeBytes).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to collect stats")
+	}
+	return &st, nil
+}
+
+func (r *AdminRepository) CreateAudit(ctx context.Context, e *admin.AuditEntry) error {
+	m := AdminAuditModel{
+		ActorID:      e.ActorID,
+		Action:       e.Action,
+		TargetUserID: e.TargetUserID,
+		Detail:       e.Detail,
+		CreatedAt:    e.CreatedAt,
+	}
+	if err := r.db.WithContext(ctx).Create(&m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to write audit log")
+	}
+	e.ID = m.ID
+	return nil
+}
+
+func (r *AdminRepository) ListAudit(ctx context.Context, filter admin.AuditFilter) ([]*admin.AuditEntry, error) {
+	db := r.db.WithContext(ctx)
+	if filter.TargetUserID != nil {
+		db = db.Where("target_user_id = ?", *filter.TargetUserID)
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	var models []AdminAuditModel
+	if err := db.Order("id DESC").Limit(filter.Limit).Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list audit log")
+	}
+	items := make([]*admin.AuditEntry, 0, len(models))
+	for _, m := range models {
+		items = append(items, &admin.AuditEntry{
+			ID:           m.ID,
+			ActorID:      m.ActorID,
+			Action:       m.Action,
+			TargetUserID: m.TargetUserID,
+			Detail:       m.Detail,
+			CreatedAt:    m.CreatedAt,
+		})
+	}
+	return items, nil
+}
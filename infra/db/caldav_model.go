@@ -0,0 +1,23 @@
+package db
+
+import "time"
+
+// CalDAVRemovalModel 离开分组的任务，sync-collection据此报告删除。由tasks上的触发器写入，见migrateTasks
+type CalDAVRemovalModel struct {
+	ID      int64 `gorm:"primaryKey;autoIncrement"`
+	GroupID int64 `gorm:"not null;index:idx_caldav_removals_group_seq"`
+	// 离开时分组的变更序号
+	Seq    int64 `gorm:"not null;index:idx_caldav_removals_group_seq"`
+	TaskID int64 `gorm:"not null"`
+	// 离开时的外部ID，和TaskID一起还原出资源名
+	ExternalID *string `gorm:"type:varchar(100)"`
+
+	// 分组删除时一起删除
+	Group GroupModel `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE"`
+
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (CalDAVRemovalModel) TableName() string {
+	return "caldav_removals"
+}
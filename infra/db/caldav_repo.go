@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+
+	"tasker/core/caldav"
+	"tasker/core/task"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type CalDAVRepository struct {
+	db *gorm.DB
+}
+
+func NewCalDAVRepository(db *gorm.DB) *CalDAVRepository {
+	return &CalDAVRepository{db: db}
+}
+
+// SyncSeq 序号由tasks上的触发器维护，见migrateTasks
+func (r *CalDAVRepository) SyncSeq(ctx context.Context, groupID int64) (int64, error) {
+	var seqs []int64
+	if err := r.db.WithContext(ctx).Model(&GroupModel{}).Where("id = ?", groupID).Pluck("change_seq", &seqs).Error; err != nil {
+		return 0, apperror.New("DB_ERROR", "failed to read sync state")
+	}
+	if len(seqs) == 0 {
+		return 0, apperror.New("GROUP_NOT_FOUND", "group not found")
+	}
+	return seqs[0], nil
+}
+
+func (r *CalDAVRepository) ChangesSince(ctx context.Context, groupID, since int64) ([]*task.Task, []caldav.Removal, error) {
+	var models []TaskModel
+	if err := r.db.WithContext(ctx).Where("group_id = ? AND change_seq > ?", groupID, since).
+		Order("change_seq ASC").Find(&models).Error; err != nil {
+		return nil, nil, apperror.New("DB_ERROR", "failed to list changes")
+	}
+	items := make([]*task.Task, 0, len(models))
+	for i := range models {
+		items = append(items, toDomain(&models[i]))
+	}
+	tasks := &TaskRepository{db: r.db}
+	if err := tasks.loadRelations(ctx, items); err != nil {
+		return nil, nil, apperror.New("DB_ERROR", "failed to list changes")
+	}
+
+	var removals []CalDAVRemovalModel
+	if err := r.db.WithContext(ctx).Where("group_id = ? AND seq > ?", groupID, since).
+		Order("seq ASC").Find(&removals).Error; err != nil {
+		return nil, nil, apperror.New("DB_ERROR", "failed to list changes")
+	}
+	removed := make([]caldav.Removal, 0, len(removals))
+	for _, m := range removals {
+		rm := caldav.Removal{TaskID: m.TaskID}
+		if m.ExternalID != nil {
+			rm.ExternalID = *m.ExternalID
+		}
+		removed = append(removed, rm)
+	}
+	return items, removed, nil
+}
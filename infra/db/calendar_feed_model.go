@@ -0,0 +1,15 @@
+package db
+
+import "time"
+
+// CalendarFeedModel 用户的日历订阅，每个用户最多一条，只保存token的sha256
+type CalendarFeedModel struct {
+	UserID         int64  `gorm:"primaryKey;autoIncrement:false"`
+	TokenHash      string `gorm:"type:char(64);not null;uniqueIndex"`
+	LastAccessedAt *time.Time
+	CreatedAt      time.Time `gorm:"not null"`
+}
+
+func (CalendarFeedModel) TableName() string {
+	return "calendar_feeds"
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tasker/core/calendar"
+	"tasker/core/transfer"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CalendarRepository struct {
+	db *gorm.DB
+}
+
+func NewCalendarRepository(db *gorm.DB) *CalendarRepository {
+	return &CalendarRepository{db: db}
+}
+
+func calendarFeedToDomain(m *CalendarFeedModel) *calendar.Feed {
+	return &calendar.Feed{
+		UserID:         m.UserID,
+		Hash:           m.TokenHash,
+		LastAccessedAt: m.LastAccessedAt,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+func (r *CalendarRepository) Get(ctx context.Context, userID int64) (*calendar.Feed, error) {
+	return r.first(ctx, "user_id = ?", userID)
+}
+
+func (r *CalendarRepository) GetByHash(ctx context.Context, hash string) (*calendar.Feed, error) {
+	return r.first(ctx, "token_hash = ?", hash)
+}
+
+// first 找不到时返回nil
+func (r *CalendarRepository) first(ctx context.Context, query string, arg any) (*calendar.Feed, error) {
+	var m CalendarFeedModel
+	if err := r.db.WithContext(ctx).Where(query, arg).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get calendar feed")
+	}
+	return calendarFeedToDomain(&m), nil
+}
+
+// Save 已有订阅时替换token，访问记录清空
+func (r *CalendarRepository) Save(ctx context.Context, f *calendar.Feed) error {
+	m := CalendarFeedModel{
+		UserID:    f.UserID,
+		TokenHash: f.Hash,
+		CreatedAt: f.CreatedAt,
+	}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "last_accessed_at", "created_at"}),
+	}).Create(&m).Error
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to save calendar feed")
+	}
+	return nil
+}
+
+func (r *CalendarRepository) Delete(ctx context.Context, userID int64) error {
+	res := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&CalendarFeedModel{})
+	if res.Error != nil {
+		return apperror.New("DB_ERROR", "failed to delete calendar feed")
+	}
+	if res.RowsAffected == 0 {
+		return apperror.New("FEED_NOT_FOUND", "calendar feed is not enabled")
+	}
+	return nil
+}
+
+func (r *CalendarRepository) RecordAccess(ctx context.Context, userID int64, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&CalendarFeedModel{}).Where("user_id = ?", userID).Update("last_accessed_at", at).Error
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to record calendar feed access")
+	}
+	return nil
+}
+
+func (r *CalendarRepository) StreamDueTasks(ctx context.Context, userID int64, groupID *int64, fn func(batch []*transfer.Record) error) error {
+	return streamRecords(r.db.WithContext(ctx), false, func(db *gorm.DB) *gorm.DB {
+		db = db.Where(accessibleTasks(r.db, userID)).Where("due_data IS NOT NULL OR due_on IS NOT NULL")
+		if groupID != nil {
+			db = db.Where("group_id = ?", *groupID)
+		}
+		return db
+	}, fn)
+}
@@ -0,0 +1,18 @@
+package db
+
+import "time"
+
+// CommentModel 任务评论
+type CommentModel struct {
+	ID     int64 `gorm:"primaryKey;autoIncrement"`
+	TaskID int64 `gorm:"not null;index:idx_comments_task_created"`
+	// 作者注销后为NULL
+	UserID    *int64    `gorm:"index"`
+	Body      string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"not null;index:idx_comments_task_created"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+func (CommentModel) TableName() string {
+	return "comments"
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"tasker/core/comment"
+	"tasker/core/mention"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type CommentRepository struct {
+	db *gorm.DB
+}
+
+func NewCommentRepository(db *gorm.DB) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+// 读取时带上作者当前的用户名，作者注销后为空
+type commentWithUsername struct {
+	CommentModel
+	Username string
+}
+
+func commentToDomain(m *commentWithUsername) *comment.Comment {
+	return &comment.Comment{
+		ID:        m.ID,
+		TaskID:    m.TaskID,
+		UserID:    derefUserID(m.UserID),
+		Username:  m.Username,
+		Body:      m.Body,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+func (r *CommentRepository) withUsername(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&CommentModel{}).
+		Select("comments.*, COALESCE(users.username, '') AS username").
+		Joins("LEFT JOIN users ON users.id = comments.user_id")
+}
+
+func (r *CommentRepository) Create(ctx context.Context, c *comment.Comment) error {
+	m := CommentModel{
+		TaskID:    c.TaskID,
+		UserID:    nullUserID(c.UserID),
+		Body:      c.Body,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+	if err := r.db.WithContext(ctx).Create(&m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create comment")
+	}
+	c.ID = m.ID
+	return nil
+}
+
+func (r *CommentRepository) GetByID(ctx context.Context, id int64) (*comment.Comment, error) {
+	var row commentWithUsername
+	if err := r.withUsername(ctx).Where("comments.id = ?", id).Take(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.New("COMMENT_NOT_FOUND", "comment not found")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get comment")
+	}
+	return commentToDomain(&row), nil
+}
+
+func (r *CommentRepository) ListByTask(ctx context.Context, taskID int64) ([]*comment.Comment, error) {
+	var rows []commentWithUsername
+	if err := r.withUsername(ctx).Where("comments.task_id = ?", taskID).
+		Order("comments.created_at ASC, comments.id ASC").Scan(&rows).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list comments")
+	}
+	items := make([]*comment.Comment, 0, len(rows))
+	for i := range rows {
+		items = append(items, commentToDomain(&rows[i]))
+	}
+	return items, nil
+}
+
+func (r *CommentRepository) Update(ctx context.Context, c *comment.Comment) error {
+	tx := r.db.WithContext(ctx).Model(&CommentModel{}).Where("id = ?", c.ID).Updates(map[string]any{
+		"body":       c.Body,
+		"updated_at": c.UpdatedAt,
+	})
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update comment")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("COMMENT_NOT_FOUND", "comment not found")
+	}
+	return nil
+}
+
+// Delete 连同评论里的提及一起删除
+func (r *CommentRepository) Delete(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("source_type = ? AND source_id = ?", mention.SourceComment, id).Delete(&MentionModel{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&CommentModel{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("COMMENT_NOT_FOUND", "comment not found")
+		}
+		return apperror.New("DB_ERROR", "failed to delete comment")
+	}
+	return nil
+}
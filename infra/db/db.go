@@ -8,6 +8,7 @@ import(
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -23,9 +24,9 @@ func NewPostgresDB() *gorm.DB {
 	password := "root"
 	dbname := "go_tasker"
 
-	// TimeZone is the correct DSN parameter (was misspelled as TimeZeno).
+	// 数据库会话统一用UTC，按用户时区的换算在service里做
 	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=Asia/Shanghai",
+		"host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=UTC",
 		host, user, password, dbname, port,
 	)
 
@@ -46,9 +47,45 @@ func NewPostgresDB() *gorm.DB {
 		log.Fatalf("failed to connect database: %v", err)
 	}
 
-	if err := db.AutoMigrate(&TaskModel{}, &UserModel{}); err != nil {
+	if err := db.AutoMigrate(
+		&WorkspaceModel{},
+		&WorkspaceMemberModel{},
+		&WorkspaceInviteModel{},
+		&GroupModel{},
+		&GroupShareModel{},
+		&TaskModel{},
+		&TaskAssigneeModel{},
+		&TaskTagModel{},
+		&CalDAVRemovalModel{},
+		&CommentModel{},
+		&MentionModel{},
+		&NotificationModel{},
+		&ActivityModel{},
+		&ShareLinkModel{},
+		&CalendarFeedModel{},
+		&ViewModel{},
+		&ViewPinModel{},
+		&UserModel{},
+		&AccessTokenModel{},
+		&RecoveryCodeModel{},
+		&LoginAttemptModel{},
+		&SessionModel{},
+		&PasswordResetModel{},
+		&AdminAuditModel{},
+	); err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
+	if err := migrateWorkspaces(db); err != nil {
+		log.Fatalf("failed to migrate workspaces: %v", err)
+	}
+	if err := migrateTasks(db); err != nil {
+		log.Fatalf("failed to migrate tasks: %v", err)
+	}
 
 	return db
 }
+
+// isUniqueViolation 判断是否违反唯一约束（Postgres错误码23505）
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "SQLSTATE 23505")
+}
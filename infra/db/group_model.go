@@ -4,9 +4,15 @@ import "time"
 
 type GroupModel struct {
 	ID int64 `gorm:"primaryKey;autoIncrement"`
-	// 联合唯一索引：确保同一个用户下，name不重复
-	UserID int64 `gorm:"not null;index:idx_users_name,unique"`
-	Name string `gorm:"type:varchar(50);not null;index:idx_users_name,unique"`
+	// 创建分组的用户，创建者注销后为NULL
+	UserID *int64 `gorm:"index"`
+	// 联合唯一索引：确保同一个工作区下，name不重复（老数据迁移前为NULL）
+	WorkspaceID *int64 `gorm:"index:idx_groups_workspace_name,unique"`
+	Name string `gorm:"type:varchar(50);not null;index:idx_groups_workspace_name,unique"`
+	// 自定义工作流的JSON，NULL表示默认工作流
+	Workflow *string `gorm:"type:jsonb"`
+	// CalDAV同步用的变更序号，只由tasks上的触发器修改
+	ChangeSeq int64 `gorm:"->;not null;default:0"`
 
 	CreatedAt time.Time `gorm:"not null"`
 	UpdatedAt time.Time `gorm:"not null"`
@@ -14,4 +20,18 @@ type GroupModel struct {
 
 func (GroupModel) TableName() string {
 	return "groups"
-}
\ No newline at end of file
+}
+
+// GroupShareModel 分组共享，(group_id, user_id)为主键
+type GroupShareModel struct {
+	GroupID    int64     `gorm:"primaryKey"`
+	UserID     int64     `gorm:"primaryKey;index"`
+	Permission string    `gorm:"type:varchar(10);not null"`
+	GrantedBy  int64     `gorm:"not null"`
+	CreatedAt  time.Time `gorm:"not null"`
+	UpdatedAt  time.Time `gorm:"not null"`
+}
+
+func (GroupShareModel) TableName() string {
+	return "group_shares"
+}
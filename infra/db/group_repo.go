@@ -2,10 +2,14 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"tasker/core/group"
+	"tasker/core/sharelink"
 	"tasker/pkg/apperror"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GroupRepository struct {
@@ -17,28 +21,61 @@ func NewGroupRepository(db *gorm.DB) *GroupRepository {
 }
 
 func groupToDomain(m *GroupModel) *group.Group {
+	var workspaceID int64
+	if m.WorkspaceID != nil {
+		workspaceID = *m.WorkspaceID
+	}
 	return &group.Group{
-		ID:        m.ID,
-		UserID:    m.UserID,
-		Name:      m.Name,
+		ID:          m.ID,
+		UserID:      derefUserID(m.UserID),
+		WorkspaceID: workspaceID,
+		Name:        m.Name,
+		Workflow:    decodeWorkflow(m.Workflow),
 		CreatedAt: m.CreatedAt,
 		UpdatedAt: m.UpdatedAt,
 	}
 }
 
 func groupToModel(t *group.Group) *GroupModel {
+	workspaceID := t.WorkspaceID
 	return &GroupModel{
-		ID:        t.ID,
-		UserID:    t.UserID,
-		Name:      t.Name,
+		ID:          t.ID,
+		UserID:      nullUserID(t.UserID),
+		WorkspaceID: &workspaceID,
+		Name:        t.Name,
+		Workflow:    encodeWorkflow(t.Workflow),
 		CreatedAt: t.CreatedAt,
 		UpdatedAt: t.UpdatedAt,
 	}
 }
 
-func (r *GroupRepository) GetByID(ctx context.Context, userID int64, ID int64) (*group.Group, error) {
+// decodeWorkflow 列里的JSON坏掉时按默认工作流处理
+func decodeWorkflow(raw *string) *group.Workflow {
+	if raw == nil {
+		return nil
+	}
+	var w group.Workflow
+	if err := json.Unmarshal([]byte(*raw), &w); err != nil || len(w.States) == 0 {
+		return nil
+	}
+	return &w
+}
+
+func encodeWorkflow(w *group.Workflow) *string {
+	if w == nil {
+		return nil
+	}
+	buf, err := json.Marshal(w)
+	if err != nil {
+		return nil
+	}
+	raw := string(buf)
+	return &raw
+}
+
+func (r *GroupRepository) GetByID(ctx context.Context, ID int64) (*group.Group, error) {
 	var m GroupModel
-	tx := r.db.WithContext(ctx).Where("user_id = ? and id = ?", userID, ID).First(&m)
+	tx := r.db.WithContext(ctx).Where("id = ?", ID).First(&m)
 	if tx.Error != nil {
 		if tx.Error == gorm.ErrRecordNotFound {
 			return nil, apperror.New("GROUP_NOT_FOUND", "group not found")
@@ -51,6 +88,9 @@ func (r *GroupRepository) GetByID(ctx context.Context, userID int64, ID int64) (
 func (r *GroupRepository) Create(ctx context.Context, group *group.Group) error {
 	m := groupToModel(group)
 	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		if isUniqueViolation(err) {
+			return apperror.New("GROUP_EXISTS", "group name already exists in this workspace")
+		}
 		return apperror.New("DB_ERROR", "failed to create group")
 	}
 
@@ -58,9 +98,9 @@ func (r *GroupRepository) Create(ctx context.Context, group *group.Group) error
 	return nil
 }
 
-func (r *GroupRepository) GetByUserIDAndName(ctx context.Context, userID int64, name string) (*group.Group, error) {
+func (r *GroupRepository) GetByWorkspaceAndName(ctx context.Context, workspaceID int64, name string) (*group.Group, error) {
 	var m GroupModel
-	tx := r.db.WithContext(ctx).Where("user_id = ? and name = ?", userID, name).First(&m)
+	tx := r.db.WithContext(ctx).Where("workspace_id = ? and name = ?", workspaceID, name).First(&m)
 	if tx.Error != nil {
 		if tx.Error == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -68,4 +108,222 @@ func (r *GroupRepository) GetByUserIDAndName(ctx context.Context, userID int64,
 		return nil, apperror.New("DB_ERROR", "failed to get group")
 	}
 	return groupToDomain(&m), nil
-}
\ No newline at end of file
+}
+func (r *GroupRepository) Delete(ctx context.Context, ID int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", ID).Delete(&GroupShareModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("target_type = ? AND target_id = ?", sharelink.TargetGroup, ID).Delete(&ShareLinkModel{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", ID).Delete(&GroupModel{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("GROUP_NOT_FOUND", "group not found")
+		}
+		return apperror.New("DB_ERROR", "failed to delete group")
+	}
+	return nil
+}
+
+func (r *GroupRepository) Update(ctx context.Context, g *group.Group) (*group.Group, error) {
+	tx := r.db.WithContext(ctx).Model(&GroupModel{}).Where("id = ?", g.ID).Updates(map[string]any{
+		"name":       g.Name,
+		"updated_at": g.UpdatedAt,
+	})
+	if tx.Error != nil {
+		return nil, apperror.New("DB_ERROR", "failed to update group")
+	}
+	if tx.RowsAffected == 0 {
+		return nil, apperror.New("GROUP_NOT_FOUND", "group not found")
+	}
+	return g, nil
+}
+
+func (r *GroupRepository) UpdateWorkflow(ctx context.Context, g *group.Group, remap map[string]group.State) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&GroupModel{}).Where("id = ?", g.ID).Updates(map[string]any{
+			"workflow":   encodeWorkflow(g.Workflow),
+			"updated_at": g.UpdatedAt,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		for from, to := range remap {
+			if err := tx.Model(&TaskModel{}).Where("group_id = ? AND status = ?", g.ID, from).
+				Updates(map[string]any{"status": to.Key, "updated_at": g.UpdatedAt}).Error; err != nil {
+				return err
+			}
+		}
+		// 保留的状态可能换了大类，进入done的记下完成时间，离开done的清空
+		for _, st := range group.WorkflowOf(g).States {
+			completedAt := gorm.Expr("NULL")
+			if st.Category == group.CategoryDone {
+				completedAt = gorm.Expr("COALESCE(completed_at, ?)", g.UpdatedAt)
+			}
+			if err := tx.Model(&TaskModel{}).Where("group_id = ? AND status = ? AND status_category <> ?", g.ID, st.Key, string(st.Category)).
+				Updates(map[string]any{
+					"status_category": string(st.Category),
+					"completed_at":    completedAt,
+					"updated_at":      g.UpdatedAt,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("GROUP_NOT_FOUND", "group not found")
+		}
+		return apperror.New("DB_ERROR", "failed to update workflow")
+	}
+	return nil
+}
+
+func (r *GroupRepository) GetListByName(ctx context.Context, userID int64, name string) (*[]group.Group, error) {
+	var models []GroupModel
+	if err := r.db.WithContext(ctx).Where(accessibleGroups(r.db, userID)).Where("name ILIKE ?", "%"+name+"%").Order("id ASC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list groups")
+	}
+	return groupsToDomain(models), nil
+}
+
+func (r *GroupRepository) GetListByUserID(ctx context.Context, userID int64) (*[]group.Group, error) {
+	var models []GroupModel
+	if err := r.db.WithContext(ctx).Where(accessibleGroups(r.db, userID)).Order("id ASC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list groups")
+	}
+	return groupsToDomain(models), nil
+}
+
+func groupsToDomain(models []GroupModel) *[]group.Group {
+	groups := make([]group.Group, 0, len(models))
+	for i := range models {
+		groups = append(groups, *groupToDomain(&models[i]))
+	}
+	return &groups
+}
+
+// sharedGroupIDs 共享给用户的分组ID子查询
+func sharedGroupIDs(db *gorm.DB, userID int64) *gorm.DB {
+	return db.Model(&GroupShareModel{}).Select("group_id").Where("user_id = ?", userID)
+}
+
+// accessibleGroups 用户所在工作区的分组，加上共享给用户的分组
+func accessibleGroups(db *gorm.DB, userID int64) *gorm.DB {
+	return db.Where("workspace_id IN (?)", memberWorkspaceIDs(db, userID)).
+		Or("id IN (?)", sharedGroupIDs(db, userID))
+}
+
+// 共享列表带上用户名
+type shareWithUsername struct {
+	GroupShareModel
+	Username string
+}
+
+func shareToDomain(m *GroupShareModel, username string) *group.Share {
+	return &group.Share{
+		GroupID:    m.GroupID,
+		UserID:     m.UserID,
+		Username:   username,
+		Permission: group.Permission(m.Permission),
+		GrantedBy:  m.GrantedBy,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+	}
+}
+
+func (r *GroupRepository) GetShare(ctx context.Context, groupID, userID int64) (*group.Share, error) {
+	var m GroupShareModel
+	tx := r.db.WithContext(ctx).Where("group_id = ? AND user_id = ?", groupID, userID).Limit(1).Find(&m)
+	if tx.Error != nil {
+		return nil, apperror.New("DB_ERROR", "failed to get share")
+	}
+	if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+	return shareToDomain(&m, ""), nil
+}
+
+func (r *GroupRepository) UpsertShare(ctx context.Context, share *group.Share) error {
+	var username string
+	tx := r.db.WithContext(ctx).Model(&UserModel{}).Select("username").Where("id = ?", share.UserID).Limit(1).Scan(&username)
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to share group")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("USER_NOT_FOUND", "user not found")
+	}
+
+	m := GroupShareModel{
+		GroupID:    share.GroupID,
+		UserID:     share.UserID,
+		Permission: string(share.Permission),
+		GrantedBy:  share.GrantedBy,
+		CreatedAt:  share.CreatedAt,
+		UpdatedAt:  share.UpdatedAt,
+	}
+	// 已共享时只改权限，保留原来的创建时间
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permission", "granted_by", "updated_at"}),
+	}).Create(&m).Error
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to share group")
+	}
+	share.Username = username
+	return nil
+}
+
+func (r *GroupRepository) ListShares(ctx context.Context, groupID int64) ([]*group.Share, error) {
+	var rows []shareWithUsername
+	err := r.db.WithContext(ctx).Table("group_shares").
+		Select("group_shares.*, users.username").
+		Joins("LEFT JOIN users ON users.id = group_shares.user_id").
+		Where("group_shares.group_id = ?", groupID).
+		Order("group_shares.created_at ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list shares")
+	}
+	items := make([]*group.Share, 0, len(rows))
+	for i := range rows {
+		items = append(items, shareToDomain(&rows[i].GroupShareModel, rows[i].Username))
+	}
+	return items, nil
+}
+
+func (r *GroupRepository) DeleteShare(ctx context.Context, groupID, userID int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&GroupShareModel{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// 失去访问权后不再负责这个分组里的任务
+		tasks := tx.Model(&TaskModel{}).Select("id").Where("group_id = ?", groupID)
+		return tx.Where("user_id = ? AND task_id IN (?)", userID, tasks).Delete(&TaskAssigneeModel{}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("SHARE_NOT_FOUND", "share not found")
+		}
+		return apperror.New("DB_ERROR", "failed to revoke share")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package db
+
+// user.LimiterStore 的Postgres实现，多副本共享同一份失败计数
+
+import (
+	"context"
+	"time"
+
+	"tasker/core/user"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type LimiterStore struct {
+	db *gorm.DB
+}
+
+func NewLimiterStore(db *gorm.DB) *LimiterStore {
+	return &LimiterStore{db: db}
+}
+
+func (s *LimiterStore) Get(ctx context.Context, key string) (*user.AttemptState, error) {
+	var m LoginAttemptModel
+	tx := s.db.WithContext(ctx).Where("attempt_key = ?", key).Limit(1).Find(&m)
+	if tx.Error != nil {
+		return nil, apperror.New("DB_ERROR", "failed to get login attempts")
+	}
+	if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &user.AttemptState{
+		Failures:      m.Failures,
+		LastFailureAt: m.LastFailureAt,
+		LockedUntil:   m.LockedUntil,
+	}, nil
+}
+
+func (s *LimiterStore) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*user.AttemptState, error) {
+	// 一条upsert完成计数，多副本并发失败时不会丢计数
+	var m LoginAttemptModel
+	err := s.db.WithContext(ctx).Raw(`
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING attempt_key, failures, last_failure_at, locked_until`,
+		key, at, resetBefore,
+	).Scan(&m).Error
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to record login attempt")
+	}
+	return &user.AttemptState{
+		Failures:      m.Failures,
+		LastFailureAt: m.LastFailureAt,
+		LockedUntil:   m.LockedUntil,
+	}, nil
+}
+
+func (s *LimiterStore) SetLockedUntil(ctx context.Context, key string, until time.Time) error {
+	err := s.db.WithContext(ctx).Model(&LoginAttemptModel{}).
+		Where("attempt_key = ?", key).
+		Update("locked_until", until).Error
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to lock login")
+	}
+	return nil
+}
+
+func (s *LimiterStore) Reset(ctx context.Context, key string) error {
+	if err := s.db.WithContext(ctx).Where("attempt_key = ?", key).Delete(&LoginAttemptModel{}).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to reset login attempts")
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package db
+
+import "time"
+
+// LoginAttemptModel 登录失败计数，key形如 user:<name> / ip:<addr> / mfa:<id>
+type LoginAttemptModel struct {
+	AttemptKey    string    `gorm:"type:varchar(255);primaryKey"`
+	Failures      int       `gorm:"not null;default:0"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+}
+
+func (LoginAttemptModel) TableName() string {
+	return "login_attempts"
+}
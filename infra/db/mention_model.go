@@ -0,0 +1,16 @@
+package db
+
+import "time"
+
+// MentionModel 任务描述或评论里的@提及，task_id冗余保存方便随任务一起删除
+type MentionModel struct {
+	SourceType string    `gorm:"type:varchar(20);primaryKey"`
+	SourceID   int64     `gorm:"primaryKey"`
+	UserID     int64     `gorm:"primaryKey;index"`
+	TaskID     int64     `gorm:"not null;index"`
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+func (MentionModel) TableName() string {
+	return "mentions"
+}
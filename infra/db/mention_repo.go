@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"tasker/core/mention"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type MentionRepository struct {
+	db *gorm.DB
+}
+
+func NewMentionRepository(db *gorm.DB) *MentionRepository {
+	return &MentionRepository{db: db}
+}
+
+func (r *MentionRepository) ResolveUsernames(ctx context.Context, names []string) ([]mention.Mention, error) {
+	var users []UserModel
+	if err := r.db.WithContext(ctx).Select("id", "username").Where("username IN ?", names).Find(&users).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to resolve mentions")
+	}
+	result := make([]mention.Mention, 0, len(users))
+	for _, u := range users {
+		result = append(result, mention.Mention{UserID: u.ID, Username: u.Username})
+	}
+	return result, nil
+}
+
+// Replace 只增删有变化的记录，已有提及的创建时间保持不变
+func (r *MentionRepository) Replace(ctx context.Context, src mention.Source, userIDs []int64, at time.Time) ([]int64, error) {
+	var added []int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []int64
+		if err := tx.Model(&MentionModel{}).
+			Where("source_type = ? AND source_id = ?", src.Type, src.ID).
+			Pluck("user_id", &existing).Error; err != nil {
+			return err
+		}
+
+		keep := make(map[int64]struct{}, len(userIDs))
+		for _, id := range userIDs {
+			keep[id] = struct{}{}
+		}
+		old := make(map[int64]struct{}, len(existing))
+		var removed []int64
+		for _, id := range existing {
+			old[id] = struct{}{}
+			if _, ok := keep[id]; !ok {
+				removed = append(removed, id)
+			}
+		}
+		rows := make([]MentionModel, 0, len(userIDs))
+		for _, id := range userIDs {
+			if _, ok := old[id]; ok {
+				continue
+			}
+			added = append(added, id)
+			rows = append(rows, MentionModel{SourceType: src.Type, SourceID: src.ID, UserID: id, TaskID: src.TaskID, CreatedAt: at})
+		}
+
+		if len(removed) > 0 {
+			if err := tx.Where("source_type = ? AND source_id = ? AND user_id IN ?", src.Type, src.ID, removed).
+				Delete(&MentionModel{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(rows) > 0 {
+			return tx.Create(&rows).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to save mentions")
+	}
+	return added, nil
+}
+
+// 列表带上当前用户名，改名后也能正确显示
+type mentionWithUsername struct {
+	MentionModel
+	Username string
+}
+
+func (r *MentionRepository) List(ctx context.Context, sourceType string, sourceIDs []int64) (map[int64][]mention.Mention, error) {
+	var rows []mentionWithUsername
+	err := r.db.WithContext(ctx).Model(&MentionModel{}).
+		Select("mentions.*, users.username").
+		Joins("JOIN users ON users.id = mentions.user_id").
+		Where("mentions.source_type = ? AND mentions.source_id IN ?", sourceType, sourceIDs).
+		Order("mentions.created_at ASC, mentions.user_id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list mentions")
+	}
+	result := make(map[int64][]mention.Mention, len(sourceIDs))
+	for _, row := range rows {
+		result[row.SourceID] = append(result[row.SourceID], mention.Mention{UserID: row.UserID, Username: row.Username})
+	}
+	return result, nil
+}
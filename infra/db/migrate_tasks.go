@@ -0,0 +1,112 @@
+package db
+
+import "gorm.io/gorm"
+
+/*
+migrateTasks 回填任务上新加的冗余列，建立ORM建不了的索引和生成列，可以重复执行
+*/
+func migrateTasks(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		steps := []string{
+			// 工作流之前只有 pending/completed，completed 在任何工作流里都是done大类
+			`UPDATE tasks SET status_category = 'done' WHERE status = 'completed' AND status_category <> 'done'`,
+
+			// 完成时间之前没有记录，已完成的任务用最后修改时间近似
+			`UPDATE tasks SET completed_at = updated_at WHERE status_category = 'done' AND completed_at IS NULL`,
+
+			// 导入按外部ID更新，只约束有外部ID的任务
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_workspace_external ON tasks (workspace_id, external_id) WHERE external_id IS NOT NULL`,
+
+			// 看板按列读取，rank按字节序比较
+			`CREATE INDEX IF NOT EXISTS idx_tasks_group_status_rank ON tasks (group_id, status, rank COLLATE "C")`,
+
+			// 列表每种排序一个复合索引，列和方向同sortKeys（CASE即statusOrder），游标分页按索引顺序往后读。
+			// 列表总是限定在用户的工作区里，workspace_id放在最前；rank排序配合group_id使用，分组放在最前
+			`DROP INDEX IF EXISTS idx_tasks_created_id`,
+			`DROP INDEX IF EXISTS idx_tasks_status_order`,
+			`DROP INDEX IF EXISTS idx_tasks_completed_id`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_workspace_created_id ON tasks (workspace_id, created_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_workspace_status_order ON tasks (workspace_id, (CASE status_category WHEN 'todo' THEN 0 WHEN 'doing' THEN 1 ELSE 2 END), status, created_at DESC, id DESC)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_group_rank_id ON tasks (group_id, rank COLLATE "C", id)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_workspace_completed_id ON tasks (workspace_id, completed_at DESC NULLS LAST, id DESC)`,
+
+			// 没有rank的老任务按创建时间排在分组里，格式同rebalanceRanksSQL
+			`UPDATE tasks t SET rank = r.rank FROM (
+				SELECT id, lpad(to_hex(row_number() OVER (PARTITION BY group_id ORDER BY created_at, id)), 8, '0') || 'V' AS rank
+				FROM tasks) r
+			 WHERE t.id = r.id AND t.rank = ''`,
+
+			// 全文检索：中日文没有空格分词，tasker_segment把每个汉字、假名拆成单独的词，
+			// 查询时再把连续的汉字写成短语，见searchQuery
+			`CREATE OR REPLACE FUNCTION tasker_segment(text) RETURNS text
+				LANGUAGE sql IMMUTABLE PARALLEL SAFE
+				AS $$ SELECT regexp_replace(coalesce($1, ''), '(` + cjkClass + `)', ' \1 ', 'g') $$`,
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('` + searchConfig + `', tasker_segment(title)), 'A') ||
+				setweight(to_tsvector('` + searchConfig + `', tasker_segment(description)), 'B')
+			) STORED`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN (search_vector)`,
+
+			// 全文检索没有结果时按标题的三元组相似度模糊匹配
+			`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_title_trgm ON tasks USING GIN (title gin_trgm_ops)`,
+
+			// 按标签筛选不区分大小写
+			`CREATE INDEX IF NOT EXISTS idx_task_tags_lower_tag ON task_tags (lower(tag), task_id)`,
+
+			// CalDAV同步令牌：任务新增、修改、删除或移入移出时给分组的change_seq加一并记到任务上，
+			// 离开分组（删除、移走、换外部ID）的记到caldav_removals。加一会锁住分组那一行直到提交，
+			// 所以同一个分组的序号按提交顺序递增，读到序号N时N和之前的变化都已经可见。
+			// 只改rank（排序、重新编号）不影响日历数据，不加一
+			`CREATE OR REPLACE FUNCTION tasker_task_changed() RETURNS trigger
+				LANGUAGE plpgsql AS $$
+			DECLARE
+				seq bigint;
+				left_group boolean := false;
+			BEGIN
+				IF TG_OP = 'DELETE' THEN
+					left_group := true;
+				ELSIF TG_OP = 'UPDATE' THEN
+					left_group := OLD.group_id IS DISTINCT FROM NEW.group_id OR OLD.external_id IS DISTINCT FROM NEW.external_id;
+				END IF;
+				IF left_group THEN
+					IF OLD.group_id IS NOT NULL THEN
+						-- 分组正在被删除时找不到，不用记录
+						UPDATE groups SET change_seq = change_seq + 1 WHERE id = OLD.group_id RETURNING change_seq INTO seq;
+						IF FOUND THEN
+							INSERT INTO caldav_removals (group_id, seq, task_id, external_id, created_at)
+							VALUES (OLD.group_id, seq, OLD.id, OLD.external_id, now());
+						END IF;
+					END IF;
+				END IF;
+				IF TG_OP = 'DELETE' THEN
+					RETURN OLD;
+				END IF;
+				IF NEW.group_id IS NOT NULL THEN
+					UPDATE groups SET change_seq = change_seq + 1 WHERE id = NEW.group_id RETURNING change_seq INTO seq;
+					IF FOUND THEN
+						NEW.change_seq := seq;
+					END IF;
+				END IF;
+				RETURN NEW;
+			END $$`,
+			`DROP TRIGGER IF EXISTS tasks_change_seq ON tasks`,
+			`CREATE TRIGGER tasks_change_seq BEFORE INSERT OR DELETE ON tasks
+				FOR EACH ROW EXECUTE FUNCTION tasker_task_changed()`,
+			`DROP TRIGGER IF EXISTS tasks_change_seq_update ON tasks`,
+			`CREATE TRIGGER tasks_change_seq_update BEFORE UPDATE ON tasks FOR EACH ROW
+				WHEN ((OLD.group_id, OLD.external_id, OLD.title, OLD.description, OLD.status, OLD.status_category,
+					OLD.priority, OLD.due_data, OLD.due_on, OLD.completed_at, OLD.created_at, OLD.updated_at)
+				IS DISTINCT FROM (NEW.group_id, NEW.external_id, NEW.title, NEW.description, NEW.status, NEW.status_category,
+					NEW.priority, NEW.due_data, NEW.due_on, NEW.completed_at, NEW.created_at, NEW.updated_at))
+				EXECUTE FUNCTION tasker_task_changed()`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_group_change ON tasks (group_id, change_seq)`,
+		}
+		for _, sql := range steps {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package db
+
+import "gorm.io/gorm"
+
+/*
+migrateWorkspaces 把工作区出现之前的数据放进每个用户的个人工作区。
+每一步都可以重复执行，已经迁移过的行不会再动
+*/
+func migrateWorkspaces(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		steps := []string{
+			// 每个用户最多一个个人工作区
+			`CREATE UNIQUE INDEX IF NOT EXISTS idx_workspaces_personal_owner ON workspaces (owner_id) WHERE personal`,
+
+			// 给还没有个人工作区的用户建一个
+			`INSERT INTO workspaces (name, owner_id, personal, created_at, updated_at)
+			 SELECT '个人', u.id, true, now(), now() FROM users u
+			 WHERE NOT EXISTS (SELECT 1 FROM workspaces w WHERE w.owner_id = u.id AND w.personal)`,
+
+			`INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+			 SELECT w.id, w.owner_id, 'owner', now() FROM workspaces w
+			 WHERE w.personal
+			 ON CONFLICT DO NOTHING`,
+
+			// 老的分组和任务归到创建者的个人工作区
+			`UPDATE groups g SET workspace_id = w.id FROM workspaces w
+			 WHERE g.workspace_id IS NULL AND w.personal AND w.owner_id = g.user_id`,
+
+			`UPDATE tasks t SET workspace_id = w.id FROM workspaces w
+			 WHERE t.workspace_id IS NULL AND w.personal AND w.owner_id = t.user_id`,
+
+			// 分组名改为在工作区内唯一，去掉原来按用户的唯一索引
+			`DROP INDEX IF EXISTS idx_users_name`,
+		}
+		for _, sql := range steps {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package db
+
+import "time"
+
+// NotificationModel 站内通知
+type NotificationModel struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement"`
+	UserID      int64  `gorm:"not null;index:idx_notifications_user_created"`
+	Type        string `gorm:"type:varchar(50);not null"`
+	WorkspaceID int64  `gorm:"not null;index"`
+	ActorID     int64  `gorm:"not null"`
+	TaskID      *int64 `gorm:"index"`
+	Title       string `gorm:"type:varchar(255);not null;default:''"`
+	ReadAt      *time.Time
+	CreatedAt   time.Time `gorm:"not null;index:idx_notifications_user_created"`
+}
+
+func (NotificationModel) TableName() string {
+	return "notifications"
+}
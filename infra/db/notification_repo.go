@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"tasker/core/notification"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func notificationToDomain(m *NotificationModel) *notification.Notification {
+	return &notification.Notification{
+		ID:          m.ID,
+		UserID:      m.UserID,
+		Type:        m.Type,
+		WorkspaceID: m.WorkspaceID,
+		ActorID:     m.ActorID,
+		TaskID:      m.TaskID,
+		Title:       m.Title,
+		ReadAt:      m.ReadAt,
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+func (r *NotificationRepository) CreateMany(ctx context.Context, items []*notification.Notification) error {
+	models := make([]NotificationModel, 0, len(items))
+	for _, n := range items {
+		models = append(models, NotificationModel{
+			UserID:      n.UserID,
+			Type:        n.Type,
+			WorkspaceID: n.WorkspaceID,
+			ActorID:     n.ActorID,
+			TaskID:      n.TaskID,
+			Title:       n.Title,
+			CreatedAt:   n.CreatedAt,
+		})
+	}
+	if err := r.db.WithContext(ctx).Create(&models).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create notifications")
+	}
+	for i := range models {
+		items[i].ID = models[i].ID
+	}
+	return nil
+}
+
+func (r *NotificationRepository) List(ctx context.Context, userID int64, filter notification.ListFilter) ([]*notification.Notification, error) {
+	db := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if filter.UnreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	var models []NotificationModel
+	if err := db.Order("created_at DESC, id DESC").Limit(filter.Limit).Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list notifications")
+	}
+	items := make([]*notification.Notification, 0, len(models))
+	for i := range models {
+		items = append(items, notificationToDomain(&models[i]))
+	}
+	return items, nil
+}
+
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID int64) (int64, error) {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&NotificationModel{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&n).Error; err != nil {
+		return 0, apperror.New("DB_ERROR", "failed to count notifications")
+	}
+	return n, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID, id int64, at time.Time) error {
+	var m NotificationModel
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&m).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return apperror.New("NOTIFICATION_NOT_FOUND", "notification not found")
+		}
+		return apperror.New("DB_ERROR", "failed to get notification")
+	}
+	if m.ReadAt != nil {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Model(&NotificationModel{}).Where("id = ?", id).Update("read_at", at).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to update notification")
+	}
+	return nil
+}
+
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID int64, at time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&NotificationModel{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", at).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to update notifications")
+	}
+	return nil
+}
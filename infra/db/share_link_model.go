@@ -0,0 +1,23 @@
+package db
+
+import "time"
+
+// ShareLinkModel 公开只读链接，只保存token的sha256
+type ShareLinkModel struct {
+	ID             int64  `gorm:"primaryKey;autoIncrement"`
+	TokenHash      string `gorm:"type:char(64);not null;uniqueIndex"`
+	TargetType     string `gorm:"type:varchar(20);not null;index:idx_share_links_target"`
+	TargetID       int64  `gorm:"not null;index:idx_share_links_target"`
+	WorkspaceID    int64  `gorm:"not null;index"`
+	CreatedBy      int64  `gorm:"not null;index"`
+	PasswordHash   string `gorm:"type:varchar(255);not null;default:''"`
+	ExpiresAt      *time.Time
+	RevokedAt      *time.Time
+	ViewCount      int64 `gorm:"not null;default:0"`
+	LastAccessedAt *time.Time
+	CreatedAt      time.Time `gorm:"not null"`
+}
+
+func (ShareLinkModel) TableName() string {
+	return "share_links"
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tasker/core/sharelink"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type ShareLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewShareLinkRepository(db *gorm.DB) *ShareLinkRepository {
+	return &ShareLinkRepository{db: db}
+}
+
+func shareLinkToDomain(m *ShareLinkModel) *sharelink.Link {
+	return &sharelink.Link{
+		ID:             m.ID,
+		TargetType:     m.TargetType,
+		TargetID:       m.TargetID,
+		WorkspaceID:    m.WorkspaceID,
+		CreatedBy:      m.CreatedBy,
+		Hash:           m.TokenHash,
+		PasswordHash:   m.PasswordHash,
+		HasPassword:    m.PasswordHash != "",
+		ExpiresAt:      m.ExpiresAt,
+		RevokedAt:      m.RevokedAt,
+		ViewCount:      m.ViewCount,
+		LastAccessedAt: m.LastAccessedAt,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+func (r *ShareLinkRepository) Create(ctx context.Context, l *sharelink.Link) error {
+	m := ShareLinkModel{
+		TokenHash:    l.Hash,
+		TargetType:   l.TargetType,
+		TargetID:     l.TargetID,
+		WorkspaceID:  l.WorkspaceID,
+		CreatedBy:    l.CreatedBy,
+		PasswordHash: l.PasswordHash,
+		ExpiresAt:    l.ExpiresAt,
+		CreatedAt:    l.CreatedAt,
+	}
+	if err := r.db.WithContext(ctx).Create(&m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create share link")
+	}
+	l.ID = m.ID
+	return nil
+}
+
+func (r *ShareLinkRepository) ListByCreator(ctx context.Context, userID int64) ([]*sharelink.Link, error) {
+	return r.list(r.db.WithContext(ctx).Where("created_by = ?", userID))
+}
+
+func (r *ShareLinkRepository) ListByTarget(ctx context.Context, targetType string, targetID int64) ([]*sharelink.Link, error) {
+	return r.list(r.db.WithContext(ctx).Where("target_type = ? AND target_id = ?", targetType, targetID))
+}
+
+// list 新创建的在前
+func (r *ShareLinkRepository) list(db *gorm.DB) ([]*sharelink.Link, error) {
+	var models []ShareLinkModel
+	if err := db.Order("created_at DESC, id DESC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list share links")
+	}
+	links := make([]*sharelink.Link, 0, len(models))
+	for i := range models {
+		links = append(links, shareLinkToDomain(&models[i]))
+	}
+	return links, nil
+}
+
+// GetByID 找不到时返回nil
+func (r *ShareLinkRepository) GetByID(ctx context.Context, id int64) (*sharelink.Link, error) {
+	var m ShareLinkModel
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get share link")
+	}
+	return shareLinkToDomain(&m), nil
+}
+
+// GetByHash 找不到时返回nil
+func (r *ShareLinkRepository) GetByHash(ctx context.Context, hash string) (*sharelink.Link, error) {
+	var m ShareLinkModel
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get share link")
+	}
+	return shareLinkToDomain(&m), nil
+}
+
+// Revoke 重复撤销保留第一次的时间
+func (r *ShareLinkRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&ShareLinkModel{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at).Error
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to revoke share link")
+	}
+	return nil
+}
+
+func (r *ShareLinkRepository) RecordView(ctx context.Context, id int64, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&ShareLinkModel{}).Where("id = ?", id).Updates(map[string]any{
+		"view_count":       gorm.Expr("view_count + 1"),
+		"last_accessed_at": at,
+	}).Error
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to record share link view")
+	}
+	return nil
+}
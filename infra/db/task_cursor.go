@@ -0,0 +1,262 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"tasker/core/group"
+	"tasker/core/task"
+)
+
+/*
+任务列表的排序和游标分页。每种排序是一串排序键，最后是id，保证顺序唯一；
+游标记下页面边上那个任务的各个键，下一页只取排在它后面的任务（keyset），
+不用OFFSET，翻页期间有任务增删也不会跳过或重复。
+排序键和migrateTasks里建的复合索引一一对应，改这里要同时改索引
+*/
+
+// statusOrder 按大类排序：todo → doing → done
+const statusOrder = "CASE tasks.status_category WHEN 'todo' THEN 0 WHEN 'doing' THEN 1 ELSE 2 END"
+
+// sortKey 排序键。expr是常量SQL，只有相关度带参数（vars）
+type sortKey struct {
+	expr string
+	vars []any
+	desc bool
+	// 可能为NULL，NULL排在最后
+	nullable bool
+	// 游标上这个键的取值，nil表示NULL
+	value func(c *task.Cursor) any
+}
+
+func timeValue(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+func idKey(desc bool) sortKey {
+	return sortKey{expr: "tasks.id", desc: desc, value: func(c *task.Cursor) any { return c.ID }}
+}
+
+func createdKey(desc bool) sortKey {
+	return sortKey{expr: "tasks.created_at", desc: desc, value: func(c *task.Cursor) any { return timeValue(c.CreatedAt) }}
+}
+
+// sortKeys 各种排序的排序键，search为nil时relevance按默认排序
+func sortKeys(sort string, search *taskSearch) []sortKey {
+	switch sort {
+	case "created_asc":
+		return []sortKey{createdKey(false), idKey(false)}
+	case "status":
+		// 同一大类里再按状态key，然后是新的在前
+		return []sortKey{
+			{expr: statusOrder, value: func(c *task.Cursor) any { return categoryOrder(c.StatusCategory) }},
+			{expr: "tasks.status", value: func(c *task.Cursor) any { return string(c.Status) }},
+			createdKey(true),
+			idKey(true),
+		}
+	case "rank":
+		// 看板顺序，配合group_id使用才有意义
+		return []sortKey{
+			{expr: "tasks." + rankOrder, value: func(c *task.Cursor) any { return c.Rank }},
+			idKey(false),
+		}
+	case "completed_desc":
+		// 没完成的排在最后
+		return []sortKey{
+			{expr: "tasks.completed_at", desc: true, nullable: true, value: func(c *task.Cursor) any { return timeValue(c.CompletedAt) }},
+			idKey(true),
+		}
+	case "relevance":
+		if search != nil {
+			return []sortKey{
+				{expr: search.rank, vars: []any{search.arg}, desc: true, value: func(c *task.Cursor) any { return c.Score }},
+				idKey(true),
+			}
+		}
+	}
+	return []sortKey{createdKey(true), idKey(true)}
+}
+
+func categoryOrder(c group.Category) int {
+	switch c {
+	case group.CategoryTodo:
+		return 0
+	case group.CategoryDoing:
+		return 1
+	}
+	return 2
+}
+
+// orderExpr ORDER BY子句，backward时整体反过来，取游标之前的一页
+func orderExpr(keys []sortKey, backward bool) clause.OrderBy {
+	parts := make([]string, 0, len(keys))
+	var vars []any
+	for _, k := range keys {
+		part := k.expr + " ASC"
+		if k.desc != backward {
+			part = k.expr + " DESC"
+		}
+		if k.nullable {
+			if backward {
+				part += " NULLS FIRST"
+			} else {
+				part += " NULLS LAST"
+			}
+		}
+		parts = append(parts, part)
+		vars = append(vars, k.vars...)
+	}
+	return clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(parts, ", "), Vars: vars, WithoutParentheses: true}}
+}
+
+// keysetExpr 排在游标之后（Backward时是之前）的任务。相邻的同方向键合成一组，
+// 组内用行比较 (a, b) < (?, ?)，和复合索引的顺序一致；
+// 组之间是前面的组都相等、当前组更靠后，对每组取OR。
+// Inclusive时最后一组（以id结尾，不为NULL）用<=或>=，带上游标上的任务
+func keysetExpr(keys []sortKey, c *task.Cursor) clause.Expr {
+	runs := keyRuns(keys, c)
+	var branches []string
+	var vars []any
+	for i, run := range runs {
+		beyond, beyondVars, ok := run.beyond(c, c.Inclusive && i == len(runs)-1)
+		if !ok {
+			continue
+		}
+		conds := make([]string, 0, i+1)
+		for _, prev := range runs[:i] {
+			eq, eqVars := prev.equal(c)
+			conds = append(conds, eq)
+			vars = append(vars, eqVars...)
+		}
+		conds = append(conds, beyond)
+		vars = append(vars, beyondVars...)
+		branches = append(branches, "("+strings.Join(conds, " AND ")+")")
+	}
+	if len(branches) == 0 {
+		return clause.Expr{SQL: "FALSE"}
+	}
+	return clause.Expr{SQL: "(" + strings.Join(branches, " OR ") + ")", Vars: vars}
+}
+
+// keyRun 方向相同、不为NULL的相邻排序键；可能为NULL的键单独一组
+type keyRun []sortKey
+
+func keyRuns(keys []sortKey, c *task.Cursor) []keyRun {
+	var runs []keyRun
+	for _, k := range keys {
+		if n := len(runs); n > 0 && k.rowComparable(c) {
+			last := runs[n-1]
+			if prev := last[len(last)-1]; prev.rowComparable(c) && prev.desc == k.desc {
+				runs[n-1] = append(last, k)
+				continue
+			}
+		}
+		runs = append(runs, keyRun{k})
+	}
+	return runs
+}
+
+// rowComparable NULL参与行比较的结果是NULL，只有确定不为NULL的键能放进行比较
+func (k sortKey) rowComparable(c *task.Cursor) bool {
+	return !k.nullable && k.value(c) != nil
+}
+
+func (run keyRun) equal(c *task.Cursor) (string, []any) {
+	conds := make([]string, 0, len(run))
+	var vars []any
+	for _, k := range run {
+		eq, eqVars := k.equal(c)
+		conds = append(conds, eq)
+		vars = append(vars, eqVars...)
+	}
+	return strings.Join(conds, " AND "), vars
+}
+
+func (run keyRun) beyond(c *task.Cursor, orEqual bool) (string, []any, bool) {
+	if len(run) == 1 {
+		return run[0].beyond(c, orEqual)
+	}
+	op := " " + compareOp(run[0].desc, c.Backward, orEqual) + " "
+	exprs := make([]string, 0, len(run))
+	marks := make([]string, 0, len(run))
+	var vars, values []any
+	for _, k := range run {
+		exprs = append(exprs, k.expr)
+		marks = append(marks, "?")
+		vars = append(vars, k.vars...)
+		values = append(values, k.value(c))
+	}
+	return "(" + strings.Join(exprs, ", ") + ")" + op + "(" + strings.Join(marks, ", ") + ")", append(vars, values...), true
+}
+
+func (k sortKey) equal(c *task.Cursor) (string, []any) {
+	v := k.value(c)
+	if v == nil {
+		return k.expr + " IS NULL", k.vars
+	}
+	return k.expr + " = ?", append(append([]any{}, k.vars...), v)
+}
+
+// compareOp 排在游标之后的比较运算符，orEqual时包括相等
+func compareOp(desc, backward, orEqual bool) string {
+	op := ">"
+	if desc != backward {
+		op = "<"
+	}
+	if orEqual {
+		op += "="
+	}
+	return op
+}
+
+// beyond 这个键排在游标之后，orEqual时包括相等（只用于不为NULL的键）；ok为false表示不可能排在后面
+func (k sortKey) beyond(c *task.Cursor, orEqual bool) (sql string, vars []any, ok bool) {
+	op := " " + compareOp(k.desc, c.Backward, orEqual) + " ?"
+	v := k.value(c)
+	withValue := append(append([]any{}, k.vars...), v)
+	switch {
+	case !k.nullable:
+		if v == nil {
+			return "", nil, false
+		}
+		return k.expr + op, withValue, true
+	case c.Backward:
+		// NULL排在最后，往前翻时NULL之前是所有非NULL
+		if v == nil {
+			return k.expr + " IS NOT NULL", k.vars, true
+		}
+		return k.expr + op, withValue, true
+	default:
+		if v == nil {
+			return "", nil, false
+		}
+		return "(" + k.expr + op + " OR " + k.expr + " IS NULL)", append(withValue, k.vars...), true
+	}
+}
+
+// cursorOf 任务在当前排序里的位置，只填排序用到的字段
+func cursorOf(sort string, search *taskSearch, row *taskSearchRow) *task.Cursor {
+	c := &task.Cursor{Sort: sort, ID: row.ID, Fuzzy: search != nil && search.fuzzy}
+	switch sort {
+	case "status":
+		c.StatusCategory = group.Category(row.StatusCategory)
+		c.Status = task.Status(row.Status)
+		c.CreatedAt = &row.CreatedAt
+	case "rank":
+		c.Rank = row.Rank
+	case "completed_desc":
+		c.CompletedAt = row.CompletedAt
+	case "relevance":
+		if row.SearchRank != nil {
+			c.Score = *row.SearchRank
+		}
+	default:
+		c.CreatedAt = &row.CreatedAt
+	}
+	return c
+}
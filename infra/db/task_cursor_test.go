@@ -0,0 +1,81 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"tasker/core/task"
+)
+
+func TestKeysetExpr(t *testing.T) {
+	at := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	const status = "CASE tasks.status_category WHEN 'todo' THEN 0 WHEN 'doing' THEN 1 ELSE 2 END"
+	tests := []struct {
+		name   string
+		cursor task.Cursor
+		sql    string
+		vars   []any
+	}{
+		{
+			name:   "same direction becomes one row comparison",
+			cursor: task.Cursor{Sort: "created_desc", ID: 5, CreatedAt: &at},
+			sql:    "(((tasks.created_at, tasks.id) < (?, ?)))",
+			vars:   []any{at, int64(5)},
+		},
+		{
+			name:   "backward flips the operator",
+			cursor: task.Cursor{Sort: "created_asc", ID: 5, CreatedAt: &at, Backward: true},
+			sql:    "(((tasks.created_at, tasks.id) < (?, ?)))",
+			vars:   []any{at, int64(5)},
+		},
+		{
+			name:   "rank keeps the collation inside the row",
+			cursor: task.Cursor{Sort: "rank", ID: 5, Rank: "00000002V"},
+			sql:    `(((tasks.rank COLLATE "C", tasks.id) > (?, ?)))`,
+			vars:   []any{"00000002V", int64(5)},
+		},
+		{
+			name:   "mixed directions compare one run at a time",
+			cursor: task.Cursor{Sort: "status", ID: 5, StatusCategory: "doing", Status: "review", CreatedAt: &at},
+			sql:    "(((" + status + ", tasks.status) > (?, ?)) OR (" + status + " = ? AND tasks.status = ? AND (tasks.created_at, tasks.id) < (?, ?)))",
+			vars:   []any{1, "review", 1, "review", at, int64(5)},
+		},
+		{
+			name:   "nullable keys are not row-compared",
+			cursor: task.Cursor{Sort: "completed_desc", ID: 5, CompletedAt: &at},
+			sql:    "(((tasks.completed_at < ? OR tasks.completed_at IS NULL)) OR (tasks.completed_at = ? AND tasks.id < ?))",
+			vars:   []any{at, at, int64(5)},
+		},
+		{
+			name:   "null cursor value going forward",
+			cursor: task.Cursor{Sort: "completed_desc", ID: 5},
+			sql:    "((tasks.completed_at IS NULL AND tasks.id < ?))",
+			vars:   []any{int64(5)},
+		},
+		{
+			name:   "inclusive includes the cursor task",
+			cursor: task.Cursor{Sort: "created_desc", ID: 5, CreatedAt: &at, Inclusive: true},
+			sql:    "(((tasks.created_at, tasks.id) <= (?, ?)))",
+			vars:   []any{at, int64(5)},
+		},
+		{
+			name:   "inclusive with a separate id run",
+			cursor: task.Cursor{Sort: "completed_desc", ID: 5, Inclusive: true},
+			sql:    "((tasks.completed_at IS NULL AND tasks.id <= ?))",
+			vars:   []any{int64(5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cursor
+			e := keysetExpr(sortKeys(c.Sort, nil), &c)
+			if e.SQL != tt.sql {
+				t.Fatalf("sql =\n%s\nwant\n%s", e.SQL, tt.sql)
+			}
+			if !reflect.DeepEqual(e.Vars, tt.vars) {
+				t.Fatalf("vars = %v, want %v", e.Vars, tt.vars)
+			}
+		})
+	}
+}
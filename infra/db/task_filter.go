@@ -0,0 +1,146 @@
+package db
+
+import (
+	"strings"
+
+	"gorm.io/gorm/clause"
+
+	"tasker/core/task"
+)
+
+/*
+把task.Condition翻译成查询条件。SQL片段都是常量，用户输入只作为参数绑定。
+每个条件对NULL都返回true或false，不返回NULL，这样取反（NOT）的结果才符合直觉
+*/
+
+// boolExpr AND/OR组合。整体和每一项都加括号，不依赖GORM按SQL文本推断优先级
+type boolExpr struct {
+	op    string
+	exprs []clause.Expression
+}
+
+func (e boolExpr) Build(b clause.Builder) {
+	b.WriteString("(")
+	for i, x := range e.exprs {
+		if i > 0 {
+			b.WriteString(" " + e.op + " ")
+		}
+		b.WriteString("(")
+		x.Build(b)
+		b.WriteString(")")
+	}
+	b.WriteString(")")
+}
+
+type notExpr struct {
+	expr clause.Expression
+}
+
+func (e notExpr) Build(b clause.Builder) {
+	b.WriteString("NOT (")
+	e.expr.Build(b)
+	b.WriteString(")")
+}
+
+// conditionExpr 条件树在service里已经校验过，这里只做翻译
+func conditionExpr(c task.Condition) clause.Expression {
+	switch c := c.(type) {
+	case task.AndCondition:
+		return boolExpr{op: "AND", exprs: conditionExprs(c)}
+	case task.OrCondition:
+		return boolExpr{op: "OR", exprs: conditionExprs(c)}
+	case task.NotCondition:
+		return notExpr{expr: conditionExpr(c.Condition)}
+	case task.StatusCondition:
+		return clause.Expr{SQL: "tasks.status = ?", Vars: []any{string(c.Status)}}
+	case task.CategoryCondition:
+		categories := make([]string, 0, len(c.Categories))
+		for _, category := range c.Categories {
+			categories = append(categories, string(category))
+		}
+		return clause.Expr{SQL: "tasks.status_category IN ?", Vars: []any{categories}}
+	case task.PriorityCondition:
+		return clause.Expr{SQL: "lower(COALESCE(tasks.priority, '')) IN ?", Vars: []any{c.Priorities}}
+	case task.DueCondition:
+		return dueExpr(c.Window)
+	case task.TimeCondition:
+		return timeExpr(c)
+	case task.GroupCondition:
+		if c.None {
+			return clause.Expr{SQL: "tasks.group_id IS NULL"}
+		}
+		return clause.Expr{SQL: "EXISTS (SELECT 1 FROM groups g WHERE g.id = tasks.group_id AND lower(g.name) = lower(?))", Vars: []any{c.Name}}
+	case task.AssigneeCondition:
+		switch {
+		case c.None:
+			return clause.Expr{SQL: "NOT EXISTS (SELECT 1 FROM task_assignees ta WHERE ta.task_id = tasks.id)"}
+		case c.UserID != nil:
+			return clause.Expr{SQL: "EXISTS (SELECT 1 FROM task_assignees ta WHERE ta.task_id = tasks.id AND ta.user_id = ?)", Vars: []any{*c.UserID}}
+		}
+		return clause.Expr{SQL: "EXISTS (SELECT 1 FROM task_assignees ta JOIN users u ON u.id = ta.user_id WHERE ta.task_id = tasks.id AND lower(u.username) = lower(?))", Vars: []any{c.Username}}
+	case task.TagCondition:
+		if c.None {
+			return clause.Expr{SQL: "NOT EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = tasks.id)"}
+		}
+		return clause.Expr{SQL: "EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = tasks.id AND lower(tt.tag) = lower(?))", Vars: []any{c.Name}}
+	case task.TitleCondition:
+		return clause.Expr{SQL: `tasks.title ILIKE ? ESCAPE '\'`, Vars: []any{"%" + escapeLike(c.Text) + "%"}}
+	case task.TextCondition:
+		// 和全文检索一样按字匹配汉字，见task_search.go
+		return clause.Expr{SQL: "tasks.search_vector @@ phraseto_tsquery('" + searchConfig + "', ?)", Vars: []any{segment(c.Text)}}
+	}
+	// 不认识的条件不匹配任何任务，不能放宽成不过滤
+	return clause.Expr{SQL: "FALSE"}
+}
+
+func conditionExprs(cs []task.Condition) []clause.Expression {
+	exprs := make([]clause.Expression, 0, len(cs))
+	for _, c := range cs {
+		exprs = append(exprs, conditionExpr(c))
+	}
+	return exprs
+}
+
+// dueExpr 同dueWindowCondition，w为nil表示没有截止时间
+func dueExpr(w *task.DueWindow) clause.Expression {
+	if w == nil {
+		return clause.Expr{SQL: "tasks.due_data IS NULL AND tasks.due_on IS NULL"}
+	}
+	return boolExpr{op: "OR", exprs: []clause.Expression{
+		rangeExpr("tasks.due_data", w.From, w.To),
+		rangeExpr("tasks.due_on", w.FromDate, w.ToDate),
+	}}
+}
+
+func timeExpr(c task.TimeCondition) clause.Expression {
+	column := "tasks.created_at"
+	switch c.Field {
+	case task.TimeUpdated:
+		column = "tasks.updated_at"
+	case task.TimeCompleted:
+		column = "tasks.completed_at"
+	}
+	if c.None {
+		return clause.Expr{SQL: column + " IS NULL"}
+	}
+	return rangeExpr(column, c.From, c.To)
+}
+
+// rangeExpr column在[from, to)里，nil表示不限；column是常量
+func rangeExpr[T any](column string, from, to *T) clause.Expr {
+	e := clause.Expr{SQL: column + " IS NOT NULL"}
+	if from != nil {
+		e.SQL += " AND " + column + " >= ?"
+		e.Vars = append(e.Vars, *from)
+	}
+	if to != nil {
+		e.SQL += " AND " + column + " < ?"
+		e.Vars = append(e.Vars, *to)
+	}
+	return e
+}
+
+// escapeLike 转义LIKE的通配符，配合ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
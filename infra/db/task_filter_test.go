@@ -0,0 +1,51 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm/clause"
+
+	"tasker/core/task"
+)
+
+// sqlBuilder 只记录SQL文本和参数，不需要数据库连接
+type sqlBuilder struct {
+	strings.Builder
+	vars []any
+}
+
+func (b *sqlBuilder) WriteQuoted(field any) {
+	b.WriteString(`"` + field.(string) + `"`)
+}
+
+func (b *sqlBuilder) AddVar(w clause.Writer, vars ...any) {
+	for _, v := range vars {
+		w.WriteString("?")
+		b.vars = append(b.vars, v)
+	}
+}
+
+func (b *sqlBuilder) AddError(err error) error {
+	return err
+}
+
+func TestConditionExprTags(t *testing.T) {
+	c := task.AndCondition{
+		task.TagCondition{Name: "Urgent"},
+		task.NotCondition{Condition: task.TagCondition{Name: "someday"}},
+		task.TagCondition{None: true},
+	}
+	var b sqlBuilder
+	conditionExpr(c).Build(&b)
+	want := "((EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = tasks.id AND lower(tt.tag) = lower(?)))" +
+		" AND (NOT (EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = tasks.id AND lower(tt.tag) = lower(?))))" +
+		" AND (NOT EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = tasks.id)))"
+	if b.String() != want {
+		t.Fatalf("sql =\n%s\nwant\n%s", b.String(), want)
+	}
+	if !reflect.DeepEqual(b.vars, []any{"Urgent", "someday"}) {
+		t.Fatalf("vars = %v", b.vars)
+	}
+}
@@ -4,20 +4,37 @@ package db
 
 import (
 	"time"
+
+	"tasker/pkg/date"
 )
 
 // TaskModel是存到postgres中的结构
 type TaskModel struct {
 	ID int64 `gorm:"primaryKey;autoIncrement"`
-	// 关联用户（集联删除）
-	UserID int64 `gorm:"not null;index"`
+	// 创建任务的用户，创建者注销后为NULL
+	UserID *int64 `gorm:"index"`
+	// 任务所属的工作区，成员按角色访问（老数据迁移前为NULL）
+	WorkspaceID *int64 `gorm:"index"`
 	Title string `gorm:"type:varchar(255);not null"`
 	Description string `gorm:"type:text"`
 	Status string `gorm:"type:varchar(20);not null;index"`
+	// 状态在所属分组工作流里的大类，筛选和排序用它，不用每次去查分组
+	StatusCategory string `gorm:"type:varchar(10);not null;default:'todo';index"`
 
-	DueData *time.Time `gorm:"not null"`
+	// 没有截止时间的任务两列都是NULL
+	DueData *time.Time `gorm:"index"`
+	// 只有日期的截止日，存成date，不换算时区
+	DueOn *date.Date `gorm:"type:date;index"`
 	Priority string `gorm:"type:varchar(20);default:'low';index"`
 	GroupID *int64 `gorm:"index"`
+	// 导入时外部系统的ID，同一工作区内唯一（部分唯一索引在migrateTasks里建）
+	ExternalID *string `gorm:"type:varchar(100)"`
+	// 进入done大类的时间，按完成时间筛选和排序
+	CompletedAt *time.Time `gorm:"index"`
+	// 看板排序用的分数索引，必须按 COLLATE "C" 比较，索引在migrateTasks里建
+	Rank string `gorm:"type:varchar(255);not null;default:''"`
+	// 最后一次变化时所属分组的变更序号，由触发器写入，见migrateTasks
+	ChangeSeq int64 `gorm:"->;not null;default:0"`
 
 	// OnDelete:SET NULL意思是如果这个组被删除了，这些人物的GroupID自动变成NULL
 	Group GroupModel `gorm:"foreignKey:GroupID;constraint:GroupID;constraint:OnDelete:SET NULL"`
@@ -29,4 +46,25 @@ type TaskModel struct {
 // TableName可以自定义表名
 func (TaskModel) TableName () string {
 	return "tasks"
-}
\ No newline at end of file
+}
+// TaskAssigneeModel 任务负责人，(task_id, user_id)为主键
+type TaskAssigneeModel struct {
+	TaskID    int64     `gorm:"primaryKey"`
+	UserID    int64     `gorm:"primaryKey;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (TaskAssigneeModel) TableName() string {
+	return "task_assignees"
+}
+
+// TaskTagModel 任务标签，(task_id, tag)为主键；按标签筛选用lower(tag)上的索引（在migrateTasks里建）
+type TaskTagModel struct {
+	TaskID    int64     `gorm:"primaryKey"`
+	Tag       string    `gorm:"type:varchar(50);primaryKey"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (TaskTagModel) TableName() string {
+	return "task_tags"
+}
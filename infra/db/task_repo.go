@@ -4,6 +4,11 @@ package db
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"tasker/core/group"
+	"tasker/core/sharelink"
 	"tasker/core/task"
 	"tasker/pkg/apperror"
 
@@ -20,41 +25,100 @@ func NewTaskRepository(db *gorm.DB) *TaskRepository {
 
 // model和domain转换
 func toDomain(m *TaskModel) *task.Task {
+	var workspaceID int64
+	if m.WorkspaceID != nil {
+		workspaceID = *m.WorkspaceID
+	}
 	return &task.Task{
 		ID:          m.ID,
-		UserID:      m.UserID,
+		UserID:      derefUserID(m.UserID),
+		WorkspaceID: workspaceID,
 		Title:       m.Title,
 		Description: m.Description,
 		Status:      task.Status(m.Status),
-
+		StatusCategory: group.Category(m.StatusCategory),
 
 		DueDate: m.DueData,
+		DueOn: m.DueOn,
 		Priority: m.Priority,
 		GroupID: m.GroupID,
+		Rank:        m.Rank,
+		CompletedAt: m.CompletedAt,
+		ExternalID:  derefString(m.ExternalID),
+		ChangeSeq:   m.ChangeSeq,
 		CreatedAt:   m.CreatedAt,
 		UpdatedAt:   m.UpdatedAt,
 	}
 }
 
 func toModel(t *task.Task) *TaskModel {
+	workspaceID := t.WorkspaceID
 	return &TaskModel{
 		ID:          t.ID,
-		UserID:      t.UserID,
+		UserID:      nullUserID(t.UserID),
+		WorkspaceID: &workspaceID,
 		Title:       t.Title,
 		Description: t.Description,
 		Status:      string(t.Status),
+		StatusCategory: string(t.StatusCategory),
 		DueData: t.DueDate,
+		DueOn: t.DueOn,
 		Priority: t.Priority,
 		GroupID: t.GroupID,
+		Rank:        t.Rank,
+		CompletedAt: t.CompletedAt,
+		ExternalID:  nullString(t.ExternalID),
 		CreatedAt:   t.CreatedAt,
 		UpdatedAt:   t.UpdatedAt,
 	}
 }
 
+// 空串存成NULL，避免占用唯一索引
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// 创建者、作者注销后列为NULL，domain里用0表示
+func nullUserID(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func derefUserID(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
 // 实现Repository接口
 func (r *TaskRepository) Create(ctx context.Context, t *task.Task) error {
 	m := toModel(t)
-	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		if err := replaceAssignees(tx, m.ID, t.AssigneeIDs, t.UpdatedAt); err != nil {
+			return err
+		}
+		return replaceTags(tx, m.ID, t.Tags, t.UpdatedAt)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperror.New("EXTERNAL_ID_EXISTS", "a task with this external_id already exists in the workspace")
+		}
 		return apperror.New("DB_ERROR", "failed to create task")
 	}
 	// 回填自增ID
@@ -62,31 +126,217 @@ func (r *TaskRepository) Create(ctx context.Context, t *task.Task) error {
 	return nil
 }
 
+// replaceAssignees 用ids覆盖任务的负责人
+func replaceAssignees(tx *gorm.DB, taskID int64, ids []int64, at time.Time) error {
+	if err := tx.Where("task_id = ?", taskID).Delete(&TaskAssigneeModel{}).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	rows := make([]TaskAssigneeModel, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, TaskAssigneeModel{TaskID: taskID, UserID: id, CreatedAt: at})
+	}
+	return tx.Create(&rows).Error
+}
+
+// replaceTags 用tags覆盖任务的标签
+func replaceTags(tx *gorm.DB, taskID int64, tags []string, at time.Time) error {
+	if err := tx.Where("task_id = ?", taskID).Delete(&TaskTagModel{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]TaskTagModel, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, TaskTagModel{TaskID: taskID, Tag: tag, CreatedAt: at})
+	}
+	return tx.Create(&rows).Error
+}
+
+// loadRelations 一次查出一批任务的负责人和标签
+func (r *TaskRepository) loadRelations(ctx context.Context, tasks []*task.Task) error {
+	if err := r.loadAssignees(ctx, tasks); err != nil {
+		return err
+	}
+	return r.loadTags(ctx, tasks)
+}
+
+// loadTags 标签按名字排序
+func (r *TaskRepository) loadTags(ctx context.Context, tasks []*task.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(tasks))
+	byID := make(map[int64]*task.Task, len(tasks))
+	for _, t := range tasks {
+		t.Tags = []string{}
+		ids = append(ids, t.ID)
+		byID[t.ID] = t
+	}
+
+	var rows []TaskTagModel
+	if err := r.db.WithContext(ctx).Where("task_id IN ?", ids).Order("lower(tag) ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		t := byID[row.TaskID]
+		t.Tags = append(t.Tags, row.Tag)
+	}
+	return nil
+}
+
+// loadAssignees 一次查出一批任务的负责人
+func (r *TaskRepository) loadAssignees(ctx context.Context, tasks []*task.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(tasks))
+	byID := make(map[int64]*task.Task, len(tasks))
+	for _, t := range tasks {
+		t.AssigneeIDs = []int64{}
+		ids = append(ids, t.ID)
+		byID[t.ID] = t
+	}
+
+	var rows []TaskAssigneeModel
+	if err := r.db.WithContext(ctx).Where("task_id IN ?", ids).Order("user_id ASC").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		t := byID[row.TaskID]
+		t.AssigneeIDs = append(t.AssigneeIDs, row.UserID)
+	}
+	return nil
+}
+
 func (r *TaskRepository) GetByID(ctx context.Context, userID, id int64) (*task.Task, error) {
+	return r.get(ctx, r.db.WithContext(ctx).Where("id = ?", id).Where(accessibleTasks(r.db, userID)))
+}
+
+func (r *TaskRepository) GetByIDUnscoped(ctx context.Context, id int64) (*task.Task, error) {
+	return r.get(ctx, r.db.WithContext(ctx).Where("id = ?", id))
+}
+
+func (r *TaskRepository) get(ctx context.Context, db *gorm.DB) (*task.Task, error) {
 	var m TaskModel
-	tx := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&m)
+	tx := db.First(&m)
 	if tx.Error != nil {
 		if tx.Error == gorm.ErrRecordNotFound {
 			return nil, apperror.New("TASK_NOT_FOUND", "task not found")
 		}
 		return nil, apperror.New("DB_ERROR", "failed to get task")
 	}
-	return toDomain(&m), nil
+	t := toDomain(&m)
+	if err := r.loadRelations(ctx, []*task.Task{t}); err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to get task")
+	}
+	return t, nil
 }
 
 func (r *TaskRepository) List(ctx context.Context, userID int64, filter task.ListTaskerFilter) (*task.ListResult, error) {
-	db := r.db.WithContext(ctx).Model(&TaskModel{}).Where("user_id = ?", userID)
+	if filter.Query == "" {
+		return r.list(r.db.WithContext(ctx), userID, filter, nil)
+	}
+
+	// 模糊匹配结果的后续页直接按相似度查
+	var res *task.ListResult
+	if filter.After == nil || !filter.After.Fuzzy {
+		q := searchQuery(filter.Query)
+		var err error
+		res, err = r.list(r.db.WithContext(ctx), userID, filter, &taskSearch{
+			match:     "tasks.search_vector @@ " + tsQuery,
+			rank:      "ts_rank_cd(tasks.search_vector, " + tsQuery + ")",
+			arg:       q,
+			highlight: true,
+		})
+		// 有结果，或者正在翻全文检索结果的后续页
+		if err != nil || filter.After != nil || len(res.Items) > 0 || (res.Total != nil && *res.Total > 0) {
+			return res, err
+		}
+	}
+
+	// 全文检索没有结果时按标题的三元组相似度再查一次，容忍拼写错误。
+	// 用<%运算符才能走trigram索引，阈值只能通过会话变量设置，所以放在事务里SET LOCAL
+	terms := fuzzyTerms(filter.Query)
+	if terms == "" {
+		if res == nil {
+			return nil, apperror.New("INVALID_CURSOR", "cursor does not match q")
+		}
+		return res, nil
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET LOCAL pg_trgm.word_similarity_threshold = " + fuzzyThreshold).Error; err != nil {
+			return err
+		}
+		var err error
+		res, err = r.list(tx, userID, filter, &taskSearch{
+			match: "? <% tasks.title",
+			rank:  "word_similarity(?, tasks.title)",
+			arg:   terms,
+			fuzzy: true,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list tasks")
+	}
+	res.Fuzzy = true
+	return res, nil
+}
+
+// taskSearch 列表的搜索条件。match和rank里各有一个占位符，都绑定arg
+type taskSearch struct {
+	match string
+	// 相关度，越大越靠前
+	rank string
+	arg  string
+	// 是否用ts_headline生成高亮，只对全文检索有意义
+	highlight bool
+	// 全文检索没有结果后的模糊匹配，记在游标里
+	fuzzy bool
+}
+
+func (r *TaskRepository) list(tx *gorm.DB, userID int64, filter task.ListTaskerFilter, search *taskSearch) (*task.ListResult, error) {
+	db := tx.Model(&TaskModel{}).Where(accessibleTasks(r.db, userID))
+	if filter.WorkspaceID != nil {
+		db = db.Where("workspace_id = ?", *filter.WorkspaceID)
+	}
+	if filter.GroupID != nil {
+		db = db.Where("group_id = ?", *filter.GroupID)
+	}
 	if filter.Status != "" {
 		db = db.Where("status = ?", string(filter.Status))
 	}
-	if filter.Query != "" {
-		q := "%" + filter.Query + "%"
-		db = db.Where("title ILIKE ? OR description ILIKE ?", q, q)
+	if len(filter.Categories) > 0 {
+		categories := make([]string, 0, len(filter.Categories))
+		for _, c := range filter.Categories {
+			categories = append(categories, string(c))
+		}
+		db = db.Where("status_category IN ?", categories)
 	}
-
-	var total int64
-	if err := db.Count(&total).Error; err != nil {
-		return nil, apperror.New("DB_ERROR", "failed to count tasks")
+	if search != nil {
+		db = db.Where(search.match, search.arg)
+	}
+	if filter.AssigneeID != nil {
+		db = db.Where("EXISTS (SELECT 1 FROM task_assignees ta WHERE ta.task_id = tasks.id AND ta.user_id = ?)", *filter.AssigneeID)
+	}
+	if filter.Unassigned {
+		db = db.Where("NOT EXISTS (SELECT 1 FROM task_assignees ta WHERE ta.task_id = tasks.id)")
+	}
+	if filter.CompletedAfter != nil {
+		db = db.Where("completed_at >= ?", *filter.CompletedAfter)
+	}
+	if filter.CompletedBefore != nil {
+		db = db.Where("completed_at < ?", *filter.CompletedBefore)
+	}
+	if w := filter.DueWindow; w != nil {
+		db = db.Where(dueWindowCondition(r.db, w))
+	}
+	if filter.Condition != nil {
+		db = db.Where(conditionExpr(filter.Condition))
 	}
 
 	page := filter.Page
@@ -97,59 +347,360 @@ func (r *TaskRepository) List(ctx context.Context, userID int64, filter task.Lis
 	if pageSize <= 0 {
 		pageSize = 10
 	}
+	res := &task.ListResult{PageSize: pageSize}
 
-	var models []TaskModel
-	order := "created_at DESC"
-	switch filter.Sort {
-	case "created_asc":
-		order = "created_at ASC"
-	case "status":
-		order = "status ASC, created_at DESC"
+	// 游标分页默认不算总数，省掉每次的COUNT
+	if !filter.CursorMode || filter.IncludeTotal {
+		var total int64
+		if err := db.Count(&total).Error; err != nil {
+			return nil, apperror.New("DB_ERROR", "failed to count tasks")
+		}
+		res.Total = &total
 	}
 
-	if err := db.Order(order).Limit(pageSize).Offset((page - 1) * pageSize).Find(&models).Error; err != nil {
+	keys := sortKeys(filter.Sort, search)
+	backward := false
+	if filter.CursorMode {
+		if c := filter.After; c != nil {
+			backward = c.Backward
+			db = db.Where(keysetExpr(keys, c))
+		}
+		// 多取一条判断是否还有下一页
+		db = db.Order(orderExpr(keys, backward)).Limit(pageSize + 1)
+	} else {
+		res.Page = page
+		db = db.Order(orderExpr(keys, false)).Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	columns := "tasks.*"
+	var vars []any
+	if search != nil {
+		columns += ", " + search.rank + " AS search_rank"
+		vars = append(vars, search.arg)
+		if search.highlight {
+			columns += ", " + highlightColumns
+			vars = append(vars, search.arg, search.arg)
+		}
+	}
+	var rows []taskSearchRow
+	if err := db.Select(columns, vars...).Find(&rows).Error; err != nil {
 		return nil, apperror.New("DB_ERROR", "failed to list tasks")
 	}
 
-	items := make([]*task.Task, 0, len(models))
-	for _, m := range models {
-		items = append(items, toDomain(&m))
+	more := false
+	if filter.CursorMode && len(rows) > pageSize {
+		more = true
+		rows = rows[:pageSize]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	items := make([]*task.Task, 0, len(rows))
+	for i := range rows {
+		t := toDomain(&rows[i].TaskModel)
+		if search != nil && search.highlight {
+			title, description := highlightOf(&rows[i])
+			t.Highlight = &task.Highlight{Title: title, Description: description}
+		}
+		items = append(items, t)
+	}
+	if err := r.loadRelations(tx.Statement.Context, items); err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list tasks")
+	}
+	res.Items = items
+
+	// 往前翻到头、一条都没有时，next_cursor回到来的那一页，包括游标上的任务
+	if backward && len(rows) == 0 {
+		next := *filter.After
+		next.Backward = false
+		next.Inclusive = true
+		res.Next = &next
+	}
+
+	// 往后翻时多取的一条说明后面还有；往前翻时说明前面还有，后面一定有（就是来的那一页）
+	if filter.CursorMode && len(rows) > 0 {
+		first := cursorOf(filter.Sort, search, &rows[0])
+		first.Backward = true
+		last := cursorOf(filter.Sort, search, &rows[len(rows)-1])
+		if backward {
+			res.Next = last
+			if more {
+				res.Prev = first
+			}
+		} else {
+			if more {
+				res.Next = last
+			}
+			if filter.After != nil {
+				res.Prev = first
+			}
+		}
+	}
+	return res, nil
+}
+
+// accessibleTasks 用户所在工作区的任务，加上共享给用户的分组里的任务
+func accessibleTasks(db *gorm.DB, userID int64) *gorm.DB {
+	return db.Where("workspace_id IN (?)", memberWorkspaceIDs(db, userID)).
+		Or("group_id IN (?)", sharedGroupIDs(db, userID))
+}
+
+// dueWindowCondition 带时刻的截止时间和只有日期的截止日，满足其一即可
+func dueWindowCondition(db *gorm.DB, w *task.DueWindow) *gorm.DB {
+	atCond := db.Where("due_data IS NOT NULL")
+	if w.From != nil {
+		atCond = atCond.Where("due_data >= ?", *w.From)
+	}
+	if w.To != nil {
+		atCond = atCond.Where("due_data < ?", *w.To)
 	}
 
-	return &task.ListResult{
-		Items:    items,
-		Total:    total,
-		Page:     page,
-		PageSize: pageSize,
-	}, nil
+	onCond := db.Where("due_on IS NOT NULL")
+	if w.FromDate != nil {
+		onCond = onCond.Where("due_on >= ?", *w.FromDate)
+	}
+	if w.ToDate != nil {
+		onCond = onCond.Where("due_on < ?", *w.ToDate)
+	}
+
+	return db.Where(atCond).Or(onCond)
+}
+
+func (r *TaskRepository) Update(ctx context.Context, userID int64, t *task.Task) error {
+	return r.update(ctx, userID, t, nil)
+}
+
+func (r *TaskRepository) UpdateIfUnchanged(ctx context.Context, userID int64, t *task.Task, changeSeq int64) error {
+	return r.update(ctx, userID, t, &changeSeq)
 }
 
-func (r *TaskRepository) Update(ctx context.Context, t *task.Task) error {
+// errTaskChanged 事务内部用来区分条件写入时任务已经被改过的情况
+var errTaskChanged = errors.New("task changed")
+
+// update changeSeq非nil时在同一条UPDATE里比较change_seq，没有更新到行再区分是任务不存在还是已经被改过
+func (r *TaskRepository) update(ctx context.Context, userID int64, t *task.Task, changeSeq *int64) error {
 	m := toModel(t)
-	tx := r.db.WithContext(ctx).Model(&TaskModel{}).Where("id = ? AND user_id = ?", t.ID, t.UserID).Updates(map[string]any{
-		"title":       m.Title,
-		"description": m.Description,
-		"status":      m.Status,
-		"updated_at":  m.UpdatedAt,
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		db := tx.Model(&TaskModel{}).Where("id = ?", t.ID).Where(accessibleTasks(r.db, userID))
+		if changeSeq != nil {
+			db = db.Where("change_seq = ?", *changeSeq)
+		}
+		res := db.Updates(map[string]any{
+			"title":       m.Title,
+			"description": m.Description,
+			"status":      m.Status,
+			"status_category": m.StatusCategory,
+			"due_data":    m.DueData,
+			"due_on":      m.DueOn,
+			"priority":    m.Priority,
+			"group_id":    m.GroupID,
+			"rank":        m.Rank,
+			"completed_at": m.CompletedAt,
+			"updated_at":  m.UpdatedAt,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			if changeSeq == nil {
+				return gorm.ErrRecordNotFound
+			}
+			var visible int64
+			if err := tx.Model(&TaskModel{}).Where("id = ?", t.ID).Where(accessibleTasks(r.db, userID)).Count(&visible).Error; err != nil {
+				return err
+			}
+			if visible == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return errTaskChanged
+		}
+		if err := replaceAssignees(tx, t.ID, t.AssigneeIDs, t.UpdatedAt); err != nil {
+			return err
+		}
+		return replaceTags(tx, t.ID, t.Tags, t.UpdatedAt)
 	})
-	if tx.Error != nil {
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("TASK_NOT_FOUND", "task not found")
+		}
+		if errors.Is(err, errTaskChanged) {
+			return apperror.New("PRECONDITION_FAILED", "the resource has been changed or does not match the condition")
+		}
 		return apperror.New("DB_ERROR", "failed to update task")
 	}
-	if tx.RowsAffected == 0 {
-		return apperror.New("TASK_NOT_FOUND", "task not found")
-	}
 	return nil
 }
 
 func (r *TaskRepository) Delete(ctx context.Context, userID, id int64) error {
-	tx := r.db.WithContext(ctx).
-		Where("id = ? AND user_id = ?", id, userID).
-		Delete(&TaskModel{})
-	if tx.Error != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先确认任务在用户的访问范围内，再删关联数据
+		var visible int64
+		if err := tx.Model(&TaskModel{}).Where("id = ?", id).Where(accessibleTasks(r.db, userID)).Count(&visible).Error; err != nil {
+			return err
+		}
+		if visible == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		for _, m := range []any{&TaskAssigneeModel{}, &TaskTagModel{}, &MentionModel{}, &CommentModel{}} {
+			if err := tx.Where("task_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("target_type = ? AND target_id = ?", sharelink.TargetTask, id).Delete(&ShareLinkModel{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&TaskModel{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("TASK_NOT_FOUND", "task not found")
+		}
 		return apperror.New("DB_ERROR", "failed to delete task")
 	}
-	if tx.RowsAffected == 0 {
-		return apperror.New("TASK_NOT_FOUND", "task not found")
+	return nil
+}
+
+func (r *TaskRepository) ListByGroup(ctx context.Context, groupID int64) ([]*task.Task, error) {
+	var models []TaskModel
+	if err := r.db.WithContext(ctx).Where("group_id = ?", groupID).Order("created_at DESC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list tasks")
+	}
+	items := make([]*task.Task, 0, len(models))
+	for i := range models {
+		items = append(items, toDomain(&models[i]))
+	}
+	if err := r.loadRelations(ctx, items); err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list tasks")
+	}
+	return items, nil
+}
+
+func (r *TaskRepository) GetByExternalID(ctx context.Context, workspaceID int64, externalID string) (*task.Task, error) {
+	var m TaskModel
+	err := r.db.WithContext(ctx).Where("workspace_id = ? AND external_id = ?", workspaceID, externalID).First(&m).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get task")
+	}
+	t := toDomain(&m)
+	if err := r.loadRelations(ctx, []*task.Task{t}); err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to get task")
+	}
+	return t, nil
+}
+
+// rankOrder 按字节比较rank，和pkg/rank的字符顺序一致
+const rankOrder = `rank COLLATE "C"`
+
+// rebalanceRanksSQL 按当前顺序给分组的任务分配 8位十六进制序号+'V' 的rank，
+// 都是9位等长，两两之间都留有空位；migrateTasks回填老数据也用同样的格式
+const rebalanceRanksSQL = `UPDATE tasks t SET rank = r.rank FROM (
+	SELECT id, lpad(to_hex(row_number() OVER (ORDER BY rank COLLATE "C", id)), 8, '0') || 'V' AS rank
+	FROM tasks WHERE group_id = ?) r
+WHERE t.id = r.id`
+
+func (r *TaskRepository) ListRanked(ctx context.Context, groupID int64) ([]*task.Task, error) {
+	var models []TaskModel
+	if err := r.db.WithContext(ctx).Where("group_id = ?", groupID).Order(rankOrder + ", id ASC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list tasks")
+	}
+	items := make([]*task.Task, 0, len(models))
+	for i := range models {
+		items = append(items, toDomain(&models[i]))
+	}
+	if err := r.loadRelations(ctx, items); err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list tasks")
+	}
+	return items, nil
+}
+
+// column 分组里某一列的任务，status为空表示整个分组
+func (r *TaskRepository) column(ctx context.Context, groupID int64, status task.Status, excludeID int64) *gorm.DB {
+	db := r.db.WithContext(ctx).Model(&TaskModel{}).Where("group_id = ? AND id <> ?", groupID, excludeID)
+	if status != "" {
+		db = db.Where("status = ?", string(status))
+	}
+	return db
+}
+
+func (r *TaskRepository) LastRank(ctx context.Context, groupID int64, status task.Status, excludeID int64) (string, error) {
+	var ranks []string
+	if err := r.column(ctx, groupID, status, excludeID).Order(rankOrder+" DESC").Limit(1).Pluck("rank", &ranks).Error; err != nil {
+		return "", apperror.New("DB_ERROR", "failed to read task rank")
+	}
+	if len(ranks) == 0 {
+		return "", nil
+	}
+	return ranks[0], nil
+}
+
+func (r *TaskRepository) AdjacentRank(ctx context.Context, groupID int64, status task.Status, rank string, next bool, excludeID int64) (string, error) {
+	db := r.column(ctx, groupID, status, excludeID)
+	if next {
+		db = db.Where(rankOrder+" > ?", rank).Order(rankOrder + " ASC")
+	} else {
+		db = db.Where(rankOrder+" < ?", rank).Order(rankOrder + " DESC")
+	}
+	var ranks []string
+	if err := db.Limit(1).Pluck("rank", &ranks).Error; err != nil {
+		return "", apperror.New("DB_ERROR", "failed to read task rank")
+	}
+	if len(ranks) == 0 {
+		return "", nil
+	}
+	return ranks[0], nil
+}
+
+// lockRanks 分组的排序锁，事务结束时自动释放；在Reorder里重排时同一个会话可以重复加锁
+func lockRanks(tx *gorm.DB, groupID int64) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", groupID).Error
+}
+
+func (r *TaskRepository) RebalanceRanks(ctx context.Context, groupID int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockRanks(tx, groupID); err != nil {
+			return err
+		}
+		return tx.Exec(rebalanceRanksSQL, groupID).Error
+	})
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to rebalance task ranks")
+	}
+	return nil
+}
+
+func (r *TaskRepository) Reorder(ctx context.Context, userID int64, t *task.Task, place func(store task.RankStore) error) error {
+	if t.GroupID == nil {
+		return apperror.New("INVALID_GROUP", "move the task into a group first")
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockRanks(tx, *t.GroupID); err != nil {
+			return err
+		}
+		// 事务里的读写都走同一个连接
+		store := &TaskRepository{db: tx}
+		if err := place(store); err != nil {
+			return err
+		}
+		return store.Update(ctx, userID, t)
+	})
+	if err != nil {
+		if _, ok := apperror.IsAppError(err); ok {
+			return err
+		}
+		return apperror.New("DB_ERROR", "failed to move task")
 	}
 	return nil
 }
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tasker/pkg/apperror"
+)
+
+func TestUpdateIfUnchanged(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	alice := UserModel{Username: "alice", Password: "x", CreatedAt: now, UpdatedAt: now}
+	bob := UserModel{Username: "bob", Password: "x", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &alice)
+	mustCreate(t, db, &bob)
+	ws := WorkspaceModel{Name: "team", OwnerID: alice.ID, CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &ws)
+	mustCreate(t, db, &WorkspaceMemberModel{WorkspaceID: ws.ID, UserID: alice.ID, Role: "owner", CreatedAt: now})
+	g := GroupModel{UserID: &alice.ID, WorkspaceID: &ws.ID, Name: "工作", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &g)
+	m := TaskModel{UserID: &alice.ID, WorkspaceID: &ws.ID, GroupID: &g.ID, Title: "初稿", Status: "pending", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &m)
+	// Postgres里由触发器写change_seq，这里直接设置
+	if err := db.Exec("UPDATE tasks SET change_seq = 41 WHERE id = ?", m.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewTaskRepository(db)
+	read := func() *TaskModel {
+		t.Helper()
+		var got TaskModel
+		if err := db.First(&got, m.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return &got
+	}
+	tk, err := repo.GetByID(ctx, alice.ID, m.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if tk.ChangeSeq != 41 {
+		t.Fatalf("ChangeSeq = %d, want 41", tk.ChangeSeq)
+	}
+
+	tk.Title = "别人改的"
+	err = repo.UpdateIfUnchanged(ctx, alice.ID, tk, 40)
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "PRECONDITION_FAILED" {
+		t.Fatalf("stale change_seq = %v, want PRECONDITION_FAILED", err)
+	}
+	if got := read(); got.Title != "初稿" {
+		t.Fatalf("stale write changed the title to %q", got.Title)
+	}
+
+	// 访问不到的任务仍然是TASK_NOT_FOUND，不透露它存在
+	err = repo.UpdateIfUnchanged(ctx, bob.ID, tk, 41)
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "TASK_NOT_FOUND" {
+		t.Fatalf("inaccessible task = %v, want TASK_NOT_FOUND", err)
+	}
+
+	tk.Title = "终稿"
+	if err := repo.UpdateIfUnchanged(ctx, alice.ID, tk, 41); err != nil {
+		t.Fatalf("UpdateIfUnchanged: %v", err)
+	}
+	if got := read(); got.Title != "终稿" {
+		t.Fatalf("title = %q", got.Title)
+	}
+}
+
+// 共享单个分组只让对方看到这个分组里的任务；读写权限由task.Service按分组校验
+func TestAccessibleTasks(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	alice := UserModel{Username: "alice", Password: "x", CreatedAt: now, UpdatedAt: now}
+	bob := UserModel{Username: "bob", Password: "x", CreatedAt: now, UpdatedAt: now}
+	carol := UserModel{Username: "carol", Password: "x", CreatedAt: now, UpdatedAt: now}
+	for _, u := range []*UserModel{&alice, &bob, &carol} {
+		mustCreate(t, db, u)
+	}
+	ws := WorkspaceModel{Name: "team", OwnerID: alice.ID, CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &ws)
+	mustCreate(t, db, &WorkspaceMemberModel{WorkspaceID: ws.ID, UserID: alice.ID, Role: "owner", CreatedAt: now})
+	shared := GroupModel{UserID: &alice.ID, WorkspaceID: &ws.ID, Name: "共享", CreatedAt: now, UpdatedAt: now}
+	private := GroupModel{UserID: &alice.ID, WorkspaceID: &ws.ID, Name: "内部", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &shared)
+	mustCreate(t, db, &private)
+	mustCreate(t, db, &GroupShareModel{GroupID: shared.ID, UserID: bob.ID, Permission: "read", GrantedBy: alice.ID, CreatedAt: now, UpdatedAt: now})
+
+	newTask := func(title string, groupID *int64) int64 {
+		m := TaskModel{UserID: &alice.ID, WorkspaceID: &ws.ID, GroupID: groupID, Title: title, Status: "pending", CreatedAt: now, UpdatedAt: now}
+		mustCreate(t, db, &m)
+		return m.ID
+	}
+	inShared := newTask("共享的任务", &shared.ID)
+	inPrivate := newTask("内部的任务", &private.ID)
+	ungrouped := newTask("没有分组的任务", nil)
+
+	repo := NewTaskRepository(db)
+	tests := []struct {
+		name    string
+		userID  int64
+		taskID  int64
+		visible bool
+	}{
+		{"member sees a shared group", alice.ID, inShared, true},
+		{"member sees a private group", alice.ID, inPrivate, true},
+		{"member sees ungrouped tasks", alice.ID, ungrouped, true},
+		{"share sees its group", bob.ID, inShared, true},
+		{"share does not see other groups", bob.ID, inPrivate, false},
+		{"share does not see ungrouped tasks", bob.ID, ungrouped, false},
+		{"stranger sees nothing", carol.ID, inShared, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.GetByID(ctx, tt.userID, tt.taskID)
+			if tt.visible {
+				if err != nil {
+					t.Fatalf("GetByID: %v", err)
+				}
+				return
+			}
+			if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "TASK_NOT_FOUND" {
+				t.Fatalf("GetByID = %v, want TASK_NOT_FOUND", err)
+			}
+		})
+	}
+
+	// 写操作同样按访问范围过滤
+	tk, err := repo.GetByID(ctx, alice.ID, inPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk.Title = "bob改的"
+	err = repo.Update(ctx, bob.ID, tk)
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "TASK_NOT_FOUND" {
+		t.Fatalf("Update = %v, want TASK_NOT_FOUND", err)
+	}
+	err = repo.Delete(ctx, bob.ID, inPrivate)
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "TASK_NOT_FOUND" {
+		t.Fatalf("Delete = %v, want TASK_NOT_FOUND", err)
+	}
+	var m TaskModel
+	if err := db.First(&m, inPrivate).Error; err != nil || m.Title != "内部的任务" {
+		t.Fatalf("task after bob's writes = %+v, %v", m, err)
+	}
+}
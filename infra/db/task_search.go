@@ -0,0 +1,176 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+任务的全文检索。tasks.search_vector是生成列（见migrateTasks）：标题权重A，描述权重B。
+Postgres自带的解析器按空格和标点分词，一串连续的汉字会被当成一个词，
+所以tasker_segment在每个汉字、假名两边加空格，每个字是一个词；
+查询时把连续的汉字写成短语（"会 议"即'会' <-> '议'），相当于子串匹配。
+下面的cjkClass、isCJK和SQL里的tasker_segment必须保持一致
+*/
+
+// searchConfig 全文检索的配置，英文按词干匹配，汉字不受影响
+const searchConfig = "english"
+
+// cjkClass 需要逐字切分的字符：平假名、片假名和CJK统一汉字（含扩展A和兼容汉字）
+const cjkClass = `[\u3040-\u30ff\u3400-\u4dbf\u4e00-\u9fff\uf900-\ufaff]`
+
+// tsQuery 解析websearch语法：引号是短语，or是或，-是排除
+const tsQuery = "websearch_to_tsquery('" + searchConfig + "', ?)"
+
+// 全文检索没有结果时的模糊匹配阈值，pg_trgm的word_similarity
+const fuzzyThreshold = "0.4"
+
+// 高亮片段的标记，文本先做HTML转义再交给ts_headline
+const (
+	markStart = "<mark>"
+	markStop  = "</mark>"
+)
+
+const headlineOptions = `StartSel="` + markStart + `", StopSel="` + markStop + `"`
+
+// htmlEscapeSQL 和html.EscapeString一样转义&<>"'，在SQL里做是因为ts_headline需要原文
+func htmlEscapeSQL(column string) string {
+	return "replace(replace(replace(replace(replace(" + column +
+		", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '\"', '&#34;'), '''', '&#39;')"
+}
+
+// highlightColumns 搜索结果附带的高亮：标题整体返回，描述只取命中的片段
+var highlightColumns = "ts_headline('" + searchConfig + "', tasker_segment(" + htmlEscapeSQL("tasks.title") + "), " + tsQuery +
+	", 'HighlightAll=true, " + headlineOptions + "') AS title_highlight, " +
+	"ts_headline('" + searchConfig + "', tasker_segment(" + htmlEscapeSQL("tasks.description") + "), " + tsQuery +
+	", 'MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \", " + headlineOptions + "') AS description_highlight"
+
+// taskSearchRow 带相关度和高亮的查询结果
+type taskSearchRow struct {
+	TaskModel            `gorm:"embedded"`
+	SearchRank           *float64
+	TitleHighlight       *string
+	DescriptionHighlight *string
+}
+
+func isCJK(r rune) bool {
+	return (r >= 0x3040 && r <= 0x30ff) || (r >= 0x3400 && r <= 0x4dbf) ||
+		(r >= 0x4e00 && r <= 0x9fff) || (r >= 0xf900 && r <= 0xfaff)
+}
+
+// searchQuery 把用户输入改写成websearch_to_tsquery能按字匹配汉字的形式：
+// 引号外连续的汉字变成短语，"-会议"变成-"会 议"；引号里的汉字之间加空格
+func searchQuery(q string) string {
+	var b strings.Builder
+	runes := []rune(q)
+	inQuote := false
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if !isCJK(r) {
+			if r == '"' {
+				inQuote = !inQuote
+			}
+			b.WriteRune(r)
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isCJK(runes[j]) {
+			j++
+		}
+		chars := make([]string, 0, j-i)
+		for _, c := range runes[i:j] {
+			chars = append(chars, string(c))
+		}
+		phrase := strings.Join(chars, " ")
+		switch {
+		case inQuote:
+			b.WriteString(" " + phrase + " ")
+		case strings.HasSuffix(b.String(), "-"):
+			b.WriteString(`"` + phrase + `" `)
+		default:
+			b.WriteString(` "` + phrase + `" `)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// segment 和SQL里的tasker_segment一样在汉字两边加空格，用于phraseto_tsquery的参数
+func segment(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if isCJK(r) {
+			b.WriteString(" " + string(r) + " ")
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// fuzzyTerms 模糊匹配用的纯文本：去掉引号、排除的词和or
+func fuzzyTerms(q string) string {
+	var terms []string
+	for _, f := range strings.Fields(strings.ReplaceAll(q, `"`, " ")) {
+		if strings.HasPrefix(f, "-") || strings.EqualFold(f, "or") {
+			continue
+		}
+		terms = append(terms, f)
+	}
+	return strings.Join(terms, " ")
+}
+
+// unsegment 去掉tasker_segment在汉字两边加的空格。
+// 原文里挨着汉字的空格和汉字之间隔着一个加上的空格，所以只删紧挨着汉字的空格（跳过高亮标记）
+func unsegment(s string) string {
+	type piece struct {
+		text string
+		r    rune
+	}
+	var pieces []piece
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], markStart):
+			pieces = append(pieces, piece{text: markStart})
+			i += len(markStart)
+		case strings.HasPrefix(s[i:], markStop):
+			pieces = append(pieces, piece{text: markStop})
+			i += len(markStop)
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			pieces = append(pieces, piece{text: s[i : i+size], r: r})
+			i += size
+		}
+	}
+	neighbor := func(i, step int) rune {
+		for i += step; i >= 0 && i < len(pieces); i += step {
+			if pieces[i].r != 0 {
+				return pieces[i].r
+			}
+		}
+		return 0
+	}
+
+	var b strings.Builder
+	for i, p := range pieces {
+		if p.r == ' ' && (isCJK(neighbor(i, -1)) || isCJK(neighbor(i, 1))) {
+			continue
+		}
+		b.WriteString(p.text)
+	}
+	return strings.ReplaceAll(b.String(), markStop+markStart, "")
+}
+
+// highlightOf 描述里没有命中时ts_headline返回开头几个词，这种情况不返回描述片段
+func highlightOf(row *taskSearchRow) (title, description string) {
+	if row.TitleHighlight != nil {
+		title = strings.TrimFunc(unsegment(*row.TitleHighlight), unicode.IsSpace)
+	}
+	if row.DescriptionHighlight != nil && strings.Contains(*row.DescriptionHighlight, markStart) {
+		description = strings.TrimFunc(unsegment(*row.DescriptionHighlight), unicode.IsSpace)
+	}
+	return title, description
+}
@@ -0,0 +1,93 @@
+package db
+
+import "testing"
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		name, q, want string
+	}{
+		{"ascii only", "weekly report", "weekly report"},
+		{"chinese becomes a phrase", "会议", ` "会 议" `},
+		{"chinese then ascii", "周会 report", ` "周 会"  report`},
+		{"ascii glued to chinese", "Q3会议", `Q3 "会 议" `},
+		{"kana", "カタカナ", ` "カ タ カ ナ" `},
+		{"full-width punctuation ends the phrase", "新年快乐！", ` "新 年 快 乐" ！`},
+		// 引号里已经是短语，只在汉字之间加空格
+		{"quoted chinese", `"会议 纪要"`, `" 会 议   纪 要 "`},
+		{"quoted mixed", `"weekly 会议"`, `"weekly  会 议 "`},
+		{"unclosed quote", `"会议`, `" 会 议 `},
+		// 排除的汉字整个短语都排除
+		{"excluded chinese", "-会议", `-"会 议" `},
+		{"excluded chinese after a term", "会议 -周报", ` "会 议"  -"周 报" `},
+		{"excluded ascii", "-report 周会", `-report  "周 会" `},
+		{"or", "会议 or 周报", ` "会 议"  or  "周 报" `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchQuery(tt.q); got != tt.want {
+				t.Fatalf("searchQuery(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuzzyTerms(t *testing.T) {
+	tests := []struct {
+		name, q, want string
+	}{
+		{"plain", "周会 report", "周会 report"},
+		{"quotes removed", `"会议 纪要" report`, "会议 纪要 report"},
+		{"exclusions dropped", "会议 -周报 -draft", "会议"},
+		{"only exclusions", "-会议", ""},
+		{"or dropped", "会议 OR 周报 or report", "会议 周报 report"},
+		{"or inside a word kept", "order", "order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fuzzyTerms(tt.q); got != tt.want {
+				t.Fatalf("fuzzyTerms(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegment(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"weekly  report", "weekly  report"},
+		{"写周报", " 写  周  报 "},
+		{"写 report", " 写  report"},
+		{"Q3规划", "Q3 规  划 "},
+		{"会议,OK", " 会  议 ,OK"},
+	}
+	for _, tt := range tests {
+		got := segment(tt.in)
+		if got != tt.want {
+			t.Errorf("segment(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		// 原文里的空格原样保留
+		if back := unsegment(got); back != tt.in {
+			t.Errorf("unsegment(segment(%q)) = %q", tt.in, back)
+		}
+	}
+}
+
+func TestUnsegment(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"no chinese", "<mark>weekly</mark> report", "<mark>weekly</mark> report"},
+		{"marked chars merge into one mark", " 周  <mark>会</mark>  <mark>议</mark>  和  report", "周<mark>会议</mark>和 report"},
+		{"mark next to ascii", "<mark>Q3</mark>  规  划 ", "<mark>Q3</mark> 规划"},
+		{"original space between chinese", " 写  周  报   <mark>会</mark>  议 ", "写周报 <mark>会</mark>议"},
+		{"marked ascii between chinese", " 写  <mark>report</mark>  完 ", "写 <mark>report</mark> 完"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unsegment(tt.in); got != tt.want {
+				t.Fatalf("unsegment(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
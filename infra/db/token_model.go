@@ -0,0 +1,23 @@
+package db
+
+import "time"
+
+// AccessTokenModel 个人访问令牌，只存sha256哈希
+type AccessTokenModel struct {
+	ID     int64  `gorm:"primaryKey;autoIncrement"`
+	UserID int64  `gorm:"not null;index"`
+	Name   string `gorm:"type:varchar(100);not null"`
+	Prefix string `gorm:"type:varchar(20);not null"`
+	Hash   string `gorm:"type:char(64);not null;uniqueIndex"`
+	// 逗号分隔的scope列表，例如 tasks:read,tasks:write
+	Scopes string `gorm:"type:varchar(255);not null"`
+
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+func (AccessTokenModel) TableName() string {
+	return "access_tokens"
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"tasker/core/token"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func tokenToDomain(m *AccessTokenModel) *token.Token {
+	scopes := make([]token.Scope, 0)
+	for _, s := range strings.Split(m.Scopes, ",") {
+		if s != "" {
+			scopes = append(scopes, token.Scope(s))
+		}
+	}
+	return &token.Token{
+		ID:         m.ID,
+		UserID:     m.UserID,
+		Name:       m.Name,
+		Prefix:     m.Prefix,
+		Hash:       m.Hash,
+		Scopes:     scopes,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+		RevokedAt:  m.RevokedAt,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+func tokenToModel(t *token.Token) *AccessTokenModel {
+	scopes := make([]string, 0, len(t.Scopes))
+	for _, s := range t.Scopes {
+		scopes = append(scopes, string(s))
+	}
+	return &AccessTokenModel{
+		ID:         t.ID,
+		UserID:     t.UserID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Hash:       t.Hash,
+		Scopes:     strings.Join(scopes, ","),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		RevokedAt:  t.RevokedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func (r *TokenRepository) Create(ctx context.Context, t *token.Token) error {
+	m := tokenToModel(t)
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create token")
+	}
+	t.ID = m.ID
+	return nil
+}
+
+func (r *TokenRepository) ListByUserID(ctx context.Context, userID int64) ([]*token.Token, error) {
+	var models []AccessTokenModel
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list tokens")
+	}
+	items := make([]*token.Token, 0, len(models))
+	for i := range models {
+		items = append(items, tokenToDomain(&models[i]))
+	}
+	return items, nil
+}
+
+func (r *TokenRepository) GetByHash(ctx context.Context, hash string) (*token.Token, error) {
+	var m AccessTokenModel
+	tx := r.db.WithContext(ctx).Where("hash = ?", hash).First(&m)
+	if tx.Error != nil {
+		if tx.Error == gorm.ErrRecordNotFound {
+			return nil, apperror.New("INVALID_TOKEN", "invalid token")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get token")
+	}
+	return tokenToDomain(&m), nil
+}
+
+func (r *TokenRepository) Revoke(ctx context.Context, userID, id int64, at time.Time) error {
+	tx := r.db.WithContext(ctx).Model(&AccessTokenModel{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", at)
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to revoke token")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("TOKEN_NOT_FOUND", "token not found")
+	}
+	return nil
+}
+
+func (r *TokenRepository) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&AccessTokenModel{}).Where("id = ?", id).Update("last_used_at", at).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to update token")
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"tasker/core/group"
+	"tasker/core/task"
+	"tasker/core/transfer"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+// 导出时每批读取的任务数
+const exportBatchSize = 500
+
+type TransferRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) *TransferRepository {
+	return &TransferRepository{db: db}
+}
+
+func (r *TransferRepository) ListGroups(ctx context.Context, workspaceID int64) ([]*group.Group, error) {
+	var models []GroupModel
+	if err := r.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).Order("id ASC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list groups")
+	}
+	groups := make([]*group.Group, 0, len(models))
+	for i := range models {
+		groups = append(groups, groupToDomain(&models[i]))
+	}
+	return groups, nil
+}
+
+func (r *TransferRepository) StreamTasks(ctx context.Context, workspaceID int64, fn func(batch []*transfer.Record) error) error {
+	return streamRecords(r.db.WithContext(ctx), true, func(db *gorm.DB) *gorm.DB {
+		return db.Where("workspace_id = ?", workspaceID)
+	}, fn)
+}
+
+// streamRecords 按ID翻页读取scope筛出的任务并转换成导出记录，带上分组名和标签，withComments时带上评论。
+// 按ID翻页而不是OFFSET，导出期间新增的任务不会让后面的批次错位
+func streamRecords(db *gorm.DB, withComments bool, scope func(db *gorm.DB) *gorm.DB, fn func(batch []*transfer.Record) error) error {
+	var lastID int64
+	for {
+		var models []TaskModel
+		err := scope(db.Model(&TaskModel{})).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(exportBatchSize).
+			Find(&models).Error
+		if err != nil {
+			return apperror.New("DB_ERROR", "failed to export tasks")
+		}
+		if len(models) == 0 {
+			return nil
+		}
+		lastID = models[len(models)-1].ID
+
+		ids := make([]int64, 0, len(models))
+		groupIDs := make([]int64, 0, len(models))
+		for i := range models {
+			ids = append(ids, models[i].ID)
+			if models[i].GroupID != nil {
+				groupIDs = append(groupIDs, *models[i].GroupID)
+			}
+		}
+		var groups []GroupModel
+		if len(groupIDs) > 0 {
+			if err := db.Select("id", "name").Where("id IN ?", groupIDs).Find(&groups).Error; err != nil {
+				return apperror.New("DB_ERROR", "failed to export tasks")
+			}
+		}
+		names := make(map[int64]string, len(groups))
+		for _, g := range groups {
+			names[g.ID] = g.Name
+		}
+
+		batch := make([]*transfer.Record, 0, len(models))
+		byID := make(map[int64]*transfer.Record, len(models))
+		for i := range models {
+			m := &models[i]
+			createdAt, updatedAt := m.CreatedAt, m.UpdatedAt
+			rec := &transfer.Record{
+				ID:          m.ID,
+				ExternalID:  derefString(m.ExternalID),
+				Title:       m.Title,
+				Description: m.Description,
+				Status:      m.Status,
+				Category:    group.Category(m.StatusCategory),
+				Priority:    m.Priority,
+				DueDate:     m.DueData,
+				DueOn:       m.DueOn,
+				CompletedAt: m.CompletedAt,
+				CreatedAt:   &createdAt,
+				UpdatedAt:   &updatedAt,
+			}
+			if m.GroupID != nil {
+				rec.Group = names[*m.GroupID]
+			}
+			batch = append(batch, rec)
+			byID[m.ID] = rec
+		}
+
+		var tags []TaskTagModel
+		if err := db.Where("task_id IN ?", ids).Order("lower(tag) ASC").Find(&tags).Error; err != nil {
+			return apperror.New("DB_ERROR", "failed to export tasks")
+		}
+		for _, tag := range tags {
+			rec := byID[tag.TaskID]
+			rec.Tags = append(rec.Tags, tag.Tag)
+		}
+
+		if withComments {
+			var comments []commentWithUsername
+			err = db.Table("comments").
+				Select("comments.*, COALESCE(users.username, '') AS username").
+				Joins("LEFT JOIN users ON users.id = comments.user_id").
+				Where("comments.task_id IN ?", ids).
+				Order("comments.created_at ASC, comments.id ASC").
+				Scan(&comments).Error
+			if err != nil {
+				return apperror.New("DB_ERROR", "failed to export comments")
+			}
+			for _, c := range comments {
+				rec := byID[c.TaskID]
+				rec.Comments = append(rec.Comments, transfer.CommentRecord{Author: c.Username, Body: c.Body, CreatedAt: c.CreatedAt})
+			}
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(models) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+func (r *TransferRepository) FindByExternalIDs(ctx context.Context, workspaceID int64, ids []string) (map[string]*task.Task, error) {
+	out := make(map[string]*task.Task, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var models []TaskModel
+	if err := r.db.WithContext(ctx).Where("workspace_id = ? AND external_id IN ?", workspaceID, ids).Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to find tasks")
+	}
+	tasks := make([]*task.Task, 0, len(models))
+	for i := range models {
+		t := toDomain(&models[i])
+		tasks = append(tasks, t)
+		out[t.ExternalID] = t
+	}
+	if err := (&TaskRepository{db: r.db}).loadRelations(ctx, tasks); err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to find tasks")
+	}
+	return out, nil
+}
+
+func (r *TransferRepository) Apply(ctx context.Context, plan *transfer.Plan) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, g := range plan.Groups {
+			m := groupToModel(g)
+			if err := tx.Create(m).Error; err != nil {
+				return err
+			}
+			g.ID = m.ID
+		}
+		if err := placeAtEnd(tx, plan.Placed); err != nil {
+			return err
+		}
+		if len(plan.Creates) > 0 {
+			models := make([]*TaskModel, 0, len(plan.Creates))
+			for _, t := range plan.Creates {
+				models = append(models, toModel(t))
+			}
+			if err := tx.CreateInBatches(models, 500).Error; err != nil {
+				return err
+			}
+			var tags []TaskTagModel
+			for i, m := range models {
+				plan.Creates[i].ID = m.ID
+				for _, tag := range plan.Creates[i].Tags {
+					tags = append(tags, TaskTagModel{TaskID: m.ID, Tag: tag, CreatedAt: m.CreatedAt})
+				}
+			}
+			if len(tags) > 0 {
+				if err := tx.CreateInBatches(tags, 500).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for _, t := range plan.Updates {
+			m := toModel(t)
+			err := tx.Model(&TaskModel{}).Where("id = ?", t.ID).Updates(map[string]any{
+				"title":           m.Title,
+				"description":     m.Description,
+				"status":          m.Status,
+				"status_category": m.StatusCategory,
+				"priority":        m.Priority,
+				"due_data":        m.DueData,
+				"due_on":          m.DueOn,
+				"group_id":        m.GroupID,
+				"rank":            m.Rank,
+				"completed_at":    m.CompletedAt,
+				"updated_at":      m.UpdatedAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if err := replaceTags(tx, t.ID, t.Tags, t.UpdatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperror.New("IMPORT_CONFLICT", "another request created groups or tasks with the same names or external_ids, try again")
+		}
+		return apperror.New("DB_ERROR", "failed to import tasks")
+	}
+	return nil
+}
+
+// placeAtEnd 把导入的任务按顺序排到各自分组的最后：在分组的排序锁里先重排已有任务，
+// 再接着编号。逐个取rank.Between(last, "")时rank每几个任务长一位，几千行就会超出列宽
+func placeAtEnd(tx *gorm.DB, placed []transfer.Placement) error {
+	byGroup := make(map[int64][]*task.Task)
+	var groupIDs []int64
+	for _, p := range placed {
+		if _, ok := byGroup[p.Group.ID]; !ok {
+			groupIDs = append(groupIDs, p.Group.ID)
+		}
+		byGroup[p.Group.ID] = append(byGroup[p.Group.ID], p.Task)
+	}
+	// 按ID顺序加锁，同时导入多个分组的请求不会互相死锁
+	sort.Slice(groupIDs, func(i, j int) bool { return groupIDs[i] < groupIDs[j] })
+	for _, groupID := range groupIDs {
+		if err := lockRanks(tx, groupID); err != nil {
+			return err
+		}
+		if err := tx.Exec(rebalanceRanksSQL, groupID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&TaskModel{}).Where("group_id = ?", groupID).Count(&count).Error; err != nil {
+			return err
+		}
+		numberRanks(groupID, count, byGroup[groupID])
+	}
+	return nil
+}
+
+// numberRanks 从分组里第count个任务之后接着编号，格式同rebalanceRanksSQL
+func numberRanks(groupID, count int64, tasks []*task.Task) {
+	for i, t := range tasks {
+		id := groupID
+		t.GroupID = &id
+		t.Rank = fmt.Sprintf("%08xV", count+int64(i)+1)
+	}
+}
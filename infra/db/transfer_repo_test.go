@@ -0,0 +1,40 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"tasker/core/task"
+	"tasker/pkg/rank"
+)
+
+func TestNumberRanks(t *testing.T) {
+	const rows = 2500
+	tasks := make([]*task.Task, rows)
+	for i := range tasks {
+		tasks[i] = &task.Task{}
+	}
+	// 分组里已有3个任务，重排后是00000001V..00000003V
+	numberRanks(9, 3, tasks)
+
+	prev := "00000003V"
+	for i, tk := range tasks {
+		if tk.GroupID == nil || *tk.GroupID != 9 {
+			t.Fatalf("task %d group = %v", i, tk.GroupID)
+		}
+		if len(tk.Rank) != 9 {
+			t.Fatalf("task %d rank %q is %d bytes, want 9", i, tk.Rank, len(tk.Rank))
+		}
+		if tk.Rank <= prev {
+			t.Fatalf("task %d rank %q does not sort after %q", i, tk.Rank, prev)
+		}
+		// 之后拖动时还能插到两个导入的任务之间
+		if _, err := rank.Between(prev, tk.Rank); err != nil {
+			t.Fatalf("no room between %q and %q: %v", prev, tk.Rank, err)
+		}
+		prev = tk.Rank
+	}
+	if want := fmt.Sprintf("%08xV", rows+3); prev != want {
+		t.Fatalf("last rank = %q, want %q", prev, want)
+	}
+}
@@ -3,13 +3,73 @@ package db
 import "time"
 
 type UserModel struct {
-	ID        int64     `gorm:"primaryKey;autoIncrement"`
-	Username  string    `gorm:"type:varchar(255);not null;uniqueIndex"`
-	Password  string    `gorm:"type:varchar(255);not null"`
-	CreatedAt time.Time `gorm:"not null"`
-	UpdatedAt time.Time `gorm:"not null"`
+	ID       int64  `gorm:"primaryKey;autoIncrement"`
+	Username string `gorm:"type:varchar(255);not null;uniqueIndex"`
+	Password string `gorm:"type:varchar(255);not null"`
+	Email    string `gorm:"type:varchar(255);not null;default:''"`
+	// 个人资料与偏好
+	DisplayName    string `gorm:"type:varchar(50);not null;default:''"`
+	AvatarURL      string `gorm:"type:varchar(512);not null;default:''"`
+	Timezone       string `gorm:"type:varchar(64);not null;default:'Asia/Shanghai'"`
+	Locale         string `gorm:"type:varchar(35);not null;default:'zh-CN'"`
+	WeekStart      int    `gorm:"type:smallint;not null;default:1"`
+	DefaultGroupID *int64
+	// 系统角色和账号状态
+	Role                  string `gorm:"type:varchar(20);not null;default:'user'"`
+	DisabledAt            *time.Time
+	PasswordResetRequired bool `gorm:"not null;default:false"`
+	// TOTP两步验证
+	TOTPSecret   string    `gorm:"column:totp_secret;type:varchar(64);not null;default:''"`
+	TOTPEnabled  bool      `gorm:"column:totp_enabled;not null;default:false"`
+	TOTPLastStep int64     `gorm:"column:totp_last_step;not null;default:0"`
+	CreatedAt    time.Time `gorm:"not null"`
+	UpdatedAt    time.Time `gorm:"not null"`
 }
 
 func (UserModel) TableName() string {
 	return "users"
-}
\ No newline at end of file
+}
+
+// RecoveryCodeModel 两步验证恢复码，只存sha256，用过即作废
+type RecoveryCodeModel struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement"`
+	UserID    int64  `gorm:"not null;index"`
+	Hash      string `gorm:"type:char(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (RecoveryCodeModel) TableName() string {
+	return "user_recovery_codes"
+}
+
+// SessionModel 登录会话，id即JWT的jti
+type SessionModel struct {
+	ID        string `gorm:"type:varchar(64);primaryKey"`
+	UserID    int64  `gorm:"not null;index"`
+	UserAgent string `gorm:"type:varchar(255);not null;default:''"`
+	IP        string `gorm:"type:varchar(64);not null;default:''"`
+	// 管理员代登录时的管理员ID
+	ImpersonatorID *int64
+	ExpiresAt      time.Time `gorm:"not null"`
+	RevokedAt      *time.Time
+	CreatedAt      time.Time `gorm:"not null"`
+}
+
+func (SessionModel) TableName() string {
+	return "sessions"
+}
+
+// PasswordResetModel 找回密码token，只存sha256
+type PasswordResetModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	UserID    int64     `gorm:"not null;index"`
+	Hash      string    `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (PasswordResetModel) TableName() string {
+	return "password_resets"
+}
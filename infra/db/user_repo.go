@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"errors"
 	"gorm.io/gorm"
 	"tasker/core/user"
 	"tasker/pkg/apperror"
+	"time"
 )
 
 type UserRepository struct {
@@ -17,28 +19,53 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 
 func userToDomain(m *UserModel) *user.User {
 	return &user.User{
-		ID:        m.ID,
-		Username:  m.Username,
-		Password:  m.Password, // 这里仍然是哈希，Service 会决定是否清掉
-		CreatedAt: m.CreatedAt,
-		UpdatedAt: m.UpdatedAt,
+		ID:                    m.ID,
+		Username:              m.Username,
+		Password:              m.Password, // 这里仍然是哈希，Service 会决定是否清掉
+		Email:                 m.Email,
+		DisplayName:           m.DisplayName,
+		AvatarURL:             m.AvatarURL,
+		Timezone:              m.Timezone,
+		Locale:                m.Locale,
+		WeekStart:             time.Weekday(m.WeekStart),
+		DefaultGroupID:        m.DefaultGroupID,
+		Role:                  user.Role(m.Role),
+		DisabledAt:            m.DisabledAt,
+		PasswordResetRequired: m.PasswordResetRequired,
+		TOTPSecret:            m.TOTPSecret,
+		TOTPEnabled:           m.TOTPEnabled,
+		TOTPLastStep:          m.TOTPLastStep,
+		CreatedAt:             m.CreatedAt,
+		UpdatedAt:             m.UpdatedAt,
 	}
 }
 
 func userToModel(u *user.User) *UserModel {
 	return &UserModel{
-		ID:        u.ID,
-		Username:  u.Username,
-		Password:  u.Password,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		ID:             u.ID,
+		Username:       u.Username,
+		Password:       u.Password,
+		Email:          u.Email,
+		DisplayName:    u.DisplayName,
+		AvatarURL:      u.AvatarURL,
+		Timezone:       u.Timezone,
+		Locale:         u.Locale,
+		WeekStart:      int(u.WeekStart),
+		DefaultGroupID: u.DefaultGroupID,
+		Role:           string(u.Role),
+		DisabledAt:     u.DisabledAt,
+		TOTPSecret:     u.TOTPSecret,
+		TOTPEnabled:    u.TOTPEnabled,
+		TOTPLastStep:   u.TOTPLastStep,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
 	}
 }
 
 // 实现user.Repository接口
 
 func (r *UserRepository) Create(ctx context.Context, u *user.User) error {
-	m :=userToModel(u)
+	m := userToModel(u)
 	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
 		// 这里可以更细化处理唯一约束错误，先简单用一个统一的DB_ERROR
 		return apperror.New("DB_ERROR", "failed to create user")
@@ -69,4 +96,422 @@ func (r *UserRepository) GetByID(ctx context.Context, id int64) (*user.User, err
 		return nil, apperror.New("DB_ERROR", "failed to get user by id")
 	}
 	return userToDomain(&m), nil
-}
\ No newline at end of file
+}
+
+func (r *UserRepository) UpdateTOTP(ctx context.Context, userID int64, secret string, enabled bool) error {
+	tx := r.db.WithContext(ctx).Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{
+		"totp_secret":  secret,
+		"totp_enabled": enabled,
+		"updated_at":   time.Now(),
+	})
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update user")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("USER_NOT_FOUND", "user not found")
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateTOTPLastStep(ctx context.Context, userID int64, step int64) error {
+	// 条件里带上step，并发提交同一个验证码时只有一个能成功
+	tx := r.db.WithContext(ctx).Model(&UserModel{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update user")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("INVALID_MFA_CODE", "invalid verification code")
+	}
+	return nil
+}
+
+func (r *UserRepository) SetTOTP(ctx context.Context, userID int64, secret string, enabled bool, recoveryHashes []string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{
+			"totp_secret":  secret,
+			"totp_enabled": enabled,
+			"updated_at":   now,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCodeModel{}).Error; err != nil {
+			return err
+		}
+		if len(recoveryHashes) == 0 {
+			return nil
+		}
+		models := make([]RecoveryCodeModel, 0, len(recoveryHashes))
+		for _, h := range recoveryHashes {
+			models = append(models, RecoveryCodeModel{UserID: userID, Hash: h, CreatedAt: now})
+		}
+		return tx.Create(&models).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return apperror.New("USER_NOT_FOUND", "user not found")
+		}
+		return apperror.New("DB_ERROR", "failed to save two-factor settings")
+	}
+	return nil
+}
+
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, userID int64, hash string, at time.Time) (bool, error) {
+	tx := r.db.WithContext(ctx).Model(&RecoveryCodeModel{}).
+		Where("user_id = ? AND hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at)
+	if tx.Error != nil {
+		return false, apperror.New("DB_ERROR", "failed to use recovery code")
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID int64, hash string) error {
+	// 改过密码就不再要求重置
+	tx := r.db.WithContext(ctx).Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{
+		"password":                hash,
+		"password_reset_required": false,
+		"updated_at":              time.Now(),
+	})
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update password")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("USER_NOT_FOUND", "user not found")
+	}
+	return nil
+}
+
+func (r *UserRepository) CreateSession(ctx context.Context, sess *user.Session) error {
+	m := &SessionModel{
+		ID:             sess.ID,
+		UserID:         sess.UserID,
+		UserAgent:      sess.UserAgent,
+		IP:             sess.IP,
+		ExpiresAt:      sess.ExpiresAt,
+		CreatedAt:      sess.CreatedAt,
+		ImpersonatorID: sess.ImpersonatorID,
+	}
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create session")
+	}
+	return nil
+}
+
+func (r *UserRepository) GetSession(ctx context.Context, id string) (*user.Session, error) {
+	var m SessionModel
+	tx := r.db.WithContext(ctx).Where("id = ?", id).First(&m)
+	if tx.Error != nil {
+		if tx.Error == gorm.ErrRecordNotFound {
+			return nil, apperror.New("SESSION_REVOKED", "session is no longer valid")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get session")
+	}
+	return &user.Session{
+		ID:             m.ID,
+		UserID:         m.UserID,
+		UserAgent:      m.UserAgent,
+		IP:             m.IP,
+		ImpersonatorID: m.ImpersonatorID,
+		ExpiresAt:      m.ExpiresAt,
+		RevokedAt:      m.RevokedAt,
+		CreatedAt:      m.CreatedAt,
+	}, nil
+}
+
+func (r *UserRepository) RevokeSessions(ctx context.Context, userID int64, exceptID string, at time.Time) error {
+	q := r.db.WithContext(ctx).Model(&SessionModel{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptID != "" {
+		q = q.Where("id <> ?", exceptID)
+	}
+	if err := q.Update("revoked_at", at).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to revoke sessions")
+	}
+	return nil
+}
+
+func (r *UserRepository) CreatePasswordReset(ctx context.Context, pr *user.PasswordReset) error {
+	m := &PasswordResetModel{
+		UserID:    pr.UserID,
+		Hash:      pr.Hash,
+		ExpiresAt: pr.ExpiresAt,
+		CreatedAt: pr.CreatedAt,
+	}
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create password reset")
+	}
+	pr.ID = m.ID
+	return nil
+}
+
+func (r *UserRepository) GetPasswordReset(ctx context.Context, hash string, at time.Time) (*user.PasswordReset, error) {
+	var m PasswordResetModel
+	err := r.db.WithContext(ctx).Where("hash = ? AND used_at IS NULL AND expires_at > ?", hash, at).First(&m).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.New("INVALID_RESET_TOKEN", "invalid or expired reset token")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get password reset")
+	}
+	return passwordResetToDomain(&m), nil
+}
+
+func (r *UserRepository) ConsumePasswordReset(ctx context.Context, hash string, at time.Time) (*user.PasswordReset, error) {
+	var m PasswordResetModel
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证同一个token并发提交时只有一个成功
+		res := tx.Model(&PasswordResetModel{}).
+			Where("hash = ? AND used_at IS NULL AND expires_at > ?", hash, at).
+			Update("used_at", at)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("hash = ?", hash).First(&m).Error; err != nil {
+			return err
+		}
+		// 同一用户其他未使用的token一并作废
+		return tx.Model(&PasswordResetModel{}).
+			Where("user_id = ? AND used_at IS NULL", m.UserID).
+			Update("used_at", at).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.New("INVALID_RESET_TOKEN", "invalid or expired reset token")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to consume password reset")
+	}
+	return passwordResetToDomain(&m), nil
+}
+
+func passwordResetToDomain(m *PasswordResetModel) *user.PasswordReset {
+	return &user.PasswordReset{
+		ID:        m.ID,
+		UserID:    m.UserID,
+		Hash:      m.Hash,
+		ExpiresAt: m.ExpiresAt,
+		UsedAt:    m.UsedAt,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, u *user.User) error {
+	tx := r.db.WithContext(ctx).Model(&UserModel{}).Where("id = ?", u.ID).Updates(map[string]any{
+		"email":            u.Email,
+		"display_name":     u.DisplayName,
+		"avatar_url":       u.AvatarURL,
+		"timezone":         u.Timezone,
+		"locale":           u.Locale,
+		"week_start":       int(u.WeekStart),
+		"default_group_id": u.DefaultGroupID,
+		"updated_at":       u.UpdatedAt,
+	})
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update user")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("USER_NOT_FOUND", "user not found")
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateUsername(ctx context.Context, userID int64, username string, at time.Time) error {
+	tx := r.db.WithContext(ctx).Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{
+		"username":   username,
+		"updated_at": at,
+	})
+	if tx.Error != nil {
+		if isUniqueViolation(tx.Error) {
+			return apperror.New("USERNAME_EXISTS", "username already exists")
+		}
+		return apperror.New("DB_ERROR", "failed to update username")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("USER_NOT_FOUND", "user not found")
+	}
+	return nil
+}
+
+// errOwnsSharedWorkspace 事务内部用来区分还拥有共享工作区的情况
+var errOwnsSharedWorkspace = errors.New("owns shared workspace")
+
+func (r *UserRepository) DeleteAccount(ctx context.Context, userID int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 拥有的共享工作区里还有其他成员时不能注销，需要先转让所有权
+		var shared int64
+		if err := tx.Model(&WorkspaceMemberModel{}).
+			Where("user_id <> ? AND workspace_id IN (?)", userID,
+				tx.Model(&WorkspaceModel{}).Select("id").Where("owner_id = ?", userID)).
+			Count(&shared).Error; err != nil {
+			return err
+		}
+		if shared > 0 {
+			return errOwnsSharedWorkspace
+		}
+
+		// 拥有的工作区（含个人工作区）连同分组、任务一起删除
+		var owned []int64
+		if err := tx.Model(&WorkspaceModel{}).Where("owner_id = ?", userID).Pluck("id", &owned).Error; err != nil {
+			return err
+		}
+		if err := deleteWorkspaces(tx, owned); err != nil {
+			return err
+		}
+
+		// 在别人工作区里创建的分组、任务和写的评论、留下的动态保留，只把创建者和作者置为NULL。
+		// 用UpdateColumn不改updated_at，这些内容本身没有变化
+		for _, m := range []any{&GroupModel{}, &TaskModel{}, &CommentModel{}} {
+			if err := tx.Model(m).Where("user_id = ?", userID).UpdateColumn("user_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&ActivityModel{}).Where("actor_id = ?", userID).UpdateColumn("actor_id", nil).Error; err != nil {
+			return err
+		}
+
+		// 先删依赖用户的数据，最后删用户本身
+		for _, m := range []any{
+			&WorkspaceMemberModel{},
+			&TaskAssigneeModel{},
+			&MentionModel{},
+			&GroupShareModel{},
+			&NotificationModel{},
+			&SessionModel{},
+			&AccessTokenModel{},
+			&RecoveryCodeModel{},
+			&PasswordResetModel{},
+			&ViewPinModel{},
+			&CalendarFeedModel{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		// 自己的视图连同别人对它的固定一起删除
+		if err := tx.Where("view_id IN (?)", tx.Model(&ViewModel{}).Select("id").Where("user_id = ?", userID)).Delete(&ViewPinModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&ViewModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("created_by = ?", userID).Delete(&ShareLinkModel{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&UserModel{}, userID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return apperror.New("USER_NOT_FOUND", "user not found")
+		}
+		if err == errOwnsSharedWorkspace {
+			return apperror.New("OWNS_SHARED_WORKSPACE", "transfer ownership of shared workspaces before deleting the account")
+		}
+		return apperror.New("DB_ERROR", "failed to delete account")
+	}
+	return nil
+}
+
+// errLastAdmin 事务内部用来区分撤掉最后一个管理员的情况
+var errLastAdmin = errors.New("last admin")
+
+// UpdateRole 撤掉管理员时先用FOR UPDATE锁住全部管理员行再计数，并发撤掉两个管理员时
+// 后一个事务会等前一个提交，重新读到的管理员只剩一个，不会把管理员撤光
+func (r *UserRepository) UpdateRole(ctx context.Context, userID int64, role user.Role, at time.Time) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if role != user.RoleAdmin {
+			var admins []int64
+			if err := tx.Raw("SELECT id FROM users WHERE role = ? FOR UPDATE", string(user.RoleAdmin)).Scan(&admins).Error; err != nil {
+				return err
+			}
+			for _, id := range admins {
+				if id == userID && len(admins) <= 1 {
+					return errLastAdmin
+				}
+			}
+		}
+		res := tx.Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{"role": string(role), "updated_at": at})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return apperror.New("USER_NOT_FOUND", "user not found")
+		}
+		if err == errLastAdmin {
+			return apperror.New("LAST_ADMIN", "cannot remove the last admin")
+		}
+		return apperror.New("DB_ERROR", "failed to update role")
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateDisabled(ctx context.Context, userID int64, disabledAt *time.Time, at time.Time) error {
+	return r.updateFields(ctx, userID, map[string]any{"disabled_at": disabledAt, "updated_at": at})
+}
+
+func (r *UserRepository) ForcePasswordReset(ctx context.Context, pr *user.PasswordReset) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UserModel{}).Where("id = ?", pr.UserID).Updates(map[string]any{
+			"password_reset_required": true,
+			"updated_at":              pr.CreatedAt,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		m := &PasswordResetModel{
+			UserID:    pr.UserID,
+			Hash:      pr.Hash,
+			ExpiresAt: pr.ExpiresAt,
+			CreatedAt: pr.CreatedAt,
+		}
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		pr.ID = m.ID
+		if err := tx.Model(&SessionModel{}).Where("user_id = ? AND revoked_at IS NULL", pr.UserID).Update("revoked_at", pr.CreatedAt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&AccessTokenModel{}).Where("user_id = ? AND revoked_at IS NULL", pr.UserID).Update("revoked_at", pr.CreatedAt).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return apperror.New("USER_NOT_FOUND", "user not found")
+		}
+		return apperror.New("DB_ERROR", "failed to force password reset")
+	}
+	return nil
+}
+
+func (r *UserRepository) updateFields(ctx context.Context, userID int64, fields map[string]any) error {
+	tx := r.db.WithContext(ctx).Model(&UserModel{}).Where("id = ?", userID).Updates(fields)
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update user")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("USER_NOT_FOUND", "user not found")
+	}
+	return nil
+}
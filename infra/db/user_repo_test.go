@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"tasker/pkg/apperror"
+)
+
+// newTestDB 内存里的SQLite，只建注销账号涉及的表；不依赖Postgres特有的语法
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// 内存库每个连接各是一份，只用一个连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(
+		&WorkspaceModel{},
+		&WorkspaceMemberModel{},
+		&WorkspaceInviteModel{},
+		&GroupModel{},
+		&GroupShareModel{},
+		&TaskModel{},
+		&TaskAssigneeModel{},
+		&TaskTagModel{},
+		&CommentModel{},
+		&MentionModel{},
+		&NotificationModel{},
+		&ActivityModel{},
+		&ShareLinkModel{},
+		&CalendarFeedModel{},
+		&ViewModel{},
+		&ViewPinModel{},
+		&UserModel{},
+		&AccessTokenModel{},
+		&RecoveryCodeModel{},
+		&SessionModel{},
+		&PasswordResetModel{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, v any) {
+	t.Helper()
+	if err := db.Create(v).Error; err != nil {
+		t.Fatalf("create %T: %v", v, err)
+	}
+}
+
+func TestDeleteAccountKeepsDataInSharedWorkspaces(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+
+	owner := UserModel{Username: "owner", Password: "x", CreatedAt: now, UpdatedAt: now}
+	alice := UserModel{Username: "alice", Password: "x", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &owner)
+	mustCreate(t, db, &alice)
+
+	// owner的团队工作区，alice是成员；alice还有自己的个人工作区
+	team := WorkspaceModel{Name: "team", OwnerID: owner.ID, CreatedAt: now, UpdatedAt: now}
+	personal := WorkspaceModel{Name: "alice", OwnerID: alice.ID, Personal: true, CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &team)
+	mustCreate(t, db, &personal)
+	mustCreate(t, db, &WorkspaceMemberModel{WorkspaceID: team.ID, UserID: owner.ID, Role: "owner", CreatedAt: now})
+	mustCreate(t, db, &WorkspaceMemberModel{WorkspaceID: team.ID, UserID: alice.ID, Role: "member", CreatedAt: now})
+	mustCreate(t, db, &WorkspaceMemberModel{WorkspaceID: personal.ID, UserID: alice.ID, Role: "owner", CreatedAt: now})
+
+	teamGroup := GroupModel{UserID: &alice.ID, WorkspaceID: &team.ID, Name: "alice's", CreatedAt: now, UpdatedAt: now}
+	ownGroup := GroupModel{UserID: &alice.ID, WorkspaceID: &personal.ID, Name: "mine", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &teamGroup)
+	mustCreate(t, db, &ownGroup)
+
+	teamTask := TaskModel{UserID: &alice.ID, WorkspaceID: &team.ID, GroupID: &teamGroup.ID, Title: "写周报", Status: "pending", CreatedAt: earlier, UpdatedAt: earlier}
+	ownerTask := TaskModel{UserID: &owner.ID, WorkspaceID: &team.ID, Title: "review", Status: "pending", CreatedAt: earlier, UpdatedAt: earlier}
+	ownTask := TaskModel{UserID: &alice.ID, WorkspaceID: &personal.ID, GroupID: &ownGroup.ID, Title: "private", Status: "pending", CreatedAt: earlier, UpdatedAt: earlier}
+	mustCreate(t, db, &teamTask)
+	mustCreate(t, db, &ownerTask)
+	mustCreate(t, db, &ownTask)
+	mustCreate(t, db, &TaskAssigneeModel{TaskID: ownerTask.ID, UserID: alice.ID, CreatedAt: now})
+
+	comment := CommentModel{TaskID: ownerTask.ID, UserID: &alice.ID, Body: "done @owner", CreatedAt: now, UpdatedAt: now}
+	ownerComment := CommentModel{TaskID: ownerTask.ID, UserID: &owner.ID, Body: "thanks", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &comment)
+	mustCreate(t, db, &ownerComment)
+	activity := ActivityModel{WorkspaceID: team.ID, TaskID: &teamTask.ID, ActorID: &alice.ID, Type: "created", Summary: "alice created", CreatedAt: now}
+	mustCreate(t, db, &activity)
+	mustCreate(t, db, &SessionModel{ID: "s1", UserID: alice.ID, ExpiresAt: now.Add(time.Hour), CreatedAt: now})
+
+	repo := NewUserRepository(db)
+	if err := repo.DeleteAccount(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteAccount: %v", err)
+	}
+
+	var users int64
+	db.Model(&UserModel{}).Where("id = ?", alice.ID).Count(&users)
+	if users != 0 {
+		t.Fatal("user row not deleted")
+	}
+
+	// 别人工作区里的分组、任务、评论和动态都还在，只是不再指向注销的用户
+	var g GroupModel
+	if err := db.First(&g, teamGroup.ID).Error; err != nil || g.UserID != nil {
+		t.Fatalf("team group = %+v, %v", g, err)
+	}
+	var tk TaskModel
+	if err := db.First(&tk, teamTask.ID).Error; err != nil || tk.UserID != nil {
+		t.Fatalf("team task = %+v, %v", tk, err)
+	}
+	if !tk.UpdatedAt.Equal(earlier) {
+		t.Fatalf("team task updated_at changed to %v", tk.UpdatedAt)
+	}
+	tk = TaskModel{}
+	if err := db.First(&tk, ownerTask.ID).Error; err != nil || tk.UserID == nil || *tk.UserID != owner.ID {
+		t.Fatalf("owner's task = %+v, %v", tk, err)
+	}
+	var c CommentModel
+	if err := db.First(&c, comment.ID).Error; err != nil || c.UserID != nil || c.Body != "done @owner" {
+		t.Fatalf("comment = %+v, %v", c, err)
+	}
+	c = CommentModel{}
+	if err := db.First(&c, ownerComment.ID).Error; err != nil || c.UserID == nil || *c.UserID != owner.ID {
+		t.Fatalf("owner's comment = %+v, %v", c, err)
+	}
+	var a ActivityModel
+	if err := db.First(&a, activity.ID).Error; err != nil || a.ActorID != nil {
+		t.Fatalf("activity = %+v, %v", a, err)
+	}
+
+	// 读出来时作者为0、用户名为空
+	comments, err := NewCommentRepository(db).ListByTask(ctx, ownerTask.ID)
+	if err != nil {
+		t.Fatalf("ListByTask: %v", err)
+	}
+	if len(comments) != 2 || comments[0].UserID != 0 || comments[0].Username != "" || comments[1].Username != "owner" {
+		t.Fatalf("comments = %+v", comments)
+	}
+
+	// 个人工作区连同里面的分组和任务一起删除，成员关系和会话也删除
+	for name, q := range map[string]*gorm.DB{
+		"personal workspace": db.Model(&WorkspaceModel{}).Where("id = ?", personal.ID),
+		"personal group":     db.Model(&GroupModel{}).Where("id = ?", ownGroup.ID),
+		"personal task":      db.Model(&TaskModel{}).Where("id = ?", ownTask.ID),
+		"memberships":        db.Model(&WorkspaceMemberModel{}).Where("user_id = ?", alice.ID),
+		"assignments":        db.Model(&TaskAssigneeModel{}).Where("user_id = ?", alice.ID),
+		"sessions":           db.Model(&SessionModel{}).Where("user_id = ?", alice.ID),
+	} {
+		var n int64
+		if err := q.Count(&n).Error; err != nil || n != 0 {
+			t.Errorf("%s: %d rows left, %v", name, n, err)
+		}
+	}
+
+	err = repo.DeleteAccount(ctx, alice.ID)
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "USER_NOT_FOUND" {
+		t.Fatalf("second DeleteAccount = %v, want USER_NOT_FOUND", err)
+	}
+}
+
+func TestDeleteAccountRefusesSharedOwnedWorkspace(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	owner := UserModel{Username: "owner", Password: "x", CreatedAt: now, UpdatedAt: now}
+	bob := UserModel{Username: "bob", Password: "x", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &owner)
+	mustCreate(t, db, &bob)
+	team := WorkspaceModel{Name: "team", OwnerID: owner.ID, CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &team)
+	mustCreate(t, db, &WorkspaceMemberModel{WorkspaceID: team.ID, UserID: owner.ID, Role: "owner", CreatedAt: now})
+	mustCreate(t, db, &WorkspaceMemberModel{WorkspaceID: team.ID, UserID: bob.ID, Role: "member", CreatedAt: now})
+	task := TaskModel{UserID: &owner.ID, WorkspaceID: &team.ID, Title: "keep", Status: "pending", CreatedAt: now, UpdatedAt: now}
+	mustCreate(t, db, &task)
+
+	err := NewUserRepository(db).DeleteAccount(context.Background(), owner.ID)
+	if appErr, ok := apperror.IsAppError(err); !ok || appErr.Code != "OWNS_SHARED_WORKSPACE" {
+		t.Fatalf("DeleteAccount = %v, want OWNS_SHARED_WORKSPACE", err)
+	}
+	var tk TaskModel
+	if err := db.First(&tk, task.ID).Error; err != nil || tk.UserID == nil || *tk.UserID != owner.ID {
+		t.Fatalf("task after refused delete = %+v, %v", tk, err)
+	}
+}
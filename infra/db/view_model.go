@@ -0,0 +1,32 @@
+package db
+
+import "time"
+
+// ViewModel 用户保存的任务列表视图，条件存成JSON
+type ViewModel struct {
+	ID     int64 `gorm:"primaryKey;autoIncrement"`
+	UserID int64 `gorm:"not null;index"`
+	// 共享到的分组，NULL表示只有创建者能看到；分组删除时视图变回私有
+	GroupID   *int64    `gorm:"index"`
+	Name      string    `gorm:"type:varchar(100);not null"`
+	Query     string    `gorm:"type:jsonb;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+
+	Group *GroupModel `gorm:"foreignKey:GroupID;constraint:OnDelete:SET NULL"`
+}
+
+func (ViewModel) TableName() string {
+	return "views"
+}
+
+// ViewPinModel 用户固定到侧边栏的视图，(user_id, view_id)为主键
+type ViewPinModel struct {
+	UserID    int64     `gorm:"primaryKey"`
+	ViewID    int64     `gorm:"primaryKey;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+func (ViewPinModel) TableName() string {
+	return "view_pins"
+}
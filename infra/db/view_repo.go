@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"tasker/core/view"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ViewRepository struct {
+	db *gorm.DB
+}
+
+func NewViewRepository(db *gorm.DB) *ViewRepository {
+	return &ViewRepository{db: db}
+}
+
+// 读取时带上当前用户是否固定
+type viewWithPin struct {
+	ViewModel
+	Pinned bool
+}
+
+// viewToDomain 列里的JSON坏掉时按空条件处理
+func viewToDomain(m *viewWithPin) *view.View {
+	var q view.Query
+	_ = json.Unmarshal([]byte(m.Query), &q)
+	createdAt, updatedAt := m.CreatedAt, m.UpdatedAt
+	return &view.View{
+		ID:        m.ID,
+		UserID:    m.UserID,
+		Name:      m.Name,
+		Query:     q,
+		GroupID:   m.GroupID,
+		Pinned:    m.Pinned,
+		CreatedAt: &createdAt,
+		UpdatedAt: &updatedAt,
+	}
+}
+
+func encodeViewQuery(q view.Query) (string, error) {
+	buf, err := json.Marshal(q)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (r *ViewRepository) withPin(ctx context.Context, viewerID int64) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&ViewModel{}).
+		Select("views.*, view_pins.user_id IS NOT NULL AS pinned").
+		Joins("LEFT JOIN view_pins ON view_pins.view_id = views.id AND view_pins.user_id = ?", viewerID)
+}
+
+func (r *ViewRepository) Create(ctx context.Context, v *view.View) error {
+	raw, err := encodeViewQuery(v.Query)
+	if err != nil {
+		return apperror.New("INTERNAL_ERROR", "failed to encode view query")
+	}
+	m := ViewModel{
+		UserID:    v.UserID,
+		GroupID:   v.GroupID,
+		Name:      v.Name,
+		Query:     raw,
+		CreatedAt: *v.CreatedAt,
+		UpdatedAt: *v.UpdatedAt,
+	}
+	if err := r.db.WithContext(ctx).Create(&m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create view")
+	}
+	v.ID = m.ID
+	return nil
+}
+
+func (r *ViewRepository) GetByID(ctx context.Context, viewerID, id int64) (*view.View, error) {
+	var row viewWithPin
+	if err := r.withPin(ctx, viewerID).Where("views.id = ?", id).Take(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.New("VIEW_NOT_FOUND", "view not found")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get view")
+	}
+	return viewToDomain(&row), nil
+}
+
+func (r *ViewRepository) ListVisible(ctx context.Context, userID int64) ([]*view.View, error) {
+	var rows []viewWithPin
+	err := r.withPin(ctx, userID).
+		Where(r.db.Where("views.user_id = ?", userID).
+			Or("views.group_id IN (?)", r.db.Model(&GroupModel{}).Select("id").Where(accessibleGroups(r.db, userID)))).
+		Order("pinned DESC, lower(views.name) ASC, views.id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list views")
+	}
+	items := make([]*view.View, 0, len(rows))
+	for i := range rows {
+		items = append(items, viewToDomain(&rows[i]))
+	}
+	return items, nil
+}
+
+func (r *ViewRepository) Update(ctx context.Context, v *view.View) error {
+	raw, err := encodeViewQuery(v.Query)
+	if err != nil {
+		return apperror.New("INTERNAL_ERROR", "failed to encode view query")
+	}
+	tx := r.db.WithContext(ctx).Model(&ViewModel{}).Where("id = ?", v.ID).Updates(map[string]any{
+		"name":       v.Name,
+		"query":      raw,
+		"group_id":   v.GroupID,
+		"updated_at": *v.UpdatedAt,
+	})
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update view")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("VIEW_NOT_FOUND", "view not found")
+	}
+	return nil
+}
+
+func (r *ViewRepository) Delete(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("view_id = ?", id).Delete(&ViewPinModel{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&ViewModel{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("VIEW_NOT_FOUND", "view not found")
+		}
+		return apperror.New("DB_ERROR", "failed to delete view")
+	}
+	return nil
+}
+
+// SetPinned 重复固定或取消固定都不报错
+func (r *ViewRepository) SetPinned(ctx context.Context, userID, id int64, pinned bool, at time.Time) error {
+	db := r.db.WithContext(ctx)
+	var err error
+	if pinned {
+		err = db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&ViewPinModel{UserID: userID, ViewID: id, CreatedAt: at}).Error
+	} else {
+		err = db.Where("user_id = ? AND view_id = ?", userID, id).Delete(&ViewPinModel{}).Error
+	}
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to pin view")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import "time"
+
+// WorkspaceModel 工作区，分组和任务都挂在工作区下
+type WorkspaceModel struct {
+	ID      int64  `gorm:"primaryKey;autoIncrement"`
+	Name    string `gorm:"type:varchar(100);not null"`
+	OwnerID int64  `gorm:"not null;index"`
+	// 每个用户只有一个个人工作区，由部分唯一索引保证（见migrateWorkspaces）
+	Personal  bool      `gorm:"not null;default:false"`
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+func (WorkspaceModel) TableName() string {
+	return "workspaces"
+}
+
+// WorkspaceMemberModel 工作区成员，(workspace_id, user_id)为主键
+type WorkspaceMemberModel struct {
+	WorkspaceID int64     `gorm:"primaryKey"`
+	UserID      int64     `gorm:"primaryKey;index"`
+	Role        string    `gorm:"type:varchar(20);not null"`
+	CreatedAt   time.Time `gorm:"not null"`
+}
+
+func (WorkspaceMemberModel) TableName() string {
+	return "workspace_members"
+}
+
+// WorkspaceInviteModel 工作区邀请，只存token的sha256
+type WorkspaceInviteModel struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement"`
+	WorkspaceID int64     `gorm:"not null;index"`
+	Role        string    `gorm:"type:varchar(20);not null"`
+	InvitedBy   int64     `gorm:"not null"`
+	Hash        string    `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	AcceptedBy  *int64
+	AcceptedAt  *time.Time
+	CreatedAt   time.Time `gorm:"not null"`
+}
+
+func (WorkspaceInviteModel) TableName() string {
+	return "workspace_invites"
+}
@@ -0,0 +1,380 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tasker/core/workspace"
+	"tasker/pkg/apperror"
+
+	"gorm.io/gorm"
+)
+
+type WorkspaceRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkspaceRepository(db *gorm.DB) *WorkspaceRepository {
+	return &WorkspaceRepository{db: db}
+}
+
+// 查询当前用户角色时用的扫描结构
+type workspaceWithRole struct {
+	WorkspaceModel
+	Role string
+}
+
+// 成员列表带上用户名
+type memberWithUsername struct {
+	WorkspaceMemberModel
+	Username string
+}
+
+// memberWorkspaceIDs 用户所在工作区ID的子查询，配合 "workspace_id IN (?)" 使用
+func memberWorkspaceIDs(db *gorm.DB, userID int64) *gorm.DB {
+	return db.Model(&WorkspaceMemberModel{}).Select("workspace_id").Where("user_id = ?", userID)
+}
+
+func workspaceToDomain(m *WorkspaceModel) *workspace.Workspace {
+	return &workspace.Workspace{
+		ID:        m.ID,
+		Name:      m.Name,
+		OwnerID:   m.OwnerID,
+		Personal:  m.Personal,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+func memberToDomain(m *WorkspaceMemberModel, username string) *workspace.Member {
+	return &workspace.Member{
+		WorkspaceID: m.WorkspaceID,
+		UserID:      m.UserID,
+		Username:    username,
+		Role:        workspace.Role(m.Role),
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+func inviteToDomain(m *WorkspaceInviteModel) *workspace.Invite {
+	return &workspace.Invite{
+		ID:          m.ID,
+		WorkspaceID: m.WorkspaceID,
+		Role:        workspace.Role(m.Role),
+		InvitedBy:   m.InvitedBy,
+		Hash:        m.Hash,
+		ExpiresAt:   m.ExpiresAt,
+		AcceptedBy:  m.AcceptedBy,
+		AcceptedAt:  m.AcceptedAt,
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+func (r *WorkspaceRepository) Create(ctx context.Context, w *workspace.Workspace, owner *workspace.Member) error {
+	m := &WorkspaceModel{
+		Name:      w.Name,
+		OwnerID:   w.OwnerID,
+		Personal:  w.Personal,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		return tx.Create(&WorkspaceMemberModel{
+			WorkspaceID: m.ID,
+			UserID:      owner.UserID,
+			Role:        string(owner.Role),
+			CreatedAt:   owner.CreatedAt,
+		}).Error
+	})
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to create workspace")
+	}
+	w.ID = m.ID
+	owner.WorkspaceID = m.ID
+	return nil
+}
+
+func (r *WorkspaceRepository) GetByID(ctx context.Context, id int64) (*workspace.Workspace, error) {
+	var m WorkspaceModel
+	if err := r.db.WithContext(ctx).First(&m, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.New("WORKSPACE_NOT_FOUND", "workspace not found")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get workspace")
+	}
+	return workspaceToDomain(&m), nil
+}
+
+func (r *WorkspaceRepository) GetPersonal(ctx context.Context, userID int64) (*workspace.Workspace, error) {
+	var m WorkspaceModel
+	if err := r.db.WithContext(ctx).Where("owner_id = ? AND personal", userID).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.New("DB_ERROR", "failed to get workspace")
+	}
+	return workspaceToDomain(&m), nil
+}
+
+func (r *WorkspaceRepository) ListByMember(ctx context.Context, userID int64) ([]*workspace.Workspace, error) {
+	var rows []workspaceWithRole
+	err := r.db.WithContext(ctx).Table("workspaces").
+		Select("workspaces.*, workspace_members.role").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ?", userID).
+		Order("workspaces.personal DESC, workspaces.id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list workspaces")
+	}
+	items := make([]*workspace.Workspace, 0, len(rows))
+	for i := range rows {
+		w := workspaceToDomain(&rows[i].WorkspaceModel)
+		w.Role = workspace.Role(rows[i].Role)
+		items = append(items, w)
+	}
+	return items, nil
+}
+
+func (r *WorkspaceRepository) Update(ctx context.Context, w *workspace.Workspace) error {
+	tx := r.db.WithContext(ctx).Model(&WorkspaceModel{}).Where("id = ?", w.ID).Updates(map[string]any{
+		"name":       w.Name,
+		"updated_at": w.UpdatedAt,
+	})
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update workspace")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("WORKSPACE_NOT_FOUND", "workspace not found")
+	}
+	return nil
+}
+
+func (r *WorkspaceRepository) Delete(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteWorkspaces(tx, []int64{id})
+	})
+	if err != nil {
+		return apperror.New("DB_ERROR", "failed to delete workspace")
+	}
+	return nil
+}
+
+// deleteWorkspaces 删除工作区及挂在下面的全部数据，调用方负责开事务
+func deleteWorkspaces(tx *gorm.DB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tasks := tx.Model(&TaskModel{}).Select("id").Where("workspace_id IN ?", ids)
+	for _, m := range []any{&TaskAssigneeModel{}, &TaskTagModel{}, &MentionModel{}, &CommentModel{}} {
+		if err := tx.Where("task_id IN (?)", tasks).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+	groups := tx.Model(&GroupModel{}).Select("id").Where("workspace_id IN ?", ids)
+	if err := tx.Where("group_id IN (?)", groups).Delete(&GroupShareModel{}).Error; err != nil {
+		return err
+	}
+	for _, m := range []any{
+		&NotificationModel{},
+		&ActivityModel{},
+		&ShareLinkModel{},
+		&TaskModel{},
+		&GroupModel{},
+		&WorkspaceInviteModel{},
+		&WorkspaceMemberModel{},
+	} {
+		if err := tx.Where("workspace_id IN ?", ids).Delete(m).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("id IN ?", ids).Delete(&WorkspaceModel{}).Error
+}
+
+func (r *WorkspaceRepository) GetMember(ctx context.Context, workspaceID, userID int64) (*workspace.Member, error) {
+	var row memberWithUsername
+	tx := r.db.WithContext(ctx).Table("workspace_members").
+		Select("workspace_members.*, users.username").
+		Joins("LEFT JOIN users ON users.id = workspace_members.user_id").
+		Where("workspace_members.workspace_id = ? AND workspace_members.user_id = ?", workspaceID, userID).
+		Limit(1).
+		Scan(&row)
+	if tx.Error != nil {
+		return nil, apperror.New("DB_ERROR", "failed to get member")
+	}
+	if tx.RowsAffected == 0 {
+		return nil, apperror.New("MEMBER_NOT_FOUND", "member not found")
+	}
+	return memberToDomain(&row.WorkspaceMemberModel, row.Username), nil
+}
+
+func (r *WorkspaceRepository) ListMembers(ctx context.Context, workspaceID int64) ([]*workspace.Member, error) {
+	var rows []memberWithUsername
+	err := r.db.WithContext(ctx).Table("workspace_members").
+		Select("workspace_members.*, users.username").
+		Joins("LEFT JOIN users ON users.id = workspace_members.user_id").
+		Where("workspace_members.workspace_id = ?", workspaceID).
+		Order("workspace_members.created_at ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list members")
+	}
+	items := make([]*workspace.Member, 0, len(rows))
+	for i := range rows {
+		items = append(items, memberToDomain(&rows[i].WorkspaceMemberModel, rows[i].Username))
+	}
+	return items, nil
+}
+
+func (r *WorkspaceRepository) UpdateMemberRole(ctx context.Context, workspaceID, userID int64, role workspace.Role) error {
+	tx := r.db.WithContext(ctx).Model(&WorkspaceMemberModel{}).
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).
+		Update("role", string(role))
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to update member")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("MEMBER_NOT_FOUND", "member not found")
+	}
+	return nil
+}
+
+func (r *WorkspaceRepository) TransferOwnership(ctx context.Context, workspaceID, fromUserID, toUserID int64, at time.Time) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&WorkspaceMemberModel{}).
+			Where("workspace_id = ? AND user_id = ?", workspaceID, fromUserID).
+			Update("role", string(workspace.RoleAdmin)).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&WorkspaceMemberModel{}).
+			Where("workspace_id = ? AND user_id = ?", workspaceID, toUserID).
+			Update("role", string(workspace.RoleOwner))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&WorkspaceModel{}).Where("id = ?", workspaceID).Updates(map[string]any{
+			"owner_id":   toUserID,
+			"updated_at": at,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("MEMBER_NOT_FOUND", "member not found")
+		}
+		return apperror.New("DB_ERROR", "failed to transfer ownership")
+	}
+	return nil
+}
+
+func (r *WorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Delete(&WorkspaceMemberModel{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// 离开工作区后不再负责其中的任务
+		tasks := tx.Model(&TaskModel{}).Select("id").Where("workspace_id = ?", workspaceID)
+		return tx.Where("user_id = ? AND task_id IN (?)", userID, tasks).Delete(&TaskAssigneeModel{}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.New("MEMBER_NOT_FOUND", "member not found")
+		}
+		return apperror.New("DB_ERROR", "failed to remove member")
+	}
+	return nil
+}
+
+func (r *WorkspaceRepository) CreateInvite(ctx context.Context, inv *workspace.Invite) error {
+	m := &WorkspaceInviteModel{
+		WorkspaceID: inv.WorkspaceID,
+		Role:        string(inv.Role),
+		InvitedBy:   inv.InvitedBy,
+		Hash:        inv.Hash,
+		ExpiresAt:   inv.ExpiresAt,
+		CreatedAt:   inv.CreatedAt,
+	}
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		return apperror.New("DB_ERROR", "failed to create invite")
+	}
+	inv.ID = m.ID
+	return nil
+}
+
+func (r *WorkspaceRepository) ListInvites(ctx context.Context, workspaceID int64) ([]*workspace.Invite, error) {
+	var models []WorkspaceInviteModel
+	if err := r.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).Order("id DESC").Find(&models).Error; err != nil {
+		return nil, apperror.New("DB_ERROR", "failed to list invites")
+	}
+	items := make([]*workspace.Invite, 0, len(models))
+	for i := range models {
+		items = append(items, inviteToDomain(&models[i]))
+	}
+	return items, nil
+}
+
+func (r *WorkspaceRepository) DeleteInvite(ctx context.Context, workspaceID, id int64) error {
+	tx := r.db.WithContext(ctx).Where("workspace_id = ? AND id = ?", workspaceID, id).Delete(&WorkspaceInviteModel{})
+	if tx.Error != nil {
+		return apperror.New("DB_ERROR", "failed to delete invite")
+	}
+	if tx.RowsAffected == 0 {
+		return apperror.New("INVITE_NOT_FOUND", "invite not found")
+	}
+	return nil
+}
+
+// errAlreadyMember 事务内部用来区分已是成员的情况
+var errAlreadyMember = errors.New("already a member")
+
+func (r *WorkspaceRepository) AcceptInvite(ctx context.Context, hash string, userID int64, at time.Time) (*workspace.Invite, error) {
+	var m WorkspaceInviteModel
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证同一个邀请只能被接受一次
+		res := tx.Model(&WorkspaceInviteModel{}).
+			Where("hash = ? AND accepted_at IS NULL AND expires_at > ?", hash, at).
+			Updates(map[string]any{"accepted_by": userID, "accepted_at": at})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("hash = ?", hash).First(&m).Error; err != nil {
+			return err
+		}
+		// 已经是成员时回滚，邀请仍然可以给别人用
+		if err := tx.Create(&WorkspaceMemberModel{
+			WorkspaceID: m.WorkspaceID,
+			UserID:      userID,
+			Role:        m.Role,
+			CreatedAt:   at,
+		}).Error; err != nil {
+			if isUniqueViolation(err) {
+				return errAlreadyMember
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.New("INVALID_INVITE", "invalid or expired invite")
+		}
+		if errors.Is(err, errAlreadyMember) {
+			return nil, apperror.New("ALREADY_MEMBER", "you are already a member of this workspace")
+		}
+		return nil, apperror.New("DB_ERROR", "failed to accept invite")
+	}
+	return inviteToDomain(&m), nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+/*
+单进程内存版的登录失败计数，适合本地开发和单副本部署；
+多副本部署请用 infra/db 的 LimiterStore，否则锁定只在一个副本上生效
+*/
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"tasker/core/user"
+)
+
+// 超过这个数量时顺手清理过期记录，防止被随机用户名撑爆内存
+const pruneThreshold = 10000
+
+type LimiterStore struct {
+	mu      sync.Mutex
+	entries map[string]*user.AttemptState
+}
+
+func NewLimiterStore() *LimiterStore {
+	return &LimiterStore{entries: map[string]*user.AttemptState{}}
+}
+
+func (s *LimiterStore) Get(ctx context.Context, key string) (*user.AttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	cp := *st
+	return &cp, nil
+}
+
+func (s *LimiterStore) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*user.AttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) >= pruneThreshold {
+		s.prune(at, resetBefore)
+	}
+
+	st, ok := s.entries[key]
+	if !ok || st.LastFailureAt.Before(resetBefore) {
+		st = &user.AttemptState{}
+		s.entries[key] = st
+	}
+	st.Failures++
+	st.LastFailureAt = at
+
+	cp := *st
+	return &cp, nil
+}
+
+func (s *LimiterStore) SetLockedUntil(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.entries[key]; ok {
+		st.LockedUntil = &until
+	}
+	return nil
+}
+
+func (s *LimiterStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// 清理计数已过期且不在锁定中的记录，调用方持有锁
+func (s *LimiterStore) prune(now, resetBefore time.Time) {
+	for key, st := range s.entries {
+		locked := st.LockedUntil != nil && st.LockedUntil.After(now)
+		if !locked && st.LastFailureAt.Before(resetBefore) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package date
+
+/*
+Date 不带时间和时区的日历日期，例如"2026-11-03截止"。
+和time.Time不同，它不会被换算成某个UTC时刻，在谁的时区里都是同一天；
+数据库里对应Postgres的date类型
+*/
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+const layout = "2006-01-02"
+
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// Parse 解析 YYYY-MM-DD
+func Parse(s string) (Date, error) {
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return Of(t), nil
+}
+
+// Of 取t在它自己的时区里的日期
+func Of(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// Today 返回loc时区里的今天
+func Today(now time.Time, loc *time.Location) Date {
+	return Of(now.In(loc))
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// StartIn 这一天在loc里的0点。用time.Date计算而不是加24小时，夏令时切换当天也正确
+func (d Date) StartIn(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// AddDays 加减天数，按日历计算
+func (d Date) AddDays(n int) Date {
+	return Of(time.Date(d.Year, d.Month, d.Day+n, 0, 0, 0, 0, time.UTC))
+}
+
+func (d Date) Before(o Date) bool {
+	return d.StartIn(time.UTC).Before(o.StartIn(time.UTC))
+}
+
+func (d Date) After(o Date) bool {
+	return o.Before(d)
+}
+
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return fmt.Errorf("date must be a string in YYYY-MM-DD format")
+	}
+	parsed, err := Parse(s[1 : len(s)-1])
+	if err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+	*d = parsed
+	return nil
+}
+
+// Value 写库时按 YYYY-MM-DD 字符串传给Postgres的date列
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan 驱动可能返回time.Time（UTC零点）或字符串
+func (d *Date) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*d = Of(v)
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case []byte:
+		parsed, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into date.Date", src)
+}
@@ -0,0 +1,83 @@
+package date
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestStartInAcrossDST(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	shanghai := mustLoad(t, "Asia/Shanghai")
+
+	tests := []struct {
+		name string
+		day  Date
+		loc  *time.Location
+		// 这一天0点的UTC时刻和这一天的长度
+		start  time.Time
+		length time.Duration
+	}{
+		{"new york normal day", Date{2026, time.March, 7}, ny, time.Date(2026, 3, 7, 5, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{"new york spring forward", Date{2026, time.March, 8}, ny, time.Date(2026, 3, 8, 5, 0, 0, 0, time.UTC), 23 * time.Hour},
+		{"new york after spring forward", Date{2026, time.March, 9}, ny, time.Date(2026, 3, 9, 4, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{"new york fall back", Date{2026, time.November, 1}, ny, time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC), 25 * time.Hour},
+		{"new york after fall back", Date{2026, time.November, 2}, ny, time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{"shanghai has no dst", Date{2026, time.March, 8}, shanghai, time.Date(2026, 3, 7, 16, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{"utc", Date{2026, time.November, 1}, time.UTC, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := tt.day.StartIn(tt.loc)
+			if !start.Equal(tt.start) {
+				t.Errorf("StartIn = %s, want %s", start.UTC(), tt.start)
+			}
+			if got := tt.day.AddDays(1).StartIn(tt.loc).Sub(start); got != tt.length {
+				t.Errorf("day length = %s, want %s", got, tt.length)
+			}
+		})
+	}
+}
+
+func TestAddDays(t *testing.T) {
+	tests := []struct {
+		day  Date
+		n    int
+		want Date
+	}{
+		{Date{2026, time.March, 7}, 1, Date{2026, time.March, 8}},
+		{Date{2026, time.March, 8}, 1, Date{2026, time.March, 9}},
+		{Date{2026, time.November, 1}, 1, Date{2026, time.November, 2}},
+		{Date{2026, time.October, 31}, 1, Date{2026, time.November, 1}},
+		{Date{2026, time.March, 1}, -1, Date{2026, time.February, 28}},
+		{Date{2028, time.March, 1}, -1, Date{2028, time.February, 29}},
+		{Date{2026, time.December, 31}, 1, Date{2027, time.January, 1}},
+		{Date{2026, time.March, 1}, 7, Date{2026, time.March, 8}},
+		{Date{2026, time.March, 8}, 0, Date{2026, time.March, 8}},
+	}
+	for _, tt := range tests {
+		if got := tt.day.AddDays(tt.n); got != tt.want {
+			t.Errorf("%s.AddDays(%d) = %s, want %s", tt.day, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestToday(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	// 纽约3月8日凌晨3点前后跳过了一小时，UTC 3月9日3点还是纽约的3月8日
+	now := time.Date(2026, 3, 9, 3, 30, 0, 0, time.UTC)
+	if got, want := Today(now, ny), (Date{2026, time.March, 8}); got != want {
+		t.Errorf("Today = %s, want %s", got, want)
+	}
+	if got, want := Today(now, time.UTC), (Date{2026, time.March, 9}); got != want {
+		t.Errorf("Today in UTC = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package events
+
+/*
+进程内的事件总线：业务模块发布事件，通知等模块订阅。
+同步分发，订阅者出错或panic只记日志，不影响发布方
+*/
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event 一条业务事件，Payload的具体类型由发布方定义
+type Event struct {
+	Type        string
+	WorkspaceID int64
+	// 触发事件的用户
+	ActorID int64
+	Payload any
+	At      time.Time
+}
+
+// Handler 订阅者
+type Handler func(ctx context.Context, e Event)
+
+// Publisher 业务模块只依赖发布接口
+type Publisher interface {
+	Publish(ctx context.Context, e Event)
+}
+
+// Bus 按事件类型分发给订阅者
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]Handler{}}
+}
+
+// Subscribe 订阅某种事件，按订阅顺序调用
+func (b *Bus) Subscribe(eventType string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], h)
+}
+
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	b.mu.RLock()
+	handlers := b.handlers[e.Type]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		dispatch(ctx, h, e)
+	}
+}
+
+func dispatch(ctx context.Context, h Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[events] handler for %s panicked: %v", e.Type, r)
+		}
+	}()
+	h(ctx, e)
+}
+
+// Discard 不订阅任何事件时使用
+type Discard struct{}
+
+func (Discard) Publish(ctx context.Context, e Event) {}
@@ -0,0 +1,329 @@
+package filterql
+
+/*
+过滤表达式的词法和语法分析，不关心有哪些字段，由调用方解释。
+
+	expr    = or
+	or      = and { "OR" and }
+	and     = unary { [ "AND" ] unary }      相邻的条件默认是AND
+	unary   = ( "-" | "NOT" ) unary | primary
+	primary = "(" expr ")" | term
+	term    = word op value | word | string
+	op      = ":" | "=" | "!=" | "<" | "<=" | ">" | ">="
+	value   = word | string
+
+string用双引号，里面可以用\"和\\转义。AND、OR、NOT不区分大小写。
+位置都是从1开始的字符（不是字节）列号
+*/
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// 限制表达式的规模，防止生成过大的查询
+const (
+	MaxLength = 500
+	MaxTerms  = 50
+	MaxDepth  = 20
+)
+
+// Op 比较运算符
+type Op string
+
+const (
+	OpHas Op = ":"
+	OpEq  Op = "="
+	OpNe  Op = "!="
+	OpLt  Op = "<"
+	OpLe  Op = "<="
+	OpGt  Op = ">"
+	OpGe  Op = ">="
+)
+
+// Expr 语法树的节点：*And、*Or、*Not或*Term
+type Expr interface {
+	// Pos 节点在输入里的列号
+	Pos() int
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+	At          int
+}
+
+type Not struct {
+	X  Expr
+	At int
+}
+
+// Term 一个条件。Field为空时是一个不带字段的搜索词
+type Term struct {
+	Field    string
+	FieldPos int
+	Op       Op
+	Value    string
+	ValuePos int
+	// 值是否用了引号，"none"和none的含义不同
+	Quoted bool
+}
+
+func (e *And) Pos() int { return e.Left.Pos() }
+func (e *Or) Pos() int  { return e.At }
+func (e *Not) Pos() int { return e.At }
+func (e *Term) Pos() int {
+	if e.Field != "" {
+		return e.FieldPos
+	}
+	return e.ValuePos
+}
+
+// Error 带列号的语法错误
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+}
+
+// Errorf 调用方解释语法树时报告错误，格式和语法错误一致
+func Errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokMinus
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return fmt.Sprintf("%q", t.text)
+	}
+	return `"` + t.text + `"`
+}
+
+// isWordRune 词里不能有空白、括号、引号和运算符
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()":=!<>`, r)
+}
+
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: pos})
+			i++
+		case r == '"':
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if c == '\\' && i+1 < len(runes) {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if c == '"' {
+					closed = true
+					break
+				}
+				b.WriteRune(c)
+			}
+			if !closed {
+				return nil, &Error{Pos: pos, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: pos})
+		case strings.ContainsRune(":=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != ':' && r != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &Error{Pos: pos, Msg: `unexpected "!", did you mean "!=" or "-"?`}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+			i += len(op)
+		case r == '-' && (len(tokens) == 0 || tokens[len(tokens)-1].kind != tokOp) &&
+			i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			// 条件开头的-是取反；运算符后面的-是值的一部分，例如due>-3d
+			tokens = append(tokens, token{kind: tokMinus, text: "-", pos: pos})
+			i++
+		default:
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: string(runes[i:j]), pos: pos})
+			i = j
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(runes) + 1}), nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	terms  int
+	depth  int
+}
+
+// Parse 解析过滤表达式，空白输入返回nil
+func Parse(input string) (Expr, error) {
+	if n := len([]rune(input)); n > MaxLength {
+		return nil, &Error{Pos: MaxLength + 1, Msg: fmt.Sprintf("the filter must be at most %d characters", MaxLength)}
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{Pos: t.pos, Msg: "unexpected " + t.String()}
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// keyword 不带引号的AND/OR/NOT，后面跟着运算符时是字段名
+func (p *parser) keyword(name string) bool {
+	t := p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.text, name) && p.tokens[p.i+1].kind != tokOp
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		at := p.next().pos
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right, At: at}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.keyword("AND") {
+			p.next()
+		} else if t := p.peek(); t.kind == tokEOF || t.kind == tokRParen || p.keyword("OR") {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	if t := p.peek(); t.kind == tokMinus || p.keyword("NOT") {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x, At: t.pos}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		if p.depth++; p.depth > MaxDepth {
+			return nil, &Error{Pos: t.pos, Msg: "too many nested parentheses"}
+		}
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		if c := p.next(); c.kind != tokRParen {
+			return nil, &Error{Pos: c.pos, Msg: `expected ")" to close "(" at column ` + fmt.Sprint(t.pos) + ", found " + c.String()}
+		}
+		return e, nil
+	case tokWord, tokString:
+		if p.terms++; p.terms > MaxTerms {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("the filter can have at most %d conditions", MaxTerms)}
+		}
+		if t.kind == tokWord && p.peek().kind == tokOp {
+			op := p.next()
+			v := p.next()
+			if v.kind != tokWord && v.kind != tokString {
+				return nil, &Error{Pos: v.pos, Msg: fmt.Sprintf("expected a value after %q, found %s", op.text, v)}
+			}
+			return &Term{
+				Field: strings.ToLower(t.text), FieldPos: t.pos, Op: Op(op.text),
+				Value: v.text, ValuePos: v.pos, Quoted: v.kind == tokString,
+			}, nil
+		}
+		return &Term{Value: t.text, ValuePos: t.pos, Quoted: t.kind == tokString}, nil
+	case tokEOF:
+		return nil, &Error{Pos: t.pos, Msg: "unexpected end of input, expected a condition"}
+	}
+	return nil, &Error{Pos: t.pos, Msg: "unexpected " + t.String() + ", expected a condition"}
+}
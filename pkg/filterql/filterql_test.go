@@ -0,0 +1,98 @@
+package filterql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+		msg   string
+	}{
+		{"unterminated string", `group:"Work`, 7, "unterminated string"},
+		{"unterminated string after escape", `title:"say \"hi\"`, 7, "unterminated string"},
+		{"unterminated string counts characters", `group:"工作" title:"周报`, 18, "unterminated string"},
+		{"missing close paren", `(status:pending OR priority:high`, 33, `expected ")" to close "(" at column 1, found end of input`},
+		{"missing close paren in nested group", `(a (b c) d`, 11, `expected ")" to close "(" at column 1`},
+		{"extra close paren", `status:pending)`, 15, `unexpected ")"`},
+		{"close paren first", `) a`, 1, `unexpected ")", expected a condition`},
+		{"empty parens", `a ()`, 4, `unexpected ")", expected a condition`},
+		{"missing value", `priority>= (due:today)`, 12, `expected a value after ">=", found "("`},
+		{"missing value at end", `status:`, 8, "expected a value after \":\", found end of input"},
+		{"bang without equals", `!status:done`, 1, `unexpected "!"`},
+		{"dangling OR", `a OR`, 5, "unexpected end of input, expected a condition"},
+		{"dangling NOT", `a NOT`, 6, "unexpected end of input, expected a condition"},
+		{"too long", strings.Repeat("a", MaxLength+1), MaxLength + 1, "at most 500 characters"},
+		{"too deep", strings.Repeat("(", MaxDepth+1) + "a" + strings.Repeat(")", MaxDepth+1), MaxDepth + 1, "too many nested parentheses"},
+		{"too many terms", strings.TrimSpace(strings.Repeat("a ", MaxTerms+1)), 2*MaxTerms + 1, "at most 50 conditions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.input, err)
+			}
+			if perr.Pos != tt.pos || !strings.Contains(perr.Msg, tt.msg) {
+				t.Fatalf("Parse(%q) = %v, want column %d: %s", tt.input, perr, tt.pos, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	e, err := Parse(`status:pending (priority>=high OR -group:"A \"b\"") NOT due<-3d 工作`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// AND左结合：((status (priority OR -group)) NOT due) 工作
+	top, ok := e.(*And)
+	if !ok {
+		t.Fatalf("top = %T, want *And", e)
+	}
+	if w, ok := top.Right.(*Term); !ok || w.Field != "" || w.Value != "工作" || w.ValuePos != 65 {
+		t.Fatalf("last term = %+v", top.Right)
+	}
+	mid := top.Left.(*And)
+	not, ok := mid.Right.(*Not)
+	if !ok || not.At != 53 {
+		t.Fatalf("NOT = %+v", mid.Right)
+	}
+	if due := not.X.(*Term); due.Field != "due" || due.Op != OpLt || due.Value != "-3d" || due.ValuePos != 61 {
+		t.Fatalf("due = %+v", due)
+	}
+	first := mid.Left.(*And)
+	if st := first.Left.(*Term); st.Field != "status" || st.Op != OpHas || st.Value != "pending" || st.FieldPos != 1 || st.ValuePos != 8 {
+		t.Fatalf("status = %+v", st)
+	}
+	or, ok := first.Right.(*Or)
+	if !ok || or.At != 32 {
+		t.Fatalf("OR = %+v", first.Right)
+	}
+	grp := or.Right.(*Not).X.(*Term)
+	if grp.Field != "group" || grp.Value != `A "b"` || !grp.Quoted || grp.FieldPos != 36 || grp.ValuePos != 42 {
+		t.Fatalf("group = %+v", grp)
+	}
+}
+
+func TestParseKeywordsAsFields(t *testing.T) {
+	// 后面跟着运算符的AND/OR/NOT是字段名，引号里的是普通词
+	e, err := Parse(`or:1 "AND"`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	and := e.(*And)
+	if f := and.Left.(*Term); f.Field != "or" || f.Value != "1" {
+		t.Fatalf("left = %+v", f)
+	}
+	if w := and.Right.(*Term); w.Value != "AND" || !w.Quoted {
+		t.Fatalf("right = %+v", w)
+	}
+	if e, err := Parse("  "); e != nil || err != nil {
+		t.Fatalf("blank input = %v, %v", e, err)
+	}
+}
@@ -0,0 +1,359 @@
+package ical
+
+/*
+iCalendar（RFC 5545）的最小读写实现：只处理内容行、组件嵌套、文本转义和日期时间，
+不展开重复规则，也不解析VTIMEZONE，TZID按IANA时区名加载
+*/
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tasker/pkg/date"
+)
+
+// 内容行最多75个字节（不含换行），超出的部分折到下一行，续行以一个空格开头
+const maxLineOctets = 75
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+)
+
+var ErrInvalid = errors.New("ical: invalid calendar data")
+
+// Property 一个属性，参数名和属性名都转成大写
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Param 读取参数，不存在时返回空串
+func (p *Property) Param(name string) string {
+	return p.Params[name]
+}
+
+// Text 反转义后的TEXT值
+func (p *Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// Component 一个组件，例如VCALENDAR、VTODO
+type Component struct {
+	Name     string
+	Props    []*Property
+	Children []*Component
+	// BEGIN所在的行号，从1开始
+	Line int
+}
+
+// Get 返回第一个同名属性，没有时返回nil
+func (c *Component) Get(name string) *Property {
+	for _, p := range c.Props {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Writer 按RFC 5545输出：CRLF换行，长行按字节折行且不拆开UTF-8字符
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+func (w *Writer) Begin(name string) {
+	w.line("BEGIN:" + name)
+}
+
+func (w *Writer) End(name string) {
+	w.line("END:" + name)
+}
+
+// Prop 写一个属性，value需要调用方按值类型处理好；params是成对的参数名和参数值
+func (w *Writer) Prop(name, value string, params ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	for i := 0; i+1 < len(params); i += 2 {
+		b.WriteByte(';')
+		b.WriteString(params[i])
+		b.WriteByte('=')
+		b.WriteString(quoteParam(params[i+1]))
+	}
+	b.WriteByte(':')
+	b.WriteString(value)
+	w.line(b.String())
+}
+
+// Text 写一个TEXT类型的属性
+func (w *Writer) Text(name, value string) {
+	w.Prop(name, EscapeText(value))
+}
+
+// Err 之前写入时遇到的第一个错误
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Flush 写出缓冲区，返回之前遇到的第一个错误
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+func (w *Writer) line(s string) {
+	if w.err != nil {
+		return
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// 续行开头的空格也占一个字节
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+	_, w.err = w.w.WriteString(b.String())
+}
+
+// quoteParam 参数值里有冒号、分号或逗号时必须加双引号；参数值本身不能包含双引号
+func quoteParam(v string) string {
+	v = strings.ReplaceAll(v, `"`, "'")
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// EscapeText 按TEXT类型转义反斜杠、分号、逗号和换行
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// UnescapeText EscapeText的逆操作，不认识的转义保留原字符
+func UnescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// SplitText 拆开用逗号分隔的多个TEXT值（例如CATEGORIES），转义的逗号不拆
+func SplitText(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, UnescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, UnescapeText(s[start:]))
+}
+
+// FormatDateTime UTC时间，例如 20261103T090000Z
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout) + "Z"
+}
+
+// FormatDate DATE类型的值，例如 20261103
+func FormatDate(d date.Date) string {
+	return d.StartIn(time.UTC).Format(dateLayout)
+}
+
+// Time 解析DATE或DATE-TIME属性。isDate为true时只有日期部分有意义。
+// 带Z的是UTC，带TZID的按IANA时区名解析（不认识时按UTC），都没有的浮动时间按floating解释
+func (p *Property) Time(floating *time.Location) (t time.Time, isDate bool, err error) {
+	v := p.Value
+	if p.Param("VALUE") == "DATE" || len(v) == len(dateLayout) {
+		t, err = time.Parse(dateLayout, v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: %s is not a date", ErrInvalid, p.Name)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err = time.Parse(dateTimeLayout, strings.TrimSuffix(v, "Z"))
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: %s is not a date-time", ErrInvalid, p.Name)
+		}
+		return t, false, nil
+	}
+	loc := floating
+	if tzid := strings.TrimPrefix(p.Param("TZID"), "/"); tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		} else {
+			loc = time.UTC
+		}
+	}
+	t, err = time.ParseInLocation(dateTimeLayout, v, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a date-time", ErrInvalid, p.Name)
+	}
+	return t, false, nil
+}
+
+// Parse 读取一个完整的iCalendar对象，返回最外层组件（通常是VCALENDAR）
+func Parse(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *Component
+	var stack []*Component
+	for _, l := range lines {
+		if l.text == "" {
+			continue
+		}
+		p, err := parseLine(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d", err, l.no)
+		}
+		switch p.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(p.Value), Line: l.no}
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fmt.Errorf("%w: line %d: more than one top-level component", ErrInvalid, l.no)
+				}
+				root = c
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalid, l.no, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: property outside of a component", ErrInvalid, l.no)
+			}
+			c := stack[len(stack)-1]
+			c.Props = append(c.Props, p)
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("%w: no calendar component", ErrInvalid)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", ErrInvalid, stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+type contentLine struct {
+	no   int
+	text string
+}
+
+// unfold 合并折行。宽松处理只用LF换行的文件
+func unfold(r io.Reader) ([]contentLine, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lines []contentLine
+	no := 0
+	for sc.Scan() {
+		no++
+		s := strings.TrimSuffix(sc.Text(), "\r")
+		if no == 1 {
+			s = strings.TrimPrefix(s, "\uFEFF")
+		}
+		if len(s) > 0 && (s[0] == ' ' || s[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1].text += s[1:]
+			continue
+		}
+		lines = append(lines, contentLine{no: no, text: s})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return lines, nil
+}
+
+// parseLine 解析 NAME;PARAM=VALUE;PARAM="VALUE":VALUE
+func parseLine(s string) (*Property, error) {
+	malformed := fmt.Errorf("%w: malformed content line", ErrInvalid)
+	i := strings.IndexAny(s, ";:")
+	if i <= 0 {
+		return nil, malformed
+	}
+	p := &Property{Name: strings.ToUpper(s[:i])}
+	rest := s[i:]
+	for rest[0] == ';' {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, malformed
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, malformed
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		}
+		// 带引号的值后面可能还有逗号分隔的其他值，只保留第一个
+		j := strings.IndexAny(rest, ";:")
+		if j < 0 {
+			return nil, malformed
+		}
+		if value == "" {
+			value = rest[:j]
+		}
+		rest = rest[j:]
+		if p.Params == nil {
+			p.Params = make(map[string]string)
+		}
+		p.Params[name] = value
+	}
+	p.Value = rest[1:]
+	return p, nil
+}
@@ -0,0 +1,277 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+func TestWriterFoldsAt75Octets(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"short", "pay rent"},
+		{"exactly one line", strings.Repeat("a", maxLineOctets-len("SUMMARY:"))},
+		{"one octet over", strings.Repeat("a", maxLineOctets-len("SUMMARY:")+1)},
+		{"long ascii", strings.Repeat("abcdefghij", 30)},
+		// 三个字节的汉字和四个字节的emoji都不能被拆到两行
+		{"chinese", strings.Repeat("写周报", 40)},
+		{"emoji", strings.Repeat("🎉", 50)},
+		{"mixed", "a" + strings.Repeat("汉🎉b", 30)},
+		{"escaped text", strings.Repeat("a,b;c\\d\n", 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.Begin("VTODO")
+			w.Text("SUMMARY", tt.value)
+			w.End("VTODO")
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output does not end with CRLF: %q", out)
+			}
+			for i, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+				if len(line) > maxLineOctets {
+					t.Fatalf("line %d has %d octets: %q", i, len(line), line)
+				}
+				if !utf8.ValidString(line) {
+					t.Fatalf("line %d splits a UTF-8 character: %q", i, line)
+				}
+			}
+
+			c, err := Parse(strings.NewReader(out))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := c.Get("SUMMARY").Text(); got != tt.value {
+				t.Fatalf("round trip = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"line1\nline2", `line1\nline2`},
+		{"crlf\r\nend", `crlf\nend`},
+		{"cr\rend", `cr\nend`},
+		{`\n is not a newline`, `\\n is not a newline`},
+		{"中文；，", "中文；，"},
+	}
+	for _, tt := range tests {
+		if got := EscapeText(tt.in); got != tt.want {
+			t.Errorf("EscapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		want := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(tt.in)
+		if got := UnescapeText(EscapeText(tt.in)); got != want {
+			t.Errorf("UnescapeText(EscapeText(%q)) = %q", tt.in, got)
+		}
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`a\Nb`, "a\nb"},
+		{`a\:b`, "a:b"},
+		{`trailing\`, `trailing\`},
+		{`\\\,`, `\,`},
+	}
+	for _, tt := range tests {
+		if got := UnescapeText(tt.in); got != tt.want {
+			t.Errorf("UnescapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"work", []string{"work"}},
+		{"work,home", []string{"work", "home"}},
+		{`a\,b,c`, []string{"a,b", "c"}},
+		{`a\\,b`, []string{`a\`, "b"}},
+		{"", []string{""}},
+		{"a,", []string{"a", ""}},
+	}
+	for _, tt := range tests {
+		if got := SplitText(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPropertyTime(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		prop   Property
+		want   time.Time
+		isDate bool
+		err    bool
+	}{
+		{
+			name: "utc",
+			prop: Property{Name: "DUE", Value: "20261103T090000Z"},
+			want: time.Date(2026, 11, 3, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "tzid",
+			prop: Property{Name: "DUE", Params: map[string]string{"TZID": "America/New_York"}, Value: "20261103T090000"},
+			want: time.Date(2026, 11, 3, 9, 0, 0, 0, newYork),
+		},
+		{
+			name: "tzid with a leading slash",
+			prop: Property{Name: "DUE", Params: map[string]string{"TZID": "/America/New_York"}, Value: "20261103T090000"},
+			want: time.Date(2026, 11, 3, 9, 0, 0, 0, newYork),
+		},
+		{
+			name: "unknown tzid is utc",
+			prop: Property{Name: "DUE", Params: map[string]string{"TZID": "Custom Zone"}, Value: "20261103T090000"},
+			want: time.Date(2026, 11, 3, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "floating time uses the given location",
+			prop: Property{Name: "DUE", Value: "20261103T090000"},
+			want: time.Date(2026, 11, 3, 9, 0, 0, 0, shanghai),
+		},
+		{
+			name:   "value=date",
+			prop:   Property{Name: "DUE", Params: map[string]string{"VALUE": "DATE"}, Value: "20261103"},
+			want:   time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC),
+			isDate: true,
+		},
+		{
+			name: "invalid date",
+			prop: Property{Name: "DTSTART", Value: "20260229"},
+			err:  true,
+		},
+		{
+			name:   "bare date",
+			prop:   Property{Name: "DTSTART", Value: "20261231"},
+			want:   time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+			isDate: true,
+		},
+		{
+			name: "date-time marked as date",
+			prop: Property{Name: "DUE", Params: map[string]string{"VALUE": "DATE"}, Value: "20261103T090000"},
+			err:  true,
+		},
+		{
+			name: "malformed utc",
+			prop: Property{Name: "DUE", Value: "2026-11-03T09:00:00Z"},
+			err:  true,
+		},
+		{
+			name: "malformed local",
+			prop: Property{Name: "DUE", Value: "tomorrow"},
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, isDate, err := tt.prop.Time(shanghai)
+			if tt.err {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("err = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Time: %v", err)
+			}
+			if !got.Equal(tt.want) || isDate != tt.isDate {
+				t.Fatalf("Time = %v (date %v), want %v (date %v)", got, isDate, tt.want, tt.isDate)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	body := "\uFEFFBEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"begin:vtodo\n" +
+		"UID:1\r\n" +
+		"SUMMARY;LANGUAGE=zh-CN:写\r\n" +
+		" 周报\r\n" +
+		"\tand more\r\n" +
+		"DUE;TZID=\"America/New_York\";X-A=b:20261103T090000\r\n" +
+		"ATTENDEE;CN=\"Doe, Jane\",\"x\":mailto:jane@example.com\r\n" +
+		"END:VTODO\r\n" +
+		"\r\n" +
+		"END:VCALENDAR\r\n"
+	c, err := Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if c.Name != "VCALENDAR" || c.Line != 1 || len(c.Children) != 1 || c.Get("VERSION").Value != "2.0" {
+		t.Fatalf("calendar = %+v", c)
+	}
+	todo := c.Children[0]
+	if todo.Name != "VTODO" || todo.Line != 3 {
+		t.Fatalf("todo = %+v", todo)
+	}
+	// 续行去掉开头的一个空格或制表符后直接拼接
+	if got := todo.Get("SUMMARY"); got.Value != "写周报and more" || got.Param("LANGUAGE") != "zh-CN" {
+		t.Fatalf("SUMMARY = %+v", got)
+	}
+	if got := todo.Get("DUE"); got.Param("TZID") != "America/New_York" || got.Param("X-A") != "b" || got.Value != "20261103T090000" {
+		t.Fatalf("DUE = %+v", got)
+	}
+	if got := todo.Get("ATTENDEE"); got.Param("CN") != "Doe, Jane" || got.Value != "mailto:jane@example.com" {
+		t.Fatalf("ATTENDEE = %+v", got)
+	}
+	if todo.Get("DESCRIPTION") != nil {
+		t.Fatal("missing property is not nil")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		msg  string
+	}{
+		{"empty", "", "no calendar component"},
+		{"missing end", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VTODO\r\n", "missing END:VCALENDAR"},
+		{"mismatched end", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR\r\n", "line 3: unexpected END:VCALENDAR"},
+		{"property outside", "VERSION:2.0\r\n", "line 1: property outside of a component"},
+		{"two top-level components", "BEGIN:A\r\nEND:A\r\nBEGIN:B\r\nEND:B\r\n", "line 3: more than one top-level component"},
+		{"no colon", "BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n", "malformed content line: line 2"},
+		{"unterminated quote", "BEGIN:VCALENDAR\r\nX;A=\"b:c\r\nEND:VCALENDAR\r\n", "malformed content line: line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.body))
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.msg) {
+				t.Fatalf("err = %v, want %q", err, tt.msg)
+			}
+		})
+	}
+}
@@ -7,43 +7,92 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	defaultIssuer   = "go-tasker"
+	defaultAudience = "go-tasker"
+)
+
 var (
-	// 写死的secret，后面可以从配置/环境变量中读取
-	secretKey = []byte("nullix")
+	// 写死的开发secret，谁都能用它伪造token；
+	// 只有设置 TASKER_JWT_DEV_SECRET=1 时 LoadFromEnv 才会用它
+	devSecret = []byte("nullix")
+
+	// 当前生效的密钥集合，启动时通过 LoadFromEnv / SetKeySet 替换
+	keys = newHMACKeySet(devSecret, defaultIssuer, defaultAudience)
 )
 
+// PurposeMFA 两步验证挑战token，只能用于 /auth/login/mfa
+const PurposeMFA = "mfa"
+
 // Claims自定义的JWT声明，里面带上userID
 type Claims struct {
 	UserID int64 `json:"user_id"`
+	// 为空表示正常登录态
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken生成一个带userID的JWT，ttl是有效期
-func GenerateToken(userID int64, ttl time.Duration) (string, error) {
+// GenerateToken生成一个带userID的JWT，sessionID写进jti，ttl是有效期
+func GenerateToken(userID int64, sessionID string, ttl time.Duration) (string, error) {
+	return generate(userID, sessionID, "", ttl)
+}
+
+// GenerateMFAToken 密码校验通过但还需要两步验证时发放的挑战token
+func GenerateMFAToken(userID int64, ttl time.Duration) (string, error) {
+	return generate(userID, "", PurposeMFA, ttl)
+}
+
+// ParseToken 解析登录态token，挑战token不能当登录态用
+func ParseToken(tokenStr string) (*Claims, error) {
+	claims, err := parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token purpose")
+	}
+	return claims, nil
+}
+
+// ParseMFAToken 解析两步验证挑战token
+func ParseMFAToken(tokenStr string) (*Claims, error) {
+	claims, err := parse(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeMFA {
+		return nil, errors.New("invalid token purpose")
+	}
+	return claims, nil
+}
+
+func generate(userID int64, sessionID, purpose string, ttl time.Duration) (string, error) {
+	ks := keys
 	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:  userID,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			Issuer:    ks.issuer,
+			Audience:  jwt.ClaimStrings{ks.audience},
 			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
-			IssuedAt: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
-			// 可根据需要设置issuer，subject，id等
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	return ks.sign(claims)
 }
 
-// ParseToken 解析 token 字符串，返回 Claims
-func ParseToken(tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		// 校验签名方法
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return secretKey, nil
-	})
+// parse 解析 token 字符串，校验签名、kid、issuer、audience，返回 Claims
+func parse(tokenStr string) (*Claims, error) {
+	ks := keys
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, ks.keyFunc,
+		jwt.WithValidMethods(ks.methods()),
+		jwt.WithIssuer(ks.issuer),
+		jwt.WithAudience(ks.audience),
+	)
 
 	if err != nil {
 		return nil, err
@@ -55,4 +104,4 @@ func ParseToken(tokenStr string) (*Claims, error) {
 	}
 
 	return claims, nil
-}
\ No newline at end of file
+}
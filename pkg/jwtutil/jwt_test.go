@@ -0,0 +1,325 @@
+package jwtutil
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useKeySet 在测试期间替换全局密钥集合，结束后恢复
+func useKeySet(t *testing.T, ks *KeySet) {
+	t.Helper()
+	prev := keys
+	SetKeySet(ks)
+	t.Cleanup(func() { SetKeySet(prev) })
+}
+
+func rsaKey(t *testing.T, kid string) *Key {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: kid, Method: jwt.SigningMethodRS256, Private: priv, Public: &priv.PublicKey}
+}
+
+func edKey(t *testing.T, kid string) *Key {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Private: priv, Public: pub}
+}
+
+// publicOnly 轮换后只留公钥的旧密钥
+func publicOnly(k *Key) *Key {
+	return &Key{ID: k.ID, Method: k.Method, Public: k.Public}
+}
+
+func mustKeySet(t *testing.T, all []*Key, signingKID string) *KeySet {
+	t.Helper()
+	ks, err := NewKeySet(all, signingKID, defaultIssuer, defaultAudience)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return ks
+}
+
+func mustToken(t *testing.T, userID int64) string {
+	t.Helper()
+	tok, err := GenerateToken(userID, "sess", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return tok
+}
+
+func header(t *testing.T, tok string) map[string]any {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(tok, &Claims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	return parsed.Header
+}
+
+func TestSigningKIDSelection(t *testing.T) {
+	oldKey, newKey := rsaKey(t, "2026-01"), edKey(t, "2026-04")
+	for _, tt := range []struct {
+		kid, alg string
+	}{
+		{"2026-01", "RS256"},
+		{"2026-04", "EdDSA"},
+	} {
+		t.Run(tt.kid, func(t *testing.T) {
+			useKeySet(t, mustKeySet(t, []*Key{oldKey, newKey}, tt.kid))
+			tok := mustToken(t, 7)
+			h := header(t, tok)
+			if h["kid"] != tt.kid || h["alg"] != tt.alg {
+				t.Fatalf("header = %v, want kid %s alg %s", h, tt.kid, tt.alg)
+			}
+			claims, err := ParseToken(tok)
+			if err != nil || claims.UserID != 7 || claims.ID != "sess" {
+				t.Fatalf("ParseToken = %+v, %v", claims, err)
+			}
+		})
+	}
+}
+
+func TestNewKeySetErrors(t *testing.T) {
+	a, b := edKey(t, "a"), edKey(t, "b")
+	tests := []struct {
+		name string
+		all  []*Key
+		kid  string
+		msg  string
+	}{
+		{"duplicate kid", []*Key{a, edKey(t, "a")}, "a", `duplicate kid "a"`},
+		{"unknown signing kid", []*Key{a, b}, "c", `signing kid "c" not found`},
+		{"empty signing kid", []*Key{a}, "", `signing kid "" not found`},
+		{"public signing key", []*Key{a, publicOnly(b)}, "b", `signing kid "b" has no private key`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeySet(tt.all, tt.kid, defaultIssuer, defaultAudience)
+			if err == nil || err.Error() != tt.msg {
+				t.Fatalf("err = %v, want %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKey := rsaKey(t, "old"), edKey(t, "new")
+
+	useKeySet(t, mustKeySet(t, []*Key{oldKey}, "old"))
+	before := mustToken(t, 1)
+
+	// 第一步：加新私钥并切换签名kid，旧token照常能用
+	SetKeySet(mustKeySet(t, []*Key{oldKey, newKey}, "new"))
+	after := mustToken(t, 2)
+	for _, tok := range []string{before, after} {
+		if _, err := ParseToken(tok); err != nil {
+			t.Fatalf("after switching kid: %v", err)
+		}
+	}
+
+	// 第二步：旧私钥换成公钥，仍然能验签
+	SetKeySet(mustKeySet(t, []*Key{publicOnly(oldKey), newKey}, "new"))
+	if _, err := ParseToken(before); err != nil {
+		t.Fatalf("old token with public key only: %v", err)
+	}
+	if set := JWKS(); len(set.Keys) != 2 || set.Keys[0].Kid != "new" || set.Keys[1].Kid != "old" {
+		t.Fatalf("JWKS = %+v", set)
+	}
+
+	// 第三步：删掉旧密钥后旧token失效，新token不受影响
+	SetKeySet(mustKeySet(t, []*Key{newKey}, "new"))
+	if _, err := ParseToken(before); err == nil {
+		t.Fatal("token signed by a removed key still accepted")
+	}
+	if _, err := ParseToken(after); err != nil {
+		t.Fatalf("new token after removing old key: %v", err)
+	}
+}
+
+func TestRejectsForgedHeaders(t *testing.T) {
+	rsaA, edB := rsaKey(t, "a"), edKey(t, "b")
+	useKeySet(t, mustKeySet(t, []*Key{rsaA, edB}, "a"))
+	claims := &Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    defaultIssuer,
+		Audience:  jwt.ClaimStrings{defaultAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		tok := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name string
+		tok  string
+	}{
+		// 配置了非对称密钥后，用开发secret或拿公钥当HMAC secret签的token都不接受
+		{"hs256 with the dev secret", sign(jwt.SigningMethodHS256, "", devSecret)},
+		{"hs256 with a kid", sign(jwt.SigningMethodHS256, "a", devSecret)},
+		{"hs256 keyed with the public key", sign(jwt.SigningMethodHS256, "a", x509.MarshalPKCS1PublicKey(&rsaA.Private.(*rsa.PrivateKey).PublicKey))},
+		{"missing kid", sign(jwt.SigningMethodRS256, "", rsaA.Private)},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "c", rsaA.Private)},
+		// kid b是Ed25519，不能拿RS256签的token冒充
+		{"alg does not match kid", sign(jwt.SigningMethodRS256, "b", rsaA.Private)},
+		{"none", sign(jwt.SigningMethodNone, "a", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseToken(tt.tok); err == nil {
+				t.Fatal("forged token accepted")
+			}
+		})
+	}
+	if _, err := ParseToken(sign(jwt.SigningMethodRS256, "a", rsaA.Private)); err != nil {
+		t.Fatalf("well-formed token rejected: %v", err)
+	}
+}
+
+func TestIssuerAndAudience(t *testing.T) {
+	k := edKey(t, "k")
+	newSet := func(iss, aud string) *KeySet {
+		ks, err := NewKeySet([]*Key{k}, "k", iss, aud)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ks
+	}
+	tests := []struct {
+		name          string
+		signer, check *KeySet
+		ok            bool
+	}{
+		{"same iss and aud", newSet("tasker", "api"), newSet("tasker", "api"), true},
+		{"other issuer", newSet("evil", "api"), newSet("tasker", "api"), false},
+		{"other audience", newSet("tasker", "admin"), newSet("tasker", "api"), false},
+		{"hs256 other issuer", newHMACKeySet(devSecret, "evil", "api"), newHMACKeySet(devSecret, "tasker", "api"), false},
+		{"hs256 other audience", newHMACKeySet(devSecret, "tasker", "admin"), newHMACKeySet(devSecret, "tasker", "api"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKeySet(t, tt.signer)
+			tok := mustToken(t, 1)
+			SetKeySet(tt.check)
+			_, err := ParseToken(tok)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseToken err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTokenPurpose(t *testing.T) {
+	useKeySet(t, mustKeySet(t, []*Key{edKey(t, "k")}, "k"))
+	mfa, err := GenerateMFAToken(3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(mfa); err == nil {
+		t.Fatal("mfa challenge accepted as a login token")
+	}
+	if c, err := ParseMFAToken(mfa); err != nil || c.UserID != 3 {
+		t.Fatalf("ParseMFAToken = %+v, %v", c, err)
+	}
+	if _, err := ParseMFAToken(mustToken(t, 3)); err == nil {
+		t.Fatal("login token accepted as an mfa challenge")
+	}
+}
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	prev := keys
+	t.Cleanup(func() { SetKeySet(prev) })
+	for _, k := range []string{"TASKER_JWT_KEY_DIR", "TASKER_JWT_SECRET", "TASKER_JWT_DEV_SECRET", "TASKER_JWT_SIGNING_KID", "TASKER_JWT_ISSUER", "TASKER_JWT_AUDIENCE"} {
+		t.Setenv(k, "")
+	}
+
+	// 什么都没配置时拒绝启动，不悄悄用写死的secret
+	SetKeySet(mustKeySet(t, []*Key{edKey(t, "k")}, "k"))
+	if err := LoadFromEnv(); err == nil || !strings.Contains(err.Error(), "TASKER_JWT_DEV_SECRET") {
+		t.Fatalf("LoadFromEnv without keys = %v", err)
+	}
+
+	t.Setenv("TASKER_JWT_DEV_SECRET", "1")
+	if err := LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv with dev secret: %v", err)
+	}
+	if string(keys.hmacSecret) != string(devSecret) {
+		t.Fatal("dev flag did not select the development secret")
+	}
+
+	// 配置了secret就不用开发secret
+	t.Setenv("TASKER_JWT_SECRET", "s3cret")
+	t.Setenv("TASKER_JWT_ISSUER", "tasker.example")
+	if err := LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv with secret: %v", err)
+	}
+	if string(keys.hmacSecret) != "s3cret" || keys.issuer != "tasker.example" || keys.audience != defaultAudience {
+		t.Fatalf("keys = %+v", keys)
+	}
+
+	// 目录里一把Ed25519私钥（PKCS#8）加一把RSA公钥（PKIX）
+	dir := t.TempDir()
+	signer, verifier := edKey(t, "ignored"), rsaKey(t, "ignored")
+	der, err := x509.MarshalPKCS8PrivateKey(signer.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2026-04.pem", "PRIVATE KEY", der)
+	der, err = x509.MarshalPKIXPublicKey(verifier.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2026-01.pem", "PUBLIC KEY", der)
+	t.Setenv("TASKER_JWT_KEY_DIR", dir)
+
+	t.Setenv("TASKER_JWT_SIGNING_KID", "2026-01")
+	if err := LoadFromEnv(); err == nil || !strings.Contains(err.Error(), "no private key") {
+		t.Fatalf("signing with a public key = %v", err)
+	}
+	t.Setenv("TASKER_JWT_SIGNING_KID", "2026-04")
+	if err := LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv with key dir: %v", err)
+	}
+	if keys.signing.ID != "2026-04" || keys.signing.Method != jwt.SigningMethodEdDSA || len(keys.verify) != 2 || keys.hmacSecret != nil {
+		t.Fatalf("keys = %+v", keys)
+	}
+	if h := header(t, mustToken(t, 1)); h["kid"] != "2026-04" {
+		t.Fatalf("header = %v", h)
+	}
+
+	t.Setenv("TASKER_JWT_KEY_DIR", t.TempDir())
+	if err := LoadFromEnv(); err == nil || !strings.Contains(err.Error(), "no .pem keys") {
+		t.Fatalf("empty key dir = %v", err)
+	}
+}
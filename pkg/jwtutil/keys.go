@@ -0,0 +1,227 @@
+package jwtutil
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+/*
+密钥轮换约定：
+  - TASKER_JWT_KEY_DIR 目录下每个 <kid>.pem 是一把密钥，文件名就是 kid
+  - 私钥（RSA / Ed25519，PKCS#1 或 PKCS#8）既能签名也能验签
+  - 公钥（PKIX）只用于验签，轮换后把旧私钥换成公钥，等旧token过期再删除
+  - TASKER_JWT_SIGNING_KID 指定用哪把私钥签名
+  - TASKER_JWT_ISSUER / TASKER_JWT_AUDIENCE 可覆盖默认的 iss / aud
+  - 不配置目录时用 TASKER_JWT_SECRET 做HS256；两个都没有时拒绝启动，
+    本地开发显式设置 TASKER_JWT_DEV_SECRET=1 才用写死的开发secret
+*/
+
+// Key 一把签名/验签密钥
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// 私钥，只用于验签的旧密钥为nil
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet 一把签名密钥 + 若干验签密钥
+type KeySet struct {
+	signing  *Key
+	verify   map[string]*Key
+	issuer   string
+	audience string
+
+	// 未配置非对称密钥时退回HS256
+	hmacSecret []byte
+}
+
+func newHMACKeySet(secret []byte, issuer, audience string) *KeySet {
+	return &KeySet{
+		verify:     map[string]*Key{},
+		issuer:     issuer,
+		audience:   audience,
+		hmacSecret: secret,
+	}
+}
+
+// NewKeySet 用给定的密钥构造KeySet，signingKID必须是其中一把私钥
+func NewKeySet(all []*Key, signingKID, issuer, audience string) (*KeySet, error) {
+	ks := &KeySet{verify: map[string]*Key{}, issuer: issuer, audience: audience}
+	for _, k := range all {
+		if _, dup := ks.verify[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate kid %q", k.ID)
+		}
+		ks.verify[k.ID] = k
+	}
+
+	k, ok := ks.verify[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("signing kid %q not found", signingKID)
+	}
+	if k.Private == nil {
+		return nil, fmt.Errorf("signing kid %q has no private key", signingKID)
+	}
+	ks.signing = k
+	return ks, nil
+}
+
+// SetKeySet 替换全局密钥集合
+func SetKeySet(ks *KeySet) {
+	keys = ks
+}
+
+// LoadFromEnv 按环境变量加载密钥；没有配置目录时用HS256
+func LoadFromEnv() error {
+	issuer := envOr("TASKER_JWT_ISSUER", defaultIssuer)
+	audience := envOr("TASKER_JWT_AUDIENCE", defaultAudience)
+
+	dir := os.Getenv("TASKER_JWT_KEY_DIR")
+	if dir == "" {
+		secret := []byte(os.Getenv("TASKER_JWT_SECRET"))
+		if len(secret) == 0 {
+			if os.Getenv("TASKER_JWT_DEV_SECRET") != "1" {
+				return errors.New("neither TASKER_JWT_KEY_DIR nor TASKER_JWT_SECRET is set (set TASKER_JWT_DEV_SECRET=1 to use the built-in development secret)")
+			}
+			log.Printf("[jwt] WARNING: signing tokens with the built-in development secret, anyone can forge them; never set TASKER_JWT_DEV_SECRET in production")
+			secret = devSecret
+		}
+		SetKeySet(newHMACKeySet(secret, issuer, audience))
+		return nil
+	}
+
+	all, err := LoadKeyDir(dir)
+	if err != nil {
+		return err
+	}
+	ks, err := NewKeySet(all, os.Getenv("TASKER_JWT_SIGNING_KID"), issuer, audience)
+	if err != nil {
+		return err
+	}
+	SetKeySet(ks)
+	return nil
+}
+
+// LoadKeyDir 读取目录下所有 .pem 文件，文件名作为kid
+func LoadKeyDir(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem keys found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	all := make([]*Key, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(p), ".pem")
+		k, err := ParseKeyPEM(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		all = append(all, k)
+	}
+	return all, nil
+}
+
+// ParseKeyPEM 解析RSA或Ed25519的私钥/公钥
+func ParseKeyPEM(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Public: k}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", parsed)
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	if ks.signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.hmacSecret)
+	}
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.Private)
+}
+
+// 允许的算法：配置了非对称密钥后不再接受HS256，防止算法混淆
+func (ks *KeySet) methods() []string {
+	if ks.signing == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	seen := map[string]bool{}
+	out := []string{}
+	for _, k := range ks.verify {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			out = append(out, alg)
+		}
+	}
+	return out
+}
+
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	if ks.signing == nil {
+		return ks.hmacSecret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	k, ok := ks.verify[kid]
+	if !ok {
+		return nil, errors.New("unknown kid")
+	}
+	// kid 对应的算法必须和token头一致
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.Public, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 发信抽象，生产环境接SMTP或第三方服务，开发环境用LogMailer
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer 不真正发信，只把内容打到日志里，方便本地开发拿到重置链接
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("[mailer] to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
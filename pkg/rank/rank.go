@@ -0,0 +1,88 @@
+package rank
+
+/*
+分数索引（fractional indexing）：用可以按字节比较的字符串表示顺序，
+在两个key之间插入只需要生成一个新key，不用改动其他行。
+字符按ASCII排列，数据库里需要用 COLLATE "C" 排序；key不以'0'结尾，保证任意两个key之间总有空位
+*/
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalid = errors.New("rank: invalid key")
+
+// Between 返回严格位于a和b之间的key，a为空表示最前，b为空表示最后
+func Between(a, b string) (string, error) {
+	if !valid(a) || !valid(b) {
+		return "", ErrInvalid
+	}
+	if a != "" && b != "" && a >= b {
+		return "", ErrInvalid
+	}
+	return midpoint(a, b), nil
+}
+
+func valid(key string) bool {
+	if key == "" {
+		return true
+	}
+	if key[len(key)-1] == digits[0] {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// midpoint 要求 a < b（b为空表示无穷大）
+func midpoint(a, b string) string {
+	if b != "" {
+		// 跳过公共前缀，a比b短的部分按'0'补齐
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	lo := 0
+	if a != "" {
+		lo = strings.IndexByte(digits, a[0])
+	}
+	hi := len(digits)
+	if b != "" {
+		hi = strings.IndexByte(digits, b[0])
+	}
+	if hi-lo > 1 {
+		return string(digits[(lo+hi+1)/2])
+	}
+	// 首位相邻：b更长时取b的首位即可，否则在a的首位后面继续找
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[lo]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
@@ -0,0 +1,147 @@
+package rank
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"empty list", "", ""},
+		{"before the first", "", "V"},
+		{"after the last", "V", ""},
+		{"wide gap", "1", "z"},
+		{"adjacent digits", "A", "B"},
+		{"adjacent digits, b longer", "A", "B5"},
+		{"common prefix", "V1", "V3"},
+		{"a is a prefix of b", "V", "V1"},
+		{"a is a prefix of b with zeros", "V", "V01"},
+		{"b is the smallest key", "", "1"},
+		{"b is a long smallest key", "", "0001"},
+		{"a is the largest digit", "z", ""},
+		{"a is a run of the largest digit", "zzz", ""},
+		{"largest digit before b", "Az", "B"},
+		{"deep common prefix", "ABCDEFGy", "ABCDEFGz"},
+		{"b just above a", "Vz", "Vz1"},
+		{"mixed lengths", "0V", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Between(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Between(%q, %q): %v", tt.a, tt.b, err)
+			}
+			assertBetween(t, tt.a, got, tt.b)
+		})
+	}
+}
+
+func TestBetweenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"equal keys", "V", "V"},
+		{"reversed keys", "W", "V"},
+		{"a ends with zero", "V0", ""},
+		{"b ends with zero", "", "V0"},
+		{"b is zero", "", "0"},
+		{"character outside the alphabet", "V-", ""},
+		{"non-ascii", "", "汉"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Between(tt.a, tt.b); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Between(%q, %q) = %q, %v, want ErrInvalid", tt.a, tt.b, got, err)
+			}
+		})
+	}
+}
+
+// 随机位置反复插入：每个新key都严格落在邻居之间，整个列表始终有序
+func TestRandomInserts(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	keys := []string{}
+	for i := 0; i < 5000; i++ {
+		pos := r.IntN(len(keys) + 1)
+		var a, b string
+		if pos > 0 {
+			a = keys[pos-1]
+		}
+		if pos < len(keys) {
+			b = keys[pos]
+		}
+		k, err := Between(a, b)
+		if err != nil {
+			t.Fatalf("insert %d: Between(%q, %q): %v", i, a, b, err)
+		}
+		assertBetween(t, a, k, b)
+		keys = slices.Insert(keys, pos, k)
+	}
+	if !slices.IsSorted(keys) {
+		t.Fatal("keys are not sorted")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] == keys[i] {
+			t.Fatalf("duplicate key %q", keys[i])
+		}
+	}
+}
+
+// 总在同一端或同一个位置插入时key线性变长，每个字符大约能放5到6次插入；
+// 看板在key超过rebalanceAt后重新编号
+func TestRepeatedInserts(t *testing.T) {
+	tests := []struct {
+		name   string
+		next   func(first, last, prev string) (string, string)
+		maxLen int
+	}{
+		{"append", func(first, last, prev string) (string, string) { return last, "" }, 201},
+		{"prepend", func(first, last, prev string) (string, string) { return "", first }, 170},
+		// 一直插在第一个key和上一次插入的key之间
+		{"after the same key", func(first, last, prev string) (string, string) { return first, prev }, 170},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, _ := Between("", "")
+			last, prev := first, ""
+			for i := 0; i < 1000; i++ {
+				a, b := tt.next(first, last, prev)
+				k, err := Between(a, b)
+				if err != nil {
+					t.Fatalf("insert %d: Between(%q, %q): %v", i, a, b, err)
+				}
+				assertBetween(t, a, k, b)
+				if len(k) > tt.maxLen {
+					t.Fatalf("insert %d: key has %d bytes, want at most %d", i, len(k), tt.maxLen)
+				}
+				if k < first {
+					first = k
+				}
+				if k > last {
+					last = k
+				}
+				prev = k
+			}
+		})
+	}
+}
+
+func assertBetween(t *testing.T, a, k, b string) {
+	t.Helper()
+	if k == "" || strings.HasSuffix(k, "0") {
+		t.Fatalf("Between(%q, %q) = %q, a key must not be empty or end in '0'", a, b, k)
+	}
+	if !valid(k) {
+		t.Fatalf("Between(%q, %q) = %q is not a valid key", a, b, k)
+	}
+	if (a != "" && k <= a) || (b != "" && k >= b) {
+		t.Fatalf("Between(%q, %q) = %q is not strictly between", a, b, k)
+	}
+}
@@ -10,7 +10,8 @@ function mapErrorMessage(code?: string, fallback?: string) {
     case "INVALID_USERNAME":
       return "用户名不能为空";
     case "INVALID_PASSWORD":
-      return "密码至少 6 位";
+      // 密码规则在后端配置，直接展示后端给出的原因
+      return fallback || "密码不符合要求";
     default:
       return fallback || "注册失败，请重试";
   }
@@ -30,7 +31,7 @@ export default function RegisterPage() {
 
   const canSubmit = useMemo(() => {
     if (username.trim().length === 0) return false;
-    if (password.length < 6) return false;
+    if (password.length < 8) return false;
     if (password !== confirm) return false;
     return true;
   }, [username, password, confirm]);
@@ -77,7 +78,7 @@ export default function RegisterPage() {
 
     if (!canSubmit) {
       if (password !== confirm) setError("两次密码不一致");
-      else setError("用户名不能为空，密码至少 6 位");
+      else setError("用户名不能为空，密码至少 8 位");
       return;
     }
 
@@ -131,7 +132,7 @@ export default function RegisterPage() {
               type="password"
               value={password}
               onChange={(e) => setPassword(e.target.value)}
-              placeholder="至少 6 位"
+              placeholder="至少 8 位，包含两类字符"
               autoComplete="new-password"
             />
           </div>